@@ -25,6 +25,7 @@ func main() {
 	// Add subcommands
 	rootCmd.AddCommand(cli.NewNewCommand())
 	rootCmd.AddCommand(cli.NewAddCommand())
+	rootCmd.AddCommand(cli.NewRemoveCommand())
 	rootCmd.AddCommand(cli.NewListCommand())
 	rootCmd.AddCommand(cli.NewBuildCommand())
 	rootCmd.AddCommand(cli.NewTestCommand())
@@ -33,9 +34,16 @@ func main() {
 	rootCmd.AddCommand(cli.NewDebugCommand())
 	rootCmd.AddCommand(cli.NewCleanCommand())
 	rootCmd.AddCommand(cli.NewPackagesCommand())
+	rootCmd.AddCommand(cli.NewPatchCommand())
+	rootCmd.AddCommand(cli.NewDevCommand())
+	rootCmd.AddCommand(cli.NewRebuildCommand())
+	rootCmd.AddCommand(cli.NewFetchCommand())
+	rootCmd.AddCommand(cli.NewFeaturesCommand())
 	rootCmd.AddCommand(cli.NewCICDCommand())
 	rootCmd.AddCommand(cli.NewVersionCommand())
 	rootCmd.AddCommand(cli.NewDoctorCommand())
+	rootCmd.AddCommand(cli.NewSecretsCommand())
+	rootCmd.AddCommand(cli.NewConfigCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -0,0 +1,82 @@
+package apps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Build systems forge knows how to package
+const (
+	BuildGo    = "go"
+	BuildCMake = "cmake"
+	BuildMeson = "meson"
+	BuildMake  = "make"
+)
+
+// buildFiles maps the file that identifies a build system to it, in the
+// order they are looked for. A Go module with a Makefile is built as Go.
+var buildFiles = []struct {
+	file  string
+	build string
+}{
+	{"go.mod", BuildGo},
+	{"CMakeLists.txt", BuildCMake},
+	{"meson.build", BuildMeson},
+	{"Makefile", BuildMake},
+}
+
+// goArchitectures maps forge architectures to GOARCH and GOARM
+var goArchitectures = map[string][2]string{
+	"x86_64":  {"amd64", ""},
+	"i386":    {"386", ""},
+	"arm":     {"arm", "5"},
+	"armv5":   {"arm", "5"},
+	"armv7":   {"arm", "7"},
+	"aarch64": {"arm64", ""},
+	"riscv64": {"riscv64", ""},
+	"mips":    {"mips", ""},
+}
+
+// invalidNameChars matches what cannot appear in a Buildroot package name
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Detect returns the build system of the sources in dir
+func Detect(dir string) (string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	for _, candidate := range buildFiles {
+		if _, err := os.Stat(filepath.Join(dir, candidate.file)); err == nil {
+			return candidate.build, nil
+		}
+	}
+	return "", fmt.Errorf("cannot detect the build system of %s (looked for go.mod, CMakeLists.txt, meson.build and Makefile)", dir)
+}
+
+// DefaultName derives a package name from the directory holding an app's
+// sources, such as sensor-daemon for ./src/Sensor_Daemon
+func DefaultName(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(filepath.Base(dir)), "-")
+	return strings.Trim(name, "-")
+}
+
+// GoArch returns the GOARCH and GOARM to cross-compile Go code for a forge
+// architecture. GOARM is empty for architectures other than 32-bit ARM.
+func GoArch(architecture string) (goarch, goarm string, err error) {
+	target, ok := goArchitectures[architecture]
+	if !ok {
+		return "", "", fmt.Errorf("cannot cross-compile Go for architecture %s", architecture)
+	}
+	return target[0], target[1], nil
+}
@@ -0,0 +1,82 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type AppsTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestAppsTestSuite(t *testing.T) {
+	suite.Run(t, new(AppsTestSuite))
+}
+
+func (s *AppsTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *AppsTestSuite) writeFile(path, content string) {
+	path = filepath.Join(s.tempDir, path)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+}
+
+func (s *AppsTestSuite) TestDetect() {
+	s.writeFile("gateway/go.mod", "module gateway\n")
+	s.writeFile("gateway/Makefile", "all:\n")
+	s.writeFile("sensord/CMakeLists.txt", "project(sensord C)\n")
+	s.writeFile("logger/meson.build", "project('logger', 'c')\n")
+	s.writeFile("blink/Makefile", "all:\n")
+	s.writeFile("docs/README", "")
+
+	for dir, build := range map[string]string{
+		"gateway": BuildGo,
+		"sensord": BuildCMake,
+		"logger":  BuildMeson,
+		"blink":   BuildMake,
+	} {
+		detected, err := Detect(filepath.Join(s.tempDir, dir))
+		s.NoError(err, dir)
+		s.Equal(build, detected, dir)
+	}
+
+	_, err := Detect(filepath.Join(s.tempDir, "docs"))
+	s.Error(err)
+	s.Contains(err.Error(), "cannot detect the build system")
+
+	_, err = Detect(filepath.Join(s.tempDir, "docs", "README"))
+	s.Error(err)
+	s.Contains(err.Error(), "is not a directory")
+}
+
+func (s *AppsTestSuite) TestDefaultName() {
+	s.Equal("sensord", DefaultName("./src/sensord/"))
+	s.Equal("sensor-daemon", DefaultName("src/Sensor_Daemon"))
+}
+
+func (s *AppsTestSuite) TestGoArch() {
+	for _, arch := range config.ValidArchitectures {
+		_, _, err := GoArch(arch)
+		s.NoError(err, arch)
+	}
+
+	goarch, goarm, err := GoArch("armv7")
+	s.NoError(err)
+	s.Equal("arm", goarch)
+	s.Equal("7", goarm)
+
+	goarch, goarm, err = GoArch("aarch64")
+	s.NoError(err)
+	s.Equal("arm64", goarch)
+	s.Empty(goarm)
+
+	_, _, err = GoArch("sparc")
+	s.Error(err)
+}
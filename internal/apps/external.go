@@ -0,0 +1,285 @@
+package apps
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/sst/forge/internal/config"
+)
+
+// ExternalDir is the BR2_EXTERNAL tree inside a project
+const ExternalDir = "external"
+
+// ExternalName is the name of the BR2_EXTERNAL tree, which Buildroot turns
+// into the BR2_EXTERNAL_FORGE_PATH variable
+const ExternalName = "FORGE"
+
+// installTarget finds an install rule in a Makefile
+var installTarget = regexp.MustCompile(`(?m)^install\s*:`)
+
+// moduleDirective finds the module path in a go.mod file
+var moduleDirective = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+
+// app is the data the package templates are rendered with
+type app struct {
+	config.AppConfig
+	Var     string // Upper-case make variable prefix
+	Symbol  string // Kconfig symbol
+	Site    string // Source directory as seen from the package .mk
+	Module  string // Go module path from go.mod
+	Main    string // Go package to build, relative to the module
+	Install bool   // The Makefile has an install target
+}
+
+var externalDesc = template.Must(template.New("external.desc").Parse(`name: ` + ExternalName + `
+desc: Applications of {{.Name}}
+`))
+
+var externalConfig = template.Must(template.New("Config.in").Parse(`# Generated by forge from the apps in forge.yml
+{{range .Apps}}source "$BR2_EXTERNAL_FORGE_PATH/package/{{.Name}}/Config.in"
+{{end}}`))
+
+var externalMakefile = template.Must(template.New("external.mk").Parse(`# Generated by forge from the apps in forge.yml
+{{range .Apps}}
+include $(BR2_EXTERNAL_FORGE_PATH)/package/{{.Name}}/{{.Name}}.mk{{end}}
+`))
+
+var packageConfig = template.Must(template.New("Config.in").Parse(`config {{.Symbol}}
+	bool "{{.Name}}"
+{{- if eq .Build "go"}}
+	depends on BR2_PACKAGE_HOST_GO_TARGET_ARCH_SUPPORTS
+{{- end}}
+	help
+	  In-house application built from {{.Path}}.
+`))
+
+var packageMakefile = template.Must(template.New("package.mk").Parse(`################################################################################
+#
+# {{.Name}}
+#
+################################################################################
+
+{{.Var}}_VERSION = local
+{{.Var}}_SITE = {{.Site}}
+{{.Var}}_SITE_METHOD = local
+{{- if eq .Build "go"}}
+{{.Var}}_GOMOD = {{.Module}}
+{{- if ne .Main "."}}
+{{.Var}}_BUILD_TARGETS = {{.Main}}
+{{- end}}
+{{- else if eq .Build "make"}}
+
+define {{.Var}}_BUILD_CMDS
+	$(TARGET_MAKE_ENV) $(MAKE) $(TARGET_CONFIGURE_OPTS) -C $(@D)
+endef
+
+define {{.Var}}_INSTALL_TARGET_CMDS
+{{- if .Install}}
+	$(TARGET_MAKE_ENV) $(MAKE) $(TARGET_CONFIGURE_OPTS) -C $(@D) DESTDIR=$(TARGET_DIR) PREFIX=/usr install
+{{- else}}
+	$(INSTALL) -D -m 0755 $(@D)/{{.Name}} $(TARGET_DIR)/usr/bin/{{.Name}}
+{{- end}}
+endef
+{{- end}}
+{{- if .Service}}
+
+define {{.Var}}_INSTALL_INIT_SYSV
+	$(INSTALL) -D -m 0755 $({{.Var}}_PKGDIR)/S90{{.Name}} $(TARGET_DIR)/etc/init.d/S90{{.Name}}
+endef
+
+define {{.Var}}_INSTALL_INIT_SYSTEMD
+	$(INSTALL) -D -m 0644 $({{.Var}}_PKGDIR)/{{.Name}}.service $(TARGET_DIR)/usr/lib/systemd/system/{{.Name}}.service
+	mkdir -p $(TARGET_DIR)/etc/systemd/system/multi-user.target.wants
+	ln -sf ../../../../usr/lib/systemd/system/{{.Name}}.service \
+		$(TARGET_DIR)/etc/systemd/system/multi-user.target.wants/{{.Name}}.service
+endef
+{{- end}}
+
+{{if eq .Build "go"}}$(eval $(golang-package)){{else if eq .Build "cmake"}}$(eval $(cmake-package)){{else if eq .Build "meson"}}$(eval $(meson-package)){{else}}$(eval $(generic-package)){{end}}
+`))
+
+var initScript = template.Must(template.New("S90").Parse(`#!/bin/sh
+#
+# Starts {{.Name}}
+#
+
+DAEMON="{{.Name}}"
+PIDFILE="/var/run/$DAEMON.pid"
+
+start() {
+	printf 'Starting %s: ' "$DAEMON"
+	start-stop-daemon -S -q -b -m -p "$PIDFILE" -x "/usr/bin/$DAEMON"
+	status=$?
+	if [ "$status" -eq 0 ]; then
+		echo "OK"
+	else
+		echo "FAIL"
+	fi
+	return "$status"
+}
+
+stop() {
+	printf 'Stopping %s: ' "$DAEMON"
+	start-stop-daemon -K -q -p "$PIDFILE"
+	status=$?
+	if [ "$status" -eq 0 ]; then
+		rm -f "$PIDFILE"
+		echo "OK"
+	else
+		echo "FAIL"
+	fi
+	return "$status"
+}
+
+case "$1" in
+	start|stop)
+		"$1";;
+	restart|reload)
+		stop
+		sleep 1
+		start;;
+	*)
+		echo "Usage: $0 {start|stop|restart|reload}"
+		exit 1
+esac
+`))
+
+var serviceUnit = template.Must(template.New("service").Parse(`[Unit]
+Description={{.Name}}
+After=network.target
+
+[Service]
+ExecStart=/usr/bin/{{.Name}}
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// WriteExternal writes the BR2_EXTERNAL tree for the apps in forge.yml into
+// the project and returns the files it created. The top-level files follow
+// forge.yml and are rewritten every time. An app's package directory is only
+// written when it does not exist, so edits to it are kept; delete it to have
+// it generated again.
+func WriteExternal(cfg *config.Config, projectDir string) ([]string, error) {
+	externalDir := filepath.Join(projectDir, ExternalDir)
+	var apps []app
+	for _, appConfig := range cfg.Apps {
+		if appConfig.Build == BuildGo {
+			if _, _, err := GoArch(cfg.Architecture); err != nil {
+				return nil, err
+			}
+		}
+		apps = append(apps, newApp(appConfig, projectDir))
+	}
+
+	var created []string
+	write := func(file string, tmpl *template.Template, data interface{}, mode os.FileMode) error {
+		var content bytes.Buffer
+		if err := tmpl.Execute(&content, data); err != nil {
+			return fmt.Errorf("failed to render %s: %v", file, err)
+		}
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			created = append(created, file)
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", filepath.Dir(file), err)
+		}
+		if err := os.WriteFile(file, content.Bytes(), mode); err != nil {
+			return fmt.Errorf("failed to write %s: %v", file, err)
+		}
+		return nil
+	}
+
+	top := struct {
+		Name string
+		Apps []app
+	}{cfg.Name, apps}
+	if err := write(filepath.Join(externalDir, "external.desc"), externalDesc, top, 0644); err != nil {
+		return nil, err
+	}
+	if err := write(filepath.Join(externalDir, "Config.in"), externalConfig, top, 0644); err != nil {
+		return nil, err
+	}
+	if err := write(filepath.Join(externalDir, "external.mk"), externalMakefile, top, 0644); err != nil {
+		return nil, err
+	}
+
+	for _, a := range apps {
+		packageDir := filepath.Join(externalDir, "package", a.Name)
+		if _, err := os.Stat(packageDir); err == nil {
+			continue
+		}
+
+		if err := write(filepath.Join(packageDir, "Config.in"), packageConfig, a, 0644); err != nil {
+			return nil, err
+		}
+		if err := write(filepath.Join(packageDir, a.Name+".mk"), packageMakefile, a, 0644); err != nil {
+			return nil, err
+		}
+		if !a.Service {
+			continue
+		}
+		if err := write(filepath.Join(packageDir, "S90"+a.Name), initScript, a, 0755); err != nil {
+			return nil, err
+		}
+		if err := write(filepath.Join(packageDir, a.Name+".service"), serviceUnit, a, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return created, nil
+}
+
+// newApp works out the package variables of an app. Relative source paths
+// are relative to the project, which is the parent of the external tree.
+func newApp(appConfig config.AppConfig, projectDir string) app {
+	a := app{
+		AppConfig: appConfig,
+		Var:       strings.ToUpper(strings.ReplaceAll(appConfig.Name, "-", "_")),
+		Symbol:    config.PackageSymbol(appConfig.Name),
+		Main:      ".",
+	}
+
+	if filepath.IsAbs(appConfig.Path) {
+		a.Site = filepath.ToSlash(appConfig.Path)
+	} else {
+		a.Site = "$(BR2_EXTERNAL_FORGE_PATH)/../" + path.Clean(filepath.ToSlash(appConfig.Path))
+	}
+	sourceDir := SourceDir(appConfig, projectDir)
+
+	switch appConfig.Build {
+	case BuildGo:
+		a.Module = appConfig.Name
+		if data, err := os.ReadFile(filepath.Join(sourceDir, "go.mod")); err == nil {
+			if match := moduleDirective.FindSubmatch(data); match != nil {
+				a.Module = string(match[1])
+			}
+		}
+		// Go projects often keep their main package in cmd/<name>
+		if info, err := os.Stat(filepath.Join(sourceDir, "cmd", appConfig.Name)); err == nil && info.IsDir() {
+			a.Main = "cmd/" + appConfig.Name
+		}
+	case BuildMake:
+		if data, err := os.ReadFile(filepath.Join(sourceDir, "Makefile")); err == nil {
+			a.Install = installTarget.Match(data)
+		}
+	}
+
+	return a
+}
+
+// SourceDir returns the directory holding an app's sources. Relative paths
+// in forge.yml are relative to the project.
+func SourceDir(appConfig config.AppConfig, projectDir string) string {
+	if filepath.IsAbs(appConfig.Path) {
+		return appConfig.Path
+	}
+	return filepath.Join(projectDir, appConfig.Path)
+}
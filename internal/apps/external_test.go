@@ -0,0 +1,136 @@
+package apps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalTestSuite struct {
+	suite.Suite
+	tempDir string
+	config  *config.Config
+}
+
+func TestExternalTestSuite(t *testing.T) {
+	suite.Run(t, new(ExternalTestSuite))
+}
+
+func (s *ExternalTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.config = &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "gateway-os",
+		Version:       "1.0.0",
+		Architecture:  "armv7",
+		Template:      "minimal",
+	}
+}
+
+func (s *ExternalTestSuite) read(path string) string {
+	data, err := os.ReadFile(filepath.Join(s.tempDir, ExternalDir, path))
+	s.Require().NoError(err)
+	return string(data)
+}
+
+func (s *ExternalTestSuite) TestGoApp() {
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "src", "sensord", "cmd", "sensord"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, "src", "sensord", "go.mod"), []byte("module example.com/sensord\n\ngo 1.21\n"), 0644))
+	s.config.Apps = []config.AppConfig{{Name: "sensord", Path: "src/sensord", Build: BuildGo, Service: true}}
+
+	created, err := WriteExternal(s.config, s.tempDir)
+	s.Require().NoError(err)
+	s.Len(created, 7)
+
+	s.Contains(s.read("external.desc"), "name: FORGE")
+	s.Contains(s.read("Config.in"), `source "$BR2_EXTERNAL_FORGE_PATH/package/sensord/Config.in"`)
+
+	s.Contains(s.read("external.mk"), "include $(BR2_EXTERNAL_FORGE_PATH)/package/sensord/sensord.mk")
+
+	s.Contains(s.read("package/sensord/Config.in"), "config BR2_PACKAGE_SENSORD\n")
+
+	makefile := s.read("package/sensord/sensord.mk")
+	s.Contains(makefile, "SENSORD_SITE = $(BR2_EXTERNAL_FORGE_PATH)/../src/sensord\n")
+	s.Contains(makefile, "SENSORD_GOMOD = example.com/sensord\n")
+	s.Contains(makefile, "SENSORD_BUILD_TARGETS = cmd/sensord\n")
+	s.NotContains(makefile, "BUILD_CMDS")
+	s.Contains(makefile, "define SENSORD_INSTALL_INIT_SYSV")
+	s.Contains(makefile, "define SENSORD_INSTALL_INIT_SYSTEMD")
+	s.Contains(makefile, "$(eval $(golang-package))")
+
+	s.Contains(s.read("package/sensord/S90sensord"), `DAEMON="sensord"`)
+	s.Contains(s.read("package/sensord/sensord.service"), "ExecStart=/usr/bin/sensord")
+	info, err := os.Stat(filepath.Join(s.tempDir, ExternalDir, "package", "sensord", "S90sensord"))
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0755), info.Mode().Perm())
+}
+
+func (s *ExternalTestSuite) TestBuildSystems() {
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.tempDir, "blink"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.tempDir, "blink", "Makefile"), []byte("all:\n\ninstall: all\n"), 0644))
+	s.config.Architecture = "aarch64"
+	s.config.Apps = []config.AppConfig{
+		{Name: "logger", Path: "logger", Build: BuildCMake},
+		{Name: "meter", Path: "/opt/src/meter", Build: BuildMeson},
+		{Name: "blink", Path: "blink", Build: BuildMake},
+	}
+
+	_, err := WriteExternal(s.config, s.tempDir)
+	s.Require().NoError(err)
+
+	logger := s.read("package/logger/logger.mk")
+	s.Contains(logger, "$(eval $(cmake-package))")
+	s.NotContains(logger, "INSTALL_INIT")
+	s.NoFileExists(filepath.Join(s.tempDir, ExternalDir, "package", "logger", "S90logger"))
+
+	meter := s.read("package/meter/meter.mk")
+	s.Contains(meter, "METER_SITE = /opt/src/meter\n")
+	s.Contains(meter, "$(eval $(meson-package))")
+
+	blink := s.read("package/blink/blink.mk")
+	s.Contains(blink, "$(MAKE) $(TARGET_CONFIGURE_OPTS) -C $(@D)\n")
+	s.Contains(blink, "DESTDIR=$(TARGET_DIR) PREFIX=/usr install")
+	s.Contains(blink, "$(eval $(generic-package))")
+}
+
+func (s *ExternalTestSuite) TestKeepsEditedPackages() {
+	s.config.Apps = []config.AppConfig{{Name: "sensord", Path: "sensord", Build: BuildCMake}}
+	_, err := WriteExternal(s.config, s.tempDir)
+	s.Require().NoError(err)
+
+	makefile := filepath.Join(s.tempDir, ExternalDir, "package", "sensord", "sensord.mk")
+	s.Require().NoError(os.WriteFile(makefile, []byte("# edited\n"), 0644))
+
+	// Adding an app rewrites the top-level files but not existing packages
+	s.config.Apps = append(s.config.Apps, config.AppConfig{Name: "blink", Path: "blink", Build: BuildMake})
+	created, err := WriteExternal(s.config, s.tempDir)
+	s.Require().NoError(err)
+	s.Equal([]string{
+		filepath.Join(s.tempDir, ExternalDir, "package", "blink", "Config.in"),
+		filepath.Join(s.tempDir, ExternalDir, "package", "blink", "blink.mk"),
+	}, created)
+
+	s.Equal("# edited\n", s.read("package/sensord/sensord.mk"))
+	s.Contains(s.read("Config.in"), "package/blink/Config.in")
+	s.Contains(s.read("package/blink/blink.mk"), "$(INSTALL) -D -m 0755 $(@D)/blink $(TARGET_DIR)/usr/bin/blink")
+}
+
+func (s *ExternalTestSuite) TestGoAppWithoutGoMod() {
+	s.config.Apps = []config.AppConfig{{Name: "sensord", Path: "sensord", Build: BuildGo}}
+
+	_, err := WriteExternal(s.config, s.tempDir)
+	s.Require().NoError(err)
+	makefile := s.read("package/sensord/sensord.mk")
+	s.Contains(makefile, "SENSORD_GOMOD = sensord\n")
+	s.NotContains(makefile, "BUILD_TARGETS")
+}
+
+func (s *ExternalTestSuite) TestUnsupportedArchitecture() {
+	s.config.Architecture = "sparc"
+	s.config.Apps = []config.AppConfig{{Name: "sensord", Path: "sensord", Build: BuildGo}}
+	_, err := WriteExternal(s.config, s.tempDir)
+	s.Error(err)
+}
@@ -0,0 +1,66 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sst/forge/internal/config"
+)
+
+// requireDirective finds a dependency in a go.mod file
+var requireDirective = regexp.MustCompile(`(?m)^require\b`)
+
+// VendorGoModules vendors the module dependencies of the Go apps in
+// forge.yml into their vendor directory with the host's go mod vendor.
+// Buildroot builds Go packages from vendored modules without network
+// access, and vendors them when it downloads a package, but apps are built
+// from the project's sources and never downloaded. Apps without
+// dependencies or with a vendor directory newer than go.mod and go.sum are
+// left alone. Offline, modules that are not vendored yet are an error.
+func VendorGoModules(ctx context.Context, cfg *config.Config, projectDir string, offline bool) error {
+	for _, appConfig := range cfg.Apps {
+		if appConfig.Build != BuildGo {
+			continue
+		}
+
+		sourceDir := SourceDir(appConfig, projectDir)
+		if !needsVendoring(sourceDir) {
+			continue
+		}
+		if offline {
+			return fmt.Errorf("the Go modules of app %s are not vendored and the build is offline (run 'go mod vendor' in %s where there is network access)", appConfig.Name, appConfig.Path)
+		}
+
+		cmd := exec.CommandContext(ctx, "go", "mod", "vendor")
+		cmd.Dir = sourceDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to vendor the Go modules of app %s: %v\n%s", appConfig.Name, err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// needsVendoring reports whether the Go module in dir has dependencies and
+// a vendor directory that is missing or older than go.mod or go.sum
+func needsVendoring(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil || !requireDirective.Match(data) {
+		return false
+	}
+
+	vendored, err := os.Stat(filepath.Join(dir, "vendor", "modules.txt"))
+	if err != nil {
+		return true
+	}
+	for _, file := range []string{"go.mod", "go.sum"} {
+		if info, err := os.Stat(filepath.Join(dir, file)); err == nil && info.ModTime().After(vendored.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package apps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type VendorTestSuite struct {
+	suite.Suite
+	tempDir string
+	config  *config.Config
+	ctx     context.Context
+}
+
+func TestVendorTestSuite(t *testing.T) {
+	suite.Run(t, new(VendorTestSuite))
+}
+
+func (s *VendorTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.ctx = context.Background()
+	s.config = &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "gateway-os",
+		Version:       "1.0.0",
+		Architecture:  "aarch64",
+		Template:      "minimal",
+		Apps:          []config.AppConfig{{Name: "sensord", Path: "sensord", Build: BuildGo}},
+	}
+
+	// A dependency replaced by a local module vendors without network access
+	s.T().Setenv("GOPROXY", "off")
+	s.T().Setenv("GOFLAGS", "")
+	s.T().Setenv("GOTOOLCHAIN", "local")
+	s.writeFile("lib/go.mod", "module example.com/lib\n\ngo 1.21\n")
+	s.writeFile("lib/lib.go", "package lib\n\nconst Name = \"lib\"\n")
+	s.writeFile("sensord/main.go", "package main\n\nimport \"example.com/lib\"\n\nfunc main() { println(lib.Name) }\n")
+	s.writeFile("sensord/go.mod", "module example.com/sensord\n\ngo 1.21\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../lib\n")
+}
+
+func (s *VendorTestSuite) writeFile(path, content string) {
+	path = filepath.Join(s.tempDir, path)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+}
+
+func (s *VendorTestSuite) TestVendorGoModules() {
+	modules := filepath.Join(s.tempDir, "sensord", "vendor", "modules.txt")
+
+	err := VendorGoModules(s.ctx, s.config, s.tempDir, true)
+	s.Error(err)
+	s.Contains(err.Error(), "the Go modules of app sensord are not vendored and the build is offline")
+
+	s.Require().NoError(VendorGoModules(s.ctx, s.config, s.tempDir, false))
+	s.FileExists(modules)
+	s.FileExists(filepath.Join(s.tempDir, "sensord", "vendor", "example.com", "lib", "lib.go"))
+
+	// Up to date modules are not vendored again, even offline
+	s.NoError(VendorGoModules(s.ctx, s.config, s.tempDir, true))
+
+	past := time.Now().Add(-time.Hour)
+	s.Require().NoError(os.Chtimes(modules, past, past))
+	s.True(needsVendoring(filepath.Join(s.tempDir, "sensord")))
+}
+
+func (s *VendorTestSuite) TestWithoutDependencies() {
+	s.writeFile("sensord/go.mod", "module example.com/sensord\n\ngo 1.21\n")
+
+	s.NoError(VendorGoModules(s.ctx, s.config, s.tempDir, true))
+	s.NoDirExists(filepath.Join(s.tempDir, "sensord", "vendor"))
+}
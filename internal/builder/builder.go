@@ -3,8 +3,10 @@ package builder
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"github.com/sst/forge/internal/buildroot"
@@ -21,6 +23,7 @@ type BuildOptions struct {
 	Jobs        int
 	OptimizeFor string
 	Timeout     time.Duration
+	Package     string // Only rebuild this package, then regenerate the images
 }
 
 // BuildOrchestrator coordinates the entire build process
@@ -34,6 +37,7 @@ type BuildOrchestrator struct {
 	metrics      *metrics.MetricsCollector
 	buildTimer   *metrics.Timer
 	buildPhases  []BuildPhase
+	options      BuildOptions
 }
 
 // BuildPhase represents a phase in the build process
@@ -51,13 +55,21 @@ func NewBuildOrchestrator(cfg *config.Config, projectDir string) *BuildOrchestra
 
 	buildDir := filepath.Join(projectDir, "build")
 	artifactsDir := filepath.Join(buildDir, "artifacts")
+	br := buildroot.NewBuildrootManager(cfg, projectDir)
+
+	// Each profile gets its own output and artifacts so variants don't clobber each other
+	if cfg.Profile != "" {
+		profileDir := filepath.Join(buildDir, "profiles", cfg.Profile)
+		artifactsDir = filepath.Join(profileDir, "artifacts")
+		br.SetOutputDir(filepath.Join(profileDir, "output"))
+	}
 
 	bo := &BuildOrchestrator{
 		config:       cfg,
 		projectDir:   projectDir,
 		buildDir:     buildDir,
 		artifactsDir: artifactsDir,
-		buildroot:    buildroot.NewBuildrootManager(cfg, projectDir),
+		buildroot:    br,
 		logger:       logger.NewLogger(logger.INFO, os.Stdout, os.Stderr),
 		metrics:      metrics.NewMetricsCollector(),
 	}
@@ -101,15 +113,26 @@ func (bo *BuildOrchestrator) initializeBuildPhases() {
 // Build executes the complete build process
 func (bo *BuildOrchestrator) Build(ctx context.Context, opts BuildOptions) error {
 	bo.logger.Info("Starting Forge OS build", "project", bo.config.Name, "version", bo.config.Version)
+	if bo.config.Profile != "" {
+		bo.logger.Info("Using build profile", "profile", bo.config.Profile)
+	}
 
-	// Set default options
+	// Fall back to the build section of forge.yml, then to defaults
+	if opts.Jobs == 0 {
+		opts.Jobs = bo.config.Build.Jobs
+	}
+	if opts.OptimizeFor == "" {
+		opts.OptimizeFor = bo.config.Build.Optimization
+	}
 	if opts.Jobs == 0 {
-		opts.Jobs = 1 // Default to 1 job for testing
+		opts.Jobs = runtime.NumCPU()
 	}
 	if opts.Timeout == 0 {
 		opts.Timeout = 2 * time.Hour // Default timeout
 	}
 
+	bo.options = opts
+
 	// Apply optimization settings
 	if opts.OptimizeFor != "" {
 		if err := bo.applyOptimization(opts.OptimizeFor); err != nil {
@@ -155,9 +178,8 @@ func (bo *BuildOrchestrator) validateBuildConfig(ctx context.Context) error {
 	}
 
 	// Validate architecture support
-	supportedArchs := []string{"x86_64", "arm", "aarch64", "mips", "riscv64"}
 	archSupported := false
-	for _, arch := range supportedArchs {
+	for _, arch := range config.ValidArchitectures {
 		if bo.config.Architecture == arch {
 			archSupported = true
 			break
@@ -208,11 +230,11 @@ func (bo *BuildOrchestrator) createBuildDirectories() error {
 	return nil
 }
 
-// applyOptimization applies build optimizations
+// applyOptimization selects the optimization the defconfig is generated
+// with, replacing build.optimization from forge.yml
 func (bo *BuildOrchestrator) applyOptimization(optimizeFor string) error {
-	validOptimizations := []string{"size", "performance", "realtime"}
 	valid := false
-	for _, opt := range validOptimizations {
+	for _, opt := range config.ValidOptimizations {
 		if optimizeFor == opt {
 			valid = true
 			break
@@ -222,31 +244,146 @@ func (bo *BuildOrchestrator) applyOptimization(optimizeFor string) error {
 		return fmt.Errorf("invalid optimization: %s", optimizeFor)
 	}
 
-	// In a real implementation, this would modify buildroot config
 	bo.logger.Info("Applying optimization", "type", optimizeFor)
+	bo.config.Build.Optimization = optimizeFor
 	return nil
 }
 
-// executeBuildrootBuild executes the Buildroot build
+// executeBuildrootBuild downloads Buildroot, generates its configuration and runs the build
 func (bo *BuildOrchestrator) executeBuildrootBuild(ctx context.Context) error {
-	// In a real implementation, this would:
-	// 1. Clone/download Buildroot
-	// 2. Apply defconfig
-	// 3. Run make with appropriate options
-	// 4. Handle parallel jobs
-	// For testing, we simulate this
+	bo.logger.Info("Executing Buildroot build", "architecture", bo.config.Architecture, "jobs", bo.options.Jobs)
+
+	bo.buildroot.SetParallelJobs(bo.options.Jobs)
 
-	bo.logger.Info("Executing Buildroot build", "architecture", bo.config.Architecture)
+	if err := bo.buildroot.DownloadBuildroot(ctx); err != nil {
+		return err
+	}
 
-	// Simulate build process (would fail in real environment without Buildroot)
-	return fmt.Errorf("buildroot build simulation - would require actual Buildroot setup")
+	if bo.options.Clean {
+		bo.logger.Info("Removing previous Buildroot output")
+		if err := bo.buildroot.Clean(); err != nil {
+			return err
+		}
+	}
+
+	if err := bo.checkSymbols(); err != nil {
+		return err
+	}
+
+	changes, err := bo.buildroot.GenerateConfig(ctx)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		bo.logger.Warn("Buildroot config: " + change.String())
+	}
+
+	if err := bo.buildroot.VendorApps(ctx); err != nil {
+		return err
+	}
+
+	// An offline build would only fail at the first missing download
+	if bo.buildroot.IsOffline() {
+		if err := bo.checkSources(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Local sources must not end up in a release image unnoticed
+	overrides, err := bo.buildroot.SourceOverrides()
+	if err != nil {
+		return err
+	}
+	for _, override := range overrides {
+		bo.logger.Warn("Building "+override.Package+" from local sources (see 'forge dev status')", "dir", override.Dir)
+	}
+
+	if bo.options.Package != "" {
+		if err := bo.buildroot.BuildPackage(ctx, bo.options.Package); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("build cancelled: %v", ctx.Err())
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := bo.buildroot.Build(ctx); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("build cancelled: %v", ctx.Err())
+		}
+		return err
+	}
+
+	// Lets 'forge rebuild' work out what later forge.yml changes touch
+	if err := bo.buildroot.SaveBuildState(); err != nil {
+		bo.logger.Warn("Failed to save build state", "error", err)
+	}
+
+	return nil
 }
 
-// collectArtifacts collects build artifacts
+// checkSources makes sure every source the configuration needs is in the
+// download directory before an offline build starts
+func (bo *BuildOrchestrator) checkSources(ctx context.Context) error {
+	missing, err := bo.buildroot.MissingSources(ctx)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for _, file := range missing {
+		bo.logger.Error("Missing source for "+file.Package, "file", file.Path)
+	}
+	return fmt.Errorf("%d source(s) missing from %s for an offline build (run 'forge fetch' where there is network access)",
+		len(missing), bo.buildroot.GetDownloadDir())
+}
+
+// checkSymbols validates the config symbols from forge.yml against the
+// Kconfig trees, so typos fail fast instead of being dropped by Buildroot
+func (bo *BuildOrchestrator) checkSymbols() error {
+	check, err := bo.buildroot.CheckSymbols()
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range check.Issues {
+		if issue.Warning {
+			bo.logger.Warn("Config symbol: " + issue.String())
+		} else {
+			bo.logger.Error("Config symbol: " + issue.String())
+		}
+	}
+	if len(bo.config.Kernel.Config) > 0 && !check.KernelChecked {
+		bo.logger.Info("Kernel options will be checked once Buildroot has extracted the kernel sources")
+	}
+
+	if errors := check.Errors(); len(errors) > 0 {
+		return fmt.Errorf("forge.yml sets %d invalid config symbol(s)", len(errors))
+	}
+	return nil
+}
+
+// collectArtifacts copies the Buildroot images into the artifacts directory
 func (bo *BuildOrchestrator) collectArtifacts(ctx context.Context) error {
-	// In a real implementation, this would copy built images to artifacts directory
 	bo.logger.Info("Collecting build artifacts")
 
+	imagesDir := bo.buildroot.GetImagesDir()
+	if _, err := os.Stat(imagesDir); os.IsNotExist(err) {
+		return fmt.Errorf("no build images found in %s", imagesDir)
+	}
+
+	destDir := filepath.Join(bo.artifactsDir, "images")
+	if err := os.RemoveAll(destDir); err != nil {
+		return fmt.Errorf("failed to remove stale artifacts: %v", err)
+	}
+
+	if err := copyDir(ctx, imagesDir, destDir); err != nil {
+		return fmt.Errorf("failed to copy build images: %v", err)
+	}
+
 	// Stop metrics collection
 	if bo.buildTimer != nil {
 		duration := bo.buildTimer.Stop()
@@ -255,3 +392,53 @@ func (bo *BuildOrchestrator) collectArtifacts(ctx context.Context) error {
 
 	return nil
 }
+
+// copyDir recursively copies a directory tree, preserving file modes
+func copyDir(ctx context.Context, src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFile copies a single file to dst with the given permissions
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
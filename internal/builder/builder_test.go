@@ -167,6 +167,21 @@ func (s *BuilderTestSuite) TestBuildOrchestratorBuildWithInvalidArchitecture() {
 	s.Error(err)
 }
 
+func (s *BuilderTestSuite) TestValidateBuildConfigArchitectures() {
+	cfg := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Template:      "minimal",
+	}
+	bo := NewBuildOrchestrator(cfg, s.tempDir)
+
+	for _, arch := range config.ValidArchitectures {
+		cfg.Architecture = arch
+		s.NoError(bo.validateBuildConfig(context.Background()), arch)
+	}
+}
+
 func (s *BuilderTestSuite) TestBuildOrchestratorBuildWithInvalidTemplate() {
 	cfg := &config.Config{
 		Name:         "test-project",
@@ -386,6 +401,26 @@ func (s *BuilderTestSuite) TestBuildOrchestratorOutputDirectories() {
 	s.Equal(expectedArtifactsDir, bo.artifactsDir)
 }
 
+func (s *BuilderTestSuite) TestBuildOrchestratorProfileDirectories() {
+	cfg := &config.Config{
+		Name:         "test-project",
+		Version:      "0.1.0",
+		Architecture: "x86_64",
+		Template:     "minimal",
+		Profile:      "prod",
+	}
+
+	projectDir := filepath.Join(s.tempDir, "project")
+	os.MkdirAll(projectDir, 0755)
+
+	bo := NewBuildOrchestrator(cfg, projectDir)
+	s.NotNil(bo)
+
+	profileDir := filepath.Join(projectDir, "build", "profiles", "prod")
+	s.Equal(filepath.Join(profileDir, "artifacts"), bo.artifactsDir)
+	s.Equal(filepath.Join(profileDir, "output"), bo.buildroot.GetOutputDir())
+}
+
 func (s *BuilderTestSuite) TestBuildOrchestratorBuildPhases() {
 	cfg := &config.Config{
 		Name:         "test-project",
@@ -404,3 +439,88 @@ func (s *BuilderTestSuite) TestBuildOrchestratorBuildPhases() {
 	s.NotNil(bo.buildPhases)
 	s.Greater(len(bo.buildPhases), 0)
 }
+
+func (s *BuilderTestSuite) TestBuildOrchestratorCollectArtifacts() {
+	cfg := &config.Config{
+		Name:         "test-project",
+		Version:      "0.1.0",
+		Architecture: "x86_64",
+		Template:     "minimal",
+	}
+
+	projectDir := filepath.Join(s.tempDir, "project")
+	os.MkdirAll(projectDir, 0755)
+
+	bo := NewBuildOrchestrator(cfg, projectDir)
+	s.NotNil(bo)
+
+	// Simulate Buildroot output images
+	imagesDir := bo.buildroot.GetImagesDir()
+	s.Require().NoError(os.MkdirAll(filepath.Join(imagesDir, "boot"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(imagesDir, "rootfs.ext2"), []byte("rootfs"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(imagesDir, "boot", "bzImage"), []byte("kernel"), 0644))
+
+	err := bo.collectArtifacts(context.Background())
+	s.NoError(err)
+
+	content, err := os.ReadFile(filepath.Join(bo.artifactsDir, "images", "rootfs.ext2"))
+	s.NoError(err)
+	s.Equal("rootfs", string(content))
+
+	content, err = os.ReadFile(filepath.Join(bo.artifactsDir, "images", "boot", "bzImage"))
+	s.NoError(err)
+	s.Equal("kernel", string(content))
+}
+
+func (s *BuilderTestSuite) TestBuildOrchestratorCollectArtifactsMissingImages() {
+	cfg := &config.Config{
+		Name:         "test-project",
+		Version:      "0.1.0",
+		Architecture: "x86_64",
+		Template:     "minimal",
+	}
+
+	projectDir := filepath.Join(s.tempDir, "project")
+	os.MkdirAll(projectDir, 0755)
+
+	bo := NewBuildOrchestrator(cfg, projectDir)
+	s.NotNil(bo)
+
+	err := bo.collectArtifacts(context.Background())
+	s.Error(err)
+	s.Contains(err.Error(), "no build images found")
+}
+
+func (s *BuilderTestSuite) TestBuildOrchestratorBuildWithApp() {
+	cfg := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Apps:          []config.AppConfig{{Name: "blinkd", Path: "blinkd", Build: "go"}},
+	}
+
+	projectDir := filepath.Join(s.tempDir, "project")
+	buildrootDir := filepath.Join(projectDir, "build", "buildroot")
+	s.Require().NoError(os.MkdirAll(filepath.Join(projectDir, "blinkd"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(projectDir, "blinkd", "go.mod"), []byte("module blinkd\n"), 0644))
+	s.Require().NoError(os.MkdirAll(buildrootDir, 0755))
+
+	// A fake Buildroot that knows the symbols of the minimal template and
+	// leaves app packages to the project's external tree
+	configIn := "config BR2_x86_64\n\tbool \"x86_64\"\n" +
+		"config BR2_TOOLCHAIN_BUILDROOT_GLIBC\n\tbool \"glibc\"\n" +
+		"config BR2_TARGET_ROOTFS_EXT2\n\tbool \"ext2\"\n" +
+		"config BR2_PACKAGE_BUSYBOX\n\tbool \"busybox\"\n"
+	makefile := "all:\n\t@mkdir -p output/images && touch output/images/rootfs.ext2\n" +
+		"olddefconfig:\n\t@true\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Config.in"), []byte(configIn), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte(makefile), 0644))
+
+	bo := NewBuildOrchestrator(cfg, projectDir)
+	s.Require().NoError(bo.Build(context.Background(), BuildOptions{Jobs: 1}))
+
+	s.FileExists(filepath.Join(projectDir, "external", "package", "blinkd", "Config.in"))
+	s.FileExists(filepath.Join(projectDir, "build", "artifacts", "images", "rootfs.ext2"))
+}
@@ -1,15 +1,19 @@
 package buildroot
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/sst/forge/internal/apps"
 	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/features"
 )
 
 // BuildrootManager manages Buildroot operations
@@ -17,6 +21,8 @@ type BuildrootManager struct {
 	config     *config.Config
 	projectDir string
 	buildDir   string
+	outputDir  string
+	jobs       int
 }
 
 // NewBuildrootManager creates a new Buildroot manager
@@ -28,18 +34,31 @@ func NewBuildrootManager(cfg *config.Config, projectDir string) *BuildrootManage
 	}
 }
 
-// DownloadBuildroot downloads and extracts Buildroot
-func (bm *BuildrootManager) DownloadBuildroot() error {
-	version := bm.config.Buildroot.Version
-	if version == "" {
-		version = "stable"
-	}
+// SetParallelJobs sets the number of parallel make jobs (0 = auto-detect)
+func (bm *BuildrootManager) SetParallelJobs(jobs int) {
+	bm.jobs = jobs
+}
+
+// SetOutputDir builds out of tree into dir (make O=dir) instead of the
+// default output directory inside the Buildroot source tree
+func (bm *BuildrootManager) SetOutputDir(dir string) {
+	bm.outputDir = dir
+}
 
+// DownloadBuildroot downloads and extracts Buildroot. With buildroot.sources
+// set, Buildroot and the package sources come from that archive instead.
+func (bm *BuildrootManager) DownloadBuildroot(ctx context.Context) error {
 	// Create build directory
 	if err := os.MkdirAll(bm.buildDir, 0755); err != nil {
 		return fmt.Errorf("failed to create build directory: %v", err)
 	}
 
+	if sources := bm.config.Buildroot.Sources; sources != "" {
+		if err := bm.ImportSources(sources); err != nil {
+			return err
+		}
+	}
+
 	// Check if Buildroot is already downloaded
 	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
 	if _, err := os.Stat(buildrootDir); err == nil {
@@ -47,22 +66,13 @@ func (bm *BuildrootManager) DownloadBuildroot() error {
 		return nil
 	}
 
-	// Determine download URL
-	var downloadURL string
-	if version == "stable" {
-		downloadURL = "https://buildroot.org/downloads/buildroot-latest.tar.gz"
-	} else {
-		downloadURL = fmt.Sprintf("https://buildroot.org/downloads/buildroot-%s.tar.gz", version)
-	}
-
-	// Download Buildroot
-	tarPath := filepath.Join(bm.buildDir, "buildroot.tar.gz")
-	if err := bm.downloadFile(downloadURL, tarPath); err != nil {
-		return fmt.Errorf("failed to download Buildroot: %v", err)
+	// The tarball is kept so that forge fetch can pack it
+	if err := bm.ensureBuildrootTarball(ctx); err != nil {
+		return err
 	}
 
 	// Extract Buildroot
-	if err := bm.extractTarGz(tarPath, bm.buildDir); err != nil {
+	if err := bm.extractTarGz(ctx, bm.GetBuildrootTarball(), bm.buildDir); err != nil {
 		return fmt.Errorf("failed to extract Buildroot: %v", err)
 	}
 
@@ -76,69 +86,141 @@ func (bm *BuildrootManager) DownloadBuildroot() error {
 		return fmt.Errorf("failed to rename Buildroot directory: %v", err)
 	}
 
-	// Clean up tar file
-	os.Remove(tarPath)
-
 	return nil
 }
 
-// GenerateConfig generates Buildroot .config file from forge configuration
-func (bm *BuildrootManager) GenerateConfig() error {
-	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
+// GenerateConfig writes the complete defconfig for the project, resolves it
+// into the Buildroot .config with olddefconfig and returns the symbols
+// Buildroot discarded or overrode
+func (bm *BuildrootManager) GenerateConfig(ctx context.Context) ([]config.SymbolChange, error) {
+	defconfig, err := bm.config.Defconfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bm.applyFeatureFiles(defconfig); err != nil {
+		return nil, fmt.Errorf("failed to apply feature config: %v", err)
+	}
 
-	// Start with default configuration
-	if err := bm.runMake(buildrootDir, "defconfig"); err != nil {
-		return fmt.Errorf("failed to generate default config: %v", err)
+	// The app packages live in the project's BR2_EXTERNAL tree
+	if len(bm.config.Apps) > 0 {
+		if _, err := apps.WriteExternal(bm.config, bm.projectDir); err != nil {
+			return nil, fmt.Errorf("failed to write external tree: %v", err)
+		}
 	}
 
-	// Apply architecture-specific configuration
-	if err := bm.applyArchitectureConfig(); err != nil {
-		return fmt.Errorf("failed to apply architecture config: %v", err)
+	// Buildroot applies patches/<package>/ after its own patches
+	if info, err := os.Stat(bm.GetPatchesDir()); err == nil && info.IsDir() {
+		defconfig.SetString("BR2_GLOBAL_PATCH_DIR", bm.GetPatchesDir(), "patches")
 	}
 
-	// Apply package configuration
-	if err := bm.applyPackageConfig(); err != nil {
-		return fmt.Errorf("failed to apply package config: %v", err)
+	// Downloads outlive the build directory and can be shared
+	defconfig.SetString("BR2_DL_DIR", bm.GetDownloadDir(), "buildroot.download_dir")
+	if bm.IsOffline() {
+		// Sources missing from the download directory fail instead of
+		// being fetched from upstream
+		defconfig.SetString("BR2_PRIMARY_SITE", "file://"+bm.GetDownloadDir(), "buildroot.offline")
+		defconfig.Set("BR2_PRIMARY_SITE_ONLY", "y", "buildroot.offline")
+		defconfig.SetString("BR2_BACKUP_SITE", "", "buildroot.offline")
 	}
 
-	// Apply kernel configuration
-	if err := bm.applyKernelConfig(); err != nil {
-		return fmt.Errorf("failed to apply kernel config: %v", err)
+	// Recorded so that make savedefconfig writes back to the same file
+	defconfigPath := bm.GetDefconfigPath()
+	defconfig.SetString("BR2_DEFCONFIG", defconfigPath, "forge")
+
+	if err := writeFile(defconfigPath, defconfig.String()); err != nil {
+		return nil, fmt.Errorf("failed to write defconfig: %v", err)
 	}
 
-	// Apply feature configuration
-	if err := bm.applyFeatureConfig(); err != nil {
-		return fmt.Errorf("failed to apply feature config: %v", err)
+	// olddefconfig resolves .config in place: it fills in defaults and drops
+	// what Kconfig does not accept
+	configPath := bm.GetConfigPath()
+	if err := writeFile(configPath, defconfig.String()); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", configPath, err)
 	}
 
-	return nil
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs("olddefconfig")...); err != nil {
+		return nil, fmt.Errorf("failed to resolve config: %v", err)
+	}
+
+	resolved, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resolved config: %v", err)
+	}
+
+	return defconfig.Compare(config.ParseConfigSymbols(resolved)), nil
 }
 
 // Build executes the Buildroot build process
-func (bm *BuildrootManager) Build() error {
+func (bm *BuildrootManager) Build(ctx context.Context) error {
 	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
 
 	// Run make with parallel jobs
-	if err := bm.runMake(buildrootDir, fmt.Sprintf("-j%d", bm.getParallelJobs())); err != nil {
+	if err := bm.runMake(ctx, buildrootDir, bm.makeArgs(fmt.Sprintf("-j%d", bm.getParallelJobs()))...); err != nil {
 		return fmt.Errorf("build failed: %v", err)
 	}
 
 	return nil
 }
 
+// Clean removes the Buildroot output directory so the next build starts from scratch
+func (bm *BuildrootManager) Clean() error {
+	if err := os.RemoveAll(bm.GetOutputDir()); err != nil {
+		return fmt.Errorf("failed to remove output directory: %v", err)
+	}
+	return nil
+}
+
+// GetBuildrootDir returns the Buildroot source directory
+func (bm *BuildrootManager) GetBuildrootDir() string {
+	return filepath.Join(bm.buildDir, "buildroot")
+}
+
 // GetOutputDir returns the Buildroot output directory
 func (bm *BuildrootManager) GetOutputDir() string {
+	if bm.outputDir != "" {
+		return bm.outputDir
+	}
 	return filepath.Join(bm.buildDir, "buildroot", "output")
 }
 
+// GetConfigPath returns the Buildroot .config file, which lives in the
+// output directory for out-of-tree builds
+func (bm *BuildrootManager) GetConfigPath() string {
+	if bm.outputDir != "" {
+		return filepath.Join(bm.outputDir, ".config")
+	}
+	return filepath.Join(bm.buildDir, "buildroot", ".config")
+}
+
+// GetDefconfigPath returns the defconfig forge generates from forge.yml
+func (bm *BuildrootManager) GetDefconfigPath() string {
+	return filepath.Join(bm.GetOutputDir(), "forge", "defconfig")
+}
+
+// GetExternalDir returns the absolute path of the project's BR2_EXTERNAL
+// tree, as make runs in the Buildroot directory
+func (bm *BuildrootManager) GetExternalDir() string {
+	dir := filepath.Join(bm.projectDir, apps.ExternalDir)
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
 // GetImagesDir returns the Buildroot images directory
 func (bm *BuildrootManager) GetImagesDir() string {
 	return filepath.Join(bm.GetOutputDir(), "images")
 }
 
 // downloadFile downloads a file from URL to local path
-func (bm *BuildrootManager) downloadFile(url, destPath string) error {
-	resp, err := http.Get(url)
+func (bm *BuildrootManager) downloadFile(ctx context.Context, url, destPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -159,8 +241,8 @@ func (bm *BuildrootManager) downloadFile(url, destPath string) error {
 }
 
 // extractTarGz extracts a tar.gz file to destination directory
-func (bm *BuildrootManager) extractTarGz(tarPath, destDir string) error {
-	cmd := exec.Command("tar", "-xzf", tarPath, "-C", destDir)
+func (bm *BuildrootManager) extractTarGz(ctx context.Context, tarPath, destDir string) error {
+	cmd := exec.CommandContext(ctx, "tar", "-xzf", tarPath, "-C", destDir)
 	return cmd.Run()
 }
 
@@ -181,149 +263,87 @@ func (bm *BuildrootManager) findExtractedBuildrootDir() string {
 }
 
 // runMake executes make command in the specified directory
-func (bm *BuildrootManager) runMake(dir string, args ...string) error {
-	cmd := exec.Command("make", args...)
+func (bm *BuildrootManager) runMake(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "make", args...)
 	cmd.Dir = dir
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// makeArgs prepends the out-of-tree output directory and the project's
+// external tree, if any, to make arguments
+func (bm *BuildrootManager) makeArgs(args ...string) []string {
+	var prefix []string
+	if bm.outputDir != "" {
+		prefix = append(prefix, "O="+bm.outputDir)
+	}
+	if len(bm.config.Apps) > 0 {
+		prefix = append(prefix, "BR2_EXTERNAL="+bm.GetExternalDir())
+	}
+	return append(prefix, args...)
+}
+
 // getParallelJobs returns the number of parallel jobs to use for building
 func (bm *BuildrootManager) getParallelJobs() int {
+	if bm.jobs > 0 {
+		return bm.jobs
+	}
+
 	// Use number of CPU cores
-	return 4 // TODO: Detect actual CPU count
+	return runtime.NumCPU()
 }
 
-// applyArchitectureConfig applies architecture-specific Buildroot configuration
-func (bm *BuildrootManager) applyArchitectureConfig() error {
-	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
-	configPath := filepath.Join(buildrootDir, ".config")
-
-	arch := bm.config.Architecture
-	var configLines []string
+// applyFeatureFiles writes the kernel options of the enabled features and
+// kernel.config as a config fragment and the features' files as a root
+// filesystem overlay, and points the defconfig at both
+func (bm *BuildrootManager) applyFeatureFiles(defconfig *config.Defconfig) error {
+	resolution := features.NewFeatureManager().ResolveFeatures(bm.config.Features)
+	forgeDir := filepath.Join(bm.GetOutputDir(), "forge")
 
-	switch arch {
-	case "x86_64":
-		configLines = []string{
-			"BR2_x86_64=y",
-			"BR2_ARCH=\"x86_64\"",
-		}
-	case "arm":
-		configLines = []string{
-			"BR2_arm=y",
-			"BR2_ARCH=\"arm\"",
-		}
-	case "aarch64":
-		configLines = []string{
-			"BR2_aarch64=y",
-			"BR2_ARCH=\"aarch64\"",
-		}
-	case "mips":
-		configLines = []string{
-			"BR2_mips=y",
-			"BR2_ARCH=\"mips\"",
+	kernelFragment, err := bm.config.KernelFragment()
+	if err != nil {
+		return err
+	}
+	if len(kernelFragment.Symbols()) > 0 {
+		fragmentPath := filepath.Join(forgeDir, "kernel.fragment")
+		fragment := "# Kernel options from forge.yml and its features\n" + kernelFragment.Lines()
+		if err := writeFile(fragmentPath, fragment); err != nil {
+			return fmt.Errorf("failed to write kernel fragment: %v", err)
 		}
-	default:
-		return fmt.Errorf("unsupported architecture: %s", arch)
+		defconfig.SetString("BR2_LINUX_KERNEL_CONFIG_FRAGMENT_FILES", fragmentPath, "kernel fragment")
 	}
 
-	return bm.appendConfigLines(configPath, configLines)
-}
-
-// applyPackageConfig applies package configuration to Buildroot
-func (bm *BuildrootManager) applyPackageConfig() error {
-	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
-	configPath := filepath.Join(buildrootDir, ".config")
-
-	var configLines []string
-
-	for _, pkg := range bm.config.Packages {
-		switch pkg {
-		case "openssh":
-			configLines = append(configLines, "BR2_PACKAGE_OPENSSH=y")
-		case "wpa_supplicant":
-			configLines = append(configLines, "BR2_PACKAGE_WPA_SUPPLICANT=y")
-		case "dhcpcd":
-			configLines = append(configLines, "BR2_PACKAGE_DHCPCD=y")
-		case "mosquitto":
-			configLines = append(configLines, "BR2_PACKAGE_MOSQUITTO=y")
-		case "python3":
-			configLines = append(configLines, "BR2_PACKAGE_PYTHON3=y")
-		case "i2c-tools":
-			configLines = append(configLines, "BR2_PACKAGE_I2C_TOOLS=y")
-		case "openvpn":
-			configLines = append(configLines, "BR2_PACKAGE_OPENVPN=y")
-		case "iptables":
-			configLines = append(configLines, "BR2_PACKAGE_IPTABLES=y")
-		case "fail2ban":
-			configLines = append(configLines, "BR2_PACKAGE_FAIL2BAN=y")
-		case "modbus":
-			configLines = append(configLines, "BR2_PACKAGE_LIBMODBUS=y")
-		case "chrony":
-			configLines = append(configLines, "BR2_PACKAGE_CHRONY=y")
-		case "rsyslog":
-			configLines = append(configLines, "BR2_PACKAGE_RSYSLOG=y")
-		case "xorg-server":
-			configLines = append(configLines, "BR2_PACKAGE_XORG7=y")
-		case "chromium":
-			configLines = append(configLines, "BR2_PACKAGE_CHROMIUM=y")
-		case "xterm":
-			configLines = append(configLines, "BR2_PACKAGE_XTERM=y")
-		case "fluxbox":
-			configLines = append(configLines, "BR2_PACKAGE_FLUXBOX=y")
-			// Add more package mappings as needed
+	var overlays []string
+	if len(resolution.Overlays) > 0 {
+		overlayDir := filepath.Join(forgeDir, "overlay")
+		if err := os.RemoveAll(overlayDir); err != nil {
+			return fmt.Errorf("failed to clean feature overlay: %v", err)
 		}
+		for path, content := range resolution.Overlays {
+			if err := writeFile(filepath.Join(overlayDir, filepath.FromSlash(path)), content); err != nil {
+				return fmt.Errorf("failed to write %s: %v", path, err)
+			}
+		}
+		overlays = append(overlays, overlayDir)
 	}
 
-	return bm.appendConfigLines(configPath, configLines)
-}
-
-// applyKernelConfig applies kernel configuration to Buildroot
-func (bm *BuildrootManager) applyKernelConfig() error {
-	// Kernel configuration is handled separately in the kernel config file
-	// This method can be extended to set kernel-related Buildroot options
-	return nil
-}
-
-// applyFeatureConfig applies feature configuration to Buildroot
-func (bm *BuildrootManager) applyFeatureConfig() error {
-	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
-	configPath := filepath.Join(buildrootDir, ".config")
-
-	var configLines []string
-
-	for _, feature := range bm.config.Features {
-		switch feature {
-		case "systemd":
-			configLines = append(configLines, "BR2_INIT_SYSTEMD=y")
-		case "network":
-			// Network features are enabled by default, but we can add specific configs
-			configLines = append(configLines, "BR2_SYSTEM_ENABLE_NLS=y")
-			// Add more feature mappings as needed
-		}
+	// The project overlay comes last so its files win over the features'
+	projectOverlay := filepath.Join(bm.projectDir, "overlays", "rootfs")
+	if info, err := os.Stat(projectOverlay); err == nil && info.IsDir() {
+		overlays = append(overlays, projectOverlay)
+	}
+	if len(overlays) > 0 {
+		defconfig.SetString("BR2_ROOTFS_OVERLAY", strings.Join(overlays, " "), "overlays")
 	}
 
-	return bm.appendConfigLines(configPath, configLines)
+	return nil
 }
 
-// appendConfigLines appends configuration lines to the Buildroot .config file
-func (bm *BuildrootManager) appendConfigLines(configPath string, lines []string) error {
-	if len(lines) == 0 {
-		return nil
-	}
-
-	file, err := os.OpenFile(configPath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
+// writeFile writes content to path, creating its directory
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	for _, line := range lines {
-		if _, err := file.WriteString(line + "\n"); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return os.WriteFile(path, []byte(content), 0644)
 }
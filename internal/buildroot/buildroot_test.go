@@ -1,8 +1,11 @@
 package buildroot
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/sst/forge/internal/config"
@@ -56,7 +59,7 @@ func (s *BuildrootTestSuite) TestDownloadBuildroot() {
 
 	// This test would actually download Buildroot, which is slow and requires internet
 	// For now, we'll just test that the method doesn't panic and creates the build directory
-	err := bm.DownloadBuildroot()
+	err := bm.DownloadBuildroot(context.Background())
 	// We expect this to fail in test environment without internet, but the directory should be created
 	if err != nil {
 		s.Contains(err.Error(), "failed to download") // Expected in test environment
@@ -75,15 +78,54 @@ func (s *BuildrootTestSuite) TestGenerateConfig() {
 	err := os.MkdirAll(buildrootDir, 0755)
 	s.NoError(err)
 
-	// Create a mock .config file
-	configPath := filepath.Join(buildrootDir, ".config")
-	err = os.WriteFile(configPath, []byte("# Test config\n"), 0644)
-	s.NoError(err)
-
 	// Test config generation
-	err = bm.GenerateConfig()
+	_, err = bm.GenerateConfig(context.Background())
 	// This will fail because we don't have a real Buildroot setup, but it should not panic
 	s.Error(err) // Expected to fail without real Buildroot
+
+	// The defconfig is written before Buildroot is asked to resolve it
+	defconfig, err := os.ReadFile(bm.GetDefconfigPath())
+	s.NoError(err)
+	s.Contains(string(defconfig), "BR2_x86_64=y")
+	s.Contains(string(defconfig), fmt.Sprintf("BR2_DEFCONFIG=\"%s\"", bm.GetDefconfigPath()))
+}
+
+func (s *BuildrootTestSuite) TestGenerateConfigReportsChanges() {
+	bm := NewBuildrootManager(s.config, s.tempDir)
+
+	// A stand-in for Buildroot's olddefconfig that drops an unknown package
+	// and disables the ext2 image
+	buildrootDir := bm.GetBuildrootDir()
+	s.Require().NoError(os.MkdirAll(buildrootDir, 0755))
+	makefile := "olddefconfig:\n" +
+		"\tsed -i -e '/BR2_PACKAGE_NOSUCHPKG/d' -e 's/^BR2_TARGET_ROOTFS_EXT2=y/# BR2_TARGET_ROOTFS_EXT2 is not set/' .config\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte(makefile), 0644))
+
+	s.config.Packages = []string{"i2c-tools", "nosuchpkg"}
+	changes, err := bm.GenerateConfig(context.Background())
+	s.Require().NoError(err)
+
+	var messages []string
+	for _, change := range changes {
+		messages = append(messages, change.String())
+	}
+	s.Equal([]string{
+		"BR2_TARGET_ROOTFS_EXT2=y (template minimal) was discarded",
+		"BR2_PACKAGE_NOSUCHPKG=y (package nosuchpkg) was discarded",
+	}, messages)
+
+	content, err := os.ReadFile(bm.GetConfigPath())
+	s.NoError(err)
+	s.Contains(string(content), "BR2_PACKAGE_I2C_TOOLS=y")
+}
+
+func (s *BuildrootTestSuite) TestGenerateConfigUnsupportedArchitecture() {
+	bm := NewBuildrootManager(s.config, s.tempDir)
+
+	s.config.Architecture = "sparc"
+	_, err := bm.GenerateConfig(context.Background())
+	s.Error(err)
+	s.Contains(err.Error(), "unsupported architecture: sparc")
 }
 
 func (s *BuildrootTestSuite) TestBuild() {
@@ -95,7 +137,7 @@ func (s *BuildrootTestSuite) TestBuild() {
 	s.NoError(err)
 
 	// Test build (will fail without real Buildroot)
-	err = bm.Build()
+	err = bm.Build(context.Background())
 	s.Error(err) // Expected to fail without real Buildroot
 }
 
@@ -105,94 +147,77 @@ func (s *BuildrootTestSuite) TestGetOutputDir() {
 	s.Equal(expected, bm.GetOutputDir())
 }
 
-func (s *BuildrootTestSuite) TestGetImagesDir() {
+func (s *BuildrootTestSuite) TestSetOutputDir() {
 	bm := NewBuildrootManager(s.config, s.tempDir)
-	expected := filepath.Join(s.tempDir, "build", "buildroot", "output", "images")
-	s.Equal(expected, bm.GetImagesDir())
+	s.Equal(filepath.Join(s.tempDir, "build", "buildroot", ".config"), bm.GetConfigPath())
+	s.Equal([]string{"defconfig"}, bm.makeArgs("defconfig"))
+
+	outputDir := filepath.Join(s.tempDir, "build", "profiles", "prod", "output")
+	bm.SetOutputDir(outputDir)
+	s.Equal(outputDir, bm.GetOutputDir())
+	s.Equal(filepath.Join(outputDir, "images"), bm.GetImagesDir())
+	s.Equal(filepath.Join(outputDir, ".config"), bm.GetConfigPath())
+	s.Equal([]string{"O=" + outputDir, "defconfig"}, bm.makeArgs("defconfig"))
 }
 
-func (s *BuildrootTestSuite) TestApplyArchitectureConfig() {
+func (s *BuildrootTestSuite) TestExternalTree() {
 	bm := NewBuildrootManager(s.config, s.tempDir)
+	s.Require().NoError(os.MkdirAll(bm.GetBuildrootDir(), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(bm.GetBuildrootDir(), "Makefile"), []byte("olddefconfig:\n\t@true\n"), 0644))
 
-	// Create mock config file
-	configPath := filepath.Join(bm.buildDir, "buildroot", ".config")
-	err := os.MkdirAll(filepath.Dir(configPath), 0755)
-	s.NoError(err)
-	err = os.WriteFile(configPath, []byte("# Base config\n"), 0644)
-	s.NoError(err)
+	s.config.Apps = []config.AppConfig{{Name: "sensord", Path: "src/sensord", Build: "cmake"}}
+	s.Equal([]string{"BR2_EXTERNAL=" + bm.GetExternalDir(), "defconfig"}, bm.makeArgs("defconfig"))
+	s.True(filepath.IsAbs(bm.GetExternalDir()))
 
-	// Test x86_64 architecture
-	s.config.Architecture = "x86_64"
-	err = bm.applyArchitectureConfig()
-	s.NoError(err)
+	_, err := bm.GenerateConfig(context.Background())
+	s.Require().NoError(err)
+	s.FileExists(filepath.Join(s.tempDir, "external", "package", "sensord", "sensord.mk"))
 
-	content, err := os.ReadFile(configPath)
-	s.NoError(err)
-	s.Contains(string(content), "BR2_x86_64=y")
-	s.Contains(string(content), "BR2_ARCH=\"x86_64\"")
+	defconfig, err := os.ReadFile(bm.GetDefconfigPath())
+	s.Require().NoError(err)
+	s.Contains(string(defconfig), "BR2_PACKAGE_SENSORD=y")
 }
 
-func (s *BuildrootTestSuite) TestApplyPackageConfig() {
+func (s *BuildrootTestSuite) TestGetImagesDir() {
 	bm := NewBuildrootManager(s.config, s.tempDir)
-
-	// Create mock config file
-	configPath := filepath.Join(bm.buildDir, "buildroot", ".config")
-	err := os.MkdirAll(filepath.Dir(configPath), 0755)
-	s.NoError(err)
-	err = os.WriteFile(configPath, []byte("# Base config\n"), 0644)
-	s.NoError(err)
-
-	// Test with some packages
-	s.config.Packages = []string{"openssh", "python3"}
-	err = bm.applyPackageConfig()
-	s.NoError(err)
-
-	content, err := os.ReadFile(configPath)
-	s.NoError(err)
-	s.Contains(string(content), "BR2_PACKAGE_OPENSSH=y")
-	s.Contains(string(content), "BR2_PACKAGE_PYTHON3=y")
+	expected := filepath.Join(s.tempDir, "build", "buildroot", "output", "images")
+	s.Equal(expected, bm.GetImagesDir())
 }
 
-func (s *BuildrootTestSuite) TestApplyFeatureConfig() {
+func (s *BuildrootTestSuite) TestApplyFeatureFiles() {
 	bm := NewBuildrootManager(s.config, s.tempDir)
 
-	// Create mock config file
-	configPath := filepath.Join(bm.buildDir, "buildroot", ".config")
-	err := os.MkdirAll(filepath.Dir(configPath), 0755)
-	s.NoError(err)
-	err = os.WriteFile(configPath, []byte("# Base config\n"), 0644)
-	s.NoError(err)
+	// The project overlay is applied after the feature overlay
+	projectOverlay := filepath.Join(s.tempDir, "overlays", "rootfs")
+	s.Require().NoError(os.MkdirAll(projectOverlay, 0755))
 
-	// Test with systemd feature
-	s.config.Features = []string{"systemd"}
-	err = bm.applyFeatureConfig()
-	s.NoError(err)
+	s.config.Features = []string{"firewall"}
+	defconfig := config.NewDefconfig()
+	s.NoError(bm.applyFeatureFiles(defconfig))
 
-	content, err := os.ReadFile(configPath)
-	s.NoError(err)
-	s.Contains(string(content), "BR2_INIT_SYSTEMD=y")
-}
+	forgeDir := filepath.Join(bm.GetOutputDir(), "forge")
+	overlay, ok := defconfig.Get("BR2_ROOTFS_OVERLAY")
+	s.True(ok)
+	s.Equal(fmt.Sprintf("\"%s %s\"", filepath.Join(forgeDir, "overlay"), projectOverlay), overlay.Value)
+	fragmentFiles, ok := defconfig.Get("BR2_LINUX_KERNEL_CONFIG_FRAGMENT_FILES")
+	s.True(ok)
+	s.Equal(fmt.Sprintf("\"%s\"", filepath.Join(forgeDir, "kernel.fragment")), fragmentFiles.Value)
 
-func (s *BuildrootTestSuite) TestAppendConfigLines() {
-	bm := NewBuildrootManager(s.config, s.tempDir)
-
-	// Create the buildroot directory structure
-	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
-	err := os.MkdirAll(buildrootDir, 0755)
+	fragment, err := os.ReadFile(filepath.Join(forgeDir, "kernel.fragment"))
 	s.NoError(err)
+	s.Contains(string(fragment), "CONFIG_NETFILTER=y")
 
-	configPath := filepath.Join(buildrootDir, ".config")
-	err = os.WriteFile(configPath, []byte("# Base config\n"), 0644)
+	rules, err := os.ReadFile(filepath.Join(forgeDir, "overlay", "etc", "iptables.conf"))
 	s.NoError(err)
+	s.Contains(string(rules), "--dport 22")
+}
 
-	lines := []string{"BR2_TEST1=y", "BR2_TEST2=y"}
-	err = bm.appendConfigLines(configPath, lines)
-	s.NoError(err)
+func (s *BuildrootTestSuite) TestApplyFeatureFilesWithoutFeatures() {
+	bm := NewBuildrootManager(s.config, s.tempDir)
 
-	content, err := os.ReadFile(configPath)
-	s.NoError(err)
-	s.Contains(string(content), "BR2_TEST1=y")
-	s.Contains(string(content), "BR2_TEST2=y")
+	defconfig := config.NewDefconfig()
+	s.NoError(bm.applyFeatureFiles(defconfig))
+	s.Empty(defconfig.Symbols())
 }
 
 func (s *BuildrootTestSuite) TestFindExtractedBuildrootDir() {
@@ -210,5 +235,36 @@ func (s *BuildrootTestSuite) TestFindExtractedBuildrootDir() {
 func (s *BuildrootTestSuite) TestGetParallelJobs() {
 	bm := NewBuildrootManager(s.config, s.tempDir)
 	jobs := bm.getParallelJobs()
-	s.Equal(4, jobs) // Currently hardcoded to 4
+	s.Equal(runtime.NumCPU(), jobs) // Defaults to the number of CPU cores
+
+	bm.SetParallelJobs(8)
+	s.Equal(8, bm.getParallelJobs())
+}
+
+func (s *BuildrootTestSuite) TestBuildCancelled() {
+	bm := NewBuildrootManager(s.config, s.tempDir)
+
+	buildrootDir := filepath.Join(bm.buildDir, "buildroot")
+	err := os.MkdirAll(buildrootDir, 0755)
+	s.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = bm.Build(ctx)
+	s.Error(err)
+}
+
+func (s *BuildrootTestSuite) TestClean() {
+	bm := NewBuildrootManager(s.config, s.tempDir)
+
+	imagesDir := bm.GetImagesDir()
+	err := os.MkdirAll(imagesDir, 0755)
+	s.NoError(err)
+
+	err = bm.Clean()
+	s.NoError(err)
+
+	_, err = os.Stat(bm.GetOutputDir())
+	s.True(os.IsNotExist(err))
 }
@@ -0,0 +1,142 @@
+package buildroot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// overrideLine matches a <PKG>_OVERRIDE_SRCDIR assignment in local.mk
+var overrideLine = regexp.MustCompile(`^\s*([A-Z0-9_]+)_OVERRIDE_SRCDIR\s*[:?]?=\s*(.*?)\s*$`)
+
+// SourceOverride is a package Buildroot builds from a local directory
+// instead of its downloaded sources
+type SourceOverride struct {
+	Package string // Lower-case package name as written in local.mk
+	Dir     string
+}
+
+// GetOverrideFilePath returns the local.mk Buildroot reads source overrides
+// from, which lives next to the .config
+func (bm *BuildrootManager) GetOverrideFilePath() string {
+	return filepath.Join(filepath.Dir(bm.GetConfigPath()), "local.mk")
+}
+
+// SourceOverrides returns the packages local.mk points at local sources
+func (bm *BuildrootManager) SourceOverrides() ([]SourceOverride, error) {
+	lines, err := bm.readOverrideFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides []SourceOverride
+	for _, line := range lines {
+		if match := overrideLine.FindStringSubmatch(line); match != nil {
+			overrides = append(overrides, SourceOverride{Package: strings.ToLower(match[1]), Dir: match[2]})
+		}
+	}
+	return overrides, nil
+}
+
+// LinkSource makes Buildroot build a package from a local directory,
+// replacing any earlier override of the package
+func (bm *BuildrootManager) LinkSource(pkg, dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	lines, err := bm.readOverrideFile()
+	if err != nil {
+		return err
+	}
+
+	entry := fmt.Sprintf("%s_OVERRIDE_SRCDIR = %s", packageVariable(pkg), abs)
+	replaced := false
+	for i, line := range lines {
+		if match := overrideLine.FindStringSubmatch(line); match != nil && match[1] == packageVariable(pkg) {
+			lines[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, entry)
+	}
+	return bm.writeOverrideFile(lines)
+}
+
+// UnlinkSource drops a package's override and reports whether it had one
+func (bm *BuildrootManager) UnlinkSource(pkg string) (bool, error) {
+	lines, err := bm.readOverrideFile()
+	if err != nil {
+		return false, err
+	}
+
+	var kept []string
+	for _, line := range lines {
+		if match := overrideLine.FindStringSubmatch(line); match != nil && match[1] == packageVariable(pkg) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) == len(lines) {
+		return false, nil
+	}
+	return true, bm.writeOverrideFile(kept)
+}
+
+// CleanPackage removes a package's build directory, so its sources are
+// extracted or synced again by the next build
+func (bm *BuildrootManager) CleanPackage(ctx context.Context, pkg string) error {
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(pkg+"-dirclean")...); err != nil {
+		return fmt.Errorf("failed to clean %s: %v", pkg, err)
+	}
+	return nil
+}
+
+// BuildPackage rebuilds a single package with <pkg>-rebuild and then
+// regenerates the root filesystem and images from what is already built
+func (bm *BuildrootManager) BuildPackage(ctx context.Context, pkg string) error {
+	jobs := fmt.Sprintf("-j%d", bm.getParallelJobs())
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(jobs, pkg+"-rebuild")...); err != nil {
+		return fmt.Errorf("failed to rebuild %s: %v", pkg, err)
+	}
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(jobs)...); err != nil {
+		return fmt.Errorf("failed to regenerate images: %v", err)
+	}
+	return nil
+}
+
+// readOverrideFile returns the lines of local.mk, or none if it does not
+// exist
+func (bm *BuildrootManager) readOverrideFile() ([]string, error) {
+	data, err := os.ReadFile(bm.GetOverrideFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", bm.GetOverrideFilePath(), err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n"), nil
+}
+
+// writeOverrideFile writes local.mk, removing it once it is empty
+func (bm *BuildrootManager) writeOverrideFile(lines []string) error {
+	path := bm.GetOverrideFilePath()
+	if strings.TrimSpace(strings.Join(lines, "")) == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
+		}
+		return nil
+	}
+	if err := writeFile(path, strings.Join(lines, "\n")+"\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
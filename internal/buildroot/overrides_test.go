@@ -0,0 +1,111 @@
+package buildroot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type OverridesTestSuite struct {
+	suite.Suite
+	tempDir string
+	bm      *BuildrootManager
+}
+
+func TestOverridesTestSuite(t *testing.T) {
+	suite.Run(t, new(OverridesTestSuite))
+}
+
+func (s *OverridesTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.bm = NewBuildrootManager(&config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+	}, s.tempDir)
+}
+
+func (s *OverridesTestSuite) sourceDir(name string) string {
+	dir := filepath.Join(s.tempDir, "src", name)
+	s.Require().NoError(os.MkdirAll(dir, 0755))
+	return dir
+}
+
+func (s *OverridesTestSuite) TestOverrideFilePath() {
+	s.Equal(filepath.Join(s.tempDir, "build", "buildroot", "local.mk"), s.bm.GetOverrideFilePath())
+
+	outputDir := filepath.Join(s.tempDir, "build", "profiles", "dev", "output")
+	s.bm.SetOutputDir(outputDir)
+	s.Equal(filepath.Join(outputDir, "local.mk"), s.bm.GetOverrideFilePath())
+}
+
+func (s *OverridesTestSuite) TestLinkAndUnlink() {
+	overrides, err := s.bm.SourceOverrides()
+	s.NoError(err)
+	s.Empty(overrides)
+
+	// Entries forge does not manage are kept
+	s.Require().NoError(writeFile(s.bm.GetOverrideFilePath(), "# My overrides\nLINUX_OVERRIDE_SRCDIR := /src/linux\n"))
+
+	busybox := s.sourceDir("busybox")
+	s.NoError(s.bm.LinkSource("busybox", busybox))
+	s.NoError(s.bm.LinkSource("wpa_supplicant", s.sourceDir("wpa")))
+
+	// Linking again replaces the entry
+	s.NoError(s.bm.LinkSource("busybox", s.sourceDir("busybox-next")))
+
+	overrides, err = s.bm.SourceOverrides()
+	s.NoError(err)
+	s.Equal([]SourceOverride{
+		{Package: "linux", Dir: "/src/linux"},
+		{Package: "busybox", Dir: filepath.Join(s.tempDir, "src", "busybox-next")},
+		{Package: "wpa_supplicant", Dir: filepath.Join(s.tempDir, "src", "wpa")},
+	}, overrides)
+
+	removed, err := s.bm.UnlinkSource("busybox")
+	s.NoError(err)
+	s.True(removed)
+	removed, err = s.bm.UnlinkSource("busybox")
+	s.NoError(err)
+	s.False(removed)
+
+	data, err := os.ReadFile(s.bm.GetOverrideFilePath())
+	s.Require().NoError(err)
+	s.Equal("# My overrides\nLINUX_OVERRIDE_SRCDIR := /src/linux\nWPA_SUPPLICANT_OVERRIDE_SRCDIR = "+filepath.Join(s.tempDir, "src", "wpa")+"\n", string(data))
+}
+
+func (s *OverridesTestSuite) TestUnlinkLastRemovesFile() {
+	s.NoError(s.bm.LinkSource("busybox", s.sourceDir("busybox")))
+	_, err := s.bm.UnlinkSource("busybox")
+	s.NoError(err)
+	s.NoFileExists(s.bm.GetOverrideFilePath())
+}
+
+func (s *OverridesTestSuite) TestLinkMissingDir() {
+	err := s.bm.LinkSource("busybox", filepath.Join(s.tempDir, "nowhere"))
+	s.Error(err)
+	s.Contains(err.Error(), "is not a directory")
+}
+
+func (s *OverridesTestSuite) TestBuildPackage() {
+	// A stand-in for Buildroot that records the targets it was asked for
+	s.Require().NoError(os.MkdirAll(s.bm.GetBuildrootDir(), 0755))
+	makefile := "all:\n\t@echo all >> targets\n" +
+		"busybox-rebuild:\n\t@echo busybox-rebuild >> targets\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(s.bm.GetBuildrootDir(), "Makefile"), []byte(makefile), 0644))
+
+	s.NoError(s.bm.BuildPackage(context.Background(), "busybox"))
+	targets, err := os.ReadFile(filepath.Join(s.bm.GetBuildrootDir(), "targets"))
+	s.Require().NoError(err)
+	s.Equal("busybox-rebuild\nall\n", string(targets))
+
+	err = s.bm.BuildPackage(context.Background(), "dropbear")
+	s.Error(err)
+	s.Contains(err.Error(), "failed to rebuild dropbear")
+}
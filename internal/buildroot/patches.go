@@ -0,0 +1,235 @@
+package buildroot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PatchesDir is the project directory holding patches for Buildroot
+// packages, one sub-directory per package, as laid out for
+// BR2_GLOBAL_PATCH_DIR
+const PatchesDir = "patches"
+
+// patchBaseTag marks the commit holding a package's sources as Buildroot
+// patches them, before the project's patches
+const patchBaseTag = "forge-patch-base"
+
+// GetPatchesDir returns the absolute path of the project's patch directory
+func (bm *BuildrootManager) GetPatchesDir() string {
+	dir := filepath.Join(bm.projectDir, PatchesDir)
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// PackageSourceDir asks Buildroot where it extracts a package's sources
+func (bm *BuildrootManager) PackageSourceDir(ctx context.Context, pkg string) (string, error) {
+	variable := packageVariable(pkg) + "_DIR"
+
+	cmd := exec.CommandContext(ctx, "make", bm.makeArgs("-s", "--no-print-directory", "printvars", "VARS="+variable)...)
+	cmd.Dir = bm.GetBuildrootDir()
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query Buildroot: %v", err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), variable+"="); ok && value != "" {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("unknown Buildroot package: %s", pkg)
+}
+
+// StartPatch extracts a package's sources with Buildroot's own patches and
+// turns them into a git repository with the project's patches for the
+// package applied as commits on top. It returns the source directory, where
+// changes are then committed with git, and the patches it applied.
+func (bm *BuildrootManager) StartPatch(ctx context.Context, pkg string) (string, []string, error) {
+	if err := bm.CleanPackage(ctx, pkg); err != nil {
+		return "", nil, err
+	}
+	// Overriding the global patch dir leaves out the project's patches,
+	// which are applied as commits instead
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(pkg+"-patch", "BR2_GLOBAL_PATCH_DIR=")...); err != nil {
+		return "", nil, fmt.Errorf("failed to extract %s: %v", pkg, err)
+	}
+
+	sourceDir, err := bm.PackageSourceDir(ctx, pkg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := runGit(ctx, sourceDir, "init", "-q"); err != nil {
+		return "", nil, err
+	}
+	// Buildroot keeps its stamp files in the source directory
+	exclude := filepath.Join(sourceDir, ".git", "info", "exclude")
+	if err := writeFile(exclude, ".stamp_*\n.applied_patches_list\n.files-list*\n"); err != nil {
+		return "", nil, fmt.Errorf("failed to write %s: %v", exclude, err)
+	}
+	steps := [][]string{
+		{"add", "-A"},
+		{"commit", "-q", "--allow-empty", "-m", pkg + " as patched by Buildroot"},
+		{"tag", patchBaseTag},
+	}
+	for _, step := range steps {
+		if _, err := runGit(ctx, sourceDir, step...); err != nil {
+			return "", nil, err
+		}
+	}
+
+	patches, err := bm.projectPatches(pkg)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, patch := range patches {
+		if err := applyPatch(ctx, sourceDir, patch); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return sourceDir, patches, nil
+}
+
+// FinishPatch writes the commits made in a package's sources since
+// StartPatch as numbered git-format patches into the project, replacing
+// the package's previous patches, and returns the patch files
+func (bm *BuildrootManager) FinishPatch(ctx context.Context, pkg string) ([]string, error) {
+	sourceDir, err := bm.PackageSourceDir(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runGit(ctx, sourceDir, "rev-parse", "-q", "--verify", "refs/tags/"+patchBaseTag); err != nil {
+		return nil, fmt.Errorf("%s is not being patched (run 'forge patch start %s' first)", pkg, pkg)
+	}
+
+	// Build output left by test builds is not part of the changes
+	status, err := runGit(ctx, sourceDir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(status) != "" {
+		return nil, fmt.Errorf("%s has uncommitted changes; commit them with git first", sourceDir)
+	}
+
+	// The new series is written next to the old one and only replaces it
+	// once git is done
+	if err := os.MkdirAll(bm.GetPatchesDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", bm.GetPatchesDir(), err)
+	}
+	tempDir, err := os.MkdirTemp(bm.GetPatchesDir(), "."+pkg+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	output, err := runGit(ctx, sourceDir, "format-patch", "--no-numbered", "--no-signature", "--zero-commit", "-o", tempDir, patchBaseTag)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := bm.projectPatches(pkg)
+	if err != nil {
+		return nil, err
+	}
+	for _, patch := range old {
+		if err := os.Remove(patch); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %v", patch, err)
+		}
+	}
+
+	patchDir := filepath.Join(bm.GetPatchesDir(), pkg)
+	var patches []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		patch := filepath.Join(patchDir, filepath.Base(line))
+		if err := os.MkdirAll(patchDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", patchDir, err)
+		}
+		if err := os.Rename(line, patch); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", patch, err)
+		}
+		patches = append(patches, patch)
+	}
+	if len(patches) == 0 {
+		// Leave no empty directory behind once a package has no patches left
+		os.Remove(patchDir)
+	}
+	return patches, nil
+}
+
+// RebuildPackage rebuilds a package from scratch, so its sources are
+// extracted and patched again
+func (bm *BuildrootManager) RebuildPackage(ctx context.Context, pkg string) error {
+	if err := bm.CleanPackage(ctx, pkg); err != nil {
+		return err
+	}
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(fmt.Sprintf("-j%d", bm.getParallelJobs()), pkg)...); err != nil {
+		return fmt.Errorf("failed to build %s: %v", pkg, err)
+	}
+	return nil
+}
+
+// projectPatches returns the patch files of a package in the project, in
+// the order Buildroot applies them
+func (bm *BuildrootManager) projectPatches(pkg string) ([]string, error) {
+	patches, err := filepath.Glob(filepath.Join(bm.GetPatchesDir(), pkg, "*.patch"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(patches)
+	return patches, nil
+}
+
+// applyPatch commits a patch file onto the sources. Patches that are not in
+// git format are committed under their file name.
+func applyPatch(ctx context.Context, dir, patch string) error {
+	if _, err := runGit(ctx, dir, "am", "-q", "--keep-cr", patch); err == nil {
+		return nil
+	}
+	runGit(ctx, dir, "am", "--abort")
+
+	if _, err := runGit(ctx, dir, "apply", "--index", patch); err != nil {
+		return fmt.Errorf("failed to apply %s: %v", filepath.Base(patch), err)
+	}
+	subject := strings.TrimSuffix(filepath.Base(patch), ".patch")
+	_, err := runGit(ctx, dir, "commit", "-q", "-m", subject)
+	return err
+}
+
+// runGit runs a git command in dir and returns its output. forge's own
+// commits get a fixed identity so no git configuration is needed.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	command := args[0]
+	args = append([]string{"-c", "user.name=Forge", "-c", "user.email=forge@localhost"}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", command, message)
+	}
+	return string(output), nil
+}
+
+// packageVariable returns the prefix of a package's make variables, e.g.
+// WPA_SUPPLICANT for wpa_supplicant and HOST_GO for host-go
+func packageVariable(pkg string) string {
+	return strings.ToUpper(strings.ReplaceAll(pkg, "-", "_"))
+}
@@ -0,0 +1,156 @@
+package buildroot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeBuildroot stands in for the Buildroot targets the patch workflow uses,
+// with a single package foo
+const fakeBuildroot = `FOO_DIR := $(CURDIR)/output/build/foo-1.0
+
+printvars:
+	@echo "FOO_DIR=$(FOO_DIR)"
+
+olddefconfig:
+	@true
+
+foo-dirclean:
+	@rm -rf $(FOO_DIR)
+
+foo-patch:
+	@mkdir -p $(FOO_DIR)
+	@printf 'hello\n' > $(FOO_DIR)/main.c
+	@touch $(FOO_DIR)/.stamp_patched
+
+foo: foo-patch
+	@touch $(FOO_DIR)/.stamp_built
+`
+
+type PatchesTestSuite struct {
+	suite.Suite
+	tempDir string
+	bm      *BuildrootManager
+	ctx     context.Context
+}
+
+func TestPatchesTestSuite(t *testing.T) {
+	suite.Run(t, new(PatchesTestSuite))
+}
+
+func (s *PatchesTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.ctx = context.Background()
+	s.bm = NewBuildrootManager(&config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+	}, s.tempDir)
+
+	s.Require().NoError(os.MkdirAll(s.bm.GetBuildrootDir(), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.bm.GetBuildrootDir(), "Makefile"), []byte(fakeBuildroot), 0644))
+}
+
+// commit changes main.c in the package sources and commits it
+func (s *PatchesTestSuite) commit(sourceDir, content, message string) {
+	s.Require().NoError(os.WriteFile(filepath.Join(sourceDir, "main.c"), []byte(content), 0644))
+	_, err := runGit(s.ctx, sourceDir, "commit", "-q", "-a", "-m", message)
+	s.Require().NoError(err)
+}
+
+func (s *PatchesTestSuite) TestPackageSourceDir() {
+	dir, err := s.bm.PackageSourceDir(s.ctx, "foo")
+	s.NoError(err)
+	s.Equal(filepath.Join(s.bm.GetBuildrootDir(), "output", "build", "foo-1.0"), dir)
+
+	_, err = s.bm.PackageSourceDir(s.ctx, "bar")
+	s.Error(err)
+	s.Contains(err.Error(), "unknown Buildroot package: bar")
+}
+
+func (s *PatchesTestSuite) TestStartAndFinish() {
+	sourceDir, applied, err := s.bm.StartPatch(s.ctx, "foo")
+	s.Require().NoError(err)
+	s.Empty(applied)
+
+	s.commit(sourceDir, "goodbye\n", "Say goodbye")
+	patches, err := s.bm.FinishPatch(s.ctx, "foo")
+	s.Require().NoError(err)
+	s.Equal([]string{filepath.Join(s.bm.GetPatchesDir(), "foo", "0001-Say-goodbye.patch")}, patches)
+
+	patch, err := os.ReadFile(patches[0])
+	s.Require().NoError(err)
+	s.Contains(string(patch), "Subject: [PATCH] Say goodbye")
+	s.Contains(string(patch), "+goodbye")
+	s.NotContains(string(patch), ".stamp_patched")
+
+	// Starting again brings the patch back as a commit to build on
+	sourceDir, applied, err = s.bm.StartPatch(s.ctx, "foo")
+	s.Require().NoError(err)
+	s.Equal(patches, applied)
+	content, err := os.ReadFile(filepath.Join(sourceDir, "main.c"))
+	s.Require().NoError(err)
+	s.Equal("goodbye\n", string(content))
+
+	s.commit(sourceDir, "goodbye world\n", "Greet the world")
+	patches, err = s.bm.FinishPatch(s.ctx, "foo")
+	s.Require().NoError(err)
+	s.Len(patches, 2)
+	s.Equal("0002-Greet-the-world.patch", filepath.Base(patches[1]))
+}
+
+func (s *PatchesTestSuite) TestFinishRequiresStart() {
+	_, err := s.bm.FinishPatch(s.ctx, "foo")
+	s.Error(err)
+	s.Contains(err.Error(), "foo is not being patched (run 'forge patch start foo' first)")
+}
+
+func (s *PatchesTestSuite) TestFinishRequiresCommit() {
+	sourceDir, _, err := s.bm.StartPatch(s.ctx, "foo")
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(sourceDir, "main.c"), []byte("changed\n"), 0644))
+
+	_, err = s.bm.FinishPatch(s.ctx, "foo")
+	s.Error(err)
+	s.Contains(err.Error(), "has uncommitted changes")
+}
+
+func (s *PatchesTestSuite) TestFinishWithoutCommits() {
+	patchDir := filepath.Join(s.bm.GetPatchesDir(), "foo")
+	s.Require().NoError(os.MkdirAll(patchDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(patchDir, "0001-old.patch"), []byte("not a patch\n"), 0644))
+
+	// A patch that no longer applies stops the start
+	_, _, err := s.bm.StartPatch(s.ctx, "foo")
+	s.Error(err)
+	s.Contains(err.Error(), "failed to apply 0001-old.patch")
+
+	s.Require().NoError(os.Remove(filepath.Join(patchDir, "0001-old.patch")))
+	_, _, err = s.bm.StartPatch(s.ctx, "foo")
+	s.Require().NoError(err)
+
+	patches, err := s.bm.FinishPatch(s.ctx, "foo")
+	s.Require().NoError(err)
+	s.Empty(patches)
+	s.NoDirExists(patchDir)
+}
+
+func (s *PatchesTestSuite) TestGlobalPatchDir() {
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.bm.GetPatchesDir(), "foo"), 0755))
+
+	_, err := s.bm.GenerateConfig(s.ctx)
+	s.Require().NoError(err)
+
+	defconfig, err := os.ReadFile(s.bm.GetDefconfigPath())
+	s.Require().NoError(err)
+	s.Contains(string(defconfig), `BR2_GLOBAL_PATCH_DIR="`+s.bm.GetPatchesDir()+`"`)
+
+	s.NoError(s.bm.RebuildPackage(s.ctx, "foo"))
+}
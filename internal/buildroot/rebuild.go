@@ -0,0 +1,403 @@
+package buildroot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sst/forge/internal/apps"
+	"github.com/sst/forge/internal/config"
+)
+
+// Actions a targeted rebuild can run on a package
+const (
+	Rebuild     = "rebuild"     // Build and install again from the extracted sources
+	Reconfigure = "reconfigure" // Configure, build and install again
+	Dirclean    = "dirclean"    // Remove the build directory so it is extracted and patched again
+)
+
+// KernelPackage is the Buildroot package building the Linux kernel
+const KernelPackage = "linux"
+
+// rootfsTypes maps the .config symbols of root filesystem images to the
+// targets generating them
+var rootfsTypes = map[string]string{
+	"BR2_TARGET_ROOTFS_BTRFS":    "rootfs-btrfs",
+	"BR2_TARGET_ROOTFS_CPIO":     "rootfs-cpio",
+	"BR2_TARGET_ROOTFS_CRAMFS":   "rootfs-cramfs",
+	"BR2_TARGET_ROOTFS_EROFS":    "rootfs-erofs",
+	"BR2_TARGET_ROOTFS_EXT2":     "rootfs-ext2",
+	"BR2_TARGET_ROOTFS_F2FS":     "rootfs-f2fs",
+	"BR2_TARGET_ROOTFS_ISO9660":  "rootfs-iso9660",
+	"BR2_TARGET_ROOTFS_JFFS2":    "rootfs-jffs2",
+	"BR2_TARGET_ROOTFS_ROMFS":    "rootfs-romfs",
+	"BR2_TARGET_ROOTFS_SQUASHFS": "rootfs-squashfs",
+	"BR2_TARGET_ROOTFS_TAR":      "rootfs-tar",
+	"BR2_TARGET_ROOTFS_UBI":      "rootfs-ubi",
+	"BR2_TARGET_ROOTFS_UBIFS":    "rootfs-ubifs",
+}
+
+// PackageTarget returns the make target running an action on a package,
+// such as busybox-reconfigure
+func PackageTarget(pkg, action string) string {
+	return pkg + "-" + action
+}
+
+// RunTargets runs make targets one after the other, then the default
+// target, which builds whatever they invalidated and regenerates the root
+// filesystem and images
+func (bm *BuildrootManager) RunTargets(ctx context.Context, targets ...string) error {
+	jobs := fmt.Sprintf("-j%d", bm.getParallelJobs())
+	for _, target := range targets {
+		if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(jobs, target)...); err != nil {
+			return fmt.Errorf("make %s failed: %v", target, err)
+		}
+	}
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(jobs)...); err != nil {
+		return fmt.Errorf("failed to regenerate images: %v", err)
+	}
+	return nil
+}
+
+// RootfsTargets returns the targets generating the root filesystem images
+// the .config enables
+func (bm *BuildrootManager) RootfsTargets() ([]string, error) {
+	data, err := os.ReadFile(bm.GetConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", bm.GetConfigPath(), err)
+	}
+	symbols := config.ParseConfigSymbols(data)
+
+	var targets []string
+	for symbol, target := range rootfsTypes {
+		if symbols[symbol] == "y" {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// RebuildRootfs regenerates the root filesystem images from the packages
+// already installed in the target directory, without building anything,
+// and runs the post-image scripts
+func (bm *BuildrootManager) RebuildRootfs(ctx context.Context) error {
+	targets, err := bm.RootfsTargets()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("the Buildroot configuration enables no root filesystem image")
+	}
+
+	for _, target := range append(targets, "target-post-image") {
+		if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs(target)...); err != nil {
+			return fmt.Errorf("make %s failed: %v", target, err)
+		}
+	}
+	return nil
+}
+
+// BuildState is what a build was configured with. It is saved after each
+// full build so the next one can tell what forge.yml changes touched.
+type BuildState struct {
+	Symbols []config.DefconfigSymbol `json:"symbols"`
+	Kernel  map[string]string        `json:"kernel,omitempty"`
+	Patches map[string]string        `json:"patches,omitempty"` // Package -> checksum of its patches
+}
+
+// RebuildPlan is what has to be rebuilt for the configuration to match
+// forge.yml again since the last build
+type RebuildPlan struct {
+	Reconfigure []string // Packages whose options changed
+	Dirclean    []string // Packages whose patches changed
+	Added       []string // Packages the next build adds
+	Removed     []string // Packages left in the target until a clean build
+	Other       []string // Other changed symbols, applied when the images are regenerated
+	Clean       []string // Changes only a clean build applies
+}
+
+// Empty reports whether nothing changed since the last build
+func (p *RebuildPlan) Empty() bool {
+	return len(p.Reconfigure) == 0 && len(p.Dirclean) == 0 && len(p.Added) == 0 &&
+		len(p.Removed) == 0 && len(p.Other) == 0 && len(p.Clean) == 0
+}
+
+// Targets returns the make targets that carry out the plan. A package that
+// is cleaned is configured again anyway.
+func (p *RebuildPlan) Targets() []string {
+	var targets []string
+	for _, pkg := range p.Dirclean {
+		targets = append(targets, PackageTarget(pkg, Dirclean))
+	}
+	for _, pkg := range p.Reconfigure {
+		if !containsString(p.Dirclean, pkg) {
+			targets = append(targets, PackageTarget(pkg, Reconfigure))
+		}
+	}
+	return targets
+}
+
+// GetBuildStatePath returns where the state of the last full build is kept
+func (bm *BuildrootManager) GetBuildStatePath() string {
+	return filepath.Join(bm.GetOutputDir(), "forge", "build-state.json")
+}
+
+// CurrentBuildState returns the state a build of the configuration would
+// have now
+func (bm *BuildrootManager) CurrentBuildState() (*BuildState, error) {
+	defconfig, err := bm.config.Defconfig()
+	if err != nil {
+		return nil, err
+	}
+	fragment, err := bm.config.KernelFragment()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &BuildState{
+		Symbols: defconfig.Symbols(),
+		Kernel:  make(map[string]string),
+		Patches: make(map[string]string),
+	}
+	for _, symbol := range fragment.Symbols() {
+		state.Kernel[symbol.Name] = symbol.Value
+	}
+
+	dirs, err := os.ReadDir(bm.GetPatchesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", bm.GetPatchesDir(), err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || strings.HasPrefix(dir.Name(), ".") {
+			continue
+		}
+		sum, err := checksumDir(filepath.Join(bm.GetPatchesDir(), dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		state.Patches[dir.Name()] = sum
+	}
+
+	return state, nil
+}
+
+// SaveBuildState records the current state as the one of the last build
+func (bm *BuildrootManager) SaveBuildState() error {
+	state, err := bm.CurrentBuildState()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build state: %v", err)
+	}
+	if err := writeFile(bm.GetBuildStatePath(), string(data)+"\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %v", bm.GetBuildStatePath(), err)
+	}
+	return nil
+}
+
+// LoadBuildState returns the state of the last full build, or nil if there
+// was none
+func (bm *BuildrootManager) LoadBuildState() (*BuildState, error) {
+	data, err := os.ReadFile(bm.GetBuildStatePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", bm.GetBuildStatePath(), err)
+	}
+
+	var state BuildState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", bm.GetBuildStatePath(), err)
+	}
+	return &state, nil
+}
+
+// PlanRebuild works out what the changes to forge.yml and the project's
+// patches since the last full build touch
+func (bm *BuildrootManager) PlanRebuild() (*RebuildPlan, error) {
+	previous, err := bm.LoadBuildState()
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		return nil, fmt.Errorf("no record of a previous build (run 'forge build' first)")
+	}
+	current, err := bm.CurrentBuildState()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RebuildPlan{}
+	add := func(list *[]string, item string) {
+		if !containsString(*list, item) {
+			*list = append(*list, item)
+		}
+	}
+
+	before := symbolMap(previous.Symbols)
+	after := symbolMap(current.Symbols)
+	for _, name := range changedKeys(valueMap(previous.Symbols), valueMap(current.Symbols)) {
+		symbol, ok := after[name]
+		if !ok {
+			symbol = before[name]
+		}
+
+		switch {
+		case strings.HasPrefix(symbol.Source, "architecture ") || symbol.Source == "toolchain":
+			add(&plan.Clean, "the architecture or toolchain changed")
+		case strings.HasPrefix(name, "BR2_INIT_"):
+			add(&plan.Clean, "the init system changed")
+		case strings.HasPrefix(name, "BR2_KERNEL_HEADERS_") || strings.HasPrefix(name, "BR2_PACKAGE_HOST_LINUX_HEADERS_"):
+			add(&plan.Clean, "the kernel headers changed")
+		case strings.HasPrefix(name, "BR2_LINUX_KERNEL"):
+			add(&plan.Reconfigure, KernelPackage)
+		case strings.HasPrefix(name, "BR2_PACKAGE_"):
+			pkg := bm.packageForSymbol(name)
+			switch {
+			case pkg == "":
+				add(&plan.Other, name)
+			case name != "BR2_PACKAGE_"+strings.ToUpper(strings.ReplaceAll(pkg, "-", "_")):
+				// An option of the package
+				add(&plan.Reconfigure, pkg)
+			case after[name].Value == "y":
+				add(&plan.Added, pkg)
+			default:
+				add(&plan.Removed, pkg)
+			}
+		default:
+			add(&plan.Other, name)
+		}
+	}
+
+	if len(changedKeys(previous.Kernel, current.Kernel)) > 0 {
+		add(&plan.Reconfigure, KernelPackage)
+	}
+	for _, pkg := range changedKeys(previous.Patches, current.Patches) {
+		add(&plan.Dirclean, pkg)
+	}
+
+	// Packages that are new get configured from scratch anyway, and the
+	// options of removed ones no longer matter
+	var reconfigure []string
+	for _, pkg := range plan.Reconfigure {
+		if !containsString(plan.Added, pkg) && !containsString(plan.Removed, pkg) {
+			reconfigure = append(reconfigure, pkg)
+		}
+	}
+	plan.Reconfigure = reconfigure
+
+	return plan, nil
+}
+
+// packageForSymbol finds the package a BR2_PACKAGE_ symbol belongs to by
+// looking for the package's .mk file under ever shorter prefixes of the
+// symbol, so BR2_PACKAGE_NGINX_HTTP_SSL belongs to nginx. It returns an
+// empty string if no package matches.
+func (bm *BuildrootManager) packageForSymbol(symbol string) string {
+	parts := strings.Split(strings.ToLower(strings.TrimPrefix(symbol, "BR2_PACKAGE_")), "_")
+	for n := len(parts); n > 0; n-- {
+		candidate := strings.Join(parts[:n], "_")
+		for _, name := range []string{candidate, strings.ReplaceAll(candidate, "_", "-")} {
+			if bm.isPackage(name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// isPackage reports whether Buildroot or the project's external tree has a
+// package with the given name
+func (bm *BuildrootManager) isPackage(name string) bool {
+	patterns := []string{
+		filepath.Join(bm.GetBuildrootDir(), "package", name, name+".mk"),
+		filepath.Join(bm.GetBuildrootDir(), "package", "*", name, name+".mk"),
+		filepath.Join(bm.projectDir, apps.ExternalDir, "package", name, name+".mk"),
+	}
+	for _, pattern := range patterns {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checksumDir hashes the names and contents of the files in a directory
+func checksumDir(dir string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.ToSlash(rel), len(data))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// symbolMap indexes defconfig symbols by name
+func symbolMap(symbols []config.DefconfigSymbol) map[string]config.DefconfigSymbol {
+	index := make(map[string]config.DefconfigSymbol, len(symbols))
+	for _, symbol := range symbols {
+		index[symbol.Name] = symbol
+	}
+	return index
+}
+
+// valueMap maps defconfig symbols to their values. Disabled symbols are
+// left out, so disabling one counts the same as dropping it.
+func valueMap(symbols []config.DefconfigSymbol) map[string]string {
+	values := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		if symbol.Value != "n" {
+			values[symbol.Name] = symbol.Value
+		}
+	}
+	return values
+}
+
+// changedKeys returns the keys whose values differ between two maps, sorted
+func changedKeys(before, after map[string]string) []string {
+	var keys []string
+	for key, value := range before {
+		if other, ok := after[key]; !ok || other != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsString checks if a slice contains a string
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
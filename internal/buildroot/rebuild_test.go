@@ -0,0 +1,152 @@
+package buildroot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// loggingBuildroot records every target make is asked to build
+const loggingBuildroot = `all:
+	@echo all >> $(CURDIR)/targets.log
+
+.DEFAULT:
+	@echo $@ >> $(CURDIR)/targets.log
+`
+
+type RebuildTestSuite struct {
+	suite.Suite
+	tempDir string
+	cfg     *config.Config
+	bm      *BuildrootManager
+	ctx     context.Context
+}
+
+func TestRebuildTestSuite(t *testing.T) {
+	suite.Run(t, new(RebuildTestSuite))
+}
+
+func (s *RebuildTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.ctx = context.Background()
+	s.cfg = &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"nginx"},
+		Kernel:        config.KernelConfig{Version: "6.1"},
+	}
+	s.bm = NewBuildrootManager(s.cfg, s.tempDir)
+
+	s.Require().NoError(os.MkdirAll(s.bm.GetBuildrootDir(), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.bm.GetBuildrootDir(), "Makefile"), []byte(loggingBuildroot), 0644))
+	for _, pkg := range []string{"busybox", "dropbear", "nginx", "wpa_supplicant"} {
+		s.Require().NoError(writeFile(filepath.Join(s.bm.GetBuildrootDir(), "package", pkg, pkg+".mk"), ""))
+	}
+}
+
+// targets returns the targets make ran so far
+func (s *RebuildTestSuite) targets() []string {
+	data, err := os.ReadFile(filepath.Join(s.bm.GetBuildrootDir(), "targets.log"))
+	s.Require().NoError(err)
+	return strings.Fields(string(data))
+}
+
+func (s *RebuildTestSuite) TestPackageTarget() {
+	s.Equal("busybox-rebuild", PackageTarget("busybox", Rebuild))
+	s.Equal("linux-reconfigure", PackageTarget(KernelPackage, Reconfigure))
+	s.Equal("dropbear-dirclean", PackageTarget("dropbear", Dirclean))
+}
+
+func (s *RebuildTestSuite) TestRunTargets() {
+	s.NoError(s.bm.RunTargets(s.ctx, "busybox-rebuild", "linux-rebuild"))
+	s.Equal([]string{"busybox-rebuild", "linux-rebuild", "all"}, s.targets())
+}
+
+func (s *RebuildTestSuite) TestRebuildRootfs() {
+	err := s.bm.RebuildRootfs(s.ctx)
+	s.Error(err)
+
+	s.Require().NoError(os.WriteFile(s.bm.GetConfigPath(), []byte("BR2_TARGET_ROOTFS_SQUASHFS=y\nBR2_TARGET_ROOTFS_EXT2=y\n# BR2_TARGET_ROOTFS_TAR is not set\n"), 0644))
+	targets, err := s.bm.RootfsTargets()
+	s.NoError(err)
+	s.Equal([]string{"rootfs-ext2", "rootfs-squashfs"}, targets)
+
+	s.NoError(s.bm.RebuildRootfs(s.ctx))
+	s.Equal([]string{"rootfs-ext2", "rootfs-squashfs", "target-post-image"}, s.targets())
+}
+
+func (s *RebuildTestSuite) TestPlanRequiresBuild() {
+	_, err := s.bm.PlanRebuild()
+	s.Error(err)
+	s.Contains(err.Error(), "run 'forge build' first")
+}
+
+func (s *RebuildTestSuite) TestBuildState() {
+	s.Require().NoError(s.bm.SaveBuildState())
+	state, err := s.bm.LoadBuildState()
+	s.Require().NoError(err)
+	s.Contains(state.Symbols, config.DefconfigSymbol{Name: "BR2_PACKAGE_NGINX", Value: "y", Source: "package nginx"})
+
+	plan, err := s.bm.PlanRebuild()
+	s.NoError(err)
+	s.True(plan.Empty())
+}
+
+func (s *RebuildTestSuite) TestPlanRebuild() {
+	s.Require().NoError(s.bm.SaveBuildState())
+
+	s.cfg.Features = []string{"web-server"}
+	s.cfg.Packages = append(s.cfg.Packages, "dropbear")
+	s.cfg.Kernel.Config = map[string]string{"USB_SERIAL": "m"}
+	s.Require().NoError(writeFile(filepath.Join(s.bm.GetPatchesDir(), "busybox", "0001-fix.patch"), "fix\n"))
+
+	plan, err := s.bm.PlanRebuild()
+	s.Require().NoError(err)
+	s.Equal([]string{"nginx", KernelPackage}, plan.Reconfigure)
+	s.Equal([]string{"busybox"}, plan.Dirclean)
+	s.Equal([]string{"dropbear"}, plan.Added)
+	s.Empty(plan.Removed)
+	s.Empty(plan.Clean)
+	s.Equal([]string{"busybox-dirclean", "nginx-reconfigure", "linux-reconfigure"}, plan.Targets())
+
+	// Dropping a package leaves it for a clean build to remove
+	s.Require().NoError(s.bm.SaveBuildState())
+	s.cfg.Features = nil
+	s.cfg.Packages = []string{"dropbear"}
+	plan, err = s.bm.PlanRebuild()
+	s.Require().NoError(err)
+	s.Equal([]string{"nginx"}, plan.Removed)
+	s.Empty(plan.Reconfigure)
+	s.Empty(plan.Targets())
+}
+
+func (s *RebuildTestSuite) TestPlanRebuildNeedsClean() {
+	s.Require().NoError(s.bm.SaveBuildState())
+
+	s.cfg.Architecture = "aarch64"
+	plan, err := s.bm.PlanRebuild()
+	s.Require().NoError(err)
+	s.Equal([]string{"the architecture or toolchain changed"}, plan.Clean)
+
+	// New kernel headers mean a new toolchain
+	s.cfg.Architecture = "x86_64"
+	s.cfg.Kernel.Version = "6.6"
+	plan, err = s.bm.PlanRebuild()
+	s.Require().NoError(err)
+	s.Equal([]string{"the kernel headers changed"}, plan.Clean)
+	s.Equal([]string{KernelPackage}, plan.Reconfigure)
+}
+
+func (s *RebuildTestSuite) TestPackageForSymbol() {
+	s.Equal("nginx", s.bm.packageForSymbol("BR2_PACKAGE_NGINX_HTTP"))
+	s.Equal("wpa_supplicant", s.bm.packageForSymbol("BR2_PACKAGE_WPA_SUPPLICANT_AP_SUPPORT"))
+	s.Equal("", s.bm.packageForSymbol("BR2_PACKAGE_UNKNOWN"))
+}
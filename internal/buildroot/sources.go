@@ -0,0 +1,447 @@
+package buildroot
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sst/forge/internal/apps"
+)
+
+// DownloadDir is where Buildroot keeps downloaded sources unless forge.yml
+// sets buildroot.download_dir
+const DownloadDir = "dl"
+
+// Entries of a source archive besides the package sources under dl/
+const (
+	sourcesManifestName  = "manifest.json"
+	sourcesBuildrootName = "buildroot.tar.gz"
+)
+
+// SourceFile is a source archive Buildroot downloads for a package
+type SourceFile struct {
+	Package string
+	Path    string // Relative to the download directory
+}
+
+// SourcesManifest describes a source archive written by PackSources
+type SourcesManifest struct {
+	Buildroot string            `json:"buildroot"` // Buildroot version the sources are for
+	Files     map[string]string `json:"files"`     // Path in the archive -> SHA-256
+}
+
+// GetDownloadDir returns the absolute path of the directory Buildroot
+// downloads sources to (BR2_DL_DIR), which outlives the build directory and
+// can be shared between projects
+func (bm *BuildrootManager) GetDownloadDir() string {
+	dir := bm.config.Buildroot.DownloadDir
+	if dir == "" {
+		dir = DownloadDir
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(bm.projectDir, dir)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// IsOffline reports whether builds must not touch the network, which is the
+// case when forge.yml asks for it or builds from a source archive
+func (bm *BuildrootManager) IsOffline() bool {
+	return bm.config.Buildroot.Offline || bm.config.Buildroot.Sources != ""
+}
+
+// GetBuildrootTarball returns where the Buildroot release tarball is kept
+func (bm *BuildrootManager) GetBuildrootTarball() string {
+	return filepath.Join(bm.buildDir, "buildroot-"+bm.buildrootVersion()+".tar.gz")
+}
+
+// Sources asks Buildroot which source archives the configuration needs
+func (bm *BuildrootManager) Sources(ctx context.Context) ([]SourceFile, error) {
+	cmd := exec.CommandContext(ctx, "make", bm.makeArgs("-s", "--no-print-directory", "show-info")...)
+	cmd.Dir = bm.GetBuildrootDir()
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Buildroot: %v", err)
+	}
+
+	var info map[string]struct {
+		DlDir     string `json:"dl_dir"`
+		Downloads []struct {
+			Source string `json:"source"`
+		} `json:"downloads"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse Buildroot package info: %v", err)
+	}
+
+	// Host and target variants of a package share their sources
+	seen := make(map[string]bool)
+	var files []SourceFile
+	for pkg, details := range info {
+		for _, download := range details.Downloads {
+			file := path.Join(details.DlDir, download.Source)
+			if download.Source == "" || seen[file] {
+				continue
+			}
+			seen[file] = true
+			files = append(files, SourceFile{Package: strings.TrimPrefix(pkg, "host-"), Path: file})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// MissingSources returns the sources the configuration needs that are not
+// in the download directory, so an offline build can fail before it starts
+func (bm *BuildrootManager) MissingSources(ctx context.Context) ([]SourceFile, error) {
+	files, err := bm.Sources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []SourceFile
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(bm.GetDownloadDir(), filepath.FromSlash(file.Path))); err != nil {
+			missing = append(missing, file)
+		}
+	}
+	return missing, nil
+}
+
+// DownloadSources downloads every source the configuration needs with
+// make source, and vendors the Go modules of the apps
+func (bm *BuildrootManager) DownloadSources(ctx context.Context) error {
+	if err := bm.VendorApps(ctx); err != nil {
+		return err
+	}
+	if err := bm.runMake(ctx, bm.GetBuildrootDir(), bm.makeArgs("source")...); err != nil {
+		return fmt.Errorf("failed to download sources: %v", err)
+	}
+	return nil
+}
+
+// VendorApps vendors the Go modules of the apps in forge.yml, which
+// Buildroot does not download like other Go packages. See
+// apps.VendorGoModules.
+func (bm *BuildrootManager) VendorApps(ctx context.Context) error {
+	return apps.VendorGoModules(ctx, bm.config, bm.projectDir, bm.IsOffline())
+}
+
+// PackSources writes the Buildroot tarball and the sources the
+// configuration needs into a tar.gz archive a build without network access
+// can use as buildroot.sources. A manifest with the checksum of every file
+// comes first in the archive, and the checksum of the archive itself is
+// written next to it in sha256sum format.
+func (bm *BuildrootManager) PackSources(ctx context.Context, archive string) (*SourcesManifest, error) {
+	files, err := bm.Sources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := bm.ensureBuildrootTarball(ctx); err != nil {
+		return nil, err
+	}
+
+	// Archive entries and the files they come from
+	entries := map[string]string{sourcesBuildrootName: bm.GetBuildrootTarball()}
+	var missing []string
+	for _, file := range files {
+		source := filepath.Join(bm.GetDownloadDir(), filepath.FromSlash(file.Path))
+		if _, err := os.Stat(source); err != nil {
+			missing = append(missing, file.Path)
+			continue
+		}
+		entries[path.Join(DownloadDir, file.Path)] = source
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("sources missing from %s: %s", bm.GetDownloadDir(), strings.Join(missing, ", "))
+	}
+
+	manifest := &SourcesManifest{Buildroot: bm.buildrootVersion(), Files: make(map[string]string)}
+	names := make([]string, 0, len(entries))
+	for name, source := range entries {
+		sum, err := checksumFile(source)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files[name] = sum
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %v", err)
+	}
+
+	// Written next to the archive first so a failure leaves no partial archive
+	temp := archive + ".tmp"
+	if err := writeSourcesArchive(temp, data, names, entries); err != nil {
+		os.Remove(temp)
+		return nil, fmt.Errorf("failed to write %s: %v", archive, err)
+	}
+	if err := os.Rename(temp, archive); err != nil {
+		os.Remove(temp)
+		return nil, fmt.Errorf("failed to write %s: %v", archive, err)
+	}
+
+	sum, err := checksumFile(archive)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(archive+".sha256", fmt.Sprintf("%s  %s\n", sum, filepath.Base(archive))); err != nil {
+		return nil, fmt.Errorf("failed to write %s.sha256: %v", archive, err)
+	}
+
+	return manifest, nil
+}
+
+// ImportSources unpacks an archive written by PackSources: the Buildroot
+// tarball into the build directory and the package sources into the
+// download directory. Every file is checked against the manifest. Importing
+// the same archive again does nothing.
+func (bm *BuildrootManager) ImportSources(archive string) error {
+	if !filepath.IsAbs(archive) {
+		archive = filepath.Join(bm.projectDir, archive)
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open source archive: %v", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s is not a source archive: %v", archive, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != sourcesManifestName {
+		return fmt.Errorf("%s is not a source archive: no %s", archive, sourcesManifestName)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", archive, err)
+	}
+	var manifest SourcesManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse the manifest of %s: %v", archive, err)
+	}
+	if manifest.Buildroot != bm.buildrootVersion() {
+		return fmt.Errorf("%s has sources for Buildroot %s, but forge.yml uses %s", archive, manifest.Buildroot, bm.buildrootVersion())
+	}
+
+	stamp := filepath.Join(bm.buildDir, "sources-"+sourcesManifestName)
+	if imported, err := os.ReadFile(stamp); err == nil && bytes.Equal(imported, data) {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", archive, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		sum, ok := manifest.Files[header.Name]
+		if !ok {
+			return fmt.Errorf("%s contains %s, which is not in its manifest", archive, header.Name)
+		}
+		var dest string
+		if header.Name == sourcesBuildrootName {
+			dest = bm.GetBuildrootTarball()
+		} else {
+			rel, ok := strings.CutPrefix(header.Name, DownloadDir+"/")
+			if !ok || path.Clean(rel) != rel || strings.HasPrefix(rel, "../") {
+				return fmt.Errorf("%s contains an unexpected file: %s", archive, header.Name)
+			}
+			dest = filepath.Join(bm.GetDownloadDir(), filepath.FromSlash(rel))
+		}
+
+		if err := extractVerified(tr, dest, sum); err != nil {
+			return fmt.Errorf("failed to import %s: %v", header.Name, err)
+		}
+		seen[header.Name] = true
+	}
+
+	for name := range manifest.Files {
+		if !seen[name] {
+			return fmt.Errorf("%s is incomplete: %s is missing", archive, name)
+		}
+	}
+
+	if err := writeFile(stamp, string(data)); err != nil {
+		return fmt.Errorf("failed to write %s: %v", stamp, err)
+	}
+	return nil
+}
+
+// StableVersion is the Buildroot release that buildroot.version stable, the
+// default, builds with: the current long-term support release
+const StableVersion = "2025.02"
+
+// buildrootVersion returns the Buildroot version forge.yml asks for
+func (bm *BuildrootManager) buildrootVersion() string {
+	switch bm.config.Buildroot.Version {
+	case "", "stable", "latest":
+		return StableVersion
+	}
+	return bm.config.Buildroot.Version
+}
+
+// BuildrootAvailable reports whether Buildroot is extracted, or can be from
+// the release tarball or the source archive without network access
+func (bm *BuildrootManager) BuildrootAvailable() bool {
+	for _, path := range []string{bm.GetBuildrootDir(), bm.GetBuildrootTarball()} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return bm.config.Buildroot.Sources != ""
+}
+
+// ensureBuildrootTarball downloads the Buildroot release tarball unless it
+// is already there
+func (bm *BuildrootManager) ensureBuildrootTarball(ctx context.Context) error {
+	tarPath := bm.GetBuildrootTarball()
+	if _, err := os.Stat(tarPath); err == nil {
+		return nil
+	}
+	if bm.IsOffline() {
+		return fmt.Errorf("Buildroot %s is not downloaded and the build is offline (run 'forge fetch' where there is network access)", bm.buildrootVersion())
+	}
+
+	downloadURL := fmt.Sprintf("https://buildroot.org/downloads/buildroot-%s.tar.gz", bm.buildrootVersion())
+
+	if err := os.MkdirAll(bm.buildDir, 0755); err != nil {
+		return fmt.Errorf("failed to create build directory: %v", err)
+	}
+	if err := bm.downloadFile(ctx, downloadURL, tarPath+".tmp"); err != nil {
+		os.Remove(tarPath + ".tmp")
+		return fmt.Errorf("failed to download Buildroot: %v", err)
+	}
+	return os.Rename(tarPath+".tmp", tarPath)
+}
+
+// writeSourcesArchive writes the manifest and then the given entries into
+// a tar.gz archive
+func writeSourcesArchive(archive string, manifest []byte, names []string, entries map[string]string) error {
+	out, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	header := &tar.Header{Name: sourcesManifestName, Mode: 0644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := addTarFile(tw, name, entries[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// addTarFile copies a file into a tar archive under the given name
+func addTarFile(tw *tar.Writer, name, source string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// extractVerified writes a file from an archive, keeping it only if its
+// SHA-256 matches
+func extractVerified(r io.Reader, dest, sum string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	temp := dest + ".tmp"
+	out, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, hash), r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && hex.EncodeToString(hash.Sum(nil)) != sum {
+		err = fmt.Errorf("checksum mismatch")
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, dest)
+}
+
+// checksumFile returns the SHA-256 of a file
+func checksumFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,204 @@
+package buildroot
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// fetchingBuildroot stands in for the Buildroot targets forge fetch uses,
+// with a package foo that has a host variant and a virtual package
+const fetchingBuildroot = `DL := $(CURDIR)/../../dl
+
+show-info:
+	@echo '{"foo":{"dl_dir":"foo","downloads":[{"source":"foo-1.0.tar.gz","uris":["https+https://example.com/foo"]}]},'
+	@echo '"host-foo":{"dl_dir":"foo","downloads":[{"source":"foo-1.0.tar.gz","uris":[]}]},'
+	@echo '"toolchain":{"dl_dir":"toolchain","downloads":[]}}'
+
+source:
+	@mkdir -p $(DL)/foo
+	@printf 'foo sources\n' > $(DL)/foo/foo-1.0.tar.gz
+
+olddefconfig:
+	@true
+`
+
+type SourcesTestSuite struct {
+	suite.Suite
+	tempDir string
+	cfg     *config.Config
+	bm      *BuildrootManager
+	ctx     context.Context
+}
+
+func TestSourcesTestSuite(t *testing.T) {
+	suite.Run(t, new(SourcesTestSuite))
+}
+
+func (s *SourcesTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.ctx = context.Background()
+	s.cfg = s.config()
+	s.bm = NewBuildrootManager(s.cfg, filepath.Join(s.tempDir, "online"))
+
+	s.Require().NoError(writeFile(filepath.Join(s.bm.GetBuildrootDir(), "Makefile"), fetchingBuildroot))
+}
+
+func (s *SourcesTestSuite) config() *config.Config {
+	return &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Buildroot:     config.BuildrootConfig{Version: "2024.02.1"},
+	}
+}
+
+// writeTarball writes a Buildroot release tarball holding the fake Makefile
+func (s *SourcesTestSuite) writeTarball(path string) {
+	release := filepath.Join(s.tempDir, "release")
+	s.Require().NoError(writeFile(filepath.Join(release, "buildroot-2024.02.1", "Makefile"), fetchingBuildroot))
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	s.Require().NoError(exec.Command("tar", "-czf", path, "-C", release, "buildroot-2024.02.1").Run())
+}
+
+func (s *SourcesTestSuite) TestDownloadDir() {
+	s.Equal(filepath.Join(s.tempDir, "online", "dl"), s.bm.GetDownloadDir())
+
+	s.cfg.Buildroot.DownloadDir = "../shared/dl"
+	s.Equal(filepath.Join(s.tempDir, "shared", "dl"), s.bm.GetDownloadDir())
+
+	s.cfg.Buildroot.DownloadDir = "/srv/dl"
+	s.Equal("/srv/dl", s.bm.GetDownloadDir())
+}
+
+func (s *SourcesTestSuite) TestStableVersion() {
+	s.Equal(filepath.Join(s.tempDir, "online", "build", "buildroot-2024.02.1.tar.gz"), s.bm.GetBuildrootTarball())
+
+	for _, version := range []string{"", "stable", "latest"} {
+		s.cfg.Buildroot.Version = version
+		s.Equal(filepath.Join(s.tempDir, "online", "build", "buildroot-"+StableVersion+".tar.gz"), s.bm.GetBuildrootTarball(), version)
+	}
+}
+
+func (s *SourcesTestSuite) TestMissingSources() {
+	sources, err := s.bm.Sources(s.ctx)
+	s.Require().NoError(err)
+	s.Equal([]SourceFile{{Package: "foo", Path: "foo/foo-1.0.tar.gz"}}, sources)
+
+	missing, err := s.bm.MissingSources(s.ctx)
+	s.Require().NoError(err)
+	s.Equal(sources, missing)
+
+	s.Require().NoError(s.bm.DownloadSources(s.ctx))
+	missing, err = s.bm.MissingSources(s.ctx)
+	s.NoError(err)
+	s.Empty(missing)
+}
+
+func (s *SourcesTestSuite) TestOfflineConfig() {
+	_, err := s.bm.GenerateConfig(s.ctx)
+	s.Require().NoError(err)
+	defconfig, err := os.ReadFile(s.bm.GetDefconfigPath())
+	s.Require().NoError(err)
+	s.Contains(string(defconfig), `BR2_DL_DIR="`+s.bm.GetDownloadDir()+`"`)
+	s.NotContains(string(defconfig), "BR2_PRIMARY_SITE")
+
+	s.cfg.Buildroot.Offline = true
+	_, err = s.bm.GenerateConfig(s.ctx)
+	s.Require().NoError(err)
+	defconfig, err = os.ReadFile(s.bm.GetDefconfigPath())
+	s.Require().NoError(err)
+	s.Contains(string(defconfig), `BR2_PRIMARY_SITE="file://`+s.bm.GetDownloadDir()+`"`)
+	s.Contains(string(defconfig), "BR2_PRIMARY_SITE_ONLY=y")
+	s.Contains(string(defconfig), `BR2_BACKUP_SITE=""`)
+}
+
+func (s *SourcesTestSuite) TestOfflineWithoutBuildroot() {
+	cfg := s.config()
+	cfg.Buildroot.Offline = true
+	bm := NewBuildrootManager(cfg, filepath.Join(s.tempDir, "offline"))
+
+	err := bm.DownloadBuildroot(s.ctx)
+	s.Error(err)
+	s.Contains(err.Error(), "Buildroot 2024.02.1 is not downloaded and the build is offline")
+}
+
+func (s *SourcesTestSuite) TestPackAndImport() {
+	s.writeTarball(s.bm.GetBuildrootTarball())
+	s.Require().NoError(s.bm.DownloadSources(s.ctx))
+
+	archive := filepath.Join(s.tempDir, "sources.tar.gz")
+	manifest, err := s.bm.PackSources(s.ctx, archive)
+	s.Require().NoError(err)
+	s.Equal("2024.02.1", manifest.Buildroot)
+	s.Len(manifest.Files, 2)
+	s.Contains(manifest.Files, "buildroot.tar.gz")
+	s.Contains(manifest.Files, "dl/foo/foo-1.0.tar.gz")
+
+	sum, err := checksumFile(archive)
+	s.Require().NoError(err)
+	checksum, err := os.ReadFile(archive + ".sha256")
+	s.Require().NoError(err)
+	s.Equal(sum+"  sources.tar.gz\n", string(checksum))
+
+	// An offline project gets Buildroot and the sources from the archive
+	cfg := s.config()
+	cfg.Buildroot.Sources = archive
+	bm := NewBuildrootManager(cfg, filepath.Join(s.tempDir, "offline"))
+	s.True(bm.IsOffline())
+	s.Require().NoError(bm.DownloadBuildroot(s.ctx))
+	s.FileExists(filepath.Join(bm.GetBuildrootDir(), "Makefile"))
+
+	missing, err := bm.MissingSources(s.ctx)
+	s.NoError(err)
+	s.Empty(missing)
+	content, err := os.ReadFile(filepath.Join(bm.GetDownloadDir(), "foo", "foo-1.0.tar.gz"))
+	s.Require().NoError(err)
+	s.Equal("foo sources\n", string(content))
+
+	// The same archive is not unpacked twice
+	s.Require().NoError(os.Remove(filepath.Join(bm.GetDownloadDir(), "foo", "foo-1.0.tar.gz")))
+	s.NoError(bm.DownloadBuildroot(s.ctx))
+	s.NoFileExists(filepath.Join(bm.GetDownloadDir(), "foo", "foo-1.0.tar.gz"))
+}
+
+func (s *SourcesTestSuite) TestImportRejectsBadArchives() {
+	source := filepath.Join(s.tempDir, "foo-1.0.tar.gz")
+	s.Require().NoError(os.WriteFile(source, []byte("foo sources\n"), 0644))
+	entries := map[string]string{"dl/foo/foo-1.0.tar.gz": source}
+	write := func(manifest SourcesManifest) string {
+		data, err := json.Marshal(manifest)
+		s.Require().NoError(err)
+		archive := filepath.Join(s.tempDir, "bad.tar.gz")
+		s.Require().NoError(writeSourcesArchive(archive, data, []string{"dl/foo/foo-1.0.tar.gz"}, entries))
+		return archive
+	}
+	sum, err := checksumFile(source)
+	s.Require().NoError(err)
+
+	err = s.bm.ImportSources(write(SourcesManifest{Buildroot: "2023.02", Files: map[string]string{"dl/foo/foo-1.0.tar.gz": sum}}))
+	s.Error(err)
+	s.Contains(err.Error(), "has sources for Buildroot 2023.02, but forge.yml uses 2024.02.1")
+
+	err = s.bm.ImportSources(write(SourcesManifest{Buildroot: "2024.02.1", Files: map[string]string{"dl/foo/foo-1.0.tar.gz": strings.Repeat("0", 64)}}))
+	s.Error(err)
+	s.Contains(err.Error(), "failed to import dl/foo/foo-1.0.tar.gz: checksum mismatch")
+	s.NoFileExists(filepath.Join(s.bm.GetDownloadDir(), "foo", "foo-1.0.tar.gz"))
+
+	err = s.bm.ImportSources(write(SourcesManifest{Buildroot: "2024.02.1", Files: map[string]string{"dl/foo/foo-1.0.tar.gz": sum, "buildroot.tar.gz": sum}}))
+	s.Error(err)
+	s.Contains(err.Error(), "is incomplete: buildroot.tar.gz is missing")
+
+	err = s.bm.ImportSources(source)
+	s.Error(err)
+	s.Contains(err.Error(), "is not a source archive")
+}
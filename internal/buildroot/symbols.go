@@ -0,0 +1,123 @@
+package buildroot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sst/forge/internal/apps"
+	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/kconfig"
+)
+
+// kernelArchitectures maps forge architectures to the kernel's arch/ directory
+var kernelArchitectures = map[string]string{
+	"x86_64":  "x86",
+	"i386":    "x86",
+	"arm":     "arm",
+	"armv5":   "arm",
+	"armv7":   "arm",
+	"aarch64": "arm64",
+	"riscv64": "riscv",
+	"mips":    "mips",
+}
+
+// SymbolCheck is the result of checking forge.yml's config symbols against
+// the Kconfig trees
+type SymbolCheck struct {
+	Issues []kconfig.Issue
+	// KernelChecked is false until Buildroot has extracted the kernel
+	// sources, so kernel options could not be checked
+	KernelChecked bool
+}
+
+// Errors returns the issues that would break the build
+func (c *SymbolCheck) Errors() []kconfig.Issue {
+	var errors []kconfig.Issue
+	for _, issue := range c.Issues {
+		if !issue.Warning {
+			errors = append(errors, issue)
+		}
+	}
+	return errors
+}
+
+// CheckSymbols validates the defconfig against the Config.in tree of the
+// downloaded Buildroot and the kernel fragment against the kernel Kconfig,
+// when the kernel sources have been extracted by an earlier build
+func (bm *BuildrootManager) CheckSymbols() (*SymbolCheck, error) {
+	defconfig, err := bm.config.Defconfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// App packages are only known to Buildroot through the external tree,
+	// which the first build has not written yet
+	var externals []kconfig.External
+	if len(bm.config.Apps) > 0 {
+		if _, err := apps.WriteExternal(bm.config, bm.projectDir); err != nil {
+			return nil, fmt.Errorf("failed to write external tree: %v", err)
+		}
+		externals = append(externals, kconfig.External{Name: apps.ExternalName, Path: bm.GetExternalDir()})
+	}
+
+	tree, err := kconfig.ParseBuildroot(bm.GetBuildrootDir(), bm.GetOutputDir(), externals...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Buildroot Kconfig: %v", err)
+	}
+	result := &SymbolCheck{Issues: tree.Check(assignments(defconfig))}
+
+	fragment, err := bm.config.KernelFragment()
+	if err != nil {
+		return nil, err
+	}
+	kernelDir := bm.findKernelDir()
+	if len(fragment.Symbols()) == 0 || kernelDir == "" {
+		return result, nil
+	}
+
+	kernelTree, err := kconfig.ParseKernel(kernelDir, kernelArchitectures[bm.config.Architecture])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel Kconfig: %v", err)
+	}
+	result.Issues = append(result.Issues, kernelTree.Check(assignments(fragment))...)
+	result.KernelChecked = true
+
+	return result, nil
+}
+
+// findKernelDir returns the kernel source directory Buildroot extracted
+// into output/build, or "" if there is none
+func (bm *BuildrootManager) findKernelDir() string {
+	entries, err := os.ReadDir(filepath.Join(bm.GetOutputDir(), "build"))
+	if err != nil {
+		return ""
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, "linux-") {
+			continue
+		}
+		// linux-headers, linux-firmware and linux-tools are other packages
+		if strings.HasPrefix(name, "linux-headers") || strings.HasPrefix(name, "linux-firmware") || strings.HasPrefix(name, "linux-tools") {
+			continue
+		}
+		dir := filepath.Join(bm.GetOutputDir(), "build", name)
+		if _, err := os.Stat(filepath.Join(dir, "Kconfig")); err == nil {
+			return dir
+		}
+	}
+
+	return ""
+}
+
+// assignments converts defconfig symbols for checking
+func assignments(defconfig *config.Defconfig) []kconfig.Assignment {
+	var result []kconfig.Assignment
+	for _, symbol := range defconfig.Symbols() {
+		result = append(result, kconfig.Assignment{Name: symbol.Name, Value: symbol.Value, Source: symbol.Source})
+	}
+	return result
+}
@@ -0,0 +1,179 @@
+package buildroot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+const buildrootConfigIn = `mainmenu "Buildroot"
+
+choice
+	prompt "Target Architecture"
+
+config BR2_x86_64
+	bool "x86_64"
+
+config BR2_arm
+	bool "ARM (little endian)"
+
+endchoice
+
+choice
+	prompt "C library"
+
+config BR2_TOOLCHAIN_BUILDROOT_GLIBC
+	bool "glibc"
+
+endchoice
+
+config BR2_TARGET_ROOTFS_EXT2
+	bool "ext2/3/4 root filesystem"
+
+config BR2_PACKAGE_BUSYBOX
+	bool "BusyBox"
+
+config BR2_PACKAGE_LIBMODBUS
+	bool "libmodbus"
+
+config BR2_KERNEL_HEADERS_AS_KERNEL
+	bool "Same as kernel being built"
+
+config BR2_LINUX_KERNEL
+	bool "Linux Kernel"
+
+if BR2_LINUX_KERNEL
+
+choice
+	prompt "Kernel version"
+
+config BR2_LINUX_KERNEL_LATEST_VERSION
+	bool "Latest version"
+
+config BR2_LINUX_KERNEL_CUSTOM_VERSION
+	bool "Custom version"
+
+endchoice
+
+config BR2_LINUX_KERNEL_CUSTOM_VERSION_VALUE
+	string "Kernel version"
+	depends on BR2_LINUX_KERNEL_CUSTOM_VERSION
+
+choice
+	prompt "Kernel configuration"
+
+config BR2_LINUX_KERNEL_USE_ARCH_DEFAULT_CONFIG
+	bool "Use the architecture default configuration"
+
+endchoice
+
+endif
+`
+
+const kernelKconfig = `mainmenu "Linux"
+
+config NET
+	bool "Networking support"
+
+config NETFILTER
+	bool "Network packet filtering framework"
+	depends on NET
+
+config HZ
+	int "Timer frequency"
+`
+
+type SymbolsTestSuite struct {
+	suite.Suite
+	tempDir string
+	config  *config.Config
+}
+
+func TestSymbolsTestSuite(t *testing.T) {
+	suite.Run(t, new(SymbolsTestSuite))
+}
+
+func (s *SymbolsTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.config = &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+	}
+
+	s.writeFile(filepath.Join("build", "buildroot", "Config.in"), buildrootConfigIn)
+}
+
+func (s *SymbolsTestSuite) writeFile(path, content string) {
+	s.Require().NoError(writeFile(filepath.Join(s.tempDir, path), content))
+}
+
+func (s *SymbolsTestSuite) TestValidConfig() {
+	s.config.Packages = []string{"modbus"}
+
+	check, err := NewBuildrootManager(s.config, s.tempDir).CheckSymbols()
+	s.Require().NoError(err)
+	s.Empty(check.Issues)
+	s.False(check.KernelChecked)
+}
+
+func (s *SymbolsTestSuite) TestUnknownPackage() {
+	s.config.Packages = []string{"busybxo"}
+
+	check, err := NewBuildrootManager(s.config, s.tempDir).CheckSymbols()
+	s.Require().NoError(err)
+	s.Require().Len(check.Errors(), 1)
+	s.Equal("BR2_PACKAGE_BUSYBXO=y (package busybxo): unknown symbol, did you mean BR2_PACKAGE_BUSYBOX?", check.Errors()[0].String())
+}
+
+func (s *SymbolsTestSuite) TestKernelOptions() {
+	s.config.Kernel.Config = map[string]string{"NETFILTER": "y", "HZ": "fast", "NETFILTR_XT": "y"}
+	s.writeFile(filepath.Join("build", "buildroot", "output", "build", "linux-headers-6.1", "Kconfig"), "")
+	s.writeFile(filepath.Join("build", "buildroot", "output", "build", "linux-6.1", "Kconfig"), kernelKconfig)
+
+	check, err := NewBuildrootManager(s.config, s.tempDir).CheckSymbols()
+	s.Require().NoError(err)
+	s.True(check.KernelChecked)
+
+	var messages []string
+	for _, issue := range check.Issues {
+		messages = append(messages, issue.String())
+	}
+	s.Equal([]string{
+		"CONFIG_HZ=fast (kernel.config): expects an integer",
+		"CONFIG_NETFILTER=y (kernel.config): depends on NET, which is not met (not enabled: CONFIG_NET)",
+		"CONFIG_NETFILTR_XT=y (kernel.config): unknown symbol, did you mean CONFIG_NETFILTER?",
+	}, messages)
+	s.Len(check.Errors(), 2)
+}
+
+func (s *SymbolsTestSuite) TestKernelNotExtracted() {
+	s.config.Kernel.Config = map[string]string{"NETFILTR": "y"}
+
+	check, err := NewBuildrootManager(s.config, s.tempDir).CheckSymbols()
+	s.Require().NoError(err)
+	s.False(check.KernelChecked)
+	s.Empty(check.Issues)
+}
+
+func (s *SymbolsTestSuite) TestBuildrootNotDownloaded() {
+	s.Require().NoError(os.RemoveAll(filepath.Join(s.tempDir, "build")))
+
+	_, err := NewBuildrootManager(s.config, s.tempDir).CheckSymbols()
+	s.Error(err)
+	s.Contains(err.Error(), "failed to read Buildroot Kconfig")
+}
+
+func (s *SymbolsTestSuite) TestAppPackage() {
+	s.config.Apps = []config.AppConfig{{Name: "blinkd", Path: "blinkd", Build: "make"}}
+	s.writeFile(filepath.Join("blinkd", "Makefile"), "all:\n")
+
+	check, err := NewBuildrootManager(s.config, s.tempDir).CheckSymbols()
+	s.Require().NoError(err)
+	s.Empty(check.Issues)
+}
@@ -2,22 +2,30 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/apps"
 	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/features"
+	"github.com/sst/forge/internal/packages"
+	"gopkg.in/yaml.v3"
 )
 
 // NewAddCommand creates the add command
 func NewAddCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add",
-		Short: "Add packages or features to a Forge OS project",
-		Long:  `Add packages or features to your Forge OS project configuration.`,
+		Short: "Add packages, features or apps to a Forge OS project",
+		Long:  `Add packages, features or in-house applications to your Forge OS project configuration.`,
 	}
 
 	cmd.AddCommand(
 		newAddPackageCommand(),
 		newAddFeatureCommand(),
+		newAddAppCommand(),
 	)
 
 	return cmd
@@ -25,11 +33,13 @@ func NewAddCommand() *cobra.Command {
 
 func newAddPackageCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "package [package-name]",
-		Short: "Add a package to the project",
-		Long: `Add a package to the forge.yml configuration.
-The package will be included in the next build.`,
-		Args: cobra.ExactArgs(1),
+		Use:   "package [package-name...]",
+		Short: "Add packages to the project",
+		Long: `Add one or more packages to the forge.yml configuration.
+The packages will be included in the next build. The dependencies they bring
+into the image and their estimated size are shown before forge.yml is saved.
+Run 'forge packages search' to find packages.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: runAddPackageCommandE,
 	}
 
@@ -41,7 +51,8 @@ func newAddFeatureCommand() *cobra.Command {
 		Use:   "feature [feature-name]",
 		Short: "Add a feature to the project",
 		Long: `Add a feature to the forge.yml configuration.
-Features provide pre-configured functionality and may include additional packages.`,
+Features provide pre-configured functionality and may include additional packages.
+Run 'forge features list' to see the available features.`,
 		Args: cobra.ExactArgs(1),
 		RunE: runAddFeatureCommandE,
 	}
@@ -49,9 +60,35 @@ Features provide pre-configured functionality and may include additional package
 	return cmd
 }
 
+func newAddAppCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "app [path]",
+		Short: "Add an in-house application built from local sources",
+		Long: `Add an application whose sources live in the project to forge.yml and
+generate its Buildroot package in the project's BR2_EXTERNAL tree (external/).
+Go, CMake, Meson and Makefile projects are detected; Go code is
+cross-compiled for the architecture in forge.yml. Buildroot builds Go code
+without network access, so the build vendors the modules a Go app depends
+on into its vendor/ directory first. Unless --no-service is
+given, the binary installed as /usr/bin/<name> is started at boot with an
+init script or a systemd unit, depending on the init system of the image.
+The generated package files can be edited; forge only rewrites them once
+their directory under external/package is deleted.`,
+		Example: `  forge add app ./src/sensord
+  forge add app ./gateway --name gatewayd --no-service`,
+		Args: cobra.ExactArgs(1),
+		RunE: runAddAppCommandE,
+	}
+
+	cmd.Flags().StringP("name", "n", "", "Package name (default: the directory name)")
+	cmd.Flags().StringP("build", "b", "", "Build system: go, cmake, meson or make (default: detected)")
+	cmd.Flags().Bool("no-service", false, "Do not start the application at boot")
+
+	return cmd
+}
+
 func runAddPackageCommandE(cmd *cobra.Command, args []string) error {
-	packageName := args[0]
-	return runAddPackageCommand([]string{packageName}, map[string]interface{}{})
+	return runAddPackageCommand(args, map[string]interface{}{})
 }
 
 func runAddFeatureCommandE(cmd *cobra.Command, args []string) error {
@@ -59,38 +96,109 @@ func runAddFeatureCommandE(cmd *cobra.Command, args []string) error {
 	return runAddFeatureCommand([]string{featureName}, map[string]interface{}{})
 }
 
+func runAddAppCommandE(cmd *cobra.Command, args []string) error {
+	name, _ := cmd.Flags().GetString("name")
+	build, _ := cmd.Flags().GetString("build")
+	noService, _ := cmd.Flags().GetBool("no-service")
+
+	return runAddAppCommand(args, map[string]interface{}{
+		"name":       name,
+		"build":      build,
+		"no-service": noService,
+	})
+}
+
 func runAddPackageCommand(args []string, flags map[string]interface{}) error {
-	if len(args) != 1 {
+	if len(args) == 0 {
 		return fmt.Errorf("package name is required")
 	}
 
-	packageName := args[0]
-
-	// Load current config
-	cfg, err := config.LoadConfig("forge.yml")
+	// Make sure the current config is valid before editing it
+	cfg, err := config.LoadConfigForEdit("forge.yml")
 	if err != nil {
 		return fmt.Errorf("failed to load forge.yml: %v", err)
 	}
 
-	// Check if package already exists
-	for _, pkg := range cfg.Packages {
-		if pkg == packageName {
-			return fmt.Errorf("package '%s' is already added to the project", packageName)
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	for _, name := range args {
+		if pm.IsValidPackage(name) {
+			continue
 		}
+		if suggestions := pm.Suggest(name); len(suggestions) > 0 {
+			unknown = append(unknown, fmt.Sprintf("unknown package '%s' (did you mean %s?)", name, strings.Join(suggestions, ", ")))
+		} else {
+			unknown = append(unknown, fmt.Sprintf("unknown package '%s' (run 'forge packages search' to find packages)", name))
+		}
+	}
+	if len(unknown) > 0 {
+		// Only the built-in packages are known until Buildroot is downloaded
+		if _, err := os.Stat(filepath.Join("build", "buildroot", "package")); err != nil {
+			unknown = append(unknown, "all Buildroot packages are known once 'forge build' has downloaded Buildroot")
+		}
+		return fmt.Errorf("%s", strings.Join(unknown, "; "))
+	}
+
+	// Edit the YAML tree in place so comments and unknown sections survive
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
 	}
 
-	// Add package
-	cfg.Packages = append(cfg.Packages, packageName)
+	added, err := doc.AddToList("packages", args...)
+	if err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+	if len(added) == 0 {
+		return fmt.Errorf("package '%s' is already added to the project", strings.Join(args, "', '"))
+	}
+
+	addition, err := pm.PlanPackageAddition(added)
+	if err != nil {
+		return err
+	}
+	if len(addition.Problems) > 0 {
+		return fmt.Errorf("cannot add %s: %s", strings.Join(added, ", "), strings.Join(addition.Problems, "; "))
+	}
+	printAddition(addition)
 
 	// Save config
-	if err := config.SaveConfig(cfg, "forge.yml"); err != nil {
+	if err := doc.Save("forge.yml"); err != nil {
 		return fmt.Errorf("failed to save forge.yml: %v", err)
 	}
 
-	fmt.Printf("Added package '%s' to forge.yml\n", packageName)
+	for _, name := range added {
+		fmt.Printf("Added package '%s' to forge.yml\n", name)
+	}
+	if skipped := len(args) - len(added); skipped > 0 {
+		fmt.Printf("Skipped %d package(s) already added to the project\n", skipped)
+	}
 	return nil
 }
 
+// printAddition shows the packages an addition brings into the image and
+// their estimated size
+func printAddition(addition *packages.Addition) {
+	if len(addition.Packages) == 0 {
+		fmt.Println("No new packages in the image")
+		return
+	}
+
+	fmt.Printf("New packages in the image: %s\n", strings.Join(addition.Packages, ", "))
+	switch {
+	case len(addition.UnknownSize) == len(addition.Packages):
+		fmt.Println("Estimated size: unknown")
+	case len(addition.UnknownSize) > 0:
+		fmt.Printf("Estimated size: +%s (unknown for %s)\n", formatSize(addition.Size), strings.Join(addition.UnknownSize, ", "))
+	default:
+		fmt.Printf("Estimated size: +%s\n", formatSize(addition.Size))
+	}
+}
+
 func runAddFeatureCommand(args []string, flags map[string]interface{}) error {
 	if len(args) != 1 {
 		return fmt.Errorf("feature name is required")
@@ -98,27 +206,144 @@ func runAddFeatureCommand(args []string, flags map[string]interface{}) error {
 
 	featureName := args[0]
 
-	// Load current config
-	cfg, err := config.LoadConfig("forge.yml")
+	// Make sure the current config is valid before editing it
+	cfg, err := config.LoadConfigForEdit("forge.yml")
 	if err != nil {
 		return fmt.Errorf("failed to load forge.yml: %v", err)
 	}
 
-	// Check if feature already exists
-	for _, feature := range cfg.Features {
-		if feature == featureName {
-			return fmt.Errorf("feature '%s' is already added to the project", featureName)
+	fm := features.NewFeatureManager()
+	if !fm.IsValidFeature(featureName) {
+		return fmt.Errorf("unknown feature '%s' (run 'forge features list' to see available features)", featureName)
+	}
+	if conflicts := fm.CheckConflicts(cfg.Features, featureName); len(conflicts) > 0 {
+		var problems []string
+		for _, conflict := range conflicts {
+			problems = append(problems, conflict.String())
 		}
+		return fmt.Errorf("cannot add feature '%s': %s", featureName, strings.Join(problems, "; "))
 	}
 
-	// Add feature
-	cfg.Features = append(cfg.Features, featureName)
+	// Edit the YAML tree in place so comments and unknown sections survive
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
+	}
+
+	added, err := doc.AddToList("features", featureName)
+	if err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+	if len(added) == 0 {
+		return fmt.Errorf("feature '%s' is already added to the project", featureName)
+	}
 
 	// Save config
-	if err := config.SaveConfig(cfg, "forge.yml"); err != nil {
+	if err := doc.Save("forge.yml"); err != nil {
 		return fmt.Errorf("failed to save forge.yml: %v", err)
 	}
 
 	fmt.Printf("Added feature '%s' to forge.yml\n", featureName)
 	return nil
 }
+
+func runAddAppCommand(args []string, flags map[string]interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("app path is required")
+	}
+
+	// Make sure the current config is valid before editing it
+	cfg, err := config.LoadConfigForEdit("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
+	}
+
+	// Sources inside the project are recorded relative to it so the
+	// project can be moved
+	path := filepath.Clean(args[0])
+	if filepath.IsAbs(path) {
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+	}
+
+	build, _ := flags["build"].(string)
+	if build == "" {
+		detected, err := apps.Detect(path)
+		if err != nil {
+			return fmt.Errorf("%v; use --build to choose one", err)
+		}
+		build = detected
+	} else if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return fmt.Errorf("app path %s is not a directory", path)
+	}
+
+	name, _ := flags["name"].(string)
+	if name == "" {
+		name = apps.DefaultName(path)
+	}
+	for _, app := range cfg.Apps {
+		if app.Name == name {
+			return fmt.Errorf("app '%s' is already added to the project", name)
+		}
+	}
+
+	// The app becomes a Buildroot package, so its name must be free
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+	if pm.IsValidPackage(name) {
+		return fmt.Errorf("app name '%s' is already used by a package (use --name to choose another)", name)
+	}
+
+	noService, _ := flags["no-service"].(bool)
+	app := config.AppConfig{
+		Name:    name,
+		Path:    filepath.ToSlash(path),
+		Build:   build,
+		Service: !noService,
+	}
+
+	var node yaml.Node
+	if err := node.Encode(app); err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+
+	// Edit the YAML tree in place so comments and unknown sections survive
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
+	}
+	if err := doc.Set(fmt.Sprintf("apps[%d]", len(cfg.Apps)), &node); err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+	if err := saveValidatedDocument(doc); err != nil {
+		return err
+	}
+
+	cfg.Apps = append(cfg.Apps, app)
+	created, err := apps.WriteExternal(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added app '%s' (%s) from %s to forge.yml\n", name, build, app.Path)
+	for _, file := range created {
+		fmt.Printf("  created %s\n", file)
+	}
+	if build == apps.BuildGo {
+		goarch, goarm, _ := apps.GoArch(cfg.Architecture)
+		if goarm != "" {
+			fmt.Printf("Go code is cross-compiled with GOARCH=%s GOARM=%s\n", goarch, goarm)
+		} else {
+			fmt.Printf("Go code is cross-compiled with GOARCH=%s\n", goarch)
+		}
+	}
+	if app.Service {
+		fmt.Printf("/usr/bin/%s is started at boot\n", name)
+	}
+	return nil
+}
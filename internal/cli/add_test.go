@@ -2,15 +2,18 @@ package cli
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/packages"
 	"github.com/stretchr/testify/suite"
 )
 
 type AddCommandTestSuite struct {
 	suite.Suite
 	tempDir string
+	oldDir  string
 }
 
 func TestAddCommandTestSuite(t *testing.T) {
@@ -18,26 +21,23 @@ func TestAddCommandTestSuite(t *testing.T) {
 }
 
 func (s *AddCommandTestSuite) SetupTest() {
-	var err error
-	s.tempDir, err = os.MkdirTemp("", "forge-add-test-*")
-	s.Require().NoError(err)
-
 	// Change to temp directory
-	oldDir, _ := os.Getwd()
-	s.tempDir = oldDir + "/" + s.tempDir
-	os.Chdir(s.tempDir)
+	s.tempDir = s.T().TempDir()
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.tempDir))
+
+	s.T().Setenv(packages.PackagesDirEnv, s.T().TempDir())
 }
 
 func (s *AddCommandTestSuite) TearDownTest() {
-	os.Chdir("/")
-	os.RemoveAll(s.tempDir)
+	os.Chdir(s.oldDir)
 }
 
 func (s *AddCommandTestSuite) TestNewAddCommand() {
 	cmd := NewAddCommand()
 	s.NotNil(cmd)
 	s.Equal("add", cmd.Use)
-	s.Contains(cmd.Short, "Add packages or features")
+	s.Contains(cmd.Short, "Add packages, features or apps")
 }
 
 func (s *AddCommandTestSuite) TestAddPackageCommand() {
@@ -87,6 +87,72 @@ func (s *AddCommandTestSuite) TestAddPackageDuplicate() {
 	s.Contains(err.Error(), "package 'nginx' is already added")
 }
 
+func (s *AddCommandTestSuite) TestAddPackageUnknown() {
+	testConfig := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{},
+		Features:      []string{},
+	}
+	s.Require().NoError(config.SaveConfig(testConfig, "forge.yml"))
+
+	err := runAddPackageCommand([]string{"nginx", "wpa_suplicant", "kubernetes"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown package 'wpa_suplicant' (did you mean wpa_supplicant?)")
+	s.Contains(err.Error(), "unknown package 'kubernetes' (run 'forge packages search' to find packages)")
+
+	// Nothing is added when any package is unknown
+	cfg, err := config.LoadConfig("forge.yml")
+	s.NoError(err)
+	s.Empty(cfg.Packages)
+}
+
+func (s *AddCommandTestSuite) TestAddMultiplePackages() {
+	testConfig := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"nginx"},
+		Features:      []string{},
+	}
+	s.Require().NoError(config.SaveConfig(testConfig, "forge.yml"))
+
+	err := runAddPackageCommand([]string{"nginx", "openssh", "fail2ban"}, map[string]interface{}{})
+	s.NoError(err)
+
+	cfg, err := config.LoadConfig("forge.yml")
+	s.NoError(err)
+	s.Equal([]string{"nginx", "openssh", "fail2ban"}, cfg.Packages)
+}
+
+func (s *AddCommandTestSuite) TestAddPackageConflict() {
+	testConfig := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"openssh"},
+		Features:      []string{},
+	}
+	s.Require().NoError(config.SaveConfig(testConfig, "forge.yml"))
+	s.Require().NoError(os.MkdirAll("packages", 0755))
+	s.Require().NoError(os.WriteFile("packages/tiny.yml", []byte("packages:\n  - name: tiny-ssh\n    conflicts: [ssh-server]\n"), 0644))
+
+	err := runAddPackageCommand([]string{"tiny-ssh"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "cannot add tiny-ssh: tiny-ssh conflicts with openssh")
+
+	cfg, err := config.LoadConfig("forge.yml")
+	s.NoError(err)
+	s.Equal([]string{"openssh"}, cfg.Packages)
+}
+
 func (s *AddCommandTestSuite) TestAddPackageNoConfig() {
 	// Don't create forge.yml
 	err := runAddPackageCommand([]string{"nginx"}, map[string]interface{}{})
@@ -141,9 +207,167 @@ func (s *AddCommandTestSuite) TestAddFeatureDuplicate() {
 	s.Contains(err.Error(), "feature 'firewall' is already added")
 }
 
+func (s *AddCommandTestSuite) TestAddFeatureUnknown() {
+	testConfig := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{},
+		Features:      []string{},
+	}
+
+	err := config.SaveConfig(testConfig, "forge.yml")
+	s.NoError(err)
+
+	err = runAddFeatureCommand([]string{"kiosk-mode"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown feature 'kiosk-mode'")
+
+	cfg, err := config.LoadConfig("forge.yml")
+	s.NoError(err)
+	s.Empty(cfg.Features)
+}
+
+func (s *AddCommandTestSuite) TestAddFeatureConflict() {
+	testConfig := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{},
+		Features:      []string{"systemd"},
+	}
+
+	err := config.SaveConfig(testConfig, "forge.yml")
+	s.NoError(err)
+
+	err = runAddFeatureCommand([]string{"sysvinit"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "sysvinit conflicts with systemd")
+}
+
 func (s *AddCommandTestSuite) TestAddFeatureNoConfig() {
 	// Don't create forge.yml
 	err := runAddFeatureCommand([]string{"firewall"}, map[string]interface{}{})
 	s.Error(err)
 	s.Contains(err.Error(), "failed to load forge.yml")
 }
+
+func (s *AddCommandTestSuite) TestAddPackageKeepsComments() {
+	content := `schema_version: "1.0"
+name: "test-project"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+
+# Base system
+packages:
+  - busybox   # Core utilities
+
+# Dashboard settings are not part of the schema
+web_dashboard:
+  port: 80
+`
+	err := os.WriteFile("forge.yml", []byte(content), 0644)
+	s.NoError(err)
+
+	err = runAddPackageCommand([]string{"nginx"}, map[string]interface{}{})
+	s.NoError(err)
+
+	err = runAddFeatureCommand([]string{"firewall"}, map[string]interface{}{})
+	s.NoError(err)
+
+	data, err := os.ReadFile("forge.yml")
+	s.NoError(err)
+	s.Contains(string(data), "# Base system\npackages:\n  - busybox   # Core utilities\n  - nginx\n")
+	s.Contains(string(data), "web_dashboard:\n  port: 80\n")
+	s.Contains(string(data), "features:\n  - firewall\n")
+}
+
+func (s *AddCommandTestSuite) TestAddWithoutSecrets() {
+	content := `schema_version: "1.0"
+name: "test-project"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+mqtt:
+  users:
+    - name: sensor
+      password: "${secret:mqtt_password}"
+`
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(content), 0644))
+
+	// Editing forge.yml never needs the secret values
+	s.NoError(runAddPackageCommand([]string{"nginx"}, map[string]interface{}{}))
+	s.NoError(runAddFeatureCommand([]string{"firewall"}, map[string]interface{}{}))
+	s.NoError(runConfigSetCommand([]string{"version", "1.1.0"}, map[string]interface{}{}))
+
+	data, err := os.ReadFile("forge.yml")
+	s.Require().NoError(err)
+	s.Contains(string(data), `password: "${secret:mqtt_password}"`)
+	s.Contains(string(data), "  - nginx\n")
+}
+
+func (s *AddCommandTestSuite) writeAppConfig() {
+	content := `schema_version: "1.0"
+name: "test-project"
+version: "1.0.0"
+architecture: "armv7"
+template: "minimal"
+packages:
+  - busybox
+`
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(content), 0644))
+}
+
+func (s *AddCommandTestSuite) TestAddApp() {
+	s.writeAppConfig()
+	s.Require().NoError(os.MkdirAll(filepath.Join("src", "Sensor_D"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join("src", "Sensor_D", "go.mod"), []byte("module sensord\n"), 0644))
+
+	err := runAddAppCommand([]string{"./src/Sensor_D/"}, map[string]interface{}{})
+	s.Require().NoError(err)
+
+	cfg, err := config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	s.Equal([]config.AppConfig{{Name: "sensor-d", Path: "src/Sensor_D", Build: "go", Service: true}}, cfg.Apps)
+
+	data, err := os.ReadFile(filepath.Join("external", "package", "sensor-d", "sensor-d.mk"))
+	s.Require().NoError(err)
+	s.Contains(string(data), "SENSOR_D_GOMOD = sensord\n")
+	s.FileExists(filepath.Join("external", "package", "sensor-d", "sensor-d.service"))
+
+	err = runAddAppCommand([]string{"src/Sensor_D"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "app 'sensor-d' is already added to the project")
+}
+
+func (s *AddCommandTestSuite) TestAddAppOptions() {
+	s.writeAppConfig()
+	s.Require().NoError(os.MkdirAll("blink", 0755))
+
+	// Nothing to detect the build system from
+	err := runAddAppCommand([]string{"blink"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "use --build to choose one")
+
+	err = runAddAppCommand([]string{"blink"}, map[string]interface{}{"name": "nginx", "build": "make"})
+	s.Error(err)
+	s.Contains(err.Error(), "app name 'nginx' is already used by a package")
+
+	err = runAddAppCommand([]string{"blink"}, map[string]interface{}{"name": "blinkd", "build": "make", "no-service": true})
+	s.Require().NoError(err)
+
+	cfg, err := config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	s.Equal([]config.AppConfig{{Name: "blinkd", Path: "blink", Build: "make"}}, cfg.Apps)
+	s.FileExists(filepath.Join("external", "package", "blinkd", "blinkd.mk"))
+	s.NoFileExists(filepath.Join("external", "package", "blinkd", "S90blinkd"))
+
+	err = runAddAppCommand([]string{"missing"}, map[string]interface{}{"build": "make"})
+	s.Error(err)
+	s.Contains(err.Error(), "app path missing is not a directory")
+}
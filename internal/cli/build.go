@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/sst/forge/internal/build"
+	"github.com/sst/forge/internal/builder"
 	"github.com/sst/forge/internal/config"
 	"github.com/sst/forge/internal/resources"
 )
@@ -25,7 +26,14 @@ This command will:
 - Check system resources
 - Download and configure Buildroot
 - Build the complete OS image
-- Generate build artifacts and reports`,
+- Generate build artifacts and reports
+
+With --package only that package is rebuilt (make <pkg>-rebuild) before the
+root filesystem and images are regenerated, which is much faster while
+working on a package linked with 'forge dev link'.
+
+Keys forge does not understand are ignored with a warning; with --strict
+they fail the build instead, which suits CI.`,
 		RunE: runBuildCommandE,
 	}
 
@@ -35,6 +43,9 @@ This command will:
 	cmd.Flags().IntP("jobs", "j", 0, "Number of parallel build jobs (0 = auto-detect)")
 	cmd.Flags().String("optimize-for", "", "Optimize build for specific use case (size, performance, realtime)")
 	cmd.Flags().String("timeout", "2h", "Build timeout duration")
+	cmd.Flags().StringP("profile", "p", "", "Build profile from forge.yml to apply (e.g. dev, prod)")
+	cmd.Flags().String("package", "", "Only rebuild this Buildroot package, then regenerate the image")
+	cmd.Flags().Bool("strict", false, "Fail on keys in forge.yml that forge does not understand")
 
 	return cmd
 }
@@ -47,6 +58,9 @@ func runBuildCommandE(cmd *cobra.Command, args []string) error {
 	jobs, _ := cmd.Flags().GetInt("jobs")
 	optimizeFor, _ := cmd.Flags().GetString("optimize-for")
 	timeout, _ := cmd.Flags().GetString("timeout")
+	profile, _ := cmd.Flags().GetString("profile")
+	pkg, _ := cmd.Flags().GetString("package")
+	strict, _ := cmd.Flags().GetBool("strict")
 
 	return runBuildCommand(args, map[string]string{
 		"clean":        fmt.Sprintf("%t", clean),
@@ -55,6 +69,9 @@ func runBuildCommandE(cmd *cobra.Command, args []string) error {
 		"jobs":         fmt.Sprintf("%d", jobs),
 		"optimize-for": optimizeFor,
 		"timeout":      timeout,
+		"profile":      profile,
+		"package":      pkg,
+		"strict":       fmt.Sprintf("%t", strict),
 	})
 }
 
@@ -66,11 +83,22 @@ func runBuildCommand(args []string, flags map[string]string) error {
 	}
 
 	// Load and validate configuration
-	config, err := loadForgeConfig("forge.yml")
+	// Strict loading rejects unknown keys instead of warning about them
+	loadConfig := loadForgeConfig
+	if flags["strict"] == "true" {
+		loadConfig = config.LoadConfigStrict
+	}
+	config, err := loadConfig("forge.yml")
 	if err != nil {
 		return fmt.Errorf("invalid forge.yml: %v", err)
 	}
 
+	// Merge the selected profile before anything derives build settings
+	config, err = config.ApplyProfile(flags["profile"])
+	if err != nil {
+		return err
+	}
+
 	// Check system resources
 	if err := checkBuildResources(config); err != nil {
 		return fmt.Errorf("resource check failed: %v", err)
@@ -82,20 +110,31 @@ func runBuildCommand(args []string, flags map[string]string) error {
 		return fmt.Errorf("failed to get current directory: %v", err)
 	}
 
+	if err := usePackageDefinitions(config, projectDir); err != nil {
+		return err
+	}
+
 	// Create build orchestrator
-	bo := build.NewBuildOrchestrator(config, projectDir)
+	bo := builder.NewBuildOrchestrator(config, projectDir)
 
 	// Parse build options
-	opts := build.BuildOptions{
+	opts := builder.BuildOptions{
 		Clean:       flags["clean"] == "true",
 		Verbose:     flags["verbose"] == "true",
 		Incremental: flags["incremental"] == "true",
+		Package:     flags["package"],
+	}
+	if opts.Package != "" && opts.Clean {
+		return fmt.Errorf("--package cannot be combined with --clean")
 	}
 
 	// Parse jobs
-	if jobs := flags["jobs"]; jobs != "" && jobs != "0" {
-		// TODO: Parse jobs value
-		opts.Jobs = 4 // Default for now
+	if jobs := flags["jobs"]; jobs != "" {
+		n, err := strconv.Atoi(jobs)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid jobs value: %s", jobs)
+		}
+		opts.Jobs = n
 	}
 
 	// Parse optimization
@@ -104,10 +143,12 @@ func runBuildCommand(args []string, flags map[string]string) error {
 	}
 
 	// Parse timeout
-	if timeoutStr := flags["timeout"]; timeoutStr != "" && timeoutStr != "2h" {
-		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
-			opts.Timeout = timeout
+	if timeoutStr := flags["timeout"]; timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout value: %s", timeoutStr)
 		}
+		opts.Timeout = timeout
 	}
 
 	// Execute build with orchestrator
@@ -101,6 +101,23 @@ func (s *BuildCommandTestSuite) TestBuildCommandCleanBuild() {
 	s.Error(err)
 }
 
+func (s *BuildCommandTestSuite) TestBuildCommandPackageWithClean() {
+	projectDir := filepath.Join(s.tempDir, "package-build-project")
+	err := createProjectStructure(projectDir, "minimal", "x86_64")
+	s.NoError(err)
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	err = runBuildCommand([]string{}, map[string]string{
+		"clean":   "true",
+		"package": "busybox",
+	})
+	s.Error(err)
+	s.Contains(err.Error(), "--package cannot be combined with --clean")
+}
+
 func (s *BuildCommandTestSuite) TestBuildCommandVerboseOutput() {
 	projectDir := filepath.Join(s.tempDir, "verbose-build-project")
 	err := createProjectStructure(projectDir, "minimal", "x86_64")
@@ -272,3 +289,65 @@ func (s *BuildCommandTestSuite) TestBuildCommandTimeoutHandling() {
 	})
 	s.Error(err) // Expected to fail initially
 }
+
+func (s *BuildCommandTestSuite) TestBuildCommandInvalidJobs() {
+	projectDir := filepath.Join(s.tempDir, "invalid-jobs-project")
+	err := createProjectStructure(projectDir, "minimal", "x86_64")
+	s.NoError(err)
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	err = runBuildCommand([]string{}, map[string]string{
+		"jobs": "many",
+	})
+	s.Error(err)
+	s.Contains(err.Error(), "invalid jobs value")
+}
+
+func (s *BuildCommandTestSuite) TestBuildCommandUnknownProfile() {
+	projectDir := filepath.Join(s.tempDir, "profile-project")
+	err := createProjectStructure(projectDir, "minimal", "x86_64")
+	s.NoError(err)
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	err = runBuildCommand([]string{}, map[string]string{
+		"profile": "prod",
+	})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown profile \"prod\"")
+}
+
+func (s *BuildCommandTestSuite) TestBuildCommandStrict() {
+	projectDir := filepath.Join(s.tempDir, "strict-project")
+	err := createProjectStructure(projectDir, "minimal", "x86_64")
+	s.NoError(err)
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	f, err := os.OpenFile("forge.yml", os.O_APPEND|os.O_WRONLY, 0644)
+	s.Require().NoError(err)
+	_, err = f.WriteString("bootloader: grub\n")
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	err = runBuildCommand([]string{}, map[string]string{
+		"strict":  "true",
+		"profile": "prod",
+	})
+	s.Error(err)
+	s.Contains(err.Error(), `unknown key "bootloader" is ignored`)
+
+	// Without --strict the key is only a warning
+	err = runBuildCommand([]string{}, map[string]string{
+		"profile": "prod",
+	})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown profile \"prod\"")
+}
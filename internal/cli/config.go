@@ -0,0 +1,514 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/buildroot"
+	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/logger"
+	"github.com/sst/forge/internal/secrets"
+	"github.com/sst/forge/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+// NewConfigCommand creates the config command
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect, edit and validate forge.yml",
+		Long: `Read and write forge.yml values by path, show the effective configuration,
+validate forge.yml and export its schema.
+
+Paths use dots for keys and brackets for list items, for example version,
+kernel.config.PREEMPT_RT or network.interfaces[0].ssid.`,
+	}
+
+	cmd.AddCommand(
+		newConfigGetCommand(),
+		newConfigSetCommand(),
+		newConfigUnsetCommand(),
+		newConfigShowCommand(),
+		newConfigValidateCommand(),
+		newConfigSchemaCommand(),
+		newConfigMigrateCommand(),
+	)
+
+	return cmd
+}
+
+func newConfigGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get [path]",
+		Short: "Print a configuration value",
+		Long: `Print the value at a path of the effective configuration, after references
+are resolved and the selected profile is merged. Secrets are redacted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runConfigGetCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Profile to merge into the configuration")
+	cmd.Flags().StringP("format", "f", "yaml", "Output format for lists and mappings (yaml, json)")
+
+	return cmd
+}
+
+func newConfigSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set [path] [value]",
+		Short: "Set a value in forge.yml",
+		Long: `Set the value at a path in forge.yml, keeping comments and layout. The value
+is checked against the type of the field and the configuration is validated
+before it is saved. Lists and mappings are given as YAML, e.g. "[busybox, nginx]".
+
+Values still held by deprecated top-level keys such as kernel_config can
+only be edited after 'forge config migrate'.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runConfigSetCommandE,
+	}
+}
+
+func newConfigUnsetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset [path]",
+		Short: "Remove a value from forge.yml",
+		Long:  `Remove the key or list item at a path in forge.yml. The configuration is validated before it is saved.`,
+		Args:  cobra.ExactArgs(1),
+		RunE:  runConfigUnsetCommandE,
+	}
+}
+
+func newConfigShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration",
+		Long: `Print the configuration forge builds with: references resolved, deprecated
+keys moved to their new place and the selected profile merged. Secrets are redacted.`,
+		Args: cobra.NoArgs,
+		RunE: runConfigShowCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Profile to merge into the configuration")
+	cmd.Flags().StringP("format", "f", "yaml", "Output format (yaml, json)")
+
+	return cmd
+}
+
+func newConfigValidateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Validate forge.yml",
+		Long: `Check forge.yml against the schema and report every problem with its
+line number: syntax errors, values of the wrong type, unknown keys, missing
+required fields, unresolved references, unknown packages and unknown features.
+
+Unknown keys are ignored and only reported as warnings. So are unknown
+packages until 'forge build' has downloaded Buildroot, as only the built-in
+packages are known before that, and secrets until the project has a secrets
+file and its key is set.
+
+With --kconfig, the Buildroot and kernel symbols forge.yml produces are also
+checked against the Kconfig trees of the downloaded sources: unknown symbols,
+values of the wrong type and unmet dependencies.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runConfigValidateCommandE,
+	}
+
+	cmd.Flags().Bool("kconfig", false, "Also check config symbols against the downloaded Buildroot and kernel")
+
+	return cmd
+}
+
+func newConfigSchemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the forge.yml JSON Schema",
+		Long: `Print the JSON Schema of forge.yml, for editors that offer completion
+and validation of YAML files.`,
+		Args: cobra.NoArgs,
+		RunE: runConfigSchemaCommandE,
+	}
+
+	cmd.Flags().StringP("output", "o", "", "Write the schema to a file instead of stdout")
+
+	return cmd
+}
+
+func newConfigMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate [file]",
+		Short: "Upgrade forge.yml to schema 2.0",
+		Long: `Rewrite forge.yml for schema 2.0: the deprecated top-level keys
+buildroot_version, kernel_version and kernel_config move into their
+buildroot and kernel sections, and the Buildroot version is made explicit,
+as is the kernel version of projects that configure the kernel. A legacy
+key whose nested form is already set is dropped. Only the affected lines
+change, so comments, spacing and key order are kept.`,
+		Example: `  forge config migrate --dry-run
+  forge config migrate`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runConfigMigrateCommandE,
+	}
+
+	cmd.Flags().Bool("dry-run", false, "List the changes without writing forge.yml")
+
+	return cmd
+}
+
+func configOutputFlags(cmd *cobra.Command) map[string]interface{} {
+	profile, _ := cmd.Flags().GetString("profile")
+	format, _ := cmd.Flags().GetString("format")
+	return map[string]interface{}{
+		"profile": profile,
+		"format":  format,
+	}
+}
+
+func runConfigGetCommandE(cmd *cobra.Command, args []string) error {
+	return runConfigGetCommand(args, configOutputFlags(cmd))
+}
+
+func runConfigSetCommandE(cmd *cobra.Command, args []string) error {
+	return runConfigSetCommand(args, map[string]interface{}{})
+}
+
+func runConfigUnsetCommandE(cmd *cobra.Command, args []string) error {
+	return runConfigUnsetCommand(args, map[string]interface{}{})
+}
+
+func runConfigShowCommandE(cmd *cobra.Command, args []string) error {
+	return runConfigShowCommand(args, configOutputFlags(cmd))
+}
+
+func runConfigValidateCommandE(cmd *cobra.Command, args []string) error {
+	kconfig, _ := cmd.Flags().GetBool("kconfig")
+	return runConfigValidateCommand(args, map[string]interface{}{
+		"kconfig": kconfig,
+	})
+}
+
+func runConfigSchemaCommandE(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	return runConfigSchemaCommand(args, map[string]interface{}{
+		"output": output,
+	})
+}
+
+func runConfigMigrateCommandE(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	return runConfigMigrateCommand(args, map[string]interface{}{
+		"dry-run": dryRun,
+	})
+}
+
+func runConfigGetCommand(args []string, flags map[string]interface{}) error {
+	cfg, err := loadEffectiveConfig(flags)
+	if err != nil {
+		return err
+	}
+
+	value, err := cfg.Lookup(args[0])
+	if err != nil {
+		return err
+	}
+
+	format, _ := flags["format"].(string)
+	if format == "yaml" {
+		// Scalars print bare so scripts can use them directly
+		switch v := value.(type) {
+		case string, bool, int, time.Duration:
+			fmt.Println(logger.Redact(fmt.Sprint(v)))
+			return nil
+		}
+	}
+
+	output, err := renderConfigValue(value, format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(logger.Redact(output))
+	return nil
+}
+
+func runConfigSetCommand(args []string, flags map[string]interface{}) error {
+	path, text := args[0], args[1]
+
+	value, err := config.ParseValue(path, text)
+	if err != nil {
+		return err
+	}
+
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return err
+	}
+	if err := doc.Set(path, value); err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+	if err := saveValidatedDocument(doc); err != nil {
+		return err
+	}
+
+	fmt.Printf("Set %s to %s\n", path, text)
+	return nil
+}
+
+func runConfigUnsetCommand(args []string, flags map[string]interface{}) error {
+	path := args[0]
+
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return err
+	}
+	removed, err := doc.Unset(path)
+	if err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+	if !removed {
+		return fmt.Errorf("%s is not set in forge.yml", path)
+	}
+	if err := saveValidatedDocument(doc); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %s\n", path)
+	return nil
+}
+
+func runConfigShowCommand(args []string, flags map[string]interface{}) error {
+	cfg, err := loadEffectiveConfig(flags)
+	if err != nil {
+		return err
+	}
+
+	format, _ := flags["format"].(string)
+	output, err := renderConfigValue(cfg, format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(logger.Redact(output))
+	return nil
+}
+
+// loadEffectiveConfig loads forge.yml and merges the profile selected in flags
+func loadEffectiveConfig(flags map[string]interface{}) (*config.Config, error) {
+	cfg, err := config.LoadConfig("forge.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	profile, _ := flags["profile"].(string)
+	return cfg.ApplyProfile(profile)
+}
+
+// saveValidatedDocument validates an edited forge.yml and saves it. Nothing
+// is written if the edit would leave the configuration invalid.
+func saveValidatedDocument(doc *config.Document) error {
+	cfg, _, err := doc.Config("forge.yml")
+	if err != nil {
+		return fmt.Errorf("not saving forge.yml: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("not saving forge.yml: %v", err)
+	}
+	return doc.Save("forge.yml")
+}
+
+// renderConfigValue encodes a configuration value as YAML or JSON, using the
+// forge.yml key names in both
+func renderConfigValue(value interface{}, format string) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to render configuration: %v", err)
+	}
+
+	switch format {
+	case "yaml", "":
+		return string(data), nil
+	case "json":
+		var generic interface{}
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return "", fmt.Errorf("failed to render configuration: %v", err)
+		}
+		output, err := json.MarshalIndent(generic, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to render configuration: %v", err)
+		}
+		return string(output) + "\n", nil
+	default:
+		return "", fmt.Errorf("unknown format %q (valid: yaml, json)", format)
+	}
+}
+
+func runConfigValidateCommand(args []string, flags map[string]interface{}) error {
+	configPath := "forge.yml"
+	if len(args) > 0 {
+		configPath = args[0]
+	}
+
+	pm, err := newPackageManager(nil, filepath.Dir(configPath))
+	if err != nil {
+		return err
+	}
+
+	validator := config.NewValidator()
+	validator.IsKnownPackage = pm.IsValidPackage
+
+	issues, err := validator.ValidateFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Packages can only be checked against the built-in ones until Buildroot
+	// is downloaded, and secrets not at all without a secrets file
+	_, err = os.Stat(filepath.Join(filepath.Dir(configPath), "build", "buildroot", "package"))
+	hasCatalog := err == nil
+
+	errorCount := 0
+	unknownPackages, unresolvedSecrets := false, false
+	for _, issue := range issues {
+		warning := issue.IsWarning()
+		switch {
+		case issue.Kind == config.IssueUnknownPackage && !hasCatalog:
+			warning, unknownPackages = true, true
+		case issue.Kind == config.IssueNoSecrets:
+			warning, unresolvedSecrets = true, true
+		}
+
+		if warning {
+			fmt.Printf("warning: %s\n", issue)
+		} else {
+			fmt.Printf("error: %s\n", issue)
+			errorCount++
+		}
+	}
+	if unknownPackages {
+		fmt.Println("hint: all Buildroot packages are known once 'forge build' has downloaded Buildroot")
+	}
+	if unresolvedSecrets {
+		fmt.Printf("hint: secrets are checked once the project has a secrets file and %s or %s is set\n", secrets.PassphraseEnv, secrets.KeyFileEnv)
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("%s has %d error(s)", configPath, errorCount)
+	}
+
+	if kconfig, _ := flags["kconfig"].(bool); kconfig {
+		if err := checkConfigSymbols(configPath); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("✓ %s is valid\n", configPath)
+	return nil
+}
+
+// checkConfigSymbols checks the symbols forge.yml produces against the
+// Kconfig trees of the Buildroot and kernel sources in the project
+func checkConfigSymbols(configPath string) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	projectDir, err := filepath.Abs(filepath.Dir(configPath))
+	if err != nil {
+		return err
+	}
+	if err := usePackageDefinitions(cfg, projectDir); err != nil {
+		return err
+	}
+	bm := buildroot.NewBuildrootManager(cfg, projectDir)
+	if _, err := os.Stat(bm.GetBuildrootDir()); os.IsNotExist(err) {
+		return fmt.Errorf("no Buildroot sources in %s (run 'forge build' first)", bm.GetBuildrootDir())
+	}
+
+	check, err := bm.CheckSymbols()
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range check.Issues {
+		if issue.Warning {
+			fmt.Printf("warning: %s\n", issue)
+		} else {
+			fmt.Printf("error: %s\n", issue)
+		}
+	}
+	if len(cfg.Kernel.Config) > 0 && !check.KernelChecked {
+		fmt.Println("Kernel options were not checked: the kernel sources have not been extracted yet")
+	}
+
+	if errors := check.Errors(); len(errors) > 0 {
+		return fmt.Errorf("%s sets %d invalid config symbol(s)", configPath, len(errors))
+	}
+	return nil
+}
+
+func runConfigSchemaCommand(args []string, flags map[string]interface{}) error {
+	schema, err := config.JSONSchema()
+	if err != nil {
+		return err
+	}
+
+	output, _ := flags["output"].(string)
+	if output == "" {
+		fmt.Print(string(schema))
+		return nil
+	}
+
+	if err := os.WriteFile(output, schema, 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %v", err)
+	}
+
+	fmt.Printf("Wrote forge.yml schema to %s\n", output)
+	return nil
+}
+
+func runConfigMigrateCommand(args []string, flags map[string]interface{}) error {
+	configPath := "forge.yml"
+	if len(args) > 0 {
+		configPath = args[0]
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	content := string(data)
+
+	from, err := version.DetectSchemaVersion(content)
+	if err != nil {
+		return err
+	}
+	if from.Major >= 2 {
+		fmt.Printf("✓ %s already uses schema %s\n", configPath, from)
+		return nil
+	}
+
+	if dryRun, _ := flags["dry-run"].(bool); dryRun {
+		changes, err := version.DryRunMigration(content)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Printf("  %s\n", change)
+		}
+		return nil
+	}
+
+	migrated, err := version.MigrateV1ToV2(content)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(configPath, []byte(migrated), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", configPath, err)
+	}
+
+	fmt.Printf("✓ Migrated %s to schema 2.0\n", configPath)
+	return nil
+}
@@ -0,0 +1,311 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigCommandTestSuite struct {
+	suite.Suite
+	tempDir string
+	oldDir  string
+}
+
+func TestConfigCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigCommandTestSuite))
+}
+
+func (s *ConfigCommandTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "forge-config-test-*")
+	s.Require().NoError(err)
+
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.tempDir))
+}
+
+func (s *ConfigCommandTestSuite) TearDownTest() {
+	os.Chdir(s.oldDir)
+	os.RemoveAll(s.tempDir)
+}
+
+func (s *ConfigCommandTestSuite) TestNewConfigCommand() {
+	cmd := NewConfigCommand()
+	s.NotNil(cmd)
+	s.Equal("config", cmd.Use)
+
+	var names []string
+	for _, sub := range cmd.Commands() {
+		names = append(names, sub.Name())
+	}
+	s.ElementsMatch([]string{"get", "set", "unset", "show", "validate", "schema", "migrate"}, names)
+}
+
+func (s *ConfigCommandTestSuite) TestValidateValidConfig() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+packages:
+  - busybox
+features:
+  - firewall
+`), 0644))
+
+	s.NoError(runConfigValidateCommand(nil, map[string]interface{}{}))
+}
+
+func (s *ConfigCommandTestSuite) TestValidateReportsErrors() {
+	s.Require().NoError(os.WriteFile("custom.yml", []byte(`schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+packages:
+  - no-such-package
+features:
+  - no-such-feature
+`), 0644))
+
+	err := runConfigValidateCommand([]string{"custom.yml"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "custom.yml has 1 error(s)")
+
+	// Once Buildroot is downloaded every package is known
+	s.Require().NoError(os.MkdirAll(filepath.Join("build", "buildroot", "package"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join("build", "buildroot", "package", "Config.in"), []byte(""), 0644))
+	err = runConfigValidateCommand([]string{"custom.yml"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "custom.yml has 2 error(s)")
+}
+
+func (s *ConfigCommandTestSuite) TestValidateWarnings() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+packages:
+  - no-such-package
+bootloader: grub
+mqtt:
+  users:
+    - username: sensor
+      password: "${secret:mqtt_password}"
+`), 0644))
+
+	s.NoError(runConfigValidateCommand(nil, map[string]interface{}{}))
+}
+
+func (s *ConfigCommandTestSuite) TestValidateMissingFile() {
+	err := runConfigValidateCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "failed to read config file")
+}
+
+func (s *ConfigCommandTestSuite) TestValidateKconfig() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+`), 0644))
+	flags := map[string]interface{}{"kconfig": true}
+
+	err := runConfigValidateCommand(nil, flags)
+	s.Error(err)
+	s.Contains(err.Error(), "run 'forge build' first")
+
+	// A Buildroot tree without BusyBox, which the minimal template needs
+	s.Require().NoError(os.MkdirAll(filepath.Join("build", "buildroot"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join("build", "buildroot", "Config.in"), []byte(`config BR2_x86_64
+	bool "x86_64"
+
+config BR2_TOOLCHAIN_BUILDROOT_GLIBC
+	bool "glibc"
+
+config BR2_TARGET_ROOTFS_EXT2
+	bool "ext2/3/4 root filesystem"
+
+config BR2_PACKAGE_BUSYBOX_CONFIG
+	string "BusyBox configuration file to use?"
+`), 0644))
+
+	err = runConfigValidateCommand(nil, flags)
+	s.Error(err)
+	s.Contains(err.Error(), "forge.yml sets 1 invalid config symbol(s)")
+}
+
+func (s *ConfigCommandTestSuite) TestSchemaToFile() {
+	output := filepath.Join(s.tempDir, "forge.schema.json")
+	s.NoError(runConfigSchemaCommand(nil, map[string]interface{}{"output": output}))
+
+	data, err := os.ReadFile(output)
+	s.Require().NoError(err)
+
+	var schema map[string]interface{}
+	s.NoError(json.Unmarshal(data, &schema))
+	s.Equal("forge.yml", schema["title"])
+}
+
+const configCommandProject = `schema_version: "1.0"
+name: "test"
+version: "1.0.0"  # bumped by CI
+architecture: "x86_64"
+template: "minimal"
+packages:
+  - busybox
+kernel:
+  config:
+    USB: y
+profiles:
+  dev:
+    packages: [gdb]
+`
+
+func (s *ConfigCommandTestSuite) TestSetAndUnset() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(configCommandProject), 0644))
+
+	s.NoError(runConfigSetCommand([]string{"version", "1.1.0"}, map[string]interface{}{}))
+	s.NoError(runConfigSetCommand([]string{"kernel.config.PREEMPT_RT", "y"}, map[string]interface{}{}))
+	s.NoError(runConfigUnsetCommand([]string{"kernel.config.USB"}, map[string]interface{}{}))
+
+	data, err := os.ReadFile("forge.yml")
+	s.Require().NoError(err)
+	s.Contains(string(data), "version: \"1.1.0\"  # bumped by CI\n")
+	s.Contains(string(data), "PREEMPT_RT: y")
+	s.NotContains(string(data), "USB")
+}
+
+func (s *ConfigCommandTestSuite) TestSetRejectsInvalidValues() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(configCommandProject), 0644))
+
+	err := runConfigSetCommand([]string{"build.jobs", "lots"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "build.jobs expects an integer")
+
+	err = runConfigSetCommand([]string{"architecture", "sparc"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "not saving forge.yml: invalid architecture: sparc")
+
+	err = runConfigUnsetCommand([]string{"name"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "name is required")
+
+	err = runConfigUnsetCommand([]string{"build.jobs"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "build.jobs is not set in forge.yml")
+
+	// Nothing was written
+	data, err := os.ReadFile("forge.yml")
+	s.Require().NoError(err)
+	s.Equal(configCommandProject, string(data))
+}
+
+func (s *ConfigCommandTestSuite) TestGetAndShow() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(configCommandProject), 0644))
+
+	s.NoError(runConfigGetCommand([]string{"version"}, map[string]interface{}{"format": "yaml"}))
+	s.NoError(runConfigGetCommand([]string{"packages"}, map[string]interface{}{"format": "json", "profile": "dev"}))
+	s.NoError(runConfigShowCommand(nil, map[string]interface{}{"format": "json", "profile": "dev"}))
+
+	err := runConfigGetCommand([]string{"colour"}, map[string]interface{}{"format": "yaml"})
+	s.Error(err)
+
+	err = runConfigShowCommand(nil, map[string]interface{}{"format": "toml"})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown format")
+
+	err = runConfigShowCommand(nil, map[string]interface{}{"format": "yaml", "profile": "prod"})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown profile")
+}
+
+// captureOutput returns what fn prints to stdout and to stderr
+func (s *ConfigCommandTestSuite) captureOutput(fn func() error) (string, string) {
+	read := func(r *os.File) <-chan string {
+		done := make(chan string)
+		go func() {
+			data, _ := io.ReadAll(r)
+			done <- string(data)
+		}()
+		return done
+	}
+	outR, outW, err := os.Pipe()
+	s.Require().NoError(err)
+	errR, errW, err := os.Pipe()
+	s.Require().NoError(err)
+	stdout, stderr := read(outR), read(errR)
+
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	err = fn()
+	os.Stdout, os.Stderr = oldOut, oldErr
+	outW.Close()
+	errW.Close()
+	s.Require().NoError(err)
+
+	return <-stdout, <-stderr
+}
+
+func (s *ConfigCommandTestSuite) TestGetPrintsOnlyTheValue() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(configCommandProject+"buildroot_version: \"2024.02\"\ncolour: blue\n"), 0644))
+
+	stdout, stderr := s.captureOutput(func() error {
+		return runConfigGetCommand([]string{"version"}, map[string]interface{}{"format": "yaml"})
+	})
+	s.Equal("1.0.0\n", stdout)
+	s.Contains(stderr, `deprecated key "buildroot_version"`)
+	s.Contains(stderr, `unknown key "colour"`)
+}
+
+func (s *ConfigCommandTestSuite) TestRenderConfigValue() {
+	output, err := renderConfigValue(map[string]interface{}{"packages": []string{"busybox", "gdb"}}, "json")
+	s.NoError(err)
+	s.Equal("{\n  \"packages\": [\n    \"busybox\",\n    \"gdb\"\n  ]\n}\n", output)
+
+	output, err = renderConfigValue([]string{"busybox"}, "yaml")
+	s.NoError(err)
+	s.Equal("- busybox\n", output)
+}
+
+func (s *ConfigCommandTestSuite) TestMigrate() {
+	legacy := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+# Pinned for the field devices
+buildroot_version: "2024.02"
+`
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(legacy), 0644))
+
+	s.NoError(runConfigMigrateCommand(nil, map[string]interface{}{"dry-run": true}))
+	data, err := os.ReadFile("forge.yml")
+	s.Require().NoError(err)
+	s.Equal(legacy, string(data))
+
+	s.NoError(runConfigMigrateCommand(nil, map[string]interface{}{}))
+	data, err = os.ReadFile("forge.yml")
+	s.Require().NoError(err)
+	s.Contains(string(data), `schema_version: "2.0"`)
+	s.Contains(string(data), "# Pinned for the field devices\nbuildroot:\n  version: \"2024.02\"\n")
+	s.NotContains(string(data), "buildroot_version")
+
+	cfg, err := config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	s.Equal("2024.02", cfg.Buildroot.Version)
+
+	// Running it again changes nothing
+	s.NoError(runConfigMigrateCommand(nil, map[string]interface{}{}))
+	again, err := os.ReadFile("forge.yml")
+	s.Require().NoError(err)
+	s.Equal(string(data), string(again))
+}
@@ -5,8 +5,12 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/logger"
+	"gopkg.in/yaml.v3"
 )
 
 // NewDebugCommand creates the debug command
@@ -113,9 +117,24 @@ func showConfigInfo() {
 
 	fmt.Println("  forge.yml found")
 
-	// Try to load and validate config
-	// This would integrate with the config package
-	fmt.Println("  Configuration validation: TODO")
+	cfg, err := config.LoadConfig("forge.yml")
+	if err != nil {
+		fmt.Printf("  Configuration validation: failed\n  %s\n", logger.Redact(err.Error()))
+		return
+	}
+	fmt.Println("  Configuration validation: passed")
+
+	// Resolved secrets were registered with the logger while loading
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		fmt.Printf("  Failed to render configuration: %v\n", err)
+		return
+	}
+
+	fmt.Println("  Resolved configuration:")
+	for _, line := range strings.Split(strings.TrimRight(logger.Redact(string(data)), "\n"), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
 }
 
 func getCurrentDir() string {
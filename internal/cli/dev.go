@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// NewDevCommand creates the dev command
+func NewDevCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "Build packages from local source trees while developing them",
+		Long: `Point Buildroot packages at local source directories instead of their
+downloaded sources, using <PKG>_OVERRIDE_SRCDIR entries in Buildroot's
+local.mk. Rebuild a linked package quickly with 'forge build --package <pkg>'.
+
+Overrides apply to every build of the output directory, so check
+'forge dev status' and unlink packages before building a release.`,
+		Example: `  forge dev link busybox ~/src/busybox
+  forge build --package busybox
+  forge dev status
+  forge dev unlink busybox`,
+	}
+
+	cmd.AddCommand(
+		newDevLinkCommand(),
+		newDevUnlinkCommand(),
+		newDevStatusCommand(),
+	)
+
+	return cmd
+}
+
+func newDevLinkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "link [package] [dir]",
+		Short: "Build a package from a local source directory",
+		Long: `Build a Buildroot package from a local source directory. The package's build
+directory is cleaned so the next build picks up the local sources.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runDevLinkCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose output directory to use")
+
+	return cmd
+}
+
+func newDevUnlinkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unlink [package]",
+		Short: "Build a package from its downloaded sources again",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runDevUnlinkCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose output directory to use")
+
+	return cmd
+}
+
+func newDevStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "List the packages built from local sources",
+		Args:  cobra.NoArgs,
+		RunE:  runDevStatusCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose output directory to use")
+
+	return cmd
+}
+
+func runDevLinkCommandE(cmd *cobra.Command, args []string) error {
+	profile, _ := cmd.Flags().GetString("profile")
+	return runDevLinkCommand(args, map[string]interface{}{"profile": profile})
+}
+
+func runDevUnlinkCommandE(cmd *cobra.Command, args []string) error {
+	profile, _ := cmd.Flags().GetString("profile")
+	return runDevUnlinkCommand(args, map[string]interface{}{"profile": profile})
+}
+
+func runDevStatusCommandE(cmd *cobra.Command, args []string) error {
+	profile, _ := cmd.Flags().GetString("profile")
+	return runDevStatusCommand(args, map[string]interface{}{"profile": profile})
+}
+
+func runDevLinkCommand(args []string, flags map[string]interface{}) error {
+	pkg, dir := args[0], args[1]
+
+	bm, err := projectBuildroot(flags)
+	if err != nil {
+		return err
+	}
+
+	// Only packages Buildroot knows can be overridden
+	ctx := context.Background()
+	if _, err := bm.PackageSourceDir(ctx, pkg); err != nil {
+		return err
+	}
+	if err := bm.LinkSource(pkg, dir); err != nil {
+		return err
+	}
+	if err := bm.CleanPackage(ctx, pkg); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Linked %s to %s in %s\n", pkg, dir, bm.GetOverrideFilePath())
+	fmt.Printf("Run 'forge build --package %s' to rebuild it from the local sources.\n", pkg)
+	return nil
+}
+
+func runDevUnlinkCommand(args []string, flags map[string]interface{}) error {
+	pkg := args[0]
+
+	bm, err := projectBuildroot(flags)
+	if err != nil {
+		return err
+	}
+
+	removed, err := bm.UnlinkSource(pkg)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%s is not linked to local sources", pkg)
+	}
+	if err := bm.CleanPackage(context.Background(), pkg); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Unlinked %s; it is built from its downloaded sources again\n", pkg)
+	return nil
+}
+
+func runDevStatusCommand(args []string, flags map[string]interface{}) error {
+	bm, err := projectBuildroot(flags)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := bm.SourceOverrides()
+	if err != nil {
+		return err
+	}
+	if len(overrides) == 0 {
+		fmt.Println("No packages are built from local sources")
+		return nil
+	}
+
+	fmt.Printf("Packages built from local sources (%s):\n", bm.GetOverrideFilePath())
+	for _, override := range overrides {
+		missing := ""
+		if _, err := os.Stat(override.Dir); err != nil {
+			missing = " (missing)"
+		}
+		fmt.Printf("  %-20s %s%s\n", override.Package, override.Dir, missing)
+	}
+	fmt.Println("Run 'forge dev unlink <package>' before building a release.")
+	return nil
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DevCommandTestSuite struct {
+	suite.Suite
+	oldDir string
+}
+
+func TestDevCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(DevCommandTestSuite))
+}
+
+func (s *DevCommandTestSuite) SetupTest() {
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.T().TempDir()))
+
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: test-project
+version: 1.0.0
+architecture: x86_64
+template: minimal
+`), 0644))
+
+	// A stand-in for Buildroot that knows a single package, busybox
+	buildrootDir := filepath.Join("build", "buildroot")
+	s.Require().NoError(os.MkdirAll(buildrootDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, ".config"), nil, 0644))
+	makefile := "printvars:\n\t@echo $(VARS)=$(if $(filter BUSYBOX_DIR,$(VARS)),$(CURDIR)/output/build/busybox)\n" +
+		"busybox-dirclean:\n\t@true\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte(makefile), 0644))
+	s.Require().NoError(os.MkdirAll(filepath.Join("src", "busybox"), 0755))
+}
+
+func (s *DevCommandTestSuite) TearDownTest() {
+	os.Chdir(s.oldDir)
+}
+
+func (s *DevCommandTestSuite) TestNewDevCommand() {
+	cmd := NewDevCommand()
+	s.Equal("dev", cmd.Use)
+	s.Len(cmd.Commands(), 3)
+}
+
+func (s *DevCommandTestSuite) TestLinkAndUnlink() {
+	s.NoError(runDevLinkCommand([]string{"busybox", "src/busybox"}, map[string]interface{}{}))
+	s.NoError(runDevStatusCommand(nil, map[string]interface{}{}))
+
+	data, err := os.ReadFile(filepath.Join("build", "buildroot", "local.mk"))
+	s.Require().NoError(err)
+	s.Contains(string(data), "BUSYBOX_OVERRIDE_SRCDIR = ")
+	s.Contains(string(data), filepath.Join("src", "busybox"))
+
+	s.NoError(runDevUnlinkCommand([]string{"busybox"}, map[string]interface{}{}))
+	s.NoFileExists(filepath.Join("build", "buildroot", "local.mk"))
+
+	err = runDevUnlinkCommand([]string{"busybox"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "busybox is not linked to local sources")
+}
+
+func (s *DevCommandTestSuite) TestLinkUnknownPackage() {
+	err := runDevLinkCommand([]string{"nosuchpkg", "src/busybox"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown Buildroot package: nosuchpkg")
+	s.NoFileExists(filepath.Join("build", "buildroot", "local.mk"))
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/features"
+)
+
+// NewFeaturesCommand creates the features command
+func NewFeaturesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "features",
+		Short: "Browse Forge OS features",
+		Long: `List the features that can be enabled with 'forge add feature' and show
+what each one brings into the image.`,
+	}
+
+	cmd.AddCommand(
+		newFeaturesListCommand(),
+		newFeaturesInfoCommand(),
+	)
+
+	return cmd
+}
+
+func newFeaturesListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [category]",
+		Short: "List available features",
+		Long:  `List all available features grouped by category, or the features in one category.`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runFeaturesListCommandE,
+	}
+
+	return cmd
+}
+
+func newFeaturesInfoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "info [feature]",
+		Short: "Show feature information",
+		Long: `Show the packages, Buildroot and kernel options, root filesystem files and
+conflicts of a feature.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runFeaturesInfoCommandE,
+	}
+
+	return cmd
+}
+
+func runFeaturesListCommandE(cmd *cobra.Command, args []string) error {
+	return runFeaturesListCommand(args, map[string]interface{}{})
+}
+
+func runFeaturesInfoCommandE(cmd *cobra.Command, args []string) error {
+	return runFeaturesInfoCommand(args, map[string]interface{}{})
+}
+
+func runFeaturesListCommand(args []string, flags map[string]interface{}) error {
+	fm := features.NewFeatureManager()
+
+	categories := fm.GetCategories()
+	if len(args) > 0 {
+		if len(fm.ListFeaturesByCategory(args[0])) == 0 {
+			return fmt.Errorf("no features found in category '%s' (valid: %s)", args[0], strings.Join(categories, ", "))
+		}
+		categories = []string{args[0]}
+	}
+
+	for i, category := range categories {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Features in category '%s':\n", category)
+		for _, feature := range fm.ListFeaturesByCategory(category) {
+			fmt.Printf("  %-18s %s\n", feature.Name, feature.Description)
+		}
+	}
+
+	fmt.Println("\nUse 'forge features info <feature>' for details and 'forge add feature <feature>' to enable one.")
+	return nil
+}
+
+func runFeaturesInfoCommand(args []string, flags map[string]interface{}) error {
+	fm := features.NewFeatureManager()
+
+	feature, err := fm.GetFeatureInfo(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Feature: %s\n", feature.Name)
+	fmt.Printf("Category: %s\n", feature.Category)
+	fmt.Printf("Description: %s\n", feature.Description)
+
+	if len(feature.Packages) > 0 {
+		fmt.Printf("Packages: %s\n", strings.Join(feature.Packages, ", "))
+	} else {
+		fmt.Println("Packages: none")
+	}
+
+	if len(feature.Conflicts) > 0 {
+		fmt.Printf("Conflicts: %s\n", strings.Join(feature.Conflicts, ", "))
+	}
+
+	if len(feature.Buildroot) > 0 {
+		fmt.Println("Buildroot options:")
+		for _, line := range feature.Buildroot {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	if len(feature.Kernel) > 0 {
+		fmt.Println("Kernel options:")
+		for _, line := range feature.Kernel {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	if len(feature.Overlays) > 0 {
+		var paths []string
+		for path := range feature.Overlays {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		fmt.Println("Root filesystem files:")
+		for _, path := range paths {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+
+	return nil
+}
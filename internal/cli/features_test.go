@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FeaturesCommandTestSuite struct {
+	suite.Suite
+}
+
+func TestFeaturesCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(FeaturesCommandTestSuite))
+}
+
+func (s *FeaturesCommandTestSuite) TestFeaturesCommandCreation() {
+	cmd := NewFeaturesCommand()
+	s.Equal("features", cmd.Use)
+
+	var names []string
+	for _, sub := range cmd.Commands() {
+		names = append(names, sub.Name())
+	}
+	s.ElementsMatch([]string{"list", "info"}, names)
+}
+
+func (s *FeaturesCommandTestSuite) TestFeaturesListCommand() {
+	err := runFeaturesListCommand([]string{}, map[string]interface{}{})
+	s.NoError(err)
+
+	err = runFeaturesListCommand([]string{"security"}, map[string]interface{}{})
+	s.NoError(err)
+}
+
+func (s *FeaturesCommandTestSuite) TestFeaturesListCommandInvalidCategory() {
+	err := runFeaturesListCommand([]string{"nonexistent"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "no features found in category 'nonexistent'")
+}
+
+func (s *FeaturesCommandTestSuite) TestFeaturesInfoCommand() {
+	err := runFeaturesInfoCommand([]string{"vpn-gateway"}, map[string]interface{}{})
+	s.NoError(err)
+
+	err = runFeaturesInfoCommand([]string{"nonexistent"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "feature nonexistent not found")
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// NewFetchCommand creates the fetch command
+func NewFetchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Download all sources for building without network access",
+		Long: `Download Buildroot and every source the project's configuration needs
+(make source) and pack them into a checksummed archive for machines without
+network access. The archive's SHA-256 is written next to it in sha256sum
+format.
+
+On the offline machine, point buildroot.sources in forge.yml at the archive;
+'forge build' then unpacks it, verifies every file and never touches the
+network. A shared download directory (buildroot.download_dir) with
+buildroot.offline: true works the same way without an archive. Offline
+builds check that every source is there before they start.
+
+With --check nothing is downloaded; the sources missing from the download
+directory are listed instead. This needs Buildroot itself, as downloaded by
+an earlier fetch or build or taken from buildroot.sources.`,
+		Example: `  forge fetch
+  forge fetch --profile prod -o /media/usb/sources.tar.gz
+  forge fetch --check`,
+		Args: cobra.NoArgs,
+		RunE: runFetchCommandE,
+	}
+
+	cmd.Flags().StringP("output", "o", "", "Archive to write (default <name>-<version>-sources.tar.gz)")
+	cmd.Flags().StringP("profile", "p", "", "Build profile from forge.yml to fetch sources for")
+	cmd.Flags().Bool("check", false, "Only list the sources missing from the download directory")
+
+	return cmd
+}
+
+func runFetchCommandE(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	profile, _ := cmd.Flags().GetString("profile")
+	check, _ := cmd.Flags().GetBool("check")
+
+	return runFetchCommand(args, map[string]interface{}{
+		"output":  output,
+		"profile": profile,
+		"check":   check,
+	})
+}
+
+func runFetchCommand(args []string, flags map[string]interface{}) error {
+	if _, err := os.Stat("forge.yml"); os.IsNotExist(err) {
+		return fmt.Errorf("no forge.yml found - not in a Forge project directory")
+	}
+
+	cfg, err := loadEffectiveConfig(flags)
+	if err != nil {
+		return err
+	}
+	bm, err := newProjectBuildroot(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Listing the sources needs Buildroot, which --check must not download
+	check, _ := flags["check"].(bool)
+	if check && !bm.BuildrootAvailable() {
+		return fmt.Errorf("Buildroot is not downloaded, so the sources cannot be listed (run 'forge fetch' to download everything)")
+	}
+
+	ctx := context.Background()
+	if err := bm.DownloadBuildroot(ctx); err != nil {
+		return err
+	}
+
+	// Sources depend on the resolved configuration, not just forge.yml
+	changes, err := bm.GenerateConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to generate Buildroot config: %v", err)
+	}
+	for _, change := range changes {
+		fmt.Printf("Warning: Buildroot config: %s\n", change)
+	}
+
+	if check {
+		missing, err := bm.MissingSources(ctx)
+		if err != nil {
+			return err
+		}
+		if len(missing) == 0 {
+			fmt.Printf("✓ All sources are in %s\n", bm.GetDownloadDir())
+			return nil
+		}
+		fmt.Printf("Sources missing from %s:\n", bm.GetDownloadDir())
+		for _, file := range missing {
+			fmt.Printf("  %-20s %s\n", file.Package, file.Path)
+		}
+		return fmt.Errorf("%d source(s) missing", len(missing))
+	}
+
+	output, _ := flags["output"].(string)
+	if output == "" {
+		name := cfg.Name + "-" + cfg.Version
+		if cfg.Profile != "" {
+			name += "-" + cfg.Profile
+		}
+		output = name + "-sources.tar.gz"
+	}
+
+	if err := bm.DownloadSources(ctx); err != nil {
+		return err
+	}
+	manifest, err := bm.PackSources(ctx, output)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Packed Buildroot %s and %d source(s) into %s\n", manifest.Buildroot, len(manifest.Files)-1, output)
+	fmt.Printf("Checksum written to %s.sha256\n", output)
+	fmt.Println("Set buildroot.sources to the archive in forge.yml to build without network access.")
+	return nil
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FetchCommandTestSuite struct {
+	suite.Suite
+	oldDir string
+}
+
+func TestFetchCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(FetchCommandTestSuite))
+}
+
+func (s *FetchCommandTestSuite) SetupTest() {
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.T().TempDir()))
+}
+
+func (s *FetchCommandTestSuite) TearDownTest() {
+	os.Chdir(s.oldDir)
+}
+
+// setupProject writes forge.yml and a downloaded fake Buildroot with one
+// package to fetch
+func (s *FetchCommandTestSuite) setupProject() {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: test-project
+version: 1.0.0
+architecture: x86_64
+template: minimal
+buildroot:
+  version: 2024.02.1
+`), 0644))
+
+	buildrootDir := filepath.Join("build", "buildroot")
+	s.Require().NoError(os.MkdirAll(buildrootDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join("build", "buildroot-2024.02.1.tar.gz"), []byte("buildroot"), 0644))
+	makefile := "show-info:\n\t@echo '{\"foo\":{\"dl_dir\":\"foo\",\"downloads\":[{\"source\":\"foo-1.0.tar.gz\"}]}}'\n" +
+		"source:\n\t@mkdir -p ../../dl/foo && echo foo > ../../dl/foo/foo-1.0.tar.gz\n" +
+		"olddefconfig:\n\t@true\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte(makefile), 0644))
+}
+
+func (s *FetchCommandTestSuite) TestNewFetchCommand() {
+	cmd := NewFetchCommand()
+	s.Equal("fetch", cmd.Use)
+	s.NotNil(cmd.Flags().Lookup("output"))
+	s.NotNil(cmd.Flags().Lookup("check"))
+}
+
+func (s *FetchCommandTestSuite) TestRequiresProject() {
+	err := runFetchCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "no forge.yml found")
+}
+
+func (s *FetchCommandTestSuite) TestFetch() {
+	s.setupProject()
+
+	err := runFetchCommand(nil, map[string]interface{}{"check": true})
+	s.Error(err)
+	s.Contains(err.Error(), "1 source(s) missing")
+
+	s.NoError(runFetchCommand(nil, map[string]interface{}{}))
+	s.FileExists("test-project-1.0.0-sources.tar.gz")
+	s.FileExists("test-project-1.0.0-sources.tar.gz.sha256")
+	s.FileExists(filepath.Join("dl", "foo", "foo-1.0.tar.gz"))
+
+	s.NoError(runFetchCommand(nil, map[string]interface{}{"check": true}))
+
+	s.NoError(runFetchCommand(nil, map[string]interface{}{"output": "sources.tar.gz"}))
+	s.FileExists("sources.tar.gz")
+}
+
+func (s *FetchCommandTestSuite) TestCheckDoesNotDownload() {
+	s.setupProject()
+	s.Require().NoError(os.RemoveAll("build"))
+
+	err := runFetchCommand(nil, map[string]interface{}{"check": true})
+	s.Error(err)
+	s.Contains(err.Error(), "Buildroot is not downloaded")
+	s.NoDirExists("build")
+}
@@ -104,8 +104,11 @@ func createGitignore(projectDir string) error {
 build/
 output/
 dl/
+*-sources.tar.gz
+*-sources.tar.gz.sha256
 .ccache/
 .cache/
+.forge/cache/
 
 # Logs
 *.log
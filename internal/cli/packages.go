@@ -1,12 +1,15 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/config"
 	"github.com/sst/forge/internal/packages"
 )
 
@@ -15,7 +18,15 @@ func NewPackagesCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "packages",
 		Short: "Manage Forge OS packages",
-		Long:  `Install, uninstall, and manage packages for your Forge OS project.`,
+		Long: `Install, uninstall, and manage packages for your Forge OS project.
+
+Once 'forge build' has downloaded Buildroot, every package of its package tree
+is available. The index is cached in .forge/cache.
+
+In-house packages are defined in YAML files in the project's packages/
+directory and in ~/.config/forge/packages (or $FORGE_PACKAGES_DIR). Project
+definitions take precedence over user-level ones, which take precedence over
+Buildroot and built-in packages of the same name.`,
 	}
 
 	cmd.AddCommand(
@@ -23,6 +34,11 @@ func NewPackagesCommand() *cobra.Command {
 		newPackagesUninstallCommand(),
 		newPackagesListCommand(),
 		newPackagesInfoCommand(),
+		newPackagesSearchCommand(),
+		newPackagesWhyCommand(),
+		newPackagesRdepsCommand(),
+		newPackagesGraphCommand(),
+		newPackagesSyncCommand(),
 	)
 
 	return cmd
@@ -32,12 +48,14 @@ func newPackagesInstallCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "install [packages...]",
 		Short: "Install packages",
-		Long:  `Install one or more packages and their dependencies.`,
-		Args:  cobra.MinimumNArgs(1),
-		RunE:  runPackagesInstallCommandE,
+		Long: `Install one or more packages and their dependencies by adding them to
+forge.yml. Once 'forge build' has set up Buildroot, its .config is
+regenerated from forge.yml.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runPackagesInstallCommandE,
 	}
 
-	cmd.Flags().StringP("buildroot", "b", "", "Path to Buildroot directory (auto-detect if not specified)")
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose .config to regenerate")
 
 	return cmd
 }
@@ -46,12 +64,34 @@ func newPackagesUninstallCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "uninstall [packages...]",
 		Short: "Uninstall packages",
-		Long:  `Uninstall one or more packages.`,
-		Args:  cobra.MinimumNArgs(1),
-		RunE:  runPackagesUninstallCommandE,
+		Long: `Uninstall one or more packages by removing them from forge.yml. Once
+'forge build' has set up Buildroot, its .config is regenerated from forge.yml.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runPackagesUninstallCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose .config to regenerate")
+
+	return cmd
+}
+
+func newPackagesSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Bring the Buildroot .config in line with forge.yml",
+		Long: `Report packages the Buildroot .config enables that forge.yml does not
+declare, and packages forge.yml declares that the .config does not enable,
+then regenerate the .config from forge.yml.
+
+With --adopt, packages enabled only in the .config, for example with
+menuconfig, are added to forge.yml first so they are kept.`,
+		Args: cobra.NoArgs,
+		RunE: runPackagesSyncCommandE,
 	}
 
-	cmd.Flags().StringP("buildroot", "b", "", "Path to Buildroot directory (auto-detect if not specified)")
+	cmd.Flags().Bool("adopt", false, "Add packages enabled only in the .config to forge.yml")
+	cmd.Flags().Bool("dry-run", false, "Only report the drift")
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose .config to compare")
 
 	return cmd
 }
@@ -79,6 +119,69 @@ func newPackagesInfoCommand() *cobra.Command {
 	return cmd
 }
 
+func newPackagesSearchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search [query]",
+		Short: "Search packages",
+		Long: `Search packages by name, Buildroot symbol, category and description, best
+match first. Names also match with a typo or as an abbreviation. Packages
+that are already part of the project's image are marked with *.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runPackagesSearchCommandE,
+	}
+
+	cmd.Flags().StringP("category", "c", "", "Only search packages in this category")
+	cmd.Flags().Bool("json", false, "Print the results as JSON")
+
+	return cmd
+}
+
+func newPackagesWhyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "why [package]",
+		Short: "Show why a package is in the image",
+		Long: `Show every dependency path from a package, feature or template in forge.yml
+to a package.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runPackagesWhyCommandE,
+	}
+
+	return cmd
+}
+
+func newPackagesRdepsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rdeps [package]",
+		Short: "List packages that depend on a package",
+		Long: `List the packages that depend on a package. Packages that are part of the
+image are marked with *.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runPackagesRdepsCommandE,
+	}
+
+	cmd.Flags().BoolP("recursive", "r", false, "Include packages that depend on it indirectly")
+
+	return cmd
+}
+
+func newPackagesGraphCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph [packages...]",
+		Short: "Export the package dependency graph",
+		Long: `Export the resolved dependency graph of the image as Graphviz DOT, Mermaid or
+JSON. Packages enabled directly by forge.yml are drawn with a bold border.
+Given packages, the graph of those packages is exported instead.`,
+		RunE: runPackagesGraphCommandE,
+	}
+
+	cmd.Flags().StringP("format", "f", "dot", "Output format (dot, mermaid, json)")
+	cmd.Flags().StringP("output", "o", "", "Write the graph to a file instead of stdout")
+	cmd.Flags().Bool("colors", false, "Colour packages by category")
+	cmd.Flags().Bool("weights", false, "Size packages by their installed size")
+
+	return cmd
+}
+
 func runPackagesInstallCommandE(cmd *cobra.Command, args []string) error {
 	// Check if we're in a Forge project directory
 	if _, err := os.Stat("forge.yml"); os.IsNotExist(err) {
@@ -92,45 +195,65 @@ func runPackagesInstallCommandE(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create package manager
-	pm := packages.NewPackageManager(cfg)
-
-	// Get Buildroot directory
-	buildrootDir, err := getBuildrootDir(cmd)
+	pm, err := newPackageManager(cfg, ".")
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Installing packages: %s\n", strings.Join(args, ", "))
-	fmt.Printf("Buildroot directory: %s\n\n", buildrootDir)
-
-	// Install packages
-	results := pm.InstallPackages(args, buildrootDir)
+	fmt.Printf("Installing packages: %s\n\n", strings.Join(args, ", "))
 
-	// Display results
-	successCount := 0
+	// Check the packages and their dependencies before touching forge.yml
+	results := pm.InstallPackages(args)
+	failed := 0
 	for _, result := range results {
-		if result.Success {
-			fmt.Printf("✓ %s installed successfully\n", result.Package)
-			if len(result.Services) > 0 {
-				fmt.Printf("  Services: %s\n", strings.Join(result.Services, ", "))
-			}
-			if len(result.ConfigFiles) > 0 {
-				fmt.Printf("  Config files: %s\n", strings.Join(result.ConfigFiles, ", "))
-			}
-			successCount++
-		} else {
+		if !result.Success {
 			fmt.Printf("✗ %s failed: %s\n", result.Package, result.Error)
+			failed++
 		}
 	}
+	if failed > 0 {
+		return fmt.Errorf("no packages were installed")
+	}
 
-	fmt.Printf("\nInstalled %d/%d packages successfully\n", successCount, len(results))
+	// Edit the YAML tree in place so comments and unknown sections survive
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
+	}
+	added, err := doc.AddToList("packages", args...)
+	if err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+	addedSet := make(map[string]bool)
+	for _, name := range added {
+		addedSet[name] = true
+	}
+	if err := saveValidatedDocument(doc); err != nil {
+		return err
+	}
 
-	if successCount < len(results) {
-		fmt.Println("\nNote: You may need to rebuild your Forge OS image for changes to take effect.")
-		fmt.Println("Run 'forge build' to rebuild.")
+	// Display results
+	for _, result := range results {
+		switch {
+		case result.RequiredBy != "":
+			fmt.Printf("✓ %s (required by %s)\n", result.Package, result.RequiredBy)
+		case addedSet[result.Package]:
+			fmt.Printf("✓ %s added to forge.yml\n", result.Package)
+		default:
+			fmt.Printf("✓ %s is already in forge.yml\n", result.Package)
+		}
+		if len(result.Services) > 0 {
+			fmt.Printf("  Services: %s\n", strings.Join(result.Services, ", "))
+		}
+		if len(result.ConfigFiles) > 0 {
+			fmt.Printf("  Config files: %s\n", strings.Join(result.ConfigFiles, ", "))
+		}
 	}
 
-	return nil
+	fmt.Printf("\nAdded %d of %d packages to forge.yml\n", len(added), len(args))
+
+	profile, _ := cmd.Flags().GetString("profile")
+	return regenerateBuildrootConfig(map[string]interface{}{"profile": profile})
 }
 
 func runPackagesUninstallCommandE(cmd *cobra.Command, args []string) error {
@@ -146,39 +269,47 @@ func runPackagesUninstallCommandE(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create package manager
-	pm := packages.NewPackageManager(cfg)
-
-	// Get Buildroot directory
-	buildrootDir, err := getBuildrootDir(cmd)
+	pm, err := newPackageManager(cfg, ".")
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Uninstalling packages: %s\n", strings.Join(args, ", "))
-	fmt.Printf("Buildroot directory: %s\n\n", buildrootDir)
-
-	// Uninstall packages
-	results := pm.UninstallPackages(args, buildrootDir)
+	fmt.Printf("Uninstalling packages: %s\n\n", strings.Join(args, ", "))
 
-	// Display results
-	successCount := 0
+	// Check that nothing else needs the packages before touching forge.yml
+	results := pm.UninstallPackages(args)
+	failed := 0
 	for _, result := range results {
-		if result.Success {
-			fmt.Printf("✓ %s uninstalled successfully\n", result.Package)
-			successCount++
-		} else {
+		if !result.Success {
 			fmt.Printf("✗ %s failed: %s\n", result.Package, result.Error)
+			failed++
 		}
 	}
+	if failed > 0 {
+		return fmt.Errorf("no packages were uninstalled")
+	}
 
-	fmt.Printf("\nUninstalled %d/%d packages successfully\n", successCount, len(results))
+	// Edit the YAML tree in place so comments and unknown sections survive
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
+	}
+	if _, err := doc.RemoveFromList("packages", args...); err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+	if err := saveValidatedDocument(doc); err != nil {
+		return err
+	}
 
-	if successCount > 0 {
-		fmt.Println("\nNote: You may need to rebuild your Forge OS image for changes to take effect.")
-		fmt.Println("Run 'forge build' to rebuild.")
+	// Display results
+	for _, result := range results {
+		fmt.Printf("✓ %s removed from forge.yml\n", result.Package)
 	}
 
-	return nil
+	fmt.Printf("\nRemoved %d packages from forge.yml\n", len(results))
+
+	profile, _ := cmd.Flags().GetString("profile")
+	return regenerateBuildrootConfig(map[string]interface{}{"profile": profile})
 }
 
 func runPackagesListCommandE(cmd *cobra.Command, args []string) error {
@@ -189,7 +320,10 @@ func runPackagesListCommandE(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create package manager
-	pm := packages.NewPackageManager(cfg)
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
 
 	if len(args) == 0 {
 		// List all categories
@@ -199,6 +333,13 @@ func runPackagesListCommandE(cmd *cobra.Command, args []string) error {
 			fmt.Printf("  %s\n", category)
 		}
 		fmt.Println("\nUse 'forge packages list <category>' to see packages in a category.")
+
+		if overrides := pm.Overrides(); len(overrides) > 0 {
+			fmt.Println("\nOverridden packages:")
+			for _, override := range overrides {
+				fmt.Printf("  %s\n", override)
+			}
+		}
 	} else {
 		// List packages in category
 		category := args[0]
@@ -224,7 +365,10 @@ func runPackagesInfoCommandE(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create package manager
-	pm := packages.NewPackageManager(cfg)
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
 
 	pkgName := args[0]
 	pkg, err := pm.GetPackageInfo(pkgName)
@@ -247,45 +391,371 @@ func runPackagesInfoCommandE(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Conflicts: %s\n", strings.Join(pkg.Conflicts, ", "))
 	}
 
+	if pkg.License != "" {
+		fmt.Printf("License: %s\n", pkg.License)
+	}
+
 	fmt.Printf("Buildroot package: %s\n", pkg.BuildrootPkg)
 
+	if pkg.Origin != "" {
+		fmt.Printf("Defined in: %s\n", pkg.Origin)
+	}
+
 	return nil
 }
 
-func getBuildrootDir(cmd *cobra.Command) (string, error) {
-	// Check if Buildroot directory was specified
-	buildrootDir, _ := cmd.Flags().GetString("buildroot")
-	if buildrootDir != "" {
-		if _, err := os.Stat(buildrootDir); os.IsNotExist(err) {
-			return "", fmt.Errorf("Buildroot directory does not exist: %s", buildrootDir)
+func runPackagesSearchCommandE(cmd *cobra.Command, args []string) error {
+	category, _ := cmd.Flags().GetString("category")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	return runPackagesSearchCommand(args, map[string]interface{}{
+		"category": category,
+		"json":     jsonOutput,
+	})
+}
+
+func runPackagesWhyCommandE(cmd *cobra.Command, args []string) error {
+	return runPackagesWhyCommand(args, map[string]interface{}{})
+}
+
+func runPackagesRdepsCommandE(cmd *cobra.Command, args []string) error {
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	return runPackagesRdepsCommand(args, map[string]interface{}{
+		"recursive": recursive,
+	})
+}
+
+func runPackagesGraphCommandE(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	colors, _ := cmd.Flags().GetBool("colors")
+	weights, _ := cmd.Flags().GetBool("weights")
+	return runPackagesGraphCommand(args, map[string]interface{}{
+		"format":  format,
+		"output":  output,
+		"colors":  colors,
+		"weights": weights,
+	})
+}
+
+func runPackagesSyncCommandE(cmd *cobra.Command, args []string) error {
+	adopt, _ := cmd.Flags().GetBool("adopt")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	profile, _ := cmd.Flags().GetString("profile")
+	return runPackagesSyncCommand(args, map[string]interface{}{
+		"adopt":   adopt,
+		"dry-run": dryRun,
+		"profile": profile,
+	})
+}
+
+// searchResult is a search result as printed with --json
+type searchResult struct {
+	*packages.PackageInfo
+	InProject bool `json:"in_project"`
+}
+
+func runPackagesSearchCommand(args []string, flags map[string]interface{}) error {
+	category, _ := flags["category"].(string)
+	if len(args) == 0 && category == "" {
+		return fmt.Errorf("a query or --category is required")
+	}
+
+	// Load configuration
+	cfg, err := loadForgeConfig("forge.yml")
+	if err != nil {
+		return fmt.Errorf("invalid forge.yml: %v", err)
+	}
+
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(args, " ")
+	results := pm.Search(query, category)
+
+	inImage := make(map[string]bool)
+	if image, err := pm.ImagePackages(); err == nil {
+		for _, name := range image {
+			inImage[name] = true
 		}
-		return buildrootDir, nil
 	}
 
-	// Auto-detect Buildroot directory
-	// Look for build directory in current project
-	buildDir := "build"
-	if _, err := os.Stat(buildDir); os.IsNotExist(err) {
-		return "", fmt.Errorf("no build directory found - run 'forge build' first")
+	if jsonOutput, _ := flags["json"].(bool); jsonOutput {
+		output := make([]searchResult, 0, len(results))
+		for _, result := range results {
+			output = append(output, searchResult{PackageInfo: result.Package, InProject: inImage[result.Package.Name]})
+		}
+		data, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render results: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
 	}
 
-	// Look for Buildroot directory in build
-	buildrootPath := filepath.Join(buildDir, "buildroot")
-	if _, err := os.Stat(buildrootPath); err == nil {
-		return buildrootPath, nil
+	if len(results) == 0 {
+		if category != "" && len(pm.ListPackagesByCategory(category)) == 0 {
+			return fmt.Errorf("unknown category '%s'", category)
+		}
+		return fmt.Errorf("no packages found matching '%s'", query)
 	}
 
-	// Look for Buildroot in the build directory structure
-	entries, err := os.ReadDir(buildDir)
+	if query == "" {
+		fmt.Printf("Packages in category '%s':\n", category)
+	} else {
+		fmt.Printf("Packages matching '%s':\n", query)
+	}
+	for _, result := range results {
+		marker := " "
+		if inImage[result.Package.Name] {
+			marker = "*"
+		}
+		fmt.Printf("%s %-15s %-10s %s\n", marker, result.Package.Name, result.Package.Category, result.Package.Description)
+	}
+
+	return nil
+}
+
+func runPackagesWhyCommand(args []string, flags map[string]interface{}) error {
+	cfg, err := loadForgeConfig("forge.yml")
+	if err != nil {
+		return fmt.Errorf("invalid forge.yml: %v", err)
+	}
+
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	paths, err := pm.Why(args[0])
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("%s is not part of the image", args[0])
+	}
+
+	fmt.Printf("%s is in the image because of:\n", args[0])
+	for _, path := range paths {
+		if len(path.Packages) == 1 {
+			fmt.Printf("  %s\n", path.Source)
+		} else {
+			fmt.Printf("  %s: %s\n", path.Source, strings.Join(path.Packages, " -> "))
+		}
+	}
+
+	return nil
+}
+
+func runPackagesRdepsCommand(args []string, flags map[string]interface{}) error {
+	cfg, err := loadForgeConfig("forge.yml")
+	if err != nil {
+		return fmt.Errorf("invalid forge.yml: %v", err)
+	}
+
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	if !pm.IsValidPackage(args[0]) {
+		return fmt.Errorf("package %s not found", args[0])
+	}
+
+	recursive, _ := flags["recursive"].(bool)
+	rdeps := pm.ReverseDependencies(args[0], recursive)
+	if len(rdeps) == 0 {
+		fmt.Printf("No packages depend on %s\n", args[0])
+		return nil
+	}
+
+	inImage := make(map[string]bool)
+	if image, err := pm.ImagePackages(); err == nil {
+		for _, name := range image {
+			inImage[name] = true
+		}
+	}
+
+	fmt.Printf("Packages that depend on %s:\n", args[0])
+	for _, name := range rdeps {
+		marker := " "
+		if inImage[name] {
+			marker = "*"
+		}
+		pkg, _ := pm.GetPackageInfo(name)
+		fmt.Printf("%s %-15s %s\n", marker, name, pkg.Description)
+	}
+
+	return nil
+}
+
+func runPackagesGraphCommand(args []string, flags map[string]interface{}) error {
+	cfg, err := loadForgeConfig("forge.yml")
 	if err != nil {
-		return "", fmt.Errorf("failed to read build directory: %v", err)
+		return fmt.Errorf("invalid forge.yml: %v", err)
 	}
 
-	for _, entry := range entries {
-		if strings.HasPrefix(entry.Name(), "buildroot-") && entry.IsDir() {
-			return filepath.Join(buildDir, entry.Name()), nil
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	var graph *packages.Graph
+	if len(args) > 0 {
+		for _, name := range args {
+			if !pm.IsValidPackage(name) {
+				return fmt.Errorf("package %s not found", name)
+			}
 		}
+		graph = pm.DependencyGraph(args)
+	} else if graph, err = pm.ImageGraph(); err != nil {
+		return err
+	}
+
+	format, _ := flags["format"].(string)
+	colors, _ := flags["colors"].(bool)
+	weights, _ := flags["weights"].(bool)
+	rendered, err := graph.Render(format, packages.GraphOptions{Colors: colors, Weights: weights})
+	if err != nil {
+		return err
+	}
+
+	output, _ := flags["output"].(string)
+	if output == "" {
+		fmt.Print(rendered)
+		return nil
+	}
+
+	if err := os.WriteFile(output, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("failed to write graph: %v", err)
+	}
+
+	fmt.Printf("Wrote dependency graph of %d packages to %s\n", len(graph.Nodes), output)
+	return nil
+}
+
+func runPackagesSyncCommand(args []string, flags map[string]interface{}) error {
+	cfg, err := loadEffectiveConfig(flags)
+	if err != nil {
+		return fmt.Errorf("invalid forge.yml: %v", err)
+	}
+
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	bm, err := newProjectBuildroot(cfg)
+	if err != nil {
+		return err
+	}
+	configPath := bm.GetConfigPath()
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		fmt.Println("No Buildroot .config yet; 'forge build' generates it from forge.yml")
+		return nil
+	}
+
+	drift, err := pm.CheckDrift(configPath)
+	if err != nil {
+		return err
+	}
+
+	if drift.Empty() {
+		fmt.Printf("✓ %s matches forge.yml\n", configPath)
+		return nil
+	}
+
+	if len(drift.Missing) > 0 {
+		fmt.Printf("Packages forge.yml enables that %s does not:\n", configPath)
+		for _, root := range drift.Missing {
+			fmt.Printf("  %-15s %s\n", root.Package, root.Source)
+		}
+	}
+	if len(drift.Extra) > 0 {
+		fmt.Printf("Packages %s enables that forge.yml does not declare:\n", configPath)
+		for _, name := range drift.Extra {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+
+	if dryRun, _ := flags["dry-run"].(bool); dryRun {
+		return nil
+	}
+
+	if adopt, _ := flags["adopt"].(bool); adopt && len(drift.Extra) > 0 {
+		doc, err := config.LoadDocument("forge.yml")
+		if err != nil {
+			return fmt.Errorf("failed to load forge.yml: %v", err)
+		}
+		if _, err := doc.AddToList("packages", drift.Extra...); err != nil {
+			return fmt.Errorf("failed to update forge.yml: %v", err)
+		}
+		if err := saveValidatedDocument(doc); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Added %s to forge.yml\n", strings.Join(drift.Extra, ", "))
+	}
+
+	return regenerateBuildrootConfig(flags)
+}
+
+// regenerateBuildrootConfig regenerates the Buildroot .config of the selected
+// profile from forge.yml, the single declared state, once 'forge build' has
+// set up Buildroot
+func regenerateBuildrootConfig(flags map[string]interface{}) error {
+	cfg, err := loadEffectiveConfig(flags)
+	if err != nil {
+		return fmt.Errorf("invalid forge.yml: %v", err)
+	}
+
+	bm, err := newProjectBuildroot(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(bm.GetBuildrootDir(), "Makefile")); err != nil {
+		fmt.Println("Run 'forge build' to build the image with the new packages.")
+		return nil
+	}
+
+	changes, err := bm.GenerateConfig(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to regenerate Buildroot config: %v", err)
+	}
+	for _, change := range changes {
+		fmt.Printf("Warning: Buildroot config: %s\n", change)
+	}
+
+	fmt.Printf("✓ Regenerated %s from forge.yml\n", bm.GetConfigPath())
+	return nil
+}
+
+// usePackageDefinitions makes the defconfig of cfg enable packages under the
+// Buildroot symbols their definitions name
+func usePackageDefinitions(cfg *config.Config, projectDir string) error {
+	_, err := newPackageManager(cfg, projectDir)
+	return err
+}
+
+// newPackageManager creates a package manager that also knows the packages
+// of the project's Buildroot tree, once 'forge build' has downloaded it, and
+// the user-level and project package definitions
+func newPackageManager(cfg *config.Config, projectDir string) (*packages.PackageManager, error) {
+	pm := packages.NewPackageManager(cfg)
+
+	buildrootDir := filepath.Join(projectDir, "build", "buildroot")
+	if _, err := os.Stat(filepath.Join(buildrootDir, "package")); err == nil {
+		catalog, err := packages.LoadCatalog(buildrootDir, filepath.Join(projectDir, ".forge", "cache", "packages.json"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		} else {
+			pm.AddCatalog(catalog)
+		}
+	}
+
+	if err := pm.LoadDefinitions(projectDir); err != nil {
+		return nil, err
 	}
 
-	return "", fmt.Errorf("Buildroot directory not found - specify with --buildroot flag")
+	return pm, nil
 }
@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/packages"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -87,13 +90,98 @@ BR2_PACKAGE_BUSYBOX=y
 	defer os.Chdir(oldWd)
 	os.Chdir(projectDir)
 
-	err = runPackagesInstallCommand([]string{"busybox"}, map[string]interface{}{
-		"buildroot": "",
-	})
+	err = runPackagesInstallCommand([]string{"busybox"}, map[string]interface{}{})
 	// Should work (busybox is already enabled)
 	s.NoError(err)
 }
 
+func (s *PackagesCommandTestSuite) TestPackagesInstallUpdatesForgeYml() {
+	projectDir := filepath.Join(s.tempDir, "install-project")
+	s.Require().NoError(createProjectStructure(projectDir, "minimal", "x86_64"))
+
+	// A stand-in for Buildroot's olddefconfig
+	buildrootDir := filepath.Join(projectDir, "build", "buildroot")
+	s.Require().NoError(os.MkdirAll(buildrootDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte("olddefconfig:\n\t@true\n"), 0644))
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	s.NoError(runPackagesInstallCommand([]string{"openssh", "fail2ban"}, map[string]interface{}{}))
+
+	cfg, err := config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	s.Contains(cfg.Packages, "openssh")
+	s.Contains(cfg.Packages, "fail2ban")
+	s.NotContains(cfg.Packages, "openssl") // Dependencies are resolved, not declared
+
+	// The .config is regenerated from forge.yml
+	content, err := os.ReadFile(filepath.Join(buildrootDir, ".config"))
+	s.Require().NoError(err)
+	s.Contains(string(content), "BR2_PACKAGE_OPENSSH=y")
+	s.Contains(string(content), "BR2_PACKAGE_FAIL2BAN=y")
+
+	// python3 is only a dependency of fail2ban
+	err = runPackagesUninstallCommand([]string{"python3"}, map[string]interface{}{})
+	s.Error(err)
+
+	s.NoError(runPackagesUninstallCommand([]string{"openssh"}, map[string]interface{}{}))
+	cfg, err = config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	s.NotContains(cfg.Packages, "openssh")
+
+	content, err = os.ReadFile(filepath.Join(buildrootDir, ".config"))
+	s.Require().NoError(err)
+	s.NotContains(string(content), "BR2_PACKAGE_OPENSSH=y")
+
+	// Unknown packages leave forge.yml untouched
+	err = runPackagesInstallCommand([]string{"nginx", "nonexistent"}, map[string]interface{}{})
+	s.Error(err)
+	cfg, err = config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	s.NotContains(cfg.Packages, "nginx")
+}
+
+func (s *PackagesCommandTestSuite) TestPackagesSync() {
+	projectDir := filepath.Join(s.tempDir, "sync-project")
+	s.Require().NoError(createProjectStructure(projectDir, "minimal", "x86_64"))
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	// Nothing to compare before the first build
+	s.NoError(runPackagesSyncCommand(nil, map[string]interface{}{}))
+
+	// mosquitto was enabled with menuconfig
+	buildrootDir := filepath.Join(projectDir, "build", "buildroot")
+	s.Require().NoError(os.MkdirAll(buildrootDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte("olddefconfig:\n\t@true\n"), 0644))
+	configPath := filepath.Join(buildrootDir, ".config")
+	s.Require().NoError(os.WriteFile(configPath, []byte("BR2_PACKAGE_BUSYBOX=y\nBR2_PACKAGE_MOSQUITTO=y\n"), 0644))
+
+	s.NoError(runPackagesSyncCommand(nil, map[string]interface{}{"dry-run": true}))
+	content, err := os.ReadFile(configPath)
+	s.Require().NoError(err)
+	s.Contains(string(content), "BR2_PACKAGE_MOSQUITTO=y")
+
+	s.NoError(runPackagesSyncCommand(nil, map[string]interface{}{"adopt": true}))
+	cfg, err := config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	s.Contains(cfg.Packages, "mosquitto")
+
+	content, err = os.ReadFile(configPath)
+	s.Require().NoError(err)
+	s.Contains(string(content), "BR2_PACKAGE_MOSQUITTO=y")
+	// make runs in build/buildroot, so generated paths must be absolute
+	s.Contains(string(content), `BR2_DEFCONFIG="`+filepath.Join(projectDir, "build", "buildroot", "output", "forge", "defconfig")+`"`)
+
+	err = runPackagesSyncCommand(nil, map[string]interface{}{"profile": "dev"})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown profile")
+}
+
 func (s *PackagesCommandTestSuite) TestPackagesInstallCommandNoProject() {
 	oldWd, _ := os.Getwd()
 	defer os.Chdir(oldWd)
@@ -147,15 +235,156 @@ func (s *PackagesCommandTestSuite) TestPackagesInfoCommandInvalidPackage() {
 	s.Contains(err.Error(), "package nonexistent not found")
 }
 
+func (s *PackagesCommandTestSuite) TestPackagesFromBuildrootTree() {
+	projectDir := filepath.Join(s.tempDir, "catalog-project")
+	s.Require().NoError(createProjectStructure(projectDir, "minimal", "x86_64"))
+
+	packageDir := filepath.Join(projectDir, "build", "buildroot", "package")
+	s.Require().NoError(os.MkdirAll(filepath.Join(packageDir, "hostapd"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(packageDir, "Config.in"), []byte(`menu "Networking applications"
+	source "package/hostapd/Config.in"
+endmenu
+`), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(packageDir, "hostapd", "Config.in"), []byte(`config BR2_PACKAGE_HOSTAPD
+	bool "hostapd"
+	help
+	  User space daemon for wireless access points.
+`), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(packageDir, "hostapd", "hostapd.mk"), []byte("HOSTAPD_VERSION = 2.10\n"), 0644))
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	s.NoError(runPackagesInfoCommand([]string{"hostapd"}, map[string]interface{}{}))
+	s.NoError(runPackagesSearchCommand([]string{"wireless"}, map[string]interface{}{}))
+	s.NoError(runPackagesListCommand([]string{"networking-applications"}, map[string]interface{}{}))
+	s.FileExists(filepath.Join(".forge", "cache", "packages.json"))
+
+	err := runPackagesSearchCommand([]string{"nonexistent"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "no packages found matching 'nonexistent'")
+}
+
+func (s *PackagesCommandTestSuite) TestPackagesFromDefinitions() {
+	s.T().Setenv(packages.PackagesDirEnv, filepath.Join(s.tempDir, "user-packages"))
+
+	projectDir := filepath.Join(s.tempDir, "definitions-project")
+	s.Require().NoError(createProjectStructure(projectDir, "minimal", "x86_64"))
+	s.Require().NoError(os.MkdirAll(filepath.Join(projectDir, "packages"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(projectDir, "packages", "acme.yml"), []byte(`packages:
+  - name: acme-agent
+    description: Fleet agent
+    dependencies: [openssl]
+`), 0644))
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+
+	s.NoError(runPackagesInfoCommand([]string{"acme-agent"}, map[string]interface{}{}))
+	s.NoError(runPackagesListCommand([]string{"custom"}, map[string]interface{}{}))
+
+	s.Require().NoError(os.WriteFile(filepath.Join("packages", "broken.yml"), []byte("packages: ["), 0644))
+	err := runPackagesInfoCommand([]string{"acme-agent"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "invalid package definitions")
+}
+
+func (s *PackagesCommandTestSuite) TestPackagesWhyAndRdeps() {
+	projectDir := filepath.Join(s.tempDir, "why-project")
+	s.Require().NoError(createProjectStructure(projectDir, "minimal", "x86_64"))
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+	s.Require().NoError(runAddPackageCommand([]string{"fail2ban"}, map[string]interface{}{}))
+
+	s.NoError(runPackagesWhyCommand([]string{"python3"}, map[string]interface{}{}))
+	s.NoError(runPackagesWhyCommand([]string{"busybox"}, map[string]interface{}{}))
+
+	err := runPackagesWhyCommand([]string{"nginx"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "nginx is not part of the image")
+
+	s.NoError(runPackagesRdepsCommand([]string{"expat"}, map[string]interface{}{"recursive": true}))
+	s.NoError(runPackagesRdepsCommand([]string{"fail2ban"}, map[string]interface{}{}))
+
+	err = runPackagesRdepsCommand([]string{"nonexistent"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "package nonexistent not found")
+}
+
+func (s *PackagesCommandTestSuite) TestPackagesGraph() {
+	projectDir := filepath.Join(s.tempDir, "graph-project")
+	s.Require().NoError(createProjectStructure(projectDir, "minimal", "x86_64"))
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+	s.Require().NoError(runAddPackageCommand([]string{"fail2ban"}, map[string]interface{}{}))
+
+	output := filepath.Join(projectDir, "packages.dot")
+	s.NoError(runPackagesGraphCommand(nil, map[string]interface{}{"format": "dot", "output": output, "colors": true}))
+	data, err := os.ReadFile(output)
+	s.Require().NoError(err)
+	s.Contains(string(data), `"fail2ban" -> "python3";`)
+
+	output = filepath.Join(projectDir, "nginx.mmd")
+	s.NoError(runPackagesGraphCommand([]string{"nginx"}, map[string]interface{}{"format": "mermaid", "output": output}))
+	data, err = os.ReadFile(output)
+	s.Require().NoError(err)
+	s.Contains(string(data), "nginx --> openssl")
+	s.NotContains(string(data), "fail2ban")
+
+	err = runPackagesGraphCommand(nil, map[string]interface{}{"format": "svg"})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown graph format")
+
+	err = runPackagesGraphCommand([]string{"nonexistent"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "package nonexistent not found")
+}
+
+func (s *PackagesCommandTestSuite) TestPackagesSearch() {
+	projectDir := filepath.Join(s.tempDir, "search-project")
+	s.Require().NoError(createProjectStructure(projectDir, "minimal", "x86_64"))
+
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	os.Chdir(projectDir)
+	s.Require().NoError(runAddPackageCommand([]string{"fail2ban"}, map[string]interface{}{}))
+
+	s.NoError(runPackagesSearchCommand([]string{"pyhton"}, map[string]interface{}{}))
+	s.NoError(runPackagesSearchCommand(nil, map[string]interface{}{"category": "security"}))
+	s.NoError(runPackagesSearchCommand([]string{"ssh"}, map[string]interface{}{"category": "network", "json": true}))
+
+	err := runPackagesSearchCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "a query or --category is required")
+
+	err = runPackagesSearchCommand(nil, map[string]interface{}{"category": "nonexistent"})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown category 'nonexistent'")
+
+	// JSON results carry the package fields and whether it is in the project
+	data, err := json.Marshal(searchResult{PackageInfo: &packages.PackageInfo{Name: "python3", Category: "languages"}, InProject: true})
+	s.Require().NoError(err)
+	s.Contains(string(data), `"name":"python3"`)
+	s.Contains(string(data), `"in_project":true`)
+}
+
 // Wrapper functions for testing
 func runPackagesInstallCommand(args []string, flags map[string]interface{}) error {
 	cmd := newPackagesInstallCommand()
-	if buildroot, ok := flags["buildroot"].(string); ok && buildroot != "" {
-		cmd.Flags().Set("buildroot", buildroot)
-	}
 	return runPackagesInstallCommandE(cmd, args)
 }
 
+func runPackagesUninstallCommand(args []string, flags map[string]interface{}) error {
+	cmd := newPackagesUninstallCommand()
+	return runPackagesUninstallCommandE(cmd, args)
+}
+
 func runPackagesListCommand(args []string, flags map[string]interface{}) error {
 	cmd := newPackagesListCommand()
 	return runPackagesListCommandE(cmd, args)
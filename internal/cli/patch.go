@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/buildroot"
+	"github.com/sst/forge/internal/config"
+)
+
+// NewPatchCommand creates the patch command
+func NewPatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "patch",
+		Short: "Maintain patches against Buildroot packages",
+		Long: `Maintain the project's patches against Buildroot packages.
+
+Patches live in patches/<package>/ and are applied by Buildroot after its own
+patches for the package (BR2_GLOBAL_PATCH_DIR). 'forge patch start' extracts
+a package's sources as a git repository with the current patches applied as
+commits; commit your changes there, then 'forge patch finish' writes the
+commits back as numbered patches and rebuilds the package.`,
+		Example: `  forge patch start busybox
+  cd build/buildroot/output/build/busybox-1.36.1 && git commit -a
+  forge patch finish busybox`,
+	}
+
+	cmd.AddCommand(
+		newPatchStartCommand(),
+		newPatchFinishCommand(),
+	)
+
+	return cmd
+}
+
+func newPatchStartCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start [package]",
+		Short: "Extract a package's sources for editing",
+		Long: `Extract the sources of a Buildroot package into a git repository. Buildroot's
+own patches are part of the first commit and the project's patches for the
+package follow as one commit each. Package names are Buildroot package names.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runPatchStartCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose output directory to use")
+
+	return cmd
+}
+
+func newPatchFinishCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "finish [package]",
+		Short: "Save a package's commits as patches and rebuild it",
+		Long: `Write the commits made in a package's sources since 'forge patch start' to
+patches/<package>/ as numbered git-format patches, replacing the package's
+previous patches, then rebuild the package from scratch with them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runPatchFinishCommandE,
+	}
+
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose output directory to use")
+	cmd.Flags().Bool("no-rebuild", false, "Only write the patches")
+
+	return cmd
+}
+
+func runPatchStartCommandE(cmd *cobra.Command, args []string) error {
+	profile, _ := cmd.Flags().GetString("profile")
+	return runPatchStartCommand(args, map[string]interface{}{"profile": profile})
+}
+
+func runPatchFinishCommandE(cmd *cobra.Command, args []string) error {
+	profile, _ := cmd.Flags().GetString("profile")
+	noRebuild, _ := cmd.Flags().GetBool("no-rebuild")
+	return runPatchFinishCommand(args, map[string]interface{}{
+		"profile":    profile,
+		"no-rebuild": noRebuild,
+	})
+}
+
+func runPatchStartCommand(args []string, flags map[string]interface{}) error {
+	pkg := args[0]
+
+	bm, err := projectBuildroot(flags)
+	if err != nil {
+		return err
+	}
+
+	sourceDir, applied, err := bm.StartPatch(context.Background(), pkg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Extracted %s into %s\n", pkg, sourceDir)
+	if len(applied) > 0 {
+		fmt.Printf("Applied %d patch(es) from %s as commits\n", len(applied), filepath.Join(buildroot.PatchesDir, pkg))
+	}
+	fmt.Printf("Edit the sources there and commit each change with git, then run 'forge patch finish %s'.\n", pkg)
+	return nil
+}
+
+func runPatchFinishCommand(args []string, flags map[string]interface{}) error {
+	pkg := args[0]
+
+	bm, err := projectBuildroot(flags)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	patches, err := bm.FinishPatch(ctx, pkg)
+	if err != nil {
+		return err
+	}
+
+	if len(patches) == 0 {
+		fmt.Printf("No commits in %s; it has no patches left\n", pkg)
+	} else {
+		fmt.Printf("Wrote %d patch(es) for %s:\n", len(patches), pkg)
+		for _, patch := range patches {
+			fmt.Printf("  %s\n", filepath.Join(buildroot.PatchesDir, pkg, filepath.Base(patch)))
+		}
+	}
+
+	if noRebuild, _ := flags["no-rebuild"].(bool); noRebuild {
+		fmt.Printf("Run 'forge build' to rebuild the image with the patches.\n")
+		return nil
+	}
+
+	// The first patches of a project only take effect once the config
+	// points Buildroot at the patch directory
+	changes, err := bm.GenerateConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to regenerate Buildroot config: %v", err)
+	}
+	for _, change := range changes {
+		fmt.Printf("Warning: Buildroot config: %s\n", change)
+	}
+
+	if err := bm.RebuildPackage(ctx, pkg); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Rebuilt %s; run 'forge build' to update the image\n", pkg)
+	return nil
+}
+
+// projectBuildroot sets up the Buildroot manager of the project in the
+// current directory, using the output directory of the selected profile. It
+// fails until 'forge build' has configured Buildroot.
+func projectBuildroot(flags map[string]interface{}) (*buildroot.BuildrootManager, error) {
+	cfg, err := loadEffectiveConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+	bm, err := newProjectBuildroot(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(bm.GetConfigPath()); err != nil {
+		return nil, fmt.Errorf("no Buildroot configuration in %s (run 'forge build' first)", bm.GetConfigPath())
+	}
+	return bm, nil
+}
+
+// newProjectBuildroot sets up the Buildroot manager of the project in the
+// current directory, using the output directory of the configuration's
+// profile
+func newProjectBuildroot(cfg *config.Config) (*buildroot.BuildrootManager, error) {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %v", err)
+	}
+	if err := usePackageDefinitions(cfg, projectDir); err != nil {
+		return nil, err
+	}
+
+	bm := buildroot.NewBuildrootManager(cfg, projectDir)
+	if cfg.Profile != "" {
+		bm.SetOutputDir(filepath.Join(projectDir, "build", "profiles", cfg.Profile, "output"))
+	}
+	return bm, nil
+}
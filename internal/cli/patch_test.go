@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PatchCommandTestSuite struct {
+	suite.Suite
+	oldDir string
+}
+
+func TestPatchCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(PatchCommandTestSuite))
+}
+
+func (s *PatchCommandTestSuite) SetupTest() {
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.T().TempDir()))
+
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: test-project
+version: 1.0.0
+architecture: x86_64
+template: minimal
+`), 0644))
+}
+
+func (s *PatchCommandTestSuite) TearDownTest() {
+	os.Chdir(s.oldDir)
+}
+
+func (s *PatchCommandTestSuite) TestNewPatchCommand() {
+	cmd := NewPatchCommand()
+	s.Equal("patch", cmd.Use)
+	s.Len(cmd.Commands(), 2)
+}
+
+func (s *PatchCommandTestSuite) TestRequiresBuild() {
+	err := runPatchStartCommand([]string{"busybox"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "run 'forge build' first")
+
+	err = runPatchFinishCommand([]string{"busybox"}, map[string]interface{}{"profile": "dev"})
+	s.Error(err)
+	s.Contains(err.Error(), "unknown profile")
+}
+
+func (s *PatchCommandTestSuite) TestStartAndFinish() {
+	buildrootDir := filepath.Join("build", "buildroot")
+	s.Require().NoError(os.MkdirAll(buildrootDir, 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, ".config"), nil, 0644))
+	makefile := "DIR := $(CURDIR)/output/build/foo-1.0\n" +
+		"printvars:\n\t@echo FOO_DIR=$(DIR)\n" +
+		"olddefconfig:\n\t@true\n" +
+		"foo-dirclean:\n\t@rm -rf $(DIR)\n" +
+		"foo-patch:\n\t@mkdir -p $(DIR) && echo hello > $(DIR)/main.c\n" +
+		"foo: foo-patch\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte(makefile), 0644))
+
+	s.NoError(runPatchStartCommand([]string{"foo"}, map[string]interface{}{}))
+	s.DirExists(filepath.Join(buildrootDir, "output", "build", "foo-1.0", ".git"))
+
+	s.NoError(runPatchFinishCommand([]string{"foo"}, map[string]interface{}{}))
+	s.NoDirExists(filepath.Join("patches", "foo"))
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/buildroot"
+)
+
+// NewRebuildCommand creates the rebuild command
+func NewRebuildCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rebuild [packages...]",
+		Short: "Rebuild only what changed instead of the whole image",
+		Long: `Rebuild individual Buildroot packages, the kernel or the root filesystem
+images, then regenerate the image from what is already built.
+
+Without arguments, forge compares forge.yml and patches/ with the last full
+'forge build' and rebuilds just the packages the changes touch: packages whose
+options changed are reconfigured, packages whose patches changed are cleaned
+and rebuilt, and new packages are built. Changes Buildroot cannot apply
+incrementally, like another architecture or init system, still need
+'forge build --clean'.
+
+Packages named on the command line are rebuilt with <pkg>-rebuild, or with
+<pkg>-reconfigure or <pkg>-dirclean when asked to.`,
+		Example: `  forge rebuild --dry-run
+  forge rebuild
+  forge rebuild busybox dropbear
+  forge rebuild --reconfigure --kernel
+  forge rebuild --rootfs`,
+		RunE: runRebuildCommandE,
+	}
+
+	cmd.Flags().Bool("reconfigure", false, "Configure the packages again before building them")
+	cmd.Flags().Bool("dirclean", false, "Clean the packages' build directories and build them from scratch")
+	cmd.Flags().Bool("kernel", false, "Rebuild the Linux kernel")
+	cmd.Flags().Bool("rootfs", false, "Only regenerate the root filesystem images")
+	cmd.Flags().Bool("dry-run", false, "Show what would be rebuilt without building")
+	cmd.Flags().StringP("profile", "p", "", "Build profile whose output directory to use")
+	cmd.Flags().IntP("jobs", "j", 0, "Number of parallel build jobs (0 = auto-detect)")
+
+	return cmd
+}
+
+func runRebuildCommandE(cmd *cobra.Command, args []string) error {
+	reconfigure, _ := cmd.Flags().GetBool("reconfigure")
+	dirclean, _ := cmd.Flags().GetBool("dirclean")
+	kernel, _ := cmd.Flags().GetBool("kernel")
+	rootfs, _ := cmd.Flags().GetBool("rootfs")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	profile, _ := cmd.Flags().GetString("profile")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+
+	return runRebuildCommand(args, map[string]interface{}{
+		"reconfigure": reconfigure,
+		"dirclean":    dirclean,
+		"kernel":      kernel,
+		"rootfs":      rootfs,
+		"dry-run":     dryRun,
+		"profile":     profile,
+		"jobs":        jobs,
+	})
+}
+
+func runRebuildCommand(args []string, flags map[string]interface{}) error {
+	reconfigure, _ := flags["reconfigure"].(bool)
+	dirclean, _ := flags["dirclean"].(bool)
+	kernel, _ := flags["kernel"].(bool)
+	rootfs, _ := flags["rootfs"].(bool)
+	dryRun, _ := flags["dry-run"].(bool)
+
+	if reconfigure && dirclean {
+		return fmt.Errorf("--reconfigure cannot be combined with --dirclean")
+	}
+	if rootfs && (len(args) > 0 || kernel || reconfigure || dirclean) {
+		return fmt.Errorf("--rootfs cannot be combined with packages or other rebuild options")
+	}
+
+	bm, err := projectBuildroot(flags)
+	if err != nil {
+		return err
+	}
+	if jobs, ok := flags["jobs"].(int); ok {
+		bm.SetParallelJobs(jobs)
+	}
+	ctx := context.Background()
+
+	if rootfs {
+		targets, err := bm.RootfsTargets()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Regenerating %s\n", strings.Join(targets, ", "))
+		if dryRun {
+			return nil
+		}
+		if err := bm.RebuildRootfs(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Root filesystem images regenerated in %s\n", bm.GetImagesDir())
+		return nil
+	}
+
+	if len(args) > 0 || kernel {
+		action := buildroot.Rebuild
+		if reconfigure {
+			action = buildroot.Reconfigure
+		} else if dirclean {
+			action = buildroot.Dirclean
+		}
+
+		packages := args
+		if kernel {
+			packages = append(packages, buildroot.KernelPackage)
+		}
+		var targets []string
+		for _, pkg := range packages {
+			targets = append(targets, buildroot.PackageTarget(pkg, action))
+		}
+
+		fmt.Printf("Running %s\n", strings.Join(targets, ", "))
+		if dryRun {
+			return nil
+		}
+		if err := bm.RunTargets(ctx, targets...); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Rebuilt %s; images are in %s\n", strings.Join(packages, ", "), bm.GetImagesDir())
+		return nil
+	}
+
+	plan, err := bm.PlanRebuild()
+	if err != nil {
+		return err
+	}
+	if len(plan.Clean) > 0 {
+		return fmt.Errorf("%s; run 'forge build --clean' instead", strings.Join(plan.Clean, ", "))
+	}
+	if plan.Empty() {
+		fmt.Println("Nothing changed since the last build")
+		return nil
+	}
+
+	printRebuildPlan(plan)
+	if dryRun {
+		return nil
+	}
+
+	changes, err := bm.GenerateConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to regenerate Buildroot config: %v", err)
+	}
+	for _, change := range changes {
+		fmt.Printf("Warning: Buildroot config: %s\n", change)
+	}
+
+	if err := bm.RunTargets(ctx, plan.Targets()...); err != nil {
+		return err
+	}
+	if err := bm.SaveBuildState(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Rebuild complete; images are in %s\n", bm.GetImagesDir())
+	if len(plan.Removed) > 0 {
+		fmt.Println("Removed packages stay in the root filesystem until 'forge build --clean'.")
+	}
+	return nil
+}
+
+// printRebuildPlan shows what a rebuild is going to do
+func printRebuildPlan(plan *buildroot.RebuildPlan) {
+	fmt.Println("Changes since the last build:")
+	sections := []struct {
+		label string
+		items []string
+	}{
+		{"Reconfigure", plan.Reconfigure},
+		{"Clean and rebuild", plan.Dirclean},
+		{"Build", plan.Added},
+		{"Removed", plan.Removed},
+		{"Other options", plan.Other},
+	}
+	for _, section := range sections {
+		if len(section.items) > 0 {
+			fmt.Printf("  %-18s %s\n", section.label+":", strings.Join(section.items, ", "))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RebuildCommandTestSuite struct {
+	suite.Suite
+	oldDir string
+}
+
+func TestRebuildCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(RebuildCommandTestSuite))
+}
+
+func (s *RebuildCommandTestSuite) SetupTest() {
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.T().TempDir()))
+
+	s.writeConfig("")
+}
+
+func (s *RebuildCommandTestSuite) TearDownTest() {
+	os.Chdir(s.oldDir)
+}
+
+func (s *RebuildCommandTestSuite) writeConfig(extra string) {
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: test-project
+version: 1.0.0
+architecture: x86_64
+template: minimal
+`+extra), 0644))
+}
+
+// setupBuildroot fakes a configured Buildroot tree whose make records the
+// targets it runs
+func (s *RebuildCommandTestSuite) setupBuildroot() {
+	buildrootDir := filepath.Join("build", "buildroot")
+	s.Require().NoError(os.MkdirAll(filepath.Join(buildrootDir, "package", "dropbear"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "package", "dropbear", "dropbear.mk"), nil, 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, ".config"), []byte("BR2_TARGET_ROOTFS_EXT2=y\n"), 0644))
+	makefile := "all:\n\t@echo all >> $(CURDIR)/targets.log\n" +
+		".DEFAULT:\n\t@echo $@ >> $(CURDIR)/targets.log\n"
+	s.Require().NoError(os.WriteFile(filepath.Join(buildrootDir, "Makefile"), []byte(makefile), 0644))
+}
+
+func (s *RebuildCommandTestSuite) targets() []string {
+	data, err := os.ReadFile(filepath.Join("build", "buildroot", "targets.log"))
+	s.Require().NoError(err)
+	return strings.Fields(string(data))
+}
+
+func (s *RebuildCommandTestSuite) TestNewRebuildCommand() {
+	cmd := NewRebuildCommand()
+	s.Equal("rebuild [packages...]", cmd.Use)
+	s.NotNil(cmd.Flags().Lookup("reconfigure"))
+	s.NotNil(cmd.Flags().Lookup("rootfs"))
+}
+
+func (s *RebuildCommandTestSuite) TestInvalidOptions() {
+	err := runRebuildCommand(nil, map[string]interface{}{"reconfigure": true, "dirclean": true})
+	s.Error(err)
+	s.Contains(err.Error(), "--reconfigure cannot be combined with --dirclean")
+
+	err = runRebuildCommand([]string{"busybox"}, map[string]interface{}{"rootfs": true})
+	s.Error(err)
+	s.Contains(err.Error(), "--rootfs cannot be combined")
+
+	err = runRebuildCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "run 'forge build' first")
+}
+
+func (s *RebuildCommandTestSuite) TestPackages() {
+	s.setupBuildroot()
+
+	s.NoError(runRebuildCommand([]string{"busybox"}, map[string]interface{}{"reconfigure": true, "kernel": true}))
+	s.Equal([]string{"busybox-reconfigure", "linux-reconfigure", "all"}, s.targets())
+}
+
+func (s *RebuildCommandTestSuite) TestRootfs() {
+	s.setupBuildroot()
+
+	s.NoError(runRebuildCommand(nil, map[string]interface{}{"rootfs": true}))
+	s.Equal([]string{"rootfs-ext2", "target-post-image"}, s.targets())
+}
+
+func (s *RebuildCommandTestSuite) TestChanged() {
+	s.writeConfig("kernel:\n  version: \"6.1\"\n")
+	s.setupBuildroot()
+
+	// The first build records what it was configured with
+	err := runRebuildCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "no record of a previous build")
+	bm, err := projectBuildroot(map[string]interface{}{})
+	s.Require().NoError(err)
+	s.Require().NoError(bm.SaveBuildState())
+
+	s.NoError(runRebuildCommand(nil, map[string]interface{}{}))
+	s.NoFileExists(filepath.Join("build", "buildroot", "targets.log"))
+
+	s.writeConfig("packages:\n  - dropbear\nkernel:\n  version: \"6.1\"\n  config:\n    USB_SERIAL: m\n")
+	s.NoError(runRebuildCommand(nil, map[string]interface{}{"dry-run": true}))
+	s.NoFileExists(filepath.Join("build", "buildroot", "targets.log"))
+
+	s.NoError(runRebuildCommand(nil, map[string]interface{}{}))
+	s.Equal([]string{"olddefconfig", "linux-reconfigure", "all"}, s.targets())
+
+	// The rebuild becomes the new baseline
+	s.NoError(runRebuildCommand(nil, map[string]interface{}{"dry-run": true}))
+
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: test-project
+version: 1.0.0
+architecture: aarch64
+template: minimal
+`), 0644))
+	err = runRebuildCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "run 'forge build --clean' instead")
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/packages"
+)
+
+// removeInput is where the answer to dropping unneeded packages is read from
+var removeInput = bufio.NewReader(os.Stdin)
+
+// NewRemoveCommand creates the remove command
+func NewRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove packages or features from a Forge OS project",
+		Long: `Remove packages or features from your Forge OS project configuration.
+
+Removing something other entries in forge.yml depend on is refused unless
+--force is given. Packages in forge.yml that are no longer needed afterwards
+are offered for removal too.`,
+	}
+
+	cmd.AddCommand(
+		newRemovePackageCommand(),
+		newRemoveFeatureCommand(),
+	)
+
+	return cmd
+}
+
+func newRemovePackageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "package [package-name]",
+		Short: "Remove a package from the project",
+		Long:  `Remove a package from the forge.yml configuration.`,
+		Args:  cobra.ExactArgs(1),
+		RunE:  runRemovePackageCommandE,
+	}
+
+	addRemoveFlags(cmd)
+
+	return cmd
+}
+
+func newRemoveFeatureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "feature [feature-name]",
+		Short: "Remove a feature from the project",
+		Long: `Remove a feature from the forge.yml configuration. Other entries depend on
+a feature when they need one of the packages it enables.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runRemoveFeatureCommandE,
+	}
+
+	addRemoveFlags(cmd)
+
+	return cmd
+}
+
+func addRemoveFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("force", "f", false, "Remove it even if other entries depend on it")
+	cmd.Flags().BoolP("yes", "y", false, "Remove packages that are no longer needed without asking")
+}
+
+func removeFlags(cmd *cobra.Command) map[string]interface{} {
+	force, _ := cmd.Flags().GetBool("force")
+	yes, _ := cmd.Flags().GetBool("yes")
+	return map[string]interface{}{
+		"force": force,
+		"yes":   yes,
+	}
+}
+
+func runRemovePackageCommandE(cmd *cobra.Command, args []string) error {
+	return runRemovePackageCommand(args, removeFlags(cmd))
+}
+
+func runRemoveFeatureCommandE(cmd *cobra.Command, args []string) error {
+	return runRemoveFeatureCommand(args, removeFlags(cmd))
+}
+
+func runRemovePackageCommand(args []string, flags map[string]interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("package name is required")
+	}
+
+	return runRemove("package", "packages", args[0], flags, (*packages.PackageManager).PlanPackageRemoval)
+}
+
+func runRemoveFeatureCommand(args []string, flags map[string]interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("feature name is required")
+	}
+
+	return runRemove("feature", "features", args[0], flags, (*packages.PackageManager).PlanFeatureRemoval)
+}
+
+// runRemove removes an entry from a forge.yml list after checking what
+// depends on it, then offers to remove the packages it leaves unneeded
+func runRemove(kind, list, name string, flags map[string]interface{}, plan func(*packages.PackageManager, string) (*packages.Removal, error)) error {
+	// Make sure the current config is valid before editing it
+	cfg, err := config.LoadConfigForEdit("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
+	}
+
+	pm, err := newPackageManager(cfg, ".")
+	if err != nil {
+		return err
+	}
+
+	removal, err := plan(pm, name)
+	if err != nil {
+		return err
+	}
+
+	force, _ := flags["force"].(bool)
+	if len(removal.Dependents) > 0 {
+		var dependents []string
+		for _, path := range removal.Dependents {
+			dependents = append(dependents, path.String())
+		}
+		if !force {
+			return fmt.Errorf("cannot remove %s '%s': needed by %s (use --force to remove it anyway)", kind, name, strings.Join(dependents, "; "))
+		}
+		fmt.Printf("Warning: %s '%s' is needed by %s\n", kind, name, strings.Join(dependents, "; "))
+	}
+
+	// Edit the YAML tree in place so comments and unknown sections survive
+	doc, err := config.LoadDocument("forge.yml")
+	if err != nil {
+		return fmt.Errorf("failed to load forge.yml: %v", err)
+	}
+
+	if _, err := doc.RemoveFromList(list, name); err != nil {
+		return fmt.Errorf("failed to update forge.yml: %v", err)
+	}
+
+	var orphans []string
+	if len(removal.Orphans) > 0 {
+		yes, _ := flags["yes"].(bool)
+		if !yes {
+			fmt.Printf("These packages are no longer needed: %s\n", strings.Join(removal.Orphans, ", "))
+			fmt.Print("Remove them too? [y/N] ")
+			answer, err := removeInput.ReadString('\n')
+			yes = err == nil && strings.EqualFold(strings.TrimSpace(answer), "y")
+		}
+		if yes {
+			if orphans, err = doc.RemoveFromList("packages", removal.Orphans...); err != nil {
+				return fmt.Errorf("failed to update forge.yml: %v", err)
+			}
+		}
+	}
+
+	// Save config
+	if err := doc.Save("forge.yml"); err != nil {
+		return fmt.Errorf("failed to save forge.yml: %v", err)
+	}
+
+	fmt.Printf("Removed %s '%s' from forge.yml\n", kind, name)
+	for _, orphan := range orphans {
+		fmt.Printf("Removed unneeded package '%s' from forge.yml\n", orphan)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/packages"
+	"github.com/stretchr/testify/suite"
+)
+
+type RemoveCommandTestSuite struct {
+	suite.Suite
+	tempDir string
+	oldDir  string
+}
+
+func TestRemoveCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoveCommandTestSuite))
+}
+
+func (s *RemoveCommandTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.tempDir))
+	s.T().Setenv(packages.PackagesDirEnv, s.T().TempDir())
+
+	s.Require().NoError(os.WriteFile("forge.yml", []byte(`schema_version: "1.0"
+name: test-project
+version: 1.0.0
+architecture: x86_64
+template: minimal
+packages:
+  - fail2ban
+  - python3
+  # Web frontend
+  - nginx
+  - openssl
+features:
+  - firewall
+`), 0644))
+}
+
+func (s *RemoveCommandTestSuite) TearDownTest() {
+	os.Chdir(s.oldDir)
+	removeInput = bufio.NewReader(os.Stdin)
+}
+
+func (s *RemoveCommandTestSuite) input(text string) {
+	removeInput = bufio.NewReader(strings.NewReader(text))
+}
+
+func (s *RemoveCommandTestSuite) load() *config.Config {
+	cfg, err := config.LoadConfig("forge.yml")
+	s.Require().NoError(err)
+	return cfg
+}
+
+func (s *RemoveCommandTestSuite) TestNewRemoveCommand() {
+	cmd := NewRemoveCommand()
+	s.Equal("remove", cmd.Use)
+	s.Len(cmd.Commands(), 2)
+}
+
+func (s *RemoveCommandTestSuite) TestRemovePackageWithDependents() {
+	err := runRemovePackageCommand([]string{"python3"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "cannot remove package 'python3': needed by package fail2ban (fail2ban -> python3)")
+	s.Contains(s.load().Packages, "python3")
+
+	s.NoError(runRemovePackageCommand([]string{"python3"}, map[string]interface{}{"force": true}))
+	s.NotContains(s.load().Packages, "python3")
+}
+
+func (s *RemoveCommandTestSuite) TestRemovePackageOrphans() {
+	// Declining keeps the unneeded packages
+	s.input("n\n")
+	s.NoError(runRemovePackageCommand([]string{"nginx"}, map[string]interface{}{}))
+	s.Equal([]string{"fail2ban", "python3", "openssl"}, s.load().Packages)
+
+	s.input("y\n")
+	s.NoError(runRemovePackageCommand([]string{"fail2ban"}, map[string]interface{}{}))
+	s.Equal([]string{"openssl"}, s.load().Packages)
+
+	data, err := os.ReadFile("forge.yml")
+	s.Require().NoError(err)
+	s.NotContains(string(data), "Web frontend")
+}
+
+func (s *RemoveCommandTestSuite) TestRemovePackageOrphansWithYes() {
+	// Without input nothing is dropped unless --yes is given
+	s.input("")
+	s.NoError(runRemovePackageCommand([]string{"nginx"}, map[string]interface{}{"yes": true}))
+	s.Equal([]string{"fail2ban", "python3"}, s.load().Packages)
+}
+
+func (s *RemoveCommandTestSuite) TestRemoveFeature() {
+	err := runRemoveFeatureCommand([]string{"firewall"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "cannot remove feature 'firewall': needed by package fail2ban (fail2ban -> iptables)")
+
+	s.NoError(runRemoveFeatureCommand([]string{"firewall"}, map[string]interface{}{"force": true}))
+	s.Empty(s.load().Features)
+}
+
+func (s *RemoveCommandTestSuite) TestRemoveMissing() {
+	err := runRemovePackageCommand([]string{"dropbear"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "package dropbear is not in forge.yml")
+
+	err = runRemoveFeatureCommand([]string{"monitoring"}, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "feature monitoring is not in forge.yml")
+}
@@ -0,0 +1,306 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/secrets"
+	"golang.org/x/term"
+)
+
+// secretsStdin reads passphrases and secret values piped to forge
+var secretsStdin = bufio.NewReader(os.Stdin)
+
+// secretsInput is where passphrases and secret values are read from when
+// they are not passed on the command line
+var secretsInput = secretsStdin
+
+// NewSecretsCommand creates the secrets command
+func NewSecretsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage encrypted project secrets",
+		Long: `Manage the encrypted forge.secrets file next to forge.yml.
+
+Secrets are referenced from forge.yml as ${secret:name} and resolved when the
+configuration is loaded. The file is unlocked with a key file (--key-file or
+FORGE_SECRETS_KEY_FILE) or a passphrase (FORGE_SECRETS_PASSPHRASE, or prompted).`,
+	}
+
+	cmd.PersistentFlags().String("key-file", "", "Key file used to unlock the secrets file")
+
+	cmd.AddCommand(
+		newSecretsInitCommand(),
+		newSecretsSetCommand(),
+		newSecretsGetCommand(),
+		newSecretsListCommand(),
+		newSecretsRemoveCommand(),
+	)
+
+	return cmd
+}
+
+func newSecretsInitCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the secrets file",
+		Long: `Create an empty encrypted forge.secrets file.
+With --generate-key a new random key file is written to the --key-file path.`,
+		Args: cobra.NoArgs,
+		RunE: runSecretsInitCommandE,
+	}
+
+	cmd.Flags().Bool("generate-key", false, "Generate a new key file at the --key-file path")
+
+	return cmd
+}
+
+func newSecretsSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set [name] [value]",
+		Short: "Add or update a secret",
+		Long:  `Add or update a secret. If the value is omitted it is read from standard input.`,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE:  runSecretsSetCommandE,
+	}
+}
+
+func newSecretsGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [name]",
+		Short: "Print a secret",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runSecretsGetCommandE,
+	}
+}
+
+func newSecretsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List secret names",
+		Args:  cobra.NoArgs,
+		RunE:  runSecretsListCommandE,
+	}
+}
+
+func newSecretsRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove [name]",
+		Short: "Remove a secret",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runSecretsRemoveCommandE,
+	}
+}
+
+func secretsFlags(cmd *cobra.Command) map[string]interface{} {
+	keyFile, _ := cmd.Flags().GetString("key-file")
+	generateKey, _ := cmd.Flags().GetBool("generate-key")
+	return map[string]interface{}{
+		"key-file":     keyFile,
+		"generate-key": generateKey,
+	}
+}
+
+func runSecretsInitCommandE(cmd *cobra.Command, args []string) error {
+	return runSecretsInitCommand(args, secretsFlags(cmd))
+}
+
+func runSecretsSetCommandE(cmd *cobra.Command, args []string) error {
+	return runSecretsSetCommand(args, secretsFlags(cmd))
+}
+
+func runSecretsGetCommandE(cmd *cobra.Command, args []string) error {
+	return runSecretsGetCommand(args, secretsFlags(cmd))
+}
+
+func runSecretsListCommandE(cmd *cobra.Command, args []string) error {
+	return runSecretsListCommand(args, secretsFlags(cmd))
+}
+
+func runSecretsRemoveCommandE(cmd *cobra.Command, args []string) error {
+	return runSecretsRemoveCommand(args, secretsFlags(cmd))
+}
+
+func runSecretsInitCommand(args []string, flags map[string]interface{}) error {
+	if secrets.Exists(secrets.DefaultFile) {
+		return fmt.Errorf("%s already exists", secrets.DefaultFile)
+	}
+
+	var key []byte
+	var err error
+	keyFile, _ := flags["key-file"].(string)
+	if generate, _ := flags["generate-key"].(bool); generate {
+		if keyFile == "" {
+			return fmt.Errorf("--generate-key requires --key-file")
+		}
+		key, err = secrets.GenerateKeyFile(keyFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Generated key file %s - keep it out of version control\n", keyFile)
+	} else {
+		key, err = secretsKey(flags, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := secrets.Create(secrets.DefaultFile, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %s\n", secrets.DefaultFile)
+	return nil
+}
+
+func runSecretsSetCommand(args []string, flags map[string]interface{}) error {
+	store, err := openSecretsStore(flags)
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	var value string
+	if len(args) > 1 {
+		value = args[1]
+	} else {
+		fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+		value, err = readSecretsLine()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := store.Set(name, value); err != nil {
+		return err
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved secret '%s'\n", name)
+	return nil
+}
+
+func runSecretsGetCommand(args []string, flags map[string]interface{}) error {
+	store, err := openSecretsStore(flags)
+	if err != nil {
+		return err
+	}
+
+	value, ok := store.Get(args[0])
+	if !ok {
+		return fmt.Errorf("secret '%s' not found", args[0])
+	}
+
+	fmt.Println(value)
+	return nil
+}
+
+func runSecretsListCommand(args []string, flags map[string]interface{}) error {
+	store, err := openSecretsStore(flags)
+	if err != nil {
+		return err
+	}
+
+	names := store.Names()
+	if len(names) == 0 {
+		fmt.Println("No secrets defined")
+		return nil
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func runSecretsRemoveCommand(args []string, flags map[string]interface{}) error {
+	store, err := openSecretsStore(flags)
+	if err != nil {
+		return err
+	}
+
+	if !store.Delete(args[0]) {
+		return fmt.Errorf("secret '%s' not found", args[0])
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed secret '%s'\n", args[0])
+	return nil
+}
+
+// openSecretsStore unlocks the project secrets file
+func openSecretsStore(flags map[string]interface{}) (*secrets.Store, error) {
+	if !secrets.Exists(secrets.DefaultFile) {
+		return nil, fmt.Errorf("no %s found - run 'forge secrets init' first", secrets.DefaultFile)
+	}
+
+	key, err := secretsKey(flags, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets.Open(secrets.DefaultFile, key)
+}
+
+// secretsKey returns the key from --key-file, the environment, or a
+// passphrase prompt, in that order
+func secretsKey(flags map[string]interface{}, confirm bool) ([]byte, error) {
+	if keyFile, _ := flags["key-file"].(string); keyFile != "" {
+		return secrets.ReadKeyFile(keyFile)
+	}
+
+	key, err := secrets.KeyFromEnv()
+	if !errors.Is(err, secrets.ErrNoKey) {
+		return key, err
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := readSecretsLine()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := readSecretsLine()
+		if err != nil {
+			return nil, err
+		}
+		if again != passphrase {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return []byte(passphrase), nil
+}
+
+// readSecretsLine reads one line from secretsInput. Typed at a terminal,
+// the line is not echoed.
+func readSecretsLine() (string, error) {
+	if fd := int(os.Stdin.Fd()); secretsInput == secretsStdin && term.IsTerminal(fd) {
+		line, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read input: %v", err)
+		}
+		return string(line), nil
+	}
+
+	line, err := secretsInput.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read input: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sst/forge/internal/secrets"
+	"github.com/stretchr/testify/suite"
+)
+
+type SecretsCommandTestSuite struct {
+	suite.Suite
+	tempDir string
+	oldDir  string
+}
+
+func TestSecretsCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(SecretsCommandTestSuite))
+}
+
+func (s *SecretsCommandTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "forge-secrets-test-*")
+	s.Require().NoError(err)
+
+	s.oldDir, _ = os.Getwd()
+	s.Require().NoError(os.Chdir(s.tempDir))
+
+	s.T().Setenv(secrets.KeyFileEnv, "")
+	s.T().Setenv(secrets.PassphraseEnv, "")
+}
+
+func (s *SecretsCommandTestSuite) TearDownTest() {
+	os.Chdir(s.oldDir)
+	os.RemoveAll(s.tempDir)
+	secretsInput = secretsStdin
+}
+
+func (s *SecretsCommandTestSuite) input(text string) {
+	secretsInput = bufio.NewReader(strings.NewReader(text))
+}
+
+func (s *SecretsCommandTestSuite) TestNewSecretsCommand() {
+	cmd := NewSecretsCommand()
+	s.NotNil(cmd)
+	s.Equal("secrets", cmd.Use)
+
+	var names []string
+	for _, sub := range cmd.Commands() {
+		names = append(names, sub.Name())
+	}
+	s.ElementsMatch([]string{"init", "set", "get", "list", "remove"}, names)
+}
+
+func (s *SecretsCommandTestSuite) TestInitWithPassphrasePrompt() {
+	s.input("hunter2\nhunter2\n")
+	s.Require().NoError(runSecretsInitCommand(nil, map[string]interface{}{}))
+	s.True(secrets.Exists(secrets.DefaultFile))
+
+	// The store opens with the same passphrase
+	_, err := secrets.Open(secrets.DefaultFile, []byte("hunter2"))
+	s.NoError(err)
+
+	// A second init is refused
+	err = runSecretsInitCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "already exists")
+}
+
+func (s *SecretsCommandTestSuite) TestInitPassphraseMismatch() {
+	s.input("hunter2\nhunter3\n")
+	err := runSecretsInitCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "passphrases do not match")
+	s.False(secrets.Exists(secrets.DefaultFile))
+}
+
+func (s *SecretsCommandTestSuite) TestInitGenerateKeyRequiresKeyFile() {
+	err := runSecretsInitCommand(nil, map[string]interface{}{"generate-key": true})
+	s.Error(err)
+	s.Contains(err.Error(), "--generate-key requires --key-file")
+}
+
+func (s *SecretsCommandTestSuite) TestSetGetListRemoveWithKeyFile() {
+	keyFile := filepath.Join(s.tempDir, "keys", "forge.key")
+	flags := map[string]interface{}{"key-file": keyFile, "generate-key": true}
+	s.Require().NoError(runSecretsInitCommand(nil, flags))
+
+	info, err := os.Stat(keyFile)
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	flags = map[string]interface{}{"key-file": keyFile}
+	s.NoError(runSecretsSetCommand([]string{"wifi_password", "s3cret"}, flags))
+
+	// Values can also be read from standard input
+	s.input("dashboard-pass\n")
+	s.NoError(runSecretsSetCommand([]string{"dashboard_password"}, flags))
+
+	s.NoError(runSecretsGetCommand([]string{"wifi_password"}, flags))
+	s.NoError(runSecretsListCommand(nil, flags))
+
+	key, err := secrets.ReadKeyFile(keyFile)
+	s.Require().NoError(err)
+	store, err := secrets.Open(secrets.DefaultFile, key)
+	s.Require().NoError(err)
+	s.Equal([]string{"dashboard_password", "wifi_password"}, store.Names())
+	value, _ := store.Get("dashboard_password")
+	s.Equal("dashboard-pass", value)
+
+	s.NoError(runSecretsRemoveCommand([]string{"wifi_password"}, flags))
+	err = runSecretsGetCommand([]string{"wifi_password"}, flags)
+	s.Error(err)
+	s.Contains(err.Error(), "not found")
+}
+
+func (s *SecretsCommandTestSuite) TestPassphraseFromEnvironment() {
+	s.T().Setenv(secrets.PassphraseEnv, "from-env")
+	s.Require().NoError(runSecretsInitCommand(nil, map[string]interface{}{}))
+	s.NoError(runSecretsSetCommand([]string{"token", "abc"}, map[string]interface{}{}))
+
+	s.T().Setenv(secrets.PassphraseEnv, "wrong")
+	err := runSecretsGetCommand([]string{"token"}, map[string]interface{}{})
+	s.ErrorIs(err, secrets.ErrWrongKey)
+}
+
+func (s *SecretsCommandTestSuite) TestCommandsWithoutSecretsFile() {
+	err := runSecretsListCommand(nil, map[string]interface{}{})
+	s.Error(err)
+	s.Contains(err.Error(), "forge secrets init")
+}
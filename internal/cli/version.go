@@ -4,6 +4,7 @@ import (
 	"runtime"
 
 	"github.com/spf13/cobra"
+	"github.com/sst/forge/internal/buildroot"
 )
 
 // NewVersionCommand creates the version command
@@ -28,7 +29,7 @@ func runVersionCommandE(cmd *cobra.Command, args []string) error {
 	cmd.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
 
 	if verbose {
-		cmd.Printf("Buildroot Version: %s\n", "stable")
+		cmd.Printf("Buildroot Version: %s\n", buildroot.StableVersion)
 		cmd.Printf("Kernel Version: %s\n", "latest")
 	}
 
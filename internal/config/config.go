@@ -1,8 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -10,21 +14,53 @@ import (
 
 // Config represents the main Forge OS configuration
 type Config struct {
-	SchemaVersion string                 `yaml:"schema_version" validate:"required"`
-	Name          string                 `yaml:"name" validate:"required"`
-	Version       string                 `yaml:"version" validate:"required"`
-	Architecture  string                 `yaml:"architecture" validate:"required"`
-	Template      string                 `yaml:"template" validate:"required"`
-	Buildroot     BuildrootConfig        `yaml:"buildroot"`
-	Kernel        KernelConfig           `yaml:"kernel"`
-	Packages      []string               `yaml:"packages"`
-	Features      []string               `yaml:"features"`
-	Overlays      map[string]interface{} `yaml:"overlays"`
+	SchemaVersion string                   `yaml:"schema_version" validate:"required"`
+	Name          string                   `yaml:"name" validate:"required"`
+	Version       string                   `yaml:"version" validate:"required"`
+	Architecture  string                   `yaml:"architecture" validate:"required"`
+	Template      string                   `yaml:"template" validate:"required"`
+	Buildroot     BuildrootConfig          `yaml:"buildroot"`
+	Kernel        KernelConfig             `yaml:"kernel"`
+	Packages      []string                 `yaml:"packages"`
+	Features      []string                 `yaml:"features"`
+	Apps          []AppConfig              `yaml:"apps,omitempty"`
+	Overlays      map[string]interface{}   `yaml:"overlays"`
+	Build         BuildConfig              `yaml:"build,omitempty"`
+	Testing       TestingConfig            `yaml:"testing,omitempty"`
+	Network       NetworkConfig            `yaml:"network,omitempty"`
+	Dnsmasq       DnsmasqConfig            `yaml:"dnsmasq,omitempty"`
+	Monitoring    MonitoringConfig         `yaml:"monitoring,omitempty"`
+	Watchdog      WatchdogConfig           `yaml:"watchdog,omitempty"`
+	MQTT          MQTTConfig               `yaml:"mqtt,omitempty"`
+	Profiles      map[string]ProfileConfig `yaml:"profiles,omitempty"`
+
+	// Profile is the name of the profile merged into this config, if any
+	Profile string `yaml:"-"`
+
+	// LookupPackageSymbol returns the Buildroot symbol of a package known to
+	// the package manager, which sets it. Packages it does not know, or all
+	// packages when it is nil, get the symbol PackageSymbol derives.
+	LookupPackageSymbol func(name string) (string, bool) `yaml:"-"`
+
+	// ResolvePackageDependencies returns the packages that the named ones
+	// need through their dependencies, and fails when a dependency can't be
+	// met. The package manager sets it; when it is nil the defconfig only
+	// enables the packages forge.yml names.
+	ResolvePackageDependencies func(names []string) ([]PackageDependency, error) `yaml:"-"`
+}
+
+// PackageDependency is a package enabled because another package needs it
+type PackageDependency struct {
+	Package    string
+	RequiredBy string
 }
 
 // BuildrootConfig represents Buildroot-specific configuration
 type BuildrootConfig struct {
-	Version string `yaml:"version"`
+	Version     string `yaml:"version"`
+	DownloadDir string `yaml:"download_dir,omitempty"` // Shared BR2_DL_DIR, relative to forge.yml
+	Sources     string `yaml:"sources,omitempty"`      // Source archive written by forge fetch
+	Offline     bool   `yaml:"offline,omitempty"`
 }
 
 // KernelConfig represents kernel-specific configuration
@@ -33,59 +69,125 @@ type KernelConfig struct {
 	Config  map[string]string `yaml:"config"`
 }
 
-// Validate checks if the configuration is valid
+// Validate checks if the configuration is valid and returns the first
+// violation found. Use a Validator to report every violation with its position.
 func (c *Config) Validate() error {
-	if c.SchemaVersion == "" {
-		return fmt.Errorf("schema_version is required")
+	if violations := c.violations(); len(violations) > 0 {
+		return errors.New(violations[0].Message)
 	}
-	if c.Name == "" {
-		return fmt.Errorf("name is required")
+	return nil
+}
+
+// LoadConfig loads and parses a forge.yml configuration file.
+// Unknown and deprecated keys are reported as warnings on stderr, so they
+// never mix with output such as forge config get's; deprecated keys are
+// still honoured.
+func LoadConfig(configPath string) (*Config, error) {
+	config, issues, err := loadConfigFile(configPath, NewInterpolator(filepath.Dir(configPath)))
+	if err != nil {
+		return nil, err
 	}
-	if c.Version == "" {
-		return fmt.Errorf("version is required")
+
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", issue)
+	}
+
+	return config, nil
+}
+
+// LoadConfigStrict loads a forge.yml configuration file and fails if it
+// contains keys that forge does not understand
+func LoadConfigStrict(configPath string) (*Config, error) {
+	config, issues, err := loadConfigFile(configPath, NewInterpolator(filepath.Dir(configPath)))
+	if err != nil {
+		return nil, err
 	}
-	if c.Architecture == "" {
-		return fmt.Errorf("architecture is required")
+
+	var unknown []ConfigIssue
+	for _, issue := range issues {
+		if issue.Kind == IssueUnknownKey {
+			unknown = append(unknown, issue)
+		}
 	}
-	if c.Template == "" {
-		return fmt.Errorf("template is required")
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%s", FormatIssues(unknown))
 	}
 
-	// Validate architecture
-	validArchs := []string{"x86_64", "arm", "aarch64", "riscv64", "i386", "armv7", "armv5", "mips"}
-	if !contains(validArchs, c.Architecture) {
-		return fmt.Errorf("invalid architecture: %s (valid: %s)", c.Architecture, strings.Join(validArchs, ", "))
+	return config, nil
+}
+
+// LoadConfigForEdit loads a forge.yml configuration file like LoadConfig for
+// commands that edit it rather than build from it. Secrets are not read, so
+// they come back empty.
+func LoadConfigForEdit(configPath string) (*Config, error) {
+	config, issues, err := loadConfigFile(configPath, NewEditInterpolator())
+	if err != nil {
+		return nil, err
 	}
 
-	// Validate template
-	validTemplates := []string{"minimal", "networking", "iot", "security", "industrial", "kiosk"}
-	if !contains(validTemplates, c.Template) {
-		return fmt.Errorf("invalid template: %s (valid: %s)", c.Template, strings.Join(validTemplates, ", "))
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", issue)
 	}
 
-	return nil
+	return config, nil
 }
 
-// LoadConfig loads and parses a forge.yml configuration file
-func LoadConfig(configPath string) (*Config, error) {
+// loadConfigFile reads, decodes and validates a forge.yml configuration file
+func loadConfigFile(configPath string, interpolator *Interpolator) (*Config, []ConfigIssue, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %v", err)
+		return nil, nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	config, issues, err := DecodeConfigWith(data, configPath, interpolator)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %v", err)
+		return nil, nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	return config, issues, nil
+}
+
+// DecodeConfig parses forge.yml content and reports deprecated keys and keys
+// that do not map onto the Config schema. References are resolved from the
+// environment and from the secrets file next to filename, which is otherwise
+// only used to label issues.
+func DecodeConfig(data []byte, filename string) (*Config, []ConfigIssue, error) {
+	return DecodeConfigWith(data, filename, NewInterpolator(filepath.Dir(filename)))
+}
+
+// DecodeConfigWith is DecodeConfig with a custom reference resolver
+func DecodeConfigWith(data []byte, filename string, interpolator *Interpolator) (*Config, []ConfigIssue, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
-	return &config, nil
+	issues := NormalizeLegacyKeys(&root, filename)
+
+	if unresolved := interpolator.Interpolate(&root, filename); len(unresolved) > 0 {
+		return nil, nil, fmt.Errorf("failed to resolve config references:\n%s", FormatIssues(unresolved))
+	}
+
+	var config Config
+	if err := root.Decode(&config); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	issues = append(issues, findUnknownKeys(&root, reflect.TypeOf(config), "", filename)...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+
+	return &config, issues, nil
 }
 
-// SaveConfig saves the configuration to a forge.yml file
+// SaveConfig saves the configuration to a forge.yml file. A loaded config
+// holds resolved references, so edits to existing files should go through
+// Document instead.
 func SaveConfig(config *Config, configPath string) error {
 	data, err := yaml.Marshal(config)
 	if err != nil {
@@ -99,96 +201,6 @@ func SaveConfig(config *Config, configPath string) error {
 	return nil
 }
 
-// GetBuildrootDefconfig generates a Buildroot defconfig from the configuration
-func (c *Config) GetBuildrootDefconfig() (string, error) {
-	var defconfig strings.Builder
-
-	// Base configuration
-	defconfig.WriteString("# Forge OS Buildroot defconfig\n")
-	defconfig.WriteString("# Generated from forge.yml\n\n")
-
-	// Architecture-specific settings
-	switch c.Architecture {
-	case "x86_64":
-		defconfig.WriteString("BR2_x86_64=y\n")
-		defconfig.WriteString("BR2_ARCH=\"x86_64\"\n")
-	case "arm":
-		defconfig.WriteString("BR2_arm=y\n")
-		defconfig.WriteString("BR2_ARCH=\"arm\"\n")
-	case "aarch64":
-		defconfig.WriteString("BR2_aarch64=y\n")
-		defconfig.WriteString("BR2_ARCH=\"aarch64\"\n")
-	case "riscv64":
-		defconfig.WriteString("BR2_riscv=y\n")
-		defconfig.WriteString("BR2_ARCH=\"riscv\"\n")
-	case "i386":
-		defconfig.WriteString("BR2_i386=y\n")
-		defconfig.WriteString("BR2_ARCH=\"i386\"\n")
-	case "armv7":
-		defconfig.WriteString("BR2_arm=y\n")
-		defconfig.WriteString("BR2_ARCH=\"arm\"\n")
-		defconfig.WriteString("BR2_ARM_CPU_ARMV7A=y\n")
-	case "armv5":
-		defconfig.WriteString("BR2_arm=y\n")
-		defconfig.WriteString("BR2_ARCH=\"arm\"\n")
-		defconfig.WriteString("BR2_ARM_CPU_ARMV5=y\n")
-	}
-
-	// Toolchain
-	defconfig.WriteString("BR2_TOOLCHAIN_BUILDROOT_GLIBC=y\n")
-
-	// Template-specific packages
-	switch c.Template {
-	case "minimal":
-		defconfig.WriteString("BR2_PACKAGE_BUSYBOX=y\n")
-		defconfig.WriteString("BR2_TARGET_ROOTFS_EXT2=y\n")
-	case "networking":
-		defconfig.WriteString("BR2_PACKAGE_BUSYBOX=y\n")
-		defconfig.WriteString("BR2_PACKAGE_DROPBEAR=y\n")
-		defconfig.WriteString("BR2_PACKAGE_WPA_SUPPLICANT=y\n")
-		defconfig.WriteString("BR2_TARGET_ROOTFS_EXT2=y\n")
-	case "iot":
-		defconfig.WriteString("BR2_PACKAGE_BUSYBOX=y\n")
-		defconfig.WriteString("BR2_PACKAGE_MOSQUITTO=y\n")
-		defconfig.WriteString("BR2_TARGET_ROOTFS_EXT2=y\n")
-	case "security":
-		defconfig.WriteString("BR2_PACKAGE_BUSYBOX=y\n")
-		defconfig.WriteString("BR2_PACKAGE_DROPBEAR=y\n")
-		defconfig.WriteString("BR2_PACKAGE_OPENVPN=y\n")
-		defconfig.WriteString("BR2_TARGET_ROOTFS_EXT2=y\n")
-	case "industrial":
-		defconfig.WriteString("BR2_PACKAGE_BUSYBOX=y\n")
-		defconfig.WriteString("BR2_PACKAGE_MODBUS=y\n")
-		defconfig.WriteString("BR2_TARGET_ROOTFS_EXT2=y\n")
-	case "kiosk":
-		defconfig.WriteString("BR2_PACKAGE_BUSYBOX=y\n")
-		defconfig.WriteString("BR2_PACKAGE_XORG7=y\n")
-		defconfig.WriteString("BR2_PACKAGE_CHROMIUM=y\n")
-		defconfig.WriteString("BR2_TARGET_ROOTFS_EXT2=y\n")
-	}
-
-	// Additional packages
-	for _, pkg := range c.Packages {
-		defconfig.WriteString(fmt.Sprintf("BR2_PACKAGE_%s=y\n", strings.ToUpper(pkg)))
-	}
-
-	// Features
-	for _, feature := range c.Features {
-		switch feature {
-		case "systemd":
-			defconfig.WriteString("BR2_INIT_SYSTEMD=y\n")
-		case "sysvinit":
-			defconfig.WriteString("BR2_INIT_SYSV=y\n")
-		case "network":
-			defconfig.WriteString("BR2_SYSTEM_ENABLE_NLS=y\n")
-		case "debug":
-			defconfig.WriteString("BR2_ENABLE_DEBUG=y\n")
-		}
-	}
-
-	return defconfig.String(), nil
-}
-
 // GetKernelConfig generates kernel configuration from the config
 func (c *Config) GetKernelConfig() (string, error) {
 	var kernelConfig strings.Builder
@@ -220,9 +232,13 @@ func (c *Config) GetKernelConfig() (string, error) {
 		kernelConfig.WriteString("CONFIG_SPI=y\n")
 	}
 
-	// Custom kernel config from forge.yml
-	for key, value := range c.Kernel.Config {
-		kernelConfig.WriteString(fmt.Sprintf("CONFIG_%s=%s\n", key, value))
+	// Kernel options needed by features and custom kernel config from forge.yml
+	fragment, err := c.KernelFragment()
+	if err != nil {
+		return "", err
+	}
+	for _, symbol := range fragment.Symbols() {
+		kernelConfig.WriteString(fmt.Sprintf("%s=%s\n", symbol.Name, symbol.Value))
 	}
 
 	return kernelConfig.String(), nil
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -286,7 +287,7 @@ func (s *ConfigTestSuite) TestConfigWithComplexFeatures() {
 	s.Contains(defconfig, "BR2_PACKAGE_NGINX=y")
 	s.Contains(defconfig, "BR2_PACKAGE_PYTHON3=y")
 	s.Contains(defconfig, "BR2_INIT_SYSTEMD=y")
-	s.Contains(defconfig, "BR2_SYSTEM_ENABLE_NLS=y")
+	s.Contains(defconfig, `BR2_SYSTEM_DHCP="eth0"`)
 	s.Contains(defconfig, "BR2_ENABLE_DEBUG=y")
 
 	// Test kernel config generation
@@ -298,3 +299,211 @@ func (s *ConfigTestSuite) TestConfigWithComplexFeatures() {
 	s.Contains(kernelConfig, "CONFIG_USB_SUPPORT=y")
 	s.Contains(kernelConfig, "CONFIG_WIRELESS=y")
 }
+
+func (s *ConfigTestSuite) TestLoadConfigTypedSections() {
+	configPath := filepath.Join(s.tempDir, "forge.yml")
+	configContent := `schema_version: "1.0"
+name: "router"
+version: "1.0.0"
+architecture: "x86_64"
+template: "networking"
+
+network:
+  interfaces:
+    - name: wlan0
+      type: wireless
+      mode: ap
+      channel: 6
+  firewall:
+    rules:
+      - action: accept
+        protocol: tcp
+        port: 22
+
+dnsmasq:
+  domain: "home.local"
+  dhcp_range: "192.168.1.100,192.168.1.200,12h"
+
+monitoring:
+  enabled: true
+  metrics_port: 9100
+  collect_interval: 30s
+
+build:
+  optimization: size
+  debug: true
+
+testing:
+  qemu:
+    memory: 512
+    network: user
+    ports:
+      - "2222:22"
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	s.NoError(err)
+
+	config, err := LoadConfig(configPath)
+	s.NoError(err)
+	s.Require().Len(config.Network.Interfaces, 1)
+	s.Equal("wlan0", config.Network.Interfaces[0].Name)
+	s.Equal("6", config.Network.Interfaces[0].Channel)
+	s.Require().Len(config.Network.Firewall.Rules, 1)
+	s.Equal("22", config.Network.Firewall.Rules[0].Port)
+	s.Equal("home.local", config.Dnsmasq.Domain)
+	s.True(config.Monitoring.Enabled)
+	s.Equal(30*time.Second, config.Monitoring.CollectInterval)
+	s.Equal("size", config.Build.Optimization)
+	s.True(config.Build.Debug)
+	s.Equal(512, config.Testing.QEMU.Memory)
+	s.Equal([]string{"2222:22"}, config.Testing.QEMU.Ports)
+}
+
+func (s *ConfigTestSuite) TestDecodeConfigReportsUnknownKeys() {
+	content := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+build:
+  optimisation: size
+network:
+  interfaces:
+    - name: eth0
+      dhpc: true
+web_dashboard:
+  port: 80
+overlays:
+  anything: goes
+`
+
+	config, issues, err := DecodeConfig([]byte(content), "forge.yml")
+	s.NoError(err)
+	s.NotNil(config)
+	s.Require().Len(issues, 3)
+
+	s.Equal("build.optimisation", issues[0].Path)
+	s.Equal(7, issues[0].Line)
+	s.Equal(3, issues[0].Column)
+
+	s.Equal("network.interfaces[0].dhpc", issues[1].Path)
+	s.Equal(11, issues[1].Line)
+
+	s.Equal("web_dashboard", issues[2].Path)
+	s.Equal("forge.yml:12:1: unknown key \"web_dashboard\" is ignored", issues[2].String())
+}
+
+func (s *ConfigTestSuite) TestLoadConfigStrict() {
+	configPath := filepath.Join(s.tempDir, "forge.yml")
+	configContent := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+testing:
+  qemu:
+    memroy: 512
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	s.NoError(err)
+
+	// Lenient loading only warns
+	config, err := LoadConfig(configPath)
+	s.NoError(err)
+	s.NotNil(config)
+
+	config, err = LoadConfigStrict(configPath)
+	s.Error(err)
+	s.Nil(config)
+	s.Contains(err.Error(), "forge.yml:8:5: unknown key \"testing.qemu.memroy\"")
+}
+
+func (s *ConfigTestSuite) TestDecodeExampleConfigs() {
+	files, err := filepath.Glob("../../examples/*/forge.yml")
+	s.NoError(err)
+	s.NotEmpty(files)
+
+	// Examples reference secrets that only exist on the author's machine
+	interpolator := &Interpolator{
+		LookupEnv:    func(string) (string, bool) { return "", false },
+		LookupSecret: func(name string) (string, error) { return "example-" + name, nil },
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		s.NoError(err)
+
+		_, _, err = DecodeConfigWith(data, file, interpolator)
+		s.NoError(err, "example %s should decode", file)
+	}
+}
+
+func (s *ConfigTestSuite) TestDecodeConfigLegacyKeys() {
+	content := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "arm"
+template: "industrial"
+buildroot_version: "2024.02"
+kernel_version: "6.1"
+kernel_config:
+  realtime: true
+  preempt_rt: true
+  debug_info: false
+`
+
+	config, issues, err := DecodeConfig([]byte(content), "forge.yml")
+	s.NoError(err)
+	s.Equal("2024.02", config.Buildroot.Version)
+	s.Equal("6.1", config.Kernel.Version)
+	s.Equal(map[string]string{"REALTIME": "y", "PREEMPT_RT": "y", "DEBUG_INFO": "n"}, config.Kernel.Config)
+
+	s.Require().Len(issues, 3)
+	for _, issue := range issues {
+		s.Equal(IssueDeprecated, issue.Kind)
+	}
+	s.Equal("forge.yml:6:1: deprecated key \"buildroot_version\", use \"buildroot.version\" instead", issues[0].String())
+	s.Equal("kernel_version", issues[1].Path)
+	s.Equal("kernel_config", issues[2].Path)
+	s.Equal(8, issues[2].Line)
+}
+
+func (s *ConfigTestSuite) TestDecodeConfigLegacyKeyConflict() {
+	content := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+kernel:
+  version: "6.6"
+kernel_version: "6.1"
+kernel_config:
+  PREEMPT: y
+`
+
+	config, issues, err := DecodeConfig([]byte(content), "forge.yml")
+	s.NoError(err)
+	s.Equal("6.6", config.Kernel.Version)
+	s.Equal(map[string]string{"PREEMPT": "y"}, config.Kernel.Config)
+
+	s.Require().Len(issues, 2)
+	s.Equal("deprecated key \"kernel_version\" is ignored because \"kernel.version\" is also set", issues[0].Message)
+	s.Equal("kernel_config", issues[1].Path)
+}
+
+func (s *ConfigTestSuite) TestLoadConfigStrictAcceptsLegacyKeys() {
+	configPath := filepath.Join(s.tempDir, "forge.yml")
+	configContent := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+buildroot_version: stable
+`
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	s.NoError(err)
+
+	config, err := LoadConfigStrict(configPath)
+	s.NoError(err)
+	s.Equal("stable", config.Buildroot.Version)
+}
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sst/forge/internal/features"
+)
+
+// architectureSymbols selects the target architecture and CPU in Buildroot
+var architectureSymbols = map[string][]string{
+	"x86_64":  {"BR2_x86_64"},
+	"i386":    {"BR2_i386"},
+	"arm":     {"BR2_arm"},
+	"armv5":   {"BR2_arm", "BR2_arm926t"},
+	"armv7":   {"BR2_arm", "BR2_cortex_a7"},
+	"aarch64": {"BR2_aarch64"},
+	"riscv64": {"BR2_riscv", "BR2_RISCV_64"},
+	"mips":    {"BR2_mips"},
+}
+
+// optimizationSymbols selects the compiler optimization level, and for
+// security the hardening options, for each build.optimization
+var optimizationSymbols = map[string][]string{
+	"size":        {"BR2_OPTIMIZE_S"},
+	"performance": {"BR2_OPTIMIZE_2"},
+	"speed":       {"BR2_OPTIMIZE_3"},
+	"realtime":    {"BR2_OPTIMIZE_2"},
+	"security":    {"BR2_OPTIMIZE_2", "BR2_SSP_STRONG", "BR2_RELRO_FULL", "BR2_FORTIFY_SOURCE_2"},
+	"debug":       {"BR2_OPTIMIZE_G"},
+}
+
+// kernelVersionPattern matches kernel versions such as 6.1 and 6.6.30
+var kernelVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(\.\d+)*$`)
+
+// templatePackages lists the packages each project template builds in
+var templatePackages = map[string][]string{
+	"minimal":    {"busybox"},
+	"networking": {"busybox", "dropbear", "wpa_supplicant"},
+	"iot":        {"busybox", "mosquitto"},
+	"security":   {"busybox", "dropbear", "openvpn"},
+	"industrial": {"busybox", "modbus"},
+	"kiosk":      {"busybox", "xorg-server", "chromium"},
+}
+
+// packageSymbols maps forge packages whose Buildroot package has a
+// different name
+var packageSymbols = map[string]string{
+	"modbus":      "BR2_PACKAGE_LIBMODBUS",
+	"xorg-server": "BR2_PACKAGE_XORG7",
+}
+
+// PackageSymbol returns the Buildroot symbol that enables a forge package.
+// Like Buildroot, it upper-cases the name and turns dashes into underscores.
+func PackageSymbol(name string) string {
+	if symbol, ok := packageSymbols[name]; ok {
+		return symbol
+	}
+	return "BR2_PACKAGE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// packageSymbol returns the Buildroot symbol that enables a package, which
+// package definitions may name themselves
+func (c *Config) packageSymbol(name string) string {
+	if c.LookupPackageSymbol != nil {
+		if symbol, ok := c.LookupPackageSymbol(name); ok {
+			return symbol
+		}
+	}
+	return PackageSymbol(name)
+}
+
+// DefconfigSymbol is one symbol of a defconfig and the forge.yml setting
+// that asked for it
+type DefconfigSymbol struct {
+	Name   string
+	Value  string // y, n, a number or a quoted string
+	Source string
+}
+
+// Defconfig is an ordered set of Buildroot symbols. Setting a symbol again
+// replaces its value but keeps its position.
+type Defconfig struct {
+	symbols []DefconfigSymbol
+	index   map[string]int
+}
+
+// NewDefconfig creates an empty defconfig
+func NewDefconfig() *Defconfig {
+	return &Defconfig{index: make(map[string]int)}
+}
+
+// Set assigns a raw value such as y or a quoted string to a symbol
+func (d *Defconfig) Set(name, value, source string) {
+	if i, ok := d.index[name]; ok {
+		if d.symbols[i].Value != value {
+			d.symbols[i] = DefconfigSymbol{Name: name, Value: value, Source: source}
+		}
+		return
+	}
+	d.index[name] = len(d.symbols)
+	d.symbols = append(d.symbols, DefconfigSymbol{Name: name, Value: value, Source: source})
+}
+
+// SetString assigns a string value to a symbol, quoting it for Kconfig
+func (d *Defconfig) SetString(name, value, source string) {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	d.Set(name, `"`+escaped+`"`, source)
+}
+
+// SetLine assigns a symbol from a NAME=value line
+func (d *Defconfig) SetLine(line, source string) error {
+	name, value, ok := strings.Cut(line, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid config line %q", line)
+	}
+	d.Set(name, value, source)
+	return nil
+}
+
+// Get returns the symbol with the given name
+func (d *Defconfig) Get(name string) (DefconfigSymbol, bool) {
+	i, ok := d.index[name]
+	if !ok {
+		return DefconfigSymbol{}, false
+	}
+	return d.symbols[i], true
+}
+
+// Symbols returns the symbols in the order they were first set
+func (d *Defconfig) Symbols() []DefconfigSymbol {
+	return append([]DefconfigSymbol(nil), d.symbols...)
+}
+
+// String renders the defconfig file
+func (d *Defconfig) String() string {
+	return "# Forge OS Buildroot defconfig\n# Generated from forge.yml\n\n" + d.Lines()
+}
+
+// Lines renders the symbols, one per line, without a header
+func (d *Defconfig) Lines() string {
+	var out strings.Builder
+	for _, symbol := range d.symbols {
+		if symbol.Value == "n" {
+			fmt.Fprintf(&out, "# %s is not set\n", symbol.Name)
+		} else {
+			fmt.Fprintf(&out, "%s=%s\n", symbol.Name, symbol.Value)
+		}
+	}
+	return out.String()
+}
+
+// Defconfig builds the complete Buildroot defconfig for the configuration:
+// architecture, toolchain, build options, template, packages, apps,
+// features and the packages those depend on
+func (c *Config) Defconfig() (*Defconfig, error) {
+	defconfig := NewDefconfig()
+
+	archSymbols, ok := architectureSymbols[c.Architecture]
+	if !ok {
+		return nil, fmt.Errorf("unsupported architecture: %s", c.Architecture)
+	}
+	for _, symbol := range archSymbols {
+		defconfig.Set(symbol, "y", "architecture "+c.Architecture)
+	}
+
+	defconfig.Set("BR2_TOOLCHAIN_BUILDROOT_GLIBC", "y", "toolchain")
+
+	if c.Build.Optimization != "" {
+		symbols, ok := optimizationSymbols[c.Build.Optimization]
+		if !ok {
+			return nil, fmt.Errorf("invalid optimization: %s", c.Build.Optimization)
+		}
+		for _, symbol := range symbols {
+			defconfig.Set(symbol, "y", "build.optimization")
+		}
+	}
+	if c.Build.Debug {
+		// Debug symbols are useless if the binaries are stripped
+		defconfig.Set("BR2_ENABLE_DEBUG", "y", "build.debug")
+		defconfig.Set("BR2_STRIP_none", "y", "build.debug")
+	}
+
+	// Packages forge.yml enables, whose dependencies are enabled too
+	var requested []string
+
+	for _, pkg := range templatePackages[c.Template] {
+		defconfig.Set(c.packageSymbol(pkg), "y", "template "+c.Template)
+		requested = append(requested, pkg)
+	}
+	if _, ok := templatePackages[c.Template]; ok {
+		defconfig.Set("BR2_TARGET_ROOTFS_EXT2", "y", "template "+c.Template)
+	}
+
+	for _, pkg := range c.Packages {
+		defconfig.Set(c.packageSymbol(pkg), "y", "package "+pkg)
+		requested = append(requested, pkg)
+	}
+
+	// App packages come from the project's BR2_EXTERNAL tree
+	for _, app := range c.Apps {
+		defconfig.Set(PackageSymbol(app.Name), "y", "app "+app.Name)
+	}
+
+	fm := features.NewFeatureManager()
+	resolution := fm.ResolveFeatures(c.Features)
+	if len(resolution.Missing) > 0 {
+		return nil, fmt.Errorf("unknown features: %s", strings.Join(resolution.Missing, ", "))
+	}
+	if len(resolution.Conflicts) > 0 {
+		var conflicts []string
+		for _, conflict := range resolution.Conflicts {
+			conflicts = append(conflicts, conflict.String())
+		}
+		return nil, fmt.Errorf("conflicting features: %s", strings.Join(conflicts, "; "))
+	}
+	for _, name := range resolution.Features {
+		feature, _ := fm.GetFeatureInfo(name)
+		for _, pkg := range feature.Packages {
+			defconfig.Set(c.packageSymbol(pkg), "y", "feature "+name)
+			requested = append(requested, pkg)
+		}
+		for _, line := range feature.Buildroot {
+			if err := defconfig.SetLine(line, "feature "+name); err != nil {
+				return nil, fmt.Errorf("feature %s: %v", name, err)
+			}
+		}
+	}
+
+	// Buildroot drops a package whose "depends on" isn't met instead of
+	// enabling what it needs, so the dependencies are enabled explicitly
+	if c.ResolvePackageDependencies != nil {
+		dependencies, err := c.ResolvePackageDependencies(requested)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range dependencies {
+			defconfig.Set(c.packageSymbol(dep.Package), "y", "dependency of "+dep.RequiredBy)
+		}
+	}
+
+	if err := c.kernelSymbols(defconfig); err != nil {
+		return nil, err
+	}
+
+	return defconfig, nil
+}
+
+// kernelSymbols builds the Linux kernel kernel.version asks for, with
+// toolchain headers to match. Kernel options alone get the latest kernel.
+func (c *Config) kernelSymbols(defconfig *Defconfig) error {
+	fragment, err := c.KernelFragment()
+	if err != nil {
+		return err
+	}
+	version := c.Kernel.Version
+	if version == "" && len(fragment.Symbols()) == 0 {
+		return nil
+	}
+
+	source := "kernel.version"
+	if version == "" {
+		source = "kernel.config"
+	}
+	defconfig.Set("BR2_LINUX_KERNEL", "y", source)
+	defconfig.Set("BR2_LINUX_KERNEL_USE_ARCH_DEFAULT_CONFIG", "y", source)
+	defconfig.Set("BR2_KERNEL_HEADERS_AS_KERNEL", "y", source)
+
+	switch strings.ToLower(version) {
+	case "", "latest", "lts":
+		defconfig.Set("BR2_LINUX_KERNEL_LATEST_VERSION", "y", source)
+	default:
+		match := kernelVersionPattern.FindStringSubmatch(version)
+		if match == nil {
+			return fmt.Errorf("invalid kernel version: %s", version)
+		}
+		defconfig.Set("BR2_LINUX_KERNEL_CUSTOM_VERSION", "y", source)
+		defconfig.SetString("BR2_LINUX_KERNEL_CUSTOM_VERSION_VALUE", version, source)
+		// Buildroot has to be told which series the headers come from
+		defconfig.Set("BR2_PACKAGE_HOST_LINUX_HEADERS_CUSTOM_"+match[1]+"_"+match[2], "y", source)
+	}
+	return nil
+}
+
+// KernelFragment builds the kernel config fragment for the configuration:
+// the options features need, then kernel.config from forge.yml
+func (c *Config) KernelFragment() (*Defconfig, error) {
+	fragment := NewDefconfig()
+
+	fm := features.NewFeatureManager()
+	for _, name := range fm.ResolveFeatures(c.Features).Features {
+		feature, _ := fm.GetFeatureInfo(name)
+		for _, line := range feature.Kernel {
+			if err := fragment.SetLine(line, "feature "+name); err != nil {
+				return nil, fmt.Errorf("feature %s: %v", name, err)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(c.Kernel.Config))
+	for key := range c.Kernel.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fragment.Set("CONFIG_"+key, c.Kernel.Config[key], "kernel.config")
+	}
+
+	return fragment, nil
+}
+
+// GetBuildrootDefconfig generates a Buildroot defconfig from the configuration
+func (c *Config) GetBuildrootDefconfig() (string, error) {
+	defconfig, err := c.Defconfig()
+	if err != nil {
+		return "", err
+	}
+	return defconfig.String(), nil
+}
+
+// ParseConfigSymbols reads the symbols of a Kconfig .config or defconfig
+// file. Symbols marked "is not set" have the value n.
+func ParseConfigSymbols(data []byte) map[string]string {
+	symbols := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if name, ok := strings.CutPrefix(line, "# "); ok {
+			if name, ok = strings.CutSuffix(name, " is not set"); ok {
+				symbols[name] = "n"
+			}
+			continue
+		}
+		if name, value, ok := strings.Cut(line, "="); ok && name != "" {
+			symbols[name] = value
+		}
+	}
+	return symbols
+}
+
+// SymbolChange is a defconfig symbol that did not end up in the resolved
+// configuration as requested
+type SymbolChange struct {
+	Symbol DefconfigSymbol
+	Actual string // Resolved value, empty when the symbol was dropped
+}
+
+// Discarded reports whether Buildroot dropped or disabled the symbol, as it
+// does for unknown symbols and ones whose dependencies are not met
+func (c SymbolChange) Discarded() bool {
+	return c.Actual == "" || c.Actual == "n"
+}
+
+func (c SymbolChange) String() string {
+	requested := fmt.Sprintf("%s=%s (%s)", c.Symbol.Name, c.Symbol.Value, c.Symbol.Source)
+	if c.Discarded() {
+		return requested + " was discarded"
+	}
+	return fmt.Sprintf("%s was overridden to %s", requested, c.Actual)
+}
+
+// Compare checks the defconfig against the symbols of a resolved .config
+// and returns the symbols that were discarded or overridden
+func (d *Defconfig) Compare(resolved map[string]string) []SymbolChange {
+	var changes []SymbolChange
+	for _, symbol := range d.symbols {
+		actual, ok := resolved[symbol.Name]
+		if symbol.Value == "n" && (!ok || actual == "n") {
+			continue
+		}
+		if actual != symbol.Value {
+			changes = append(changes, SymbolChange{Symbol: symbol, Actual: actual})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DefconfigTestSuite struct {
+	suite.Suite
+	config *Config
+}
+
+func TestDefconfigTestSuite(t *testing.T) {
+	suite.Run(t, new(DefconfigTestSuite))
+}
+
+func (s *DefconfigTestSuite) SetupTest() {
+	s.config = &Config{
+		SchemaVersion: "1.0",
+		Name:          "test",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+	}
+}
+
+func (s *DefconfigTestSuite) TestPackageSymbol() {
+	s.Equal("BR2_PACKAGE_OPENSSH", PackageSymbol("openssh"))
+	s.Equal("BR2_PACKAGE_I2C_TOOLS", PackageSymbol("i2c-tools"))
+	s.Equal("BR2_PACKAGE_WPA_SUPPLICANT", PackageSymbol("wpa_supplicant"))
+	s.Equal("BR2_PACKAGE_LIBMODBUS", PackageSymbol("modbus"))
+	s.Equal("BR2_PACKAGE_XORG7", PackageSymbol("xorg-server"))
+}
+
+func (s *DefconfigTestSuite) TestEveryArchitectureIsSupported() {
+	for _, arch := range ValidArchitectures {
+		s.config.Architecture = arch
+		_, err := s.config.Defconfig()
+		s.NoError(err, arch)
+	}
+
+	s.config.Architecture = "sparc"
+	_, err := s.config.Defconfig()
+	s.Error(err)
+	s.Contains(err.Error(), "unsupported architecture: sparc")
+}
+
+func (s *DefconfigTestSuite) TestSources() {
+	s.config.Template = "industrial"
+	s.config.Packages = []string{"i2c-tools", "busybox"}
+	s.config.Features = []string{"firewall"}
+	s.config.Apps = []AppConfig{{Name: "sensor-d", Path: "src/sensord", Build: "go"}}
+
+	defconfig, err := s.config.Defconfig()
+	s.Require().NoError(err)
+
+	symbol, ok := defconfig.Get("BR2_PACKAGE_LIBMODBUS")
+	s.True(ok)
+	s.Equal("template industrial", symbol.Source)
+
+	symbol, ok = defconfig.Get("BR2_PACKAGE_I2C_TOOLS")
+	s.True(ok)
+	s.Equal(DefconfigSymbol{Name: "BR2_PACKAGE_I2C_TOOLS", Value: "y", Source: "package i2c-tools"}, symbol)
+
+	// Asking for a symbol twice keeps the first reason
+	symbol, _ = defconfig.Get("BR2_PACKAGE_BUSYBOX")
+	s.Equal("template industrial", symbol.Source)
+
+	symbol, _ = defconfig.Get("BR2_PACKAGE_IPTABLES")
+	s.Equal("feature firewall", symbol.Source)
+
+	symbol, _ = defconfig.Get("BR2_PACKAGE_SENSOR_D")
+	s.Equal("app sensor-d", symbol.Source)
+}
+
+func (s *DefconfigTestSuite) TestFeatureErrors() {
+	s.config.Features = []string{"kiosk-mode"}
+	_, err := s.config.Defconfig()
+	s.Error(err)
+	s.Contains(err.Error(), "unknown features: kiosk-mode")
+
+	s.config.Features = []string{"systemd", "sysvinit"}
+	_, err = s.config.Defconfig()
+	s.Error(err)
+	s.Contains(err.Error(), "sysvinit conflicts with systemd")
+}
+
+func (s *DefconfigTestSuite) TestKernel() {
+	defconfig, err := s.config.Defconfig()
+	s.Require().NoError(err)
+	_, ok := defconfig.Get("BR2_LINUX_KERNEL")
+	s.False(ok)
+
+	s.config.Kernel.Version = "6.1.55"
+	defconfig, err = s.config.Defconfig()
+	s.Require().NoError(err)
+	s.Contains(defconfig.String(), `BR2_LINUX_KERNEL=y
+BR2_LINUX_KERNEL_USE_ARCH_DEFAULT_CONFIG=y
+BR2_KERNEL_HEADERS_AS_KERNEL=y
+BR2_LINUX_KERNEL_CUSTOM_VERSION=y
+BR2_LINUX_KERNEL_CUSTOM_VERSION_VALUE="6.1.55"
+BR2_PACKAGE_HOST_LINUX_HEADERS_CUSTOM_6_1=y
+`)
+	symbol, _ := defconfig.Get("BR2_LINUX_KERNEL")
+	s.Equal("kernel.version", symbol.Source)
+
+	s.config.Kernel.Version = "latest"
+	defconfig, err = s.config.Defconfig()
+	s.Require().NoError(err)
+	_, ok = defconfig.Get("BR2_LINUX_KERNEL_LATEST_VERSION")
+	s.True(ok)
+	_, ok = defconfig.Get("BR2_LINUX_KERNEL_CUSTOM_VERSION")
+	s.False(ok)
+
+	// Kernel options alone still need a kernel to apply to
+	s.config.Kernel = KernelConfig{Config: map[string]string{"USB_SERIAL": "m"}}
+	defconfig, err = s.config.Defconfig()
+	s.Require().NoError(err)
+	symbol, ok = defconfig.Get("BR2_LINUX_KERNEL_LATEST_VERSION")
+	s.True(ok)
+	s.Equal("kernel.config", symbol.Source)
+
+	s.config.Kernel = KernelConfig{Version: "six"}
+	_, err = s.config.Defconfig()
+	s.Error(err)
+	s.Contains(err.Error(), "invalid kernel version: six")
+}
+
+func (s *DefconfigTestSuite) TestString() {
+	defconfig := NewDefconfig()
+	defconfig.Set("BR2_arm", "y", "architecture arm")
+	defconfig.Set("BR2_TARGET_ROOTFS_TAR", "n", "test")
+	defconfig.SetString("BR2_ROOTFS_OVERLAY", `/a "b"`, "test")
+	defconfig.Set("BR2_arm", "y", "again")
+
+	s.Equal(`# Forge OS Buildroot defconfig
+# Generated from forge.yml
+
+BR2_arm=y
+# BR2_TARGET_ROOTFS_TAR is not set
+BR2_ROOTFS_OVERLAY="/a \"b\""
+`, defconfig.String())
+
+	s.Error(defconfig.SetLine("BR2_NO_VALUE", "test"))
+}
+
+func (s *DefconfigTestSuite) TestParseConfigSymbols() {
+	symbols := ParseConfigSymbols([]byte(`#
+# Automatically generated file; DO NOT EDIT.
+#
+BR2_HAVE_DOT_CONFIG=y
+BR2_ARCH="x86_64"
+# BR2_i386 is not set
+BR2_JLEVEL=0
+`))
+
+	s.Equal(map[string]string{
+		"BR2_HAVE_DOT_CONFIG": "y",
+		"BR2_ARCH":            `"x86_64"`,
+		"BR2_i386":            "n",
+		"BR2_JLEVEL":          "0",
+	}, symbols)
+}
+
+func (s *DefconfigTestSuite) TestCompare() {
+	defconfig := NewDefconfig()
+	defconfig.Set("BR2_PACKAGE_BUSYBOX", "y", "template minimal")
+	defconfig.Set("BR2_PACKAGE_MODBUS", "y", "package modbus")
+	defconfig.Set("BR2_PACKAGE_XTERM", "y", "package xterm")
+	defconfig.Set("BR2_JLEVEL", "4", "build")
+	defconfig.Set("BR2_TARGET_ROOTFS_TAR", "n", "test")
+
+	changes := defconfig.Compare(map[string]string{
+		"BR2_PACKAGE_BUSYBOX": "y",
+		"BR2_PACKAGE_XTERM":   "n",
+		"BR2_JLEVEL":          "0",
+	})
+
+	var messages []string
+	for _, change := range changes {
+		messages = append(messages, change.String())
+	}
+	s.Equal([]string{
+		"BR2_PACKAGE_MODBUS=y (package modbus) was discarded",
+		"BR2_PACKAGE_XTERM=y (package xterm) was discarded",
+		"BR2_JLEVEL=4 (build) was overridden to 0",
+	}, messages)
+}
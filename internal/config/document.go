@@ -0,0 +1,847 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a forge.yml file held both as text and as a YAML node tree.
+// Edits are spliced into the original text so comments, key order, blank
+// lines and sections that Config does not model are left untouched. Files
+// with CRLF line endings are edited with plain newlines and written back
+// with CRLF.
+type Document struct {
+	data []byte
+	root yaml.Node
+	crlf bool
+}
+
+// pairRef locates a key/value pair inside a mapping node
+type pairRef struct {
+	mapping *yaml.Node
+	index   int
+}
+
+func (p pairRef) key() *yaml.Node   { return p.mapping.Content[p.index] }
+func (p pairRef) value() *yaml.Node { return p.mapping.Content[p.index+1] }
+
+// itemPrefix matches the text in front of a block sequence item, e.g. "  - "
+var itemPrefix = regexp.MustCompile(`^\s*-\s+$`)
+
+// LoadDocument reads a forge.yml file for editing
+func LoadDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	return ParseDocument(data)
+}
+
+// ParseDocument parses forge.yml content for editing
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{crlf: bytes.Contains(data, []byte("\r\n"))}
+	if doc.crlf {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	if err := doc.reset(data); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Bytes returns the current document text
+func (d *Document) Bytes() []byte {
+	if d.crlf {
+		return bytes.ReplaceAll(d.data, []byte("\n"), []byte("\r\n"))
+	}
+	return d.data
+}
+
+// Save writes the document to path
+func (d *Document) Save(path string) error {
+	if err := os.WriteFile(path, d.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// Config decodes the document into a Config to check an edit. The filename
+// is only used to label issues. Secrets are not read, so they come back empty.
+func (d *Document) Config(filename string) (*Config, []ConfigIssue, error) {
+	return DecodeConfigWith(d.data, filename, NewEditInterpolator())
+}
+
+// Lookup returns the node at a dotted path such as kernel.version, or nil
+// if any part of the path is missing
+func (d *Document) Lookup(path string) *yaml.Node {
+	chain := d.lookup(path)
+	if chain == nil {
+		return nil
+	}
+	return chain[len(chain)-1].value()
+}
+
+// List returns the scalar items of the sequence at a dotted path
+func (d *Document) List(path string) []string {
+	chain := d.lookup(path)
+	if chain == nil {
+		return nil
+	}
+
+	var values []string
+	for _, item := range chain[len(chain)-1].value().Content {
+		if item.Kind == yaml.ScalarNode {
+			values = append(values, item.Value)
+		}
+	}
+	return values
+}
+
+// AddToList appends values to the sequence at a dotted path, creating it if
+// needed. Values already in the list are skipped; the ones added are returned.
+func (d *Document) AddToList(path string, values ...string) ([]string, error) {
+	existing := d.List(path)
+
+	var added []string
+	for _, value := range values {
+		if !contains(existing, value) && !contains(added, value) {
+			added = append(added, value)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	chain := d.lookup(path)
+	if chain == nil {
+		items := make([]*yaml.Node, len(added))
+		for i, value := range added {
+			items[i] = scalarNode(value)
+		}
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: items}
+		return added, d.insertPath(path, seq)
+	}
+
+	ref := chain[len(chain)-1]
+	seq := ref.value()
+	if seq.Kind != yaml.SequenceNode {
+		if seq.Kind != yaml.ScalarNode || seq.Tag != "!!null" {
+			return nil, fmt.Errorf("%s is not a list", path)
+		}
+		// "packages:" with no items parses as null
+		*seq = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	}
+
+	lines := d.lines()
+	if last, ok := d.blockItemPrefix(seq, lines); ok {
+		_, end := d.span(chain, lines)
+		newLines := make([]string, len(added))
+		for i, value := range added {
+			rendered, err := renderScalar(value)
+			if err != nil {
+				return nil, err
+			}
+			newLines[i] = last + rendered
+		}
+		return added, d.splice(lines, end, end, newLines)
+	}
+
+	// Flow or empty sequences are rewritten as a block list, so a comment
+	// trailing "[]" moves up to the key
+	seq.Style = 0
+	if key := ref.key(); seq.LineComment != "" && key.LineComment == "" {
+		key.LineComment, seq.LineComment = seq.LineComment, ""
+	}
+	for _, value := range added {
+		seq.Content = append(seq.Content, scalarNode(value))
+	}
+	return added, d.rewritePair(chain, lines)
+}
+
+// RemoveFromList removes values from the sequence at a dotted path and
+// returns the ones that were present
+func (d *Document) RemoveFromList(path string, values ...string) ([]string, error) {
+	chain := d.lookup(path)
+	if chain == nil {
+		return nil, nil
+	}
+
+	seq := chain[len(chain)-1].value()
+	if seq.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+
+	lines := d.lines()
+	_, blockEnd := d.span(chain, lines)
+	_, block := d.blockItemPrefix(seq, lines)
+
+	var removed []string
+	var kept []*yaml.Node
+	type lineRange struct{ start, end int }
+	var ranges []lineRange
+	for i, item := range seq.Content {
+		if item.Kind != yaml.ScalarNode || !contains(values, item.Value) || contains(removed, item.Value) {
+			kept = append(kept, item)
+			continue
+		}
+		removed = append(removed, item.Value)
+
+		if block {
+			end := blockEnd
+			if i+1 < len(seq.Content) {
+				end = trimTrailing(lines, item.Line, seq.Content[i+1].Line, item.Column)
+			}
+			start := item.Line - commentLinesAbove(lines, item.Line)
+			ranges = append(ranges, lineRange{start, end})
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	// Emptying a list, or editing a flow list, rewrites the whole entry
+	if !block || len(kept) == 0 {
+		seq.Content = kept
+		if len(kept) == 0 {
+			seq.Style = yaml.FlowStyle
+		}
+		return removed, d.rewritePair(chain, lines)
+	}
+
+	// Delete from the bottom up so earlier line numbers stay valid
+	for i := len(ranges) - 1; i >= 0; i-- {
+		lines = append(lines[:ranges[i].start-1], lines[ranges[i].end-1:]...)
+	}
+	return removed, d.reset([]byte(strings.Join(lines, "\n")))
+}
+
+// Set writes value at a config path such as version, kernel.config.PREEMPT_RT
+// or network.interfaces[0].ssid, creating missing mappings along the way. A
+// scalar replacing a scalar is edited in place so its inline comment stays.
+func (d *Document) Set(path string, value *yaml.Node) error {
+	parts, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if err := d.checkLegacyKeys(parts, path); err != nil {
+		return err
+	}
+
+	lines := d.lines()
+	node := documentRoot(&d.root)
+	var chain []pairRef
+	indexed := false
+	walked := ""
+
+	for i, part := range parts {
+		last := i == len(parts)-1
+		parent := walked
+
+		if part.isIndex() {
+			walked = fmt.Sprintf("%s[%d]", walked, part.index)
+			if node.Kind != yaml.SequenceNode {
+				return fmt.Errorf("%s is not a list", parent)
+			}
+			indexed = true
+
+			// One past the end appends a new item
+			if part.index == len(node.Content) {
+				item, err := buildPath(parts[i+1:], value, walked)
+				if err != nil {
+					return err
+				}
+				node.Content = append(node.Content, item)
+				return d.rewritePair(chain, lines)
+			}
+			if part.index > len(node.Content) {
+				return fmt.Errorf("%s is out of range: %s has %d items", walked, parent, len(node.Content))
+			}
+			if last {
+				return d.replaceNode(chain, &node.Content[part.index], value, lines)
+			}
+			node = node.Content[part.index]
+			continue
+		}
+
+		walked = joinPath(walked, part.key)
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", parent)
+		}
+
+		index := MappingIndex(node, part.key)
+		if index < 0 {
+			item, err := buildPath(parts[i+1:], value, walked)
+			if err != nil {
+				return err
+			}
+			if !indexed {
+				keys := make([]string, 0, i+1)
+				for _, p := range parts[:i+1] {
+					keys = append(keys, p.key)
+				}
+				return d.insertPath(strings.Join(keys, "."), item)
+			}
+			node.Content = append(node.Content, scalarNode(part.key), item)
+			return d.rewritePair(chain, lines)
+		}
+
+		ref := pairRef{mapping: node, index: index}
+		if !indexed {
+			chain = append(chain, ref)
+		}
+		if last {
+			return d.replaceNode(chain, &node.Content[index+1], value, lines)
+		}
+		node = ref.value()
+	}
+
+	return nil
+}
+
+// Unset removes the key or list item at a config path and reports whether
+// it was present. Removed keys take their comment lines with them.
+func (d *Document) Unset(path string) (bool, error) {
+	parts, err := parsePath(path)
+	if err != nil {
+		return false, err
+	}
+	if err := d.checkLegacyKeys(parts, path); err != nil {
+		return false, err
+	}
+
+	lines := d.lines()
+	node := documentRoot(&d.root)
+	var chain []pairRef
+	indexed := false
+
+	for i, part := range parts {
+		last := i == len(parts)-1
+
+		if part.isIndex() {
+			if node.Kind != yaml.SequenceNode || part.index >= len(node.Content) {
+				return false, nil
+			}
+			indexed = true
+			if last {
+				node.Content = append(node.Content[:part.index], node.Content[part.index+1:]...)
+				if len(node.Content) == 0 {
+					node.Style = yaml.FlowStyle
+				}
+				return true, d.rewritePair(chain, lines)
+			}
+			node = node.Content[part.index]
+			continue
+		}
+
+		index := MappingIndex(node, part.key)
+		if index < 0 {
+			return false, nil
+		}
+		ref := pairRef{mapping: node, index: index}
+		if !indexed {
+			chain = append(chain, ref)
+		}
+		if !last {
+			node = ref.value()
+			continue
+		}
+
+		if indexed || inFlow(chain) {
+			node.Content = append(node.Content[:index], node.Content[index+2:]...)
+			if indexed {
+				return true, d.rewritePair(chain, lines)
+			}
+			data, err := MarshalDocument(&d.root)
+			if err != nil {
+				return false, err
+			}
+			return true, d.reset(data)
+		}
+
+		start, end := d.span(chain, lines)
+		start -= commentLinesAbove(lines, start)
+		result := append(append([]string{}, lines[:start-1]...), lines[end-1:]...)
+
+		// Don't leave two blank lines where a section used to be
+		if start >= 2 && start-1 < len(result) && strings.TrimSpace(result[start-2]) == "" && strings.TrimSpace(result[start-1]) == "" {
+			result = append(result[:start-1], result[start:]...)
+		}
+		return true, d.reset([]byte(strings.Join(result, "\n")))
+	}
+
+	return false, nil
+}
+
+// checkLegacyKeys refuses edits below a path that a deprecated top-level
+// key still provides. Once the nested key exists it wins over the legacy
+// one, so setting kernel.config.X would silently drop all of kernel_config.
+func (d *Document) checkLegacyKeys(parts []pathPart, path string) error {
+	root := documentRoot(&d.root)
+	for _, legacy := range LegacyKeys {
+		if MappingIndex(root, legacy.Key) < 0 || parts[0].key != legacy.Section {
+			continue
+		}
+		if len(parts) == 1 || parts[1].key == legacy.Field {
+			return fmt.Errorf("%s is still set by the deprecated key %q; run 'forge config migrate' before editing %s", legacy.Path(), legacy.Key, path)
+		}
+	}
+	return nil
+}
+
+// replaceNode puts value in slot, the value of the last pair in chain or an
+// item below it, keeping the comments of the node it replaces
+func (d *Document) replaceNode(chain []pairRef, slot **yaml.Node, value *yaml.Node, lines []string) error {
+	old := *slot
+	if old.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode {
+		if edited, ok := replaceScalarText(old, value, lines); ok {
+			return d.reset([]byte(strings.Join(edited, "\n")))
+		}
+	}
+
+	if value.HeadComment == "" && value.LineComment == "" {
+		value.HeadComment, value.LineComment = old.HeadComment, old.LineComment
+	}
+	if value.Kind != yaml.ScalarNode && value.LineComment != "" && len(chain) > 0 {
+		// A block value can't carry a comment on its first line, so move it to the key
+		key := chain[len(chain)-1].key()
+		if key.LineComment == "" {
+			key.LineComment = value.LineComment
+		}
+		value.LineComment = ""
+	}
+	*slot = value
+	return d.rewritePair(chain, lines)
+}
+
+// replaceScalarText swaps the text of a scalar written on a single line for
+// the rendering of value, leaving the rest of the line alone
+func replaceScalarText(old, value *yaml.Node, lines []string) ([]string, bool) {
+	if old.Line < 1 || old.Line > len(lines) {
+		return nil, false
+	}
+	line := []rune(lines[old.Line-1])
+	start := old.Column - 1
+	if start < 0 || start > len(line) {
+		return nil, false
+	}
+
+	end := -1
+	switch {
+	case old.Style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				end = i + 1
+				break
+			}
+		}
+	case old.Style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				end = i + 1
+				break
+			}
+		}
+	case old.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+		end = len(line)
+		if comment := strings.Index(string(line[start:]), " #"); comment >= 0 {
+			end = start + len([]rune(string(line[start:])[:comment]))
+		}
+		for end > start && (line[end-1] == ' ' || line[end-1] == '\t') {
+			end--
+		}
+	}
+	if end < 0 {
+		return nil, false
+	}
+
+	// Only edit text that really is the scalar, which rules out scalars
+	// inside flow collections and values spread over several lines
+	var current string
+	if err := yaml.Unmarshal([]byte(string(line[start:end])), &current); err != nil || current != old.Value {
+		return nil, false
+	}
+
+	node := *value
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+	if node.Tag == "!!str" && node.Style == 0 {
+		// Keep the quoting style the file already uses
+		node.Style = old.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+	}
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return nil, false
+	}
+	rendered := strings.TrimRight(string(data), "\n")
+	if strings.Contains(rendered, "\n") {
+		return nil, false
+	}
+
+	edited := append([]string{}, lines...)
+	edited[old.Line-1] = string(line[:start]) + rendered + string(line[end:])
+	return edited, true
+}
+
+// buildPath wraps value in the mappings and single-item lists described by
+// the remaining parts of a path, for a path that does not exist yet
+func buildPath(parts []pathPart, value *yaml.Node, walked string) (*yaml.Node, error) {
+	for _, part := range parts {
+		if part.isIndex() && part.index != 0 {
+			return nil, fmt.Errorf("%s[%d] is out of range: %s has 0 items", walked, part.index, walked)
+		}
+		if !part.isIndex() {
+			walked = joinPath(walked, part.key)
+		}
+	}
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i].isIndex() {
+			value = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{value}}
+		} else {
+			value = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{scalarNode(parts[i].key), value}}
+		}
+	}
+	return value, nil
+}
+
+// inFlow reports whether any mapping in chain is written in flow style
+func inFlow(chain []pairRef) bool {
+	for _, ref := range chain {
+		if ref.mapping.Style&yaml.FlowStyle != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reset replaces the document text and re-parses the node tree
+func (d *Document) reset(data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse config file: %v", err)
+	}
+	if root.Kind == 0 {
+		// An empty file still needs a mapping to edit
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if documentRoot(&root).Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse config file: top level is not a mapping")
+	}
+	d.data = data
+	d.root = root
+	return nil
+}
+
+// lines splits the document text into lines; line n is at index n-1
+func (d *Document) lines() []string {
+	return strings.Split(string(d.data), "\n")
+}
+
+// splice replaces the 1-based, end-exclusive line range [start, end) with
+// newLines and re-parses the result
+func (d *Document) splice(lines []string, start, end int, newLines []string) error {
+	result := make([]string, 0, len(lines)+len(newLines))
+	result = append(result, lines[:start-1]...)
+	result = append(result, newLines...)
+	result = append(result, lines[end-1:]...)
+	return d.reset([]byte(strings.Join(result, "\n")))
+}
+
+// lookup resolves a dotted path to the chain of pairs leading to it, or nil
+// if any part of the path is missing
+func (d *Document) lookup(path string) []pairRef {
+	node := documentRoot(&d.root)
+	var chain []pairRef
+	for _, key := range strings.Split(path, ".") {
+		index := MappingIndex(node, key)
+		if index < 0 {
+			return nil
+		}
+		ref := pairRef{mapping: node, index: index}
+		chain = append(chain, ref)
+		node = ref.value()
+	}
+	return chain
+}
+
+// insertPath adds value at a dotted path whose last part is missing. The
+// new key is added to the deepest existing mapping on the path.
+func (d *Document) insertPath(path string, value *yaml.Node) error {
+	keys := strings.Split(path, ".")
+	lines := d.lines()
+
+	// Find the deepest existing ancestor
+	var chain []pairRef
+	node := documentRoot(&d.root)
+	depth := 0
+	for ; depth < len(keys)-1; depth++ {
+		index := MappingIndex(node, keys[depth])
+		if index < 0 {
+			break
+		}
+		ref := pairRef{mapping: node, index: index}
+		if ref.value().Kind != yaml.MappingNode {
+			if ref.value().Tag != "!!null" {
+				return fmt.Errorf("%s is not a mapping", strings.Join(keys[:depth+1], "."))
+			}
+			*ref.value() = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		chain = append(chain, ref)
+		node = ref.value()
+	}
+
+	// Build the missing part of the path from the inside out
+	for i := len(keys) - 1; i > depth; i-- {
+		value = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{scalarNode(keys[i]), value}}
+	}
+	// In a block mapping the new pair goes after the last one, leaving the
+	// existing lines alone
+	appendLine := 0
+	if len(chain) > 0 && len(node.Content) > 0 && !inFlow(chain) && node.Style&yaml.FlowStyle == 0 {
+		_, appendLine = d.span(append(chain, pairRef{mapping: node, index: len(node.Content) - 2}), lines)
+	}
+
+	node.Content = append(node.Content, scalarNode(keys[depth]), value)
+	added := pairRef{mapping: node, index: len(node.Content) - 2}
+
+	if appendLine > 0 {
+		rendered, err := renderPair(added, node.Content[0].Column-1)
+		if err != nil {
+			return err
+		}
+		return d.splice(lines, appendLine, appendLine, rendered)
+	}
+
+	if len(chain) > 0 {
+		return d.rewritePair(chain, lines)
+	}
+
+	// New top-level entries go at the end of the file
+	rendered, err := renderPair(added, 0)
+	if err != nil {
+		return err
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, rendered...)
+	lines = append(lines, "")
+	return d.reset([]byte(strings.Join(lines, "\n")))
+}
+
+// rewritePair re-renders the last pair of chain in place of its old text
+func (d *Document) rewritePair(chain []pairRef, lines []string) error {
+	for _, ref := range chain {
+		if ref.mapping.Style&yaml.FlowStyle != 0 {
+			// Flow mappings have no line structure to splice into
+			data, err := MarshalDocument(&d.root)
+			if err != nil {
+				return err
+			}
+			return d.reset(data)
+		}
+	}
+
+	ref := chain[len(chain)-1]
+	start, end := d.span(chain, lines)
+	rendered, err := renderPair(ref, ref.key().Column-1)
+	if err != nil {
+		return err
+	}
+	keepCommentSpacing(rendered, commentGaps(ref, lines))
+	return d.splice(lines, start, end, rendered)
+}
+
+// commentGaps collects, for each inline comment in the original text of a
+// pair, the whitespace that separated it from the value, so aligned
+// comments such as "busybox   # base" survive a re-render
+func commentGaps(ref pairRef, lines []string) map[string][]string {
+	gaps := map[string][]string{}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.LineComment != "" && node.Line >= 1 && node.Line <= len(lines) {
+			line := lines[node.Line-1]
+			if at := strings.LastIndex(line, node.LineComment); at > 0 {
+				start := len(strings.TrimRight(line[:at], " \t"))
+				gaps[node.LineComment] = append(gaps[node.LineComment], line[start:at])
+			}
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(ref.key())
+	walk(ref.value())
+	return gaps
+}
+
+// keepCommentSpacing puts the original whitespace back in front of the
+// inline comments the encoder wrote with a single space
+func keepCommentSpacing(rendered []string, gaps map[string][]string) {
+	for i, line := range rendered {
+		for comment, spacing := range gaps {
+			if len(spacing) == 0 || !strings.HasSuffix(line, " "+comment) {
+				continue
+			}
+			if spacing[0] != "" {
+				rendered[i] = line[:len(line)-len(comment)-1] + spacing[0] + comment
+			}
+			gaps[comment] = spacing[1:]
+			break
+		}
+	}
+}
+
+// span returns the 1-based, end-exclusive line range of the last pair in
+// chain, from its key up to the next sibling. Trailing blank lines and
+// comments that belong to what follows are left out.
+func (d *Document) span(chain []pairRef, lines []string) (int, int) {
+	ref := chain[len(chain)-1]
+	start := ref.key().Line
+
+	end := len(lines) + 1
+	if ref.index+2 < len(ref.mapping.Content) {
+		end = ref.mapping.Content[ref.index+2].Line
+	} else if len(chain) > 1 {
+		_, end = d.span(chain[:len(chain)-1], lines)
+	}
+
+	return start, trimTrailing(lines, start, end, ref.key().Column)
+}
+
+// blockItemPrefix returns the text in front of the last item of a block
+// sequence of scalars, such as "  - ", and whether the sequence can be
+// edited line by line
+func (d *Document) blockItemPrefix(seq *yaml.Node, lines []string) (string, bool) {
+	if seq.Style&yaml.FlowStyle != 0 || len(seq.Content) == 0 {
+		return "", false
+	}
+
+	for _, item := range seq.Content {
+		if item.Kind != yaml.ScalarNode || strings.Contains(item.Value, "\n") {
+			return "", false
+		}
+	}
+
+	last := seq.Content[len(seq.Content)-1]
+	line := []rune(lines[last.Line-1])
+	if last.Column-1 > len(line) {
+		return "", false
+	}
+	prefix := string(line[:last.Column-1])
+	if !itemPrefix.MatchString(prefix) {
+		return "", false
+	}
+	return prefix, true
+}
+
+// trimTrailing moves end back over blank lines and over comments indented no
+// deeper than column, which belong to whatever follows the range
+func trimTrailing(lines []string, start, end, column int) int {
+	for end-1 > start {
+		line := lines[end-2]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			end--
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if strings.HasPrefix(trimmed, "#") && indent < column {
+			end--
+			continue
+		}
+		break
+	}
+	return end
+}
+
+// commentLinesAbove counts the comment lines directly above line n
+func commentLinesAbove(lines []string, n int) int {
+	count := 0
+	for i := n - 2; i >= 0 && strings.HasPrefix(strings.TrimSpace(lines[i]), "#"); i-- {
+		count++
+	}
+	return count
+}
+
+// renderPair encodes a single key/value pair as block YAML lines indented by
+// indent spaces. The key's head comment stays in the original text.
+func renderPair(ref pairRef, indent int) ([]string, error) {
+	key := *ref.key()
+	key.HeadComment = ""
+	key.FootComment = ""
+
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{&key, ref.value()}}
+	data, err := MarshalDocument(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	pad := strings.Repeat(" ", indent)
+	for i, line := range rendered {
+		if line != "" {
+			rendered[i] = pad + line
+		}
+	}
+	return rendered, nil
+}
+
+// renderScalar encodes a string the way it would appear as a YAML value
+func renderScalar(value string) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %v", err)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// scalarNode builds a plain string node
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// MarshalDocument encodes a parsed forge.yml document using the layout of the
+// generated files: two-space indentation, and a blank line before each
+// top-level comment block so commented sections stay visually separated
+func MarshalDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	result := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if i > 0 && strings.HasPrefix(line, "#") {
+			previous := lines[i-1]
+			if previous != "" && !strings.HasPrefix(previous, "#") {
+				result = append(result, "")
+			}
+		}
+		result = append(result, line)
+	}
+
+	return []byte(strings.Join(result, "\n")), nil
+}
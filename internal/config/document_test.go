@@ -0,0 +1,427 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+)
+
+type DocumentTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestDocumentTestSuite(t *testing.T) {
+	suite.Run(t, new(DocumentTestSuite))
+}
+
+func (s *DocumentTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "forge-document-test-*")
+	s.Require().NoError(err)
+}
+
+func (s *DocumentTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+const commentedConfig = `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+
+# Packages for the router
+packages:
+  - dnsmasq        # DHCP and DNS server
+  # VPN
+  - wireguard      # VPN support
+  - iptables       # Firewall rules
+
+# Router features
+features:
+  - firewall
+
+# Not part of the Config schema
+web_dashboard:
+  port: 80
+`
+
+func (s *DocumentTestSuite) TestAddToListKeepsComments() {
+	doc, err := ParseDocument([]byte(commentedConfig))
+	s.Require().NoError(err)
+
+	added, err := doc.AddToList("packages", "nginx", "dnsmasq")
+	s.NoError(err)
+	s.Equal([]string{"nginx"}, added)
+
+	expected := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+
+# Packages for the router
+packages:
+  - dnsmasq        # DHCP and DNS server
+  # VPN
+  - wireguard      # VPN support
+  - iptables       # Firewall rules
+  - nginx
+
+# Router features
+features:
+  - firewall
+
+# Not part of the Config schema
+web_dashboard:
+  port: 80
+`
+	s.Equal(expected, string(doc.Bytes()))
+	s.Equal([]string{"dnsmasq", "wireguard", "iptables", "nginx"}, doc.List("packages"))
+}
+
+func (s *DocumentTestSuite) TestAddToListNothingNew() {
+	doc, err := ParseDocument([]byte(commentedConfig))
+	s.Require().NoError(err)
+
+	added, err := doc.AddToList("features", "firewall")
+	s.NoError(err)
+	s.Empty(added)
+	s.Equal(commentedConfig, string(doc.Bytes()))
+}
+
+func (s *DocumentTestSuite) TestRemoveFromListKeepsComments() {
+	doc, err := ParseDocument([]byte(commentedConfig))
+	s.Require().NoError(err)
+
+	removed, err := doc.RemoveFromList("packages", "wireguard", "missing")
+	s.NoError(err)
+	s.Equal([]string{"wireguard"}, removed)
+
+	expected := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+
+# Packages for the router
+packages:
+  - dnsmasq        # DHCP and DNS server
+  - iptables       # Firewall rules
+
+# Router features
+features:
+  - firewall
+
+# Not part of the Config schema
+web_dashboard:
+  port: 80
+`
+	s.Equal(expected, string(doc.Bytes()))
+}
+
+func (s *DocumentTestSuite) TestRemoveLastItem() {
+	doc, err := ParseDocument([]byte(commentedConfig))
+	s.Require().NoError(err)
+
+	removed, err := doc.RemoveFromList("features", "firewall")
+	s.NoError(err)
+	s.Equal([]string{"firewall"}, removed)
+	s.Contains(string(doc.Bytes()), "# Router features\nfeatures: []\n\n# Not part of the Config schema\n")
+	s.Empty(doc.List("features"))
+}
+
+func (s *DocumentTestSuite) TestAddToEmptyAndMissingLists() {
+	doc, err := ParseDocument([]byte(`schema_version: "1.0"
+packages: [] # none yet
+features:
+`))
+	s.Require().NoError(err)
+
+	_, err = doc.AddToList("packages", "busybox")
+	s.NoError(err)
+	_, err = doc.AddToList("features", "watchdog")
+	s.NoError(err)
+	_, err = doc.AddToList("testing.qemu.ports", "2222:22")
+	s.NoError(err)
+
+	expected := `schema_version: "1.0"
+packages: # none yet
+  - busybox
+features:
+  - watchdog
+
+testing:
+  qemu:
+    ports:
+      - 2222:22
+`
+	s.Equal(expected, string(doc.Bytes()))
+}
+
+func (s *DocumentTestSuite) TestAddToListNotAList() {
+	doc, err := ParseDocument([]byte(commentedConfig))
+	s.Require().NoError(err)
+
+	_, err = doc.AddToList("name", "other")
+	s.Error(err)
+	s.Contains(err.Error(), "name is not a list")
+}
+
+func (s *DocumentTestSuite) TestLoadSaveDocument() {
+	configPath := filepath.Join(s.tempDir, "forge.yml")
+	s.Require().NoError(os.WriteFile(configPath, []byte(commentedConfig), 0644))
+
+	doc, err := LoadDocument(configPath)
+	s.Require().NoError(err)
+	_, err = doc.AddToList("features", "watchdog")
+	s.NoError(err)
+	s.NoError(doc.Save(configPath))
+
+	config, err := LoadConfig(configPath)
+	s.NoError(err)
+	s.Equal([]string{"firewall", "watchdog"}, config.Features)
+
+	data, err := os.ReadFile(configPath)
+	s.NoError(err)
+	s.Contains(string(data), "web_dashboard:\n  port: 80\n")
+}
+
+func (s *DocumentTestSuite) TestParseDocumentInvalid() {
+	_, err := ParseDocument([]byte("- just\n- a list\n"))
+	s.Error(err)
+}
+
+const editableConfig = `schema_version: "1.0"
+name: "test"  # project name
+version: 1.0.0
+architecture: "x86_64"
+template: "minimal"
+
+# Kernel tuning
+kernel:
+  version: lts
+  config:
+    USB: y   # USB support
+
+# Packages
+packages:
+  - busybox
+network:
+  interfaces:
+    - name: eth0
+      dhcp: true
+features: [firewall]
+`
+
+func (s *DocumentTestSuite) set(doc *Document, path, text string) {
+	value, err := ParseValue(path, text)
+	s.Require().NoError(err)
+	s.Require().NoError(doc.Set(path, value))
+}
+
+func (s *DocumentTestSuite) TestSetScalarInPlace() {
+	doc, err := ParseDocument([]byte(editableConfig))
+	s.Require().NoError(err)
+
+	s.set(doc, "version", "2.0.0")
+	s.set(doc, "name", "router")
+	s.set(doc, "kernel.config.USB", "n")
+
+	expected := strings.NewReplacer(
+		"version: 1.0.0", "version: 2.0.0",
+		`name: "test"`, `name: "router"`,
+		"USB: y   # USB support", "USB: n   # USB support",
+	).Replace(editableConfig)
+	s.Equal(expected, string(doc.Bytes()))
+}
+
+func (s *DocumentTestSuite) TestSetNewKeys() {
+	doc, err := ParseDocument([]byte(editableConfig))
+	s.Require().NoError(err)
+
+	s.set(doc, "kernel.config.PREEMPT_RT", "y")
+	s.set(doc, "build.jobs", "4")
+
+	s.Contains(string(doc.Bytes()), "  config:\n    USB: y   # USB support\n    PREEMPT_RT: y\n\n# Packages\n")
+	s.True(strings.HasSuffix(string(doc.Bytes()), "features: [firewall]\n\nbuild:\n  jobs: 4\n"))
+
+	config, _, err := doc.Config("forge.yml")
+	s.Require().NoError(err)
+	s.Equal(4, config.Build.Jobs)
+	s.Equal("y", config.Kernel.Config["PREEMPT_RT"])
+}
+
+func (s *DocumentTestSuite) TestSetIndexedPaths() {
+	doc, err := ParseDocument([]byte(editableConfig))
+	s.Require().NoError(err)
+
+	s.set(doc, "network.interfaces[0].name", "eth1")
+	s.set(doc, "network.interfaces[1].name", "wlan0")
+	s.set(doc, "features[0]", "watchdog")
+	s.set(doc, "testing.qemu.ports[0]", "2222:22")
+
+	config, _, err := doc.Config("forge.yml")
+	s.Require().NoError(err)
+	s.Require().Len(config.Network.Interfaces, 2)
+	s.Equal("eth1", config.Network.Interfaces[0].Name)
+	s.True(config.Network.Interfaces[0].DHCP)
+	s.Equal("wlan0", config.Network.Interfaces[1].Name)
+	s.Equal([]string{"watchdog"}, config.Features)
+	s.Equal([]string{"2222:22"}, config.Testing.QEMU.Ports)
+	s.Contains(string(doc.Bytes()), "# Kernel tuning\n")
+
+	value, err := ParseValue("packages[3]", "nginx")
+	s.Require().NoError(err)
+	err = doc.Set("packages[3]", value)
+	s.Error(err)
+	s.Contains(err.Error(), "packages[3] is out of range: packages has 1 items")
+}
+
+func (s *DocumentTestSuite) TestSetThroughScalar() {
+	doc, err := ParseDocument([]byte(editableConfig))
+	s.Require().NoError(err)
+
+	err = doc.Set("version.major", scalarNode("1"))
+	s.Error(err)
+	s.Contains(err.Error(), "version is not a mapping")
+}
+
+func (s *DocumentTestSuite) TestUnset() {
+	doc, err := ParseDocument([]byte(editableConfig))
+	s.Require().NoError(err)
+
+	removed, err := doc.Unset("kernel")
+	s.NoError(err)
+	s.True(removed)
+	s.NotContains(string(doc.Bytes()), "Kernel tuning")
+	s.Contains(string(doc.Bytes()), "template: \"minimal\"\n\n# Packages\npackages:\n")
+
+	removed, err = doc.Unset("network.interfaces[0].dhcp")
+	s.NoError(err)
+	s.True(removed)
+	s.Contains(string(doc.Bytes()), "interfaces:\n    - name: eth0\nfeatures:")
+
+	removed, err = doc.Unset("features[0]")
+	s.NoError(err)
+	s.True(removed)
+	s.Contains(string(doc.Bytes()), "features: []\n")
+
+	removed, err = doc.Unset("build.jobs")
+	s.NoError(err)
+	s.False(removed)
+}
+
+func (s *DocumentTestSuite) TestKeepsCRLFLineEndings() {
+	crlf := strings.ReplaceAll(commentedConfig, "\n", "\r\n")
+	doc, err := ParseDocument([]byte(crlf))
+	s.Require().NoError(err)
+
+	_, err = doc.AddToList("packages", "nginx")
+	s.Require().NoError(err)
+	s.Require().NoError(doc.Set("web_dashboard.port", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: "8080"}))
+	_, err = doc.AddToList("services", "sshd")
+	s.Require().NoError(err)
+
+	data := string(doc.Bytes())
+	s.Contains(data, "  - iptables       # Firewall rules\r\n  - nginx\r\n")
+	s.Contains(data, "  port: 8080\r\n")
+	s.Contains(data, "services:\r\n  - sshd\r\n")
+	s.NotContains(strings.ReplaceAll(data, "\r\n", ""), "\n")
+	s.Equal([]string{"dnsmasq", "wireguard", "iptables", "nginx"}, doc.List("packages"))
+}
+
+func (s *DocumentTestSuite) TestRewriteKeepsCommentSpacing() {
+	doc, err := ParseDocument([]byte(`packages:
+  - busybox   # base
+  - dropbear  # ssh
+  - [nested]
+`))
+	s.Require().NoError(err)
+
+	// A list that can't be edited line by line is re-rendered
+	_, err = doc.RemoveFromList("packages", "dropbear")
+	s.Require().NoError(err)
+	s.Equal("packages:\n  - busybox   # base\n  - [nested]\n", string(doc.Bytes()))
+}
+
+func (s *DocumentTestSuite) TestEditsUnderLegacyKeys() {
+	legacy := `schema_version: "1.0"
+kernel_config:
+  preempt_rt: true
+`
+	doc, err := ParseDocument([]byte(legacy))
+	s.Require().NoError(err)
+
+	// A nested kernel.config would replace kernel_config instead of adding to it
+	err = doc.Set("kernel.config.USB", scalarNode("y"))
+	s.Error(err)
+	s.Contains(err.Error(), `kernel.config is still set by the deprecated key "kernel_config"; run 'forge config migrate'`)
+	_, err = doc.Unset("kernel")
+	s.Error(err)
+	s.Equal(legacy, string(doc.Bytes()))
+
+	s.NoError(doc.Set("kernel.version", scalarNode("6.6")))
+	s.Contains(string(doc.Bytes()), "kernel:\n  version: \"6.6\"\n")
+}
+
+func (s *DocumentTestSuite) TestMoveLegacyKeys() {
+	doc, err := ParseDocument([]byte(`name: test   # project
+
+buildroot_version: stable   # pinned below
+kernel:
+
+# Real-time kernel
+kernel_config:
+    preempt_rt: true    # low latency
+    CONFIG_debug_info: false
+packages:
+  - busybox       # base
+`))
+	s.Require().NoError(err)
+
+	changes, err := doc.MoveLegacyKeys()
+	s.Require().NoError(err)
+	s.Equal([]string{"move buildroot_version to buildroot.version", "move kernel_config to kernel.config"}, changes)
+	s.Equal(`name: test   # project
+
+buildroot:
+  version: stable   # pinned below
+kernel:
+    # Real-time kernel
+    config:
+        PREEMPT_RT: y    # low latency
+        DEBUG_INFO: n
+
+packages:
+  - busybox       # base
+`, string(doc.Bytes()))
+}
+
+func (s *DocumentTestSuite) TestMoveLegacyKeysDropsOverridden() {
+	doc, err := ParseDocument([]byte(`kernel:
+  version: "6.6"  # current LTS
+kernel_version: "6.1"
+packages: [busybox]
+`))
+	s.Require().NoError(err)
+
+	changes, err := doc.MoveLegacyKeys()
+	s.Require().NoError(err)
+	s.Equal([]string{"drop kernel_version (kernel.version is already set)"}, changes)
+	s.Equal("kernel:\n  version: \"6.6\"  # current LTS\npackages: [busybox]\n", string(doc.Bytes()))
+
+	// Flow mappings can't take moved lines, so the tree is re-encoded
+	doc, err = ParseDocument([]byte("kernel: {version: \"6.6\"}\nkernel_config:\n  preempt_rt: true\n"))
+	s.Require().NoError(err)
+	changes, err = doc.MoveLegacyKeys()
+	s.Require().NoError(err)
+	s.Equal([]string{"move kernel_config to kernel.config"}, changes)
+	s.Nil(doc.Lookup("kernel_config"))
+	s.Equal("y", doc.Lookup("kernel.config.PREEMPT_RT").Value)
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sst/forge/internal/logger"
+	"github.com/sst/forge/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// referencePattern matches ${...} references and the $${ escape
+var referencePattern = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// errNoSecrets is returned for secrets used in a project without a secrets file
+var errNoSecrets = fmt.Errorf("no secrets file %s (create one with forge secrets init)", secrets.DefaultFile)
+
+// envNamePattern matches valid environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Interpolator resolves ${VAR}, ${VAR:-default} and ${secret:name}
+// references in forge.yml values
+type Interpolator struct {
+	// LookupEnv returns the value of an environment variable
+	LookupEnv func(name string) (string, bool)
+	// LookupSecret returns the value of a secret from the secrets file
+	LookupSecret func(name string) (string, error)
+}
+
+// NewInterpolator resolves references from the process environment and from
+// the secrets file in dir. The secrets file is only opened, with the key from
+// FORGE_SECRETS_KEY_FILE or FORGE_SECRETS_PASSPHRASE, when a secret is used.
+func NewInterpolator(dir string) *Interpolator {
+	path := filepath.Join(dir, secrets.DefaultFile)
+
+	var store *secrets.Store
+	var openErr error
+	return &Interpolator{
+		LookupEnv: os.LookupEnv,
+		LookupSecret: func(name string) (string, error) {
+			if store == nil && openErr == nil {
+				store, openErr = openSecrets(path)
+			}
+			if openErr != nil {
+				return "", openErr
+			}
+
+			value, ok := store.Get(name)
+			if !ok {
+				return "", fmt.Errorf("secret %q is not defined in %s", name, secrets.DefaultFile)
+			}
+			return value, nil
+		},
+	}
+}
+
+// NewEditInterpolator resolves environment references like NewInterpolator
+// but never reads the secrets file: every secret resolves to an empty value.
+// Checking an edit to forge.yml must not need the secrets unlocked.
+func NewEditInterpolator() *Interpolator {
+	return &Interpolator{
+		LookupEnv: os.LookupEnv,
+		LookupSecret: func(name string) (string, error) {
+			return "", nil
+		},
+	}
+}
+
+// openSecrets opens the project secrets file with the key from the environment
+func openSecrets(path string) (*secrets.Store, error) {
+	if !secrets.Exists(path) {
+		return nil, errNoSecrets
+	}
+
+	key, err := secrets.KeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets.Open(path, key)
+}
+
+// Interpolate replaces references in every scalar value of a parsed forge.yml
+// document, in place. Resolved secrets are registered with the logger so they
+// are redacted from log output. References that cannot be resolved are
+// returned as issues.
+func (in *Interpolator) Interpolate(node *yaml.Node, file string) []ConfigIssue {
+	var issues []ConfigIssue
+
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			issues = append(issues, in.Interpolate(child, file)...)
+		}
+
+	case yaml.MappingNode:
+		// Only values are interpolated, never keys
+		for i := 1; i < len(node.Content); i += 2 {
+			issues = append(issues, in.Interpolate(node.Content[i], file)...)
+		}
+
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+
+		value := referencePattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+			if match == "$${" {
+				return "${"
+			}
+
+			resolved, err := in.resolve(match[2 : len(match)-1])
+			if err != nil {
+				kind := IssueUnresolved
+				if err == errNoSecrets || err == secrets.ErrNoKey {
+					kind = IssueNoSecrets
+				}
+				issues = append(issues, ConfigIssue{
+					Kind:    kind,
+					File:    file,
+					Line:    node.Line,
+					Column:  node.Column,
+					Message: err.Error(),
+				})
+				return match
+			}
+			return resolved
+		})
+
+		node.Value = value
+		if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			// Let plain values such as port: ${PORT} resolve to their natural type
+			node.Tag = ""
+		}
+	}
+
+	return issues
+}
+
+// resolve returns the value of a single reference body, the text between ${ and }
+func (in *Interpolator) resolve(reference string) (string, error) {
+	if name, ok := strings.CutPrefix(reference, "secret:"); ok {
+		if !secrets.ValidName(name) {
+			return "", fmt.Errorf("invalid secret reference ${%s}", reference)
+		}
+		value, err := in.LookupSecret(name)
+		if err != nil {
+			return "", err
+		}
+		logger.AddSensitiveValue(value)
+		return value, nil
+	}
+
+	name, fallback, hasFallback := strings.Cut(reference, ":-")
+	if !envNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid reference ${%s}", reference)
+	}
+
+	value, ok := in.LookupEnv(name)
+	if !ok || value == "" {
+		if hasFallback {
+			return fallback, nil
+		}
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/logger"
+	"github.com/sst/forge/internal/secrets"
+	"github.com/stretchr/testify/suite"
+)
+
+type InterpolateTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestInterpolateTestSuite(t *testing.T) {
+	suite.Run(t, new(InterpolateTestSuite))
+}
+
+func (s *InterpolateTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "forge-interpolate-test-*")
+	s.Require().NoError(err)
+}
+
+func (s *InterpolateTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+const interpolatedConfig = `schema_version: "1.0"
+name: "${PRODUCT_NAME}"
+version: "${VERSION:-0.1.0}"
+architecture: "x86_64"
+template: "networking"
+build:
+  jobs: ${JOBS}
+network:
+  interfaces:
+    - name: wlan0
+      ssid: "${SSID}-lab"
+      password: "${secret:wifi_password}"
+      description: "costs $${PRICE}"
+`
+
+func (s *InterpolateTestSuite) interpolator(env map[string]string, secretValues map[string]string) *Interpolator {
+	return &Interpolator{
+		LookupEnv: func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+		LookupSecret: func(name string) (string, error) {
+			value, ok := secretValues[name]
+			if !ok {
+				return "", fmt.Errorf("secret %q is not defined in %s", name, secrets.DefaultFile)
+			}
+			return value, nil
+		},
+	}
+}
+
+func (s *InterpolateTestSuite) TestDecodeConfigResolvesReferences() {
+	interpolator := s.interpolator(
+		map[string]string{"PRODUCT_NAME": "router", "JOBS": "4", "SSID": "forge"},
+		map[string]string{"wifi_password": "p4ssw0rd-from-store"},
+	)
+
+	config, issues, err := DecodeConfigWith([]byte(interpolatedConfig), "forge.yml", interpolator)
+	s.Require().NoError(err)
+	s.Empty(issues)
+	s.Equal("router", config.Name)
+	s.Equal("0.1.0", config.Version)
+	s.Equal(4, config.Build.Jobs)
+	s.Require().Len(config.Network.Interfaces, 1)
+	s.Equal("forge-lab", config.Network.Interfaces[0].SSID)
+	s.Equal("p4ssw0rd-from-store", config.Network.Interfaces[0].Password)
+	s.Equal("costs ${PRICE}", config.Network.Interfaces[0].Description)
+
+	// Resolved secrets never reach the logs
+	s.Equal("password is [REDACTED]", logger.Redact("password is p4ssw0rd-from-store"))
+}
+
+func (s *InterpolateTestSuite) TestDecodeConfigUnresolvedReferences() {
+	interpolator := s.interpolator(map[string]string{"JOBS": "4"}, nil)
+
+	_, _, err := DecodeConfigWith([]byte(interpolatedConfig), "forge.yml", interpolator)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "forge.yml:2:7: environment variable PRODUCT_NAME is not set")
+	s.Contains(err.Error(), "forge.yml:11:13: environment variable SSID is not set")
+	s.Contains(err.Error(), "forge.yml:12:17: secret \"wifi_password\" is not defined")
+}
+
+func (s *InterpolateTestSuite) TestInvalidReferences() {
+	content := `schema_version: "1.0"
+name: "${1BAD}"
+version: "${secret:bad name}"
+architecture: "x86_64"
+template: "minimal"
+`
+	_, _, err := DecodeConfigWith([]byte(content), "forge.yml", s.interpolator(nil, nil))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "invalid reference ${1BAD}")
+	s.Contains(err.Error(), "invalid secret reference ${secret:bad name}")
+}
+
+func (s *InterpolateTestSuite) TestLoadConfigWithSecretsFile() {
+	store, err := secrets.Create(filepath.Join(s.tempDir, secrets.DefaultFile), []byte("test passphrase"))
+	s.Require().NoError(err)
+	s.Require().NoError(store.Set("wifi_password", "from-secrets-file"))
+	s.Require().NoError(store.Save())
+
+	configPath := filepath.Join(s.tempDir, "forge.yml")
+	s.Require().NoError(os.WriteFile(configPath, []byte(interpolatedConfig), 0644))
+
+	s.T().Setenv("PRODUCT_NAME", "router")
+	s.T().Setenv("JOBS", "2")
+	s.T().Setenv("SSID", "forge")
+	s.T().Setenv(secrets.KeyFileEnv, "")
+	s.T().Setenv(secrets.PassphraseEnv, "")
+
+	// Without a key the secrets file stays locked
+	_, err = LoadConfig(configPath)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "secrets are locked")
+
+	issues, err := NewValidator().ValidateFile(configPath)
+	s.Require().NoError(err)
+	s.Require().Len(issues, 1)
+	s.Equal(IssueNoSecrets, issues[0].Kind)
+
+	// Edits are checked without the secrets
+	doc, err := LoadDocument(configPath)
+	s.Require().NoError(err)
+	config, _, err := doc.Config(configPath)
+	s.Require().NoError(err)
+	s.Empty(config.Network.Interfaces[0].Password)
+
+	s.T().Setenv(secrets.PassphraseEnv, "test passphrase")
+	config, err = LoadConfig(configPath)
+	s.Require().NoError(err)
+	s.Equal("from-secrets-file", config.Network.Interfaces[0].Password)
+}
+
+func (s *InterpolateTestSuite) TestLoadConfigWithoutSecretsFile() {
+	configPath := filepath.Join(s.tempDir, "forge.yml")
+	s.Require().NoError(os.WriteFile(configPath, []byte(interpolatedConfig), 0644))
+
+	s.T().Setenv("PRODUCT_NAME", "router")
+	s.T().Setenv("JOBS", "2")
+	s.T().Setenv("SSID", "forge")
+
+	_, err := LoadConfig(configPath)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "no secrets file forge.secrets")
+
+	issues, err := NewValidator().ValidateFile(configPath)
+	s.Require().NoError(err)
+	s.Require().Len(issues, 1)
+	s.Equal(IssueNoSecrets, issues[0].Kind)
+	s.False(issues[0].IsWarning())
+
+	doc, err := LoadDocument(configPath)
+	s.Require().NoError(err)
+	_, _, err = doc.Config(configPath)
+	s.NoError(err)
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IssueKind classifies a ConfigIssue
+type IssueKind int
+
+const (
+	// IssueUnknownKey marks a key that has no place in the Config schema
+	IssueUnknownKey IssueKind = iota
+	// IssueDeprecated marks a key that is still accepted but has moved
+	IssueDeprecated
+	// IssueUnresolved marks a ${...} reference that could not be resolved
+	IssueUnresolved
+	// IssueInvalid marks a syntax error, a value of the wrong type, or a
+	// value that fails validation
+	IssueInvalid
+	// IssueUnknownPackage marks a package that is not in the package catalog
+	IssueUnknownPackage
+	// IssueUnknownFeature marks a feature that forge does not provide
+	IssueUnknownFeature
+	// IssueNoSecrets marks a ${secret:...} reference that cannot be checked
+	// because the project has no secrets file yet or its secrets are locked
+	IssueNoSecrets
+)
+
+// ConfigIssue describes a problem found at a specific position in forge.yml
+type ConfigIssue struct {
+	Kind    IssueKind
+	File    string
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+// String formats the issue as file:line:column: message
+func (i ConfigIssue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.File, i.Message)
+	}
+	if i.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+}
+
+// IsWarning reports whether the issue leaves the configuration usable.
+// Unknown keys are ignored and deprecated keys are still honoured.
+func (i ConfigIssue) IsWarning() bool {
+	return i.Kind == IssueUnknownKey || i.Kind == IssueDeprecated
+}
+
+// FormatIssues joins issues into a multi-line message
+func FormatIssues(issues []ConfigIssue) string {
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		lines[i] = issue.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// findUnknownKeys walks a YAML node against a Go type and reports mapping keys
+// that have no corresponding struct field, and would therefore be silently dropped
+func findUnknownKeys(node *yaml.Node, t reflect.Type, path, file string) []ConfigIssue {
+	var issues []ConfigIssue
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			issues = append(issues, findUnknownKeys(child, t, path, file)...)
+		}
+
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keyNode, valueNode := node.Content[i], node.Content[i+1]
+				keyPath := joinPath(path, keyNode.Value)
+
+				field, ok := fields[keyNode.Value]
+				if !ok {
+					issues = append(issues, ConfigIssue{
+						Kind:    IssueUnknownKey,
+						File:    file,
+						Line:    keyNode.Line,
+						Column:  keyNode.Column,
+						Path:    keyPath,
+						Message: fmt.Sprintf("unknown key %q is ignored", keyPath),
+					})
+					continue
+				}
+
+				issues = append(issues, findUnknownKeys(valueNode, field.Type, keyPath, file)...)
+			}
+		case reflect.Map:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keyPath := joinPath(path, node.Content[i].Value)
+				issues = append(issues, findUnknownKeys(node.Content[i+1], t.Elem(), keyPath, file)...)
+			}
+		}
+
+	case yaml.SequenceNode:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, item := range node.Content {
+				issues = append(issues, findUnknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), file)...)
+			}
+		}
+	}
+
+	return issues
+}
+
+// yamlFields maps the YAML key of each exported struct field to the field
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// joinPath appends a key to a dotted config path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LegacyKey describes a schema 1.0 top-level key that moved into a section
+type LegacyKey struct {
+	Key     string
+	Section string
+	Field   string
+}
+
+// Path returns the dotted path that replaces the legacy key
+func (k LegacyKey) Path() string {
+	return k.Section + "." + k.Field
+}
+
+// LegacyKeys lists the deprecated top-level keys and where they now live
+var LegacyKeys = []LegacyKey{
+	{Key: "buildroot_version", Section: "buildroot", Field: "version"},
+	{Key: "kernel_version", Section: "kernel", Field: "version"},
+	{Key: "kernel_config", Section: "kernel", Field: "config"},
+}
+
+// NormalizeLegacyKeys rewrites deprecated top-level keys of a parsed forge.yml
+// document into their nested form, in place. The moved nodes keep their
+// position and comments. When both shapes are present the nested value wins.
+// A deprecation issue is returned for every legacy key found.
+func NormalizeLegacyKeys(doc *yaml.Node, file string) []ConfigIssue {
+	root := documentRoot(doc)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var issues []ConfigIssue
+	for _, legacy := range LegacyKeys {
+		index := MappingIndex(root, legacy.Key)
+		if index < 0 {
+			continue
+		}
+
+		keyNode, valueNode := root.Content[index], root.Content[index+1]
+		issue := ConfigIssue{
+			Kind:    IssueDeprecated,
+			File:    file,
+			Line:    keyNode.Line,
+			Column:  keyNode.Column,
+			Path:    legacy.Key,
+			Message: fmt.Sprintf("deprecated key %q, use %q instead", legacy.Key, legacy.Path()),
+		}
+
+		if legacy.Key == "kernel_config" {
+			normalizeKernelConfig(valueNode)
+		}
+
+		sectionIndex := MappingIndex(root, legacy.Section)
+		switch {
+		case sectionIndex < 0:
+			// Replace the legacy pair in place so the section keeps its position
+			sectionKey := &yaml.Node{
+				Kind:        yaml.ScalarNode,
+				Tag:         "!!str",
+				Value:       legacy.Section,
+				HeadComment: keyNode.HeadComment,
+				Line:        keyNode.Line,
+				Column:      keyNode.Column,
+			}
+			keyNode.HeadComment = ""
+			keyNode.Value = legacy.Field
+			section := &yaml.Node{
+				Kind:    yaml.MappingNode,
+				Tag:     "!!map",
+				Content: []*yaml.Node{keyNode, valueNode},
+				Line:    keyNode.Line,
+				Column:  keyNode.Column,
+			}
+			root.Content[index], root.Content[index+1] = sectionKey, section
+			issues = append(issues, issue)
+			continue
+
+		case MappingIndex(root.Content[sectionIndex+1], legacy.Field) >= 0:
+			issue.Message = fmt.Sprintf("deprecated key %q is ignored because %q is also set", legacy.Key, legacy.Path())
+
+		default:
+			section := root.Content[sectionIndex+1]
+			if section.Kind != yaml.MappingNode {
+				// An empty "kernel:" entry decodes as a null scalar
+				section.Kind, section.Tag, section.Value = yaml.MappingNode, "!!map", ""
+			}
+			keyNode.Value = legacy.Field
+			section.Content = append(section.Content, keyNode, valueNode)
+		}
+
+		root.Content = append(root.Content[:index], root.Content[index+2:]...)
+		issues = append(issues, issue)
+	}
+
+	return issues
+}
+
+// MoveLegacyKeys is NormalizeLegacyKeys for a Document. The deprecated
+// keys are moved by editing the text, so their comments and spacing come
+// along unchanged. It returns one description per legacy key, such as
+// "move kernel_config to kernel.config" or, when the nested key is already
+// set and wins, "drop kernel_version (kernel.version is already set)".
+func (d *Document) MoveLegacyKeys() ([]string, error) {
+	var moves []LegacyKey
+	var changes []string
+	for _, legacy := range LegacyKeys {
+		if d.Lookup(legacy.Key) == nil {
+			continue
+		}
+		if d.Lookup(legacy.Path()) != nil {
+			changes = append(changes, fmt.Sprintf("drop %s (%s is already set)", legacy.Key, legacy.Path()))
+		} else {
+			changes = append(changes, fmt.Sprintf("move %s to %s", legacy.Key, legacy.Path()))
+			moves = append(moves, legacy)
+		}
+	}
+
+	for _, legacy := range LegacyKeys {
+		if d.Lookup(legacy.Key) == nil {
+			continue
+		}
+		if d.Lookup(legacy.Path()) != nil {
+			if _, err := d.Unset(legacy.Key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		moved, err := d.moveLegacyKey(legacy)
+		if err != nil {
+			return nil, err
+		}
+		if !moved {
+			// Layouts the text edit can't handle, such as flow mappings,
+			// are normalized in the tree and re-encoded
+			NormalizeLegacyKeys(&d.root, "")
+			data, err := MarshalDocument(&d.root)
+			if err != nil {
+				return nil, err
+			}
+			return changes, d.reset(data)
+		}
+	}
+
+	for _, legacy := range moves {
+		if legacy.Key == "kernel_config" {
+			return changes, d.normalizeKernelConfigText(legacy.Path())
+		}
+	}
+	return changes, nil
+}
+
+// moveLegacyKey moves the lines of a top-level legacy key into its section,
+// or renames it to the section in place when there is none. It reports
+// false, leaving the document alone, when the layout can't be edited as
+// lines.
+func (d *Document) moveLegacyKey(legacy LegacyKey) (bool, error) {
+	root := documentRoot(&d.root)
+	if root.Style&yaml.FlowStyle != 0 {
+		return false, nil
+	}
+	ref := pairRef{mapping: root, index: MappingIndex(root, legacy.Key)}
+	key, value := ref.key(), ref.value()
+
+	lines := d.lines()
+	start, end := d.span([]pairRef{ref}, lines)
+	indent := key.Column - 1
+	if indent > len(lines[start-1]) || !strings.HasPrefix(lines[start-1][indent:], legacy.Key+":") {
+		return false, nil
+	}
+	// The text after the key, such as ': "6.1" # LTS'
+	rest := lines[start-1][indent+len(legacy.Key):]
+
+	// Match the indentation the file already uses for nested values
+	step := 2
+	if value.Kind == yaml.MappingNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0 && value.Content[0].Column > key.Column {
+		step = value.Content[0].Column - key.Column
+	}
+
+	sectionIndex := MappingIndex(root, legacy.Section)
+	if sectionIndex < 0 {
+		pad := strings.Repeat(" ", indent)
+		moved := []string{pad + legacy.Section + ":", pad + strings.Repeat(" ", step) + legacy.Field + rest}
+		moved = append(moved, shiftLines(lines[start:end-1], step)...)
+		return true, d.splice(lines, start, end, moved)
+	}
+
+	section := pairRef{mapping: root, index: sectionIndex}
+	var childIndent, at int
+	switch sectionValue := section.value(); {
+	case sectionValue.Kind == yaml.MappingNode && sectionValue.Style&yaml.FlowStyle == 0 && len(sectionValue.Content) > 0:
+		childIndent = sectionValue.Content[0].Column - 1
+		last := pairRef{mapping: sectionValue, index: len(sectionValue.Content) - 2}
+		_, at = d.span([]pairRef{section, last}, lines)
+	case sectionValue.Tag == "!!null" && sectionValue.Value == "":
+		// An empty "kernel:" entry
+		childIndent = section.key().Column - 1 + step
+		at = section.key().Line + 1
+	default:
+		return false, nil
+	}
+
+	pad := strings.Repeat(" ", childIndent)
+	var moved []string
+	for _, comment := range lines[start-1-commentLinesAbove(lines, start) : start-1] {
+		moved = append(moved, pad+strings.TrimSpace(comment))
+	}
+	moved = append(moved, pad+legacy.Field+rest)
+	moved = append(moved, shiftLines(lines[start:end-1], childIndent-indent)...)
+
+	// Copy the lines into the section, then remove the legacy key with
+	// the comments above it
+	if err := d.splice(lines, at, at, moved); err != nil {
+		return false, err
+	}
+	_, err := d.Unset(legacy.Key)
+	return true, err
+}
+
+// normalizeKernelConfigText applies normalizeKernelConfig to the mapping at
+// path by editing its keys and values in the text
+func (d *Document) normalizeKernelConfigText(path string) error {
+	config := d.Lookup(path)
+	if config == nil || config.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	if config.Style&yaml.FlowStyle != 0 {
+		return d.normalizeKernelConfigTree(config)
+	}
+
+	lines := d.lines()
+	for i := 0; i+1 < len(config.Content); i += 2 {
+		key, value := config.Content[i], config.Content[i+1]
+
+		// Edit the value first, since renaming the key moves it
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!bool" {
+			var enabled bool
+			if err := value.Decode(&enabled); err != nil {
+				continue
+			}
+			symbol := scalarNode("n")
+			if enabled {
+				symbol.Value = "y"
+			}
+			edited, ok := replaceScalarText(value, symbol, lines)
+			if !ok {
+				return d.normalizeKernelConfigTree(config)
+			}
+			lines = edited
+		}
+
+		name := strings.ToUpper(strings.TrimPrefix(key.Value, "CONFIG_"))
+		if name == key.Value {
+			continue
+		}
+		line, column := lines[key.Line-1], key.Column-1
+		if key.Style != 0 || column > len(line) || !strings.HasPrefix(line[column:], key.Value) {
+			return d.normalizeKernelConfigTree(config)
+		}
+		lines[key.Line-1] = line[:column] + name + line[column+len(key.Value):]
+	}
+
+	return d.reset([]byte(strings.Join(lines, "\n")))
+}
+
+// normalizeKernelConfigTree is the fallback of normalizeKernelConfigText for
+// layouts it can't edit as text, re-encoding the whole document
+func (d *Document) normalizeKernelConfigTree(config *yaml.Node) error {
+	normalizeKernelConfig(config)
+	data, err := MarshalDocument(&d.root)
+	if err != nil {
+		return err
+	}
+	return d.reset(data)
+}
+
+// shiftLines indents every non-blank line by n more spaces
+func shiftLines(lines []string, n int) []string {
+	pad := strings.Repeat(" ", n)
+	shifted := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			line = pad + line
+		}
+		shifted[i] = line
+	}
+	return shifted
+}
+
+// normalizeKernelConfig converts a schema 1.0 kernel_config mapping such as
+// "preempt_rt: true" into kernel symbol form, "PREEMPT_RT: y"
+func normalizeKernelConfig(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		key.Value = strings.ToUpper(strings.TrimPrefix(key.Value, "CONFIG_"))
+
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!bool" {
+			var enabled bool
+			if err := value.Decode(&enabled); err == nil {
+				value.Tag = "!!str"
+				value.Value = "n"
+				if enabled {
+					value.Value = "y"
+				}
+			}
+		}
+	}
+}
+
+// documentRoot returns the top-level node of a parsed YAML document
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc == nil {
+		return nil
+	}
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil
+		}
+		return doc.Content[0]
+	}
+	return doc
+}
+
+// MappingIndex returns the index of key within a mapping node's content,
+// or -1 if the node is not a mapping or has no such key
+func MappingIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pathPart is one step of a config path: a mapping key, or a list index
+// when key is empty
+type pathPart struct {
+	key   string
+	index int
+}
+
+func (p pathPart) isIndex() bool { return p.key == "" }
+
+// parsePath splits a config path such as network.interfaces[0].ssid into
+// its keys and indexes
+func parsePath(path string) ([]pathPart, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty config path")
+	}
+
+	var parts []pathPart
+	for _, segment := range strings.Split(path, ".") {
+		key := segment
+		var indexes string
+		if open := strings.Index(segment, "["); open >= 0 {
+			key, indexes = segment[:open], segment[open:]
+		}
+		if key == "" && (len(parts) == 0 || indexes == "") {
+			return nil, fmt.Errorf("invalid config path %q", path)
+		}
+		if key != "" {
+			if strings.ContainsAny(key, "]") {
+				return nil, fmt.Errorf("invalid config path %q", path)
+			}
+			parts = append(parts, pathPart{key: key})
+		}
+
+		for indexes != "" {
+			end := strings.Index(indexes, "]")
+			if indexes[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid config path %q", path)
+			}
+			index, err := strconv.Atoi(indexes[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index %q in config path %q", indexes[1:end], path)
+			}
+			parts = append(parts, pathPart{index: index})
+			indexes = indexes[end+1:]
+		}
+	}
+
+	return parts, nil
+}
+
+// schemaType returns the Go type found at a config path, or an error if the
+// path does not exist in the Config schema
+func schemaType(path string) (reflect.Type, error) {
+	parts, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(Config{})
+	walked := ""
+	for _, part := range parts {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Interface {
+			// Free-form sections such as overlays accept anything below them
+			return t, nil
+		}
+
+		parent := walked
+		if part.isIndex() {
+			walked = fmt.Sprintf("%s[%d]", walked, part.index)
+			if t.Kind() != reflect.Slice {
+				return nil, fmt.Errorf("%s is not a list", parent)
+			}
+			t = t.Elem()
+			continue
+		}
+
+		walked = joinPath(walked, part.key)
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := yamlFields(t)[part.key]
+			if !ok {
+				return nil, fmt.Errorf("unknown config key %q", walked)
+			}
+			t = field.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("%s is not a mapping", parent)
+		}
+	}
+
+	return t, nil
+}
+
+// ParseValue converts command-line text into a YAML node for the config path,
+// checking it against the type of the field. Strings are taken literally;
+// other types, including lists and mappings, are parsed as YAML. A ${...}
+// reference is accepted for any scalar field.
+func ParseValue(path, text string) (*yaml.Node, error) {
+	t, err := schemaType(path)
+	if err != nil {
+		return nil, err
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.String || (isScalarType(t) && referencePattern.MatchString(text)) {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: text}, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s expects %s, got %q", path, describeGoType(t.String()), text)
+	}
+	node := doc.Content[0]
+
+	target := reflect.New(t)
+	if err := node.Decode(target.Interface()); err != nil {
+		return nil, fmt.Errorf("%s expects %s, got %q", path, describeGoType(t.String()), text)
+	}
+	if t == reflect.TypeOf(time.Duration(0)) && node.Tag == "!!int" {
+		// A bare number would be read as nanoseconds
+		return nil, fmt.Errorf("%s expects %s, got %q", path, describeGoType(t.String()), text)
+	}
+
+	return node, nil
+}
+
+// isScalarType reports whether values of t are written as YAML scalars
+func isScalarType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Interface:
+		return false
+	}
+	return true
+}
+
+// Lookup returns the value at a config path. Fields that are not set return
+// their zero value; missing map keys and list items are an error.
+func (c *Config) Lookup(path string) (interface{}, error) {
+	parts, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := schemaType(path); err != nil {
+		return nil, err
+	}
+
+	value := reflect.ValueOf(c).Elem()
+	walked := ""
+	for _, part := range parts {
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil, fmt.Errorf("%s is not set", walked)
+			}
+			value = value.Elem()
+		}
+
+		if part.isIndex() {
+			walked = fmt.Sprintf("%s[%d]", walked, part.index)
+			if value.Kind() != reflect.Slice || part.index >= value.Len() {
+				return nil, fmt.Errorf("%s is not set", walked)
+			}
+			value = value.Index(part.index)
+			continue
+		}
+
+		walked = joinPath(walked, part.key)
+		switch value.Kind() {
+		case reflect.Struct:
+			value = value.FieldByIndex(yamlFields(value.Type())[part.key].Index)
+		case reflect.Map:
+			item := value.MapIndex(reflect.ValueOf(part.key))
+			if !item.IsValid() {
+				return nil, fmt.Errorf("%s is not set", walked)
+			}
+			value = item
+		default:
+			return nil, fmt.Errorf("%s is not set", walked)
+		}
+	}
+
+	return value.Interface(), nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+)
+
+type PathTestSuite struct {
+	suite.Suite
+}
+
+func TestPathTestSuite(t *testing.T) {
+	suite.Run(t, new(PathTestSuite))
+}
+
+func (s *PathTestSuite) TestParsePath() {
+	parts, err := parsePath("network.interfaces[1].members[0]")
+	s.NoError(err)
+	s.Equal([]pathPart{{key: "network"}, {key: "interfaces"}, {index: 1}, {key: "members"}, {index: 0}}, parts)
+
+	for _, path := range []string{"", ".name", "name.", "packages[", "packages[x]", "packages[-1]", "[0]", "a]b"} {
+		_, err := parsePath(path)
+		s.Error(err, path)
+	}
+}
+
+func (s *PathTestSuite) TestParseValue() {
+	node, err := ParseValue("version", "1.0")
+	s.NoError(err)
+	s.Equal("!!str", node.Tag)
+	s.Equal("1.0", node.Value)
+
+	node, err = ParseValue("build.jobs", "8")
+	s.NoError(err)
+	s.Equal("!!int", node.Tag)
+
+	node, err = ParseValue("build.jobs", "${JOBS}")
+	s.NoError(err)
+	s.Equal("${JOBS}", node.Value)
+
+	node, err = ParseValue("packages", "[busybox, nginx]")
+	s.NoError(err)
+	s.Equal(yaml.SequenceNode, node.Kind)
+
+	_, err = ParseValue("monitoring.collect_interval", "30s")
+	s.NoError(err)
+
+	_, err = ParseValue("profiles.dev.build.debug", "true")
+	s.NoError(err)
+
+	_, err = ParseValue("overlays.etc.motd", "{content: hello}")
+	s.NoError(err)
+}
+
+func (s *PathTestSuite) TestParseValueTypeErrors() {
+	tests := map[string][2]string{
+		"build.jobs":                  {"many", "build.jobs expects an integer, got \"many\""},
+		"build.debug":                 {"maybe", "build.debug expects true or false, got \"maybe\""},
+		"packages":                    {"{a: b}", "packages expects a list, got \"{a: b}\""},
+		"monitoring.collect_interval": {"30", "monitoring.collect_interval expects a duration such as 30s, got \"30\""},
+		"build.colour":                {"blue", "unknown config key \"build.colour\""},
+		"version.major":               {"1", "version is not a mapping"},
+		"name[0]":                     {"x", "name is not a list"},
+	}
+	for path, test := range tests {
+		_, err := ParseValue(path, test[0])
+		s.Require().Error(err, path)
+		s.Equal(test[1], err.Error(), path)
+	}
+}
+
+func (s *PathTestSuite) TestLookup() {
+	debug := true
+	config := &Config{
+		Name:     "test",
+		Packages: []string{"busybox", "nginx"},
+		Kernel:   KernelConfig{Config: map[string]string{"USB": "y"}},
+		Network: NetworkConfig{Interfaces: []NetworkInterface{
+			{Name: "eth0", DHCP: true},
+		}},
+		Monitoring: MonitoringConfig{CollectInterval: 30 * time.Second},
+		Profiles: map[string]ProfileConfig{
+			"dev": {Build: ProfileBuildConfig{Debug: &debug}},
+		},
+	}
+
+	tests := map[string]interface{}{
+		"name":                        "test",
+		"packages":                    []string{"busybox", "nginx"},
+		"packages[1]":                 "nginx",
+		"kernel.config.USB":           "y",
+		"network.interfaces[0].dhcp":  true,
+		"monitoring.collect_interval": 30 * time.Second,
+		"build.jobs":                  0,
+		"profiles.dev.build.debug":    &debug,
+	}
+	for path, expected := range tests {
+		value, err := config.Lookup(path)
+		s.NoError(err, path)
+		s.Equal(expected, value, path)
+	}
+
+	for path, message := range map[string]string{
+		"packages[2]":               "packages[2] is not set",
+		"kernel.config.PREEMPT_RT":  "kernel.config.PREEMPT_RT is not set",
+		"profiles.prod.description": "profiles.prod is not set",
+		"colour":                    "unknown config key \"colour\"",
+	} {
+		_, err := config.Lookup(path)
+		s.Require().Error(err, path)
+		s.Equal(message, err.Error(), path)
+	}
+}
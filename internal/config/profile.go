@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// profileNamePattern restricts profile names to safe directory names
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ProfileNames returns the names of the profiles defined in forge.yml, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile returns a copy of the configuration with the named profile
+// merged in. Profile packages and features are added after the removals are
+// applied, kernel config symbols override the base ones, and any build option
+// the profile sets replaces the base value. An empty name returns the
+// configuration unchanged.
+func (c *Config) ApplyProfile(name string) (*Config, error) {
+	if name == "" {
+		return c, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return nil, fmt.Errorf("unknown profile %q: forge.yml defines no profiles", name)
+		}
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	merged := *c
+	merged.Profile = name
+	merged.Packages = mergeList(c.Packages, profile.RemovePackages, profile.Packages)
+	merged.Features = mergeList(c.Features, profile.RemoveFeatures, profile.Features)
+
+	if profile.Kernel.Version != "" {
+		merged.Kernel.Version = profile.Kernel.Version
+	}
+	if len(c.Kernel.Config) > 0 || len(profile.Kernel.Config) > 0 {
+		merged.Kernel.Config = make(map[string]string, len(c.Kernel.Config)+len(profile.Kernel.Config))
+		for symbol, value := range c.Kernel.Config {
+			merged.Kernel.Config[symbol] = value
+		}
+		for symbol, value := range profile.Kernel.Config {
+			merged.Kernel.Config[symbol] = value
+		}
+	}
+
+	if profile.Build.Optimization != "" {
+		merged.Build.Optimization = profile.Build.Optimization
+	}
+	if profile.Build.Debug != nil {
+		merged.Build.Debug = *profile.Build.Debug
+	}
+	if profile.Build.Jobs != 0 {
+		merged.Build.Jobs = profile.Build.Jobs
+	}
+
+	return &merged, nil
+}
+
+// mergeList returns base without the removed items, followed by the added
+// items that are not already present
+func mergeList(base, remove, add []string) []string {
+	result := make([]string, 0, len(base)+len(add))
+	for _, item := range base {
+		if !contains(remove, item) {
+			result = append(result, item)
+		}
+	}
+	for _, item := range add {
+		if !contains(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ProfileTestSuite struct {
+	suite.Suite
+}
+
+func TestProfileTestSuite(t *testing.T) {
+	suite.Run(t, new(ProfileTestSuite))
+}
+
+const profileConfig = `schema_version: "1.0"
+name: "product"
+version: "1.0.0"
+architecture: "aarch64"
+template: "networking"
+packages:
+  - busybox
+  - openssh
+  - strace
+features:
+  - firewall
+kernel:
+  version: "6.1"
+  config:
+    DEBUG_INFO: y
+    PREEMPT: y
+build:
+  optimization: performance
+  debug: true
+profiles:
+  dev:
+    description: Lab builds with debugging tools
+    packages:
+      - gdb
+      - openssh
+  prod:
+    remove_packages:
+      - strace
+      - openssh
+    features:
+      - auto-updates
+    kernel:
+      config:
+        DEBUG_INFO: n
+    build:
+      optimization: size
+      debug: false
+      jobs: 4
+`
+
+func (s *ProfileTestSuite) TestDecodeProfiles() {
+	config, issues, err := DecodeConfig([]byte(profileConfig), "forge.yml")
+	s.NoError(err)
+	s.Empty(issues)
+	s.Equal([]string{"dev", "prod"}, config.ProfileNames())
+	s.Equal("Lab builds with debugging tools", config.Profiles["dev"].Description)
+	s.Require().NotNil(config.Profiles["prod"].Build.Debug)
+	s.False(*config.Profiles["prod"].Build.Debug)
+}
+
+func (s *ProfileTestSuite) TestApplyProfile() {
+	config, _, err := DecodeConfig([]byte(profileConfig), "forge.yml")
+	s.Require().NoError(err)
+
+	prod, err := config.ApplyProfile("prod")
+	s.NoError(err)
+	s.Equal("prod", prod.Profile)
+	s.Equal([]string{"busybox"}, prod.Packages)
+	s.Equal([]string{"firewall", "auto-updates"}, prod.Features)
+	s.Equal("6.1", prod.Kernel.Version)
+	s.Equal(map[string]string{"DEBUG_INFO": "n", "PREEMPT": "y"}, prod.Kernel.Config)
+	s.Equal("size", prod.Build.Optimization)
+	s.False(prod.Build.Debug)
+	s.Equal(4, prod.Build.Jobs)
+
+	dev, err := config.ApplyProfile("dev")
+	s.NoError(err)
+	s.Equal([]string{"busybox", "openssh", "strace", "gdb"}, dev.Packages)
+	s.True(dev.Build.Debug)
+	s.Equal("performance", dev.Build.Optimization)
+
+	// The base configuration is left untouched
+	s.Equal("", config.Profile)
+	s.Equal([]string{"busybox", "openssh", "strace"}, config.Packages)
+	s.Equal("y", config.Kernel.Config["DEBUG_INFO"])
+}
+
+func (s *ProfileTestSuite) TestApplyProfileNone() {
+	config, _, err := DecodeConfig([]byte(profileConfig), "forge.yml")
+	s.Require().NoError(err)
+
+	merged, err := config.ApplyProfile("")
+	s.NoError(err)
+	s.Same(config, merged)
+}
+
+func (s *ProfileTestSuite) TestApplyUnknownProfile() {
+	config, _, err := DecodeConfig([]byte(profileConfig), "forge.yml")
+	s.Require().NoError(err)
+
+	_, err = config.ApplyProfile("staging")
+	s.Error(err)
+	s.Contains(err.Error(), "unknown profile \"staging\" (available: dev, prod)")
+
+	config.Profiles = nil
+	_, err = config.ApplyProfile("staging")
+	s.Error(err)
+	s.Contains(err.Error(), "forge.yml defines no profiles")
+}
+
+func (s *ProfileTestSuite) TestProfileDefconfig() {
+	config, _, err := DecodeConfig([]byte(profileConfig), "forge.yml")
+	s.Require().NoError(err)
+
+	prod, err := config.ApplyProfile("prod")
+	s.Require().NoError(err)
+
+	defconfig, err := prod.GetBuildrootDefconfig()
+	s.NoError(err)
+	s.NotContains(defconfig, "BR2_PACKAGE_STRACE=y")
+
+	kernelConfig, err := prod.GetKernelConfig()
+	s.NoError(err)
+	s.Contains(kernelConfig, "CONFIG_DEBUG_INFO=n")
+}
+
+func (s *ProfileTestSuite) TestProfileBuildOptions() {
+	config, _, err := DecodeConfig([]byte(profileConfig), "forge.yml")
+	s.Require().NoError(err)
+
+	dev, err := config.ApplyProfile("dev")
+	s.Require().NoError(err)
+	devDefconfig, err := dev.GetBuildrootDefconfig()
+	s.Require().NoError(err)
+	s.Contains(devDefconfig, "BR2_OPTIMIZE_2=y\nBR2_ENABLE_DEBUG=y\nBR2_STRIP_none=y\n")
+
+	prod, err := config.ApplyProfile("prod")
+	s.Require().NoError(err)
+	prodDefconfig, err := prod.GetBuildrootDefconfig()
+	s.Require().NoError(err)
+	s.Contains(prodDefconfig, "BR2_OPTIMIZE_S=y\n")
+	s.NotContains(prodDefconfig, "BR2_ENABLE_DEBUG")
+	s.NotContains(prodDefconfig, "BR2_STRIP_none")
+	s.NotEqual(devDefconfig, prodDefconfig)
+}
+
+func (s *ProfileTestSuite) TestValidateProfileNames() {
+	config, _, err := DecodeConfig([]byte(profileConfig), "forge.yml")
+	s.Require().NoError(err)
+	s.NoError(config.Validate())
+
+	config.Profiles["prod"] = ProfileConfig{Build: ProfileBuildConfig{Optimization: "fast"}}
+	err = config.Validate()
+	s.Error(err)
+	s.Contains(err.Error(), "invalid optimization: fast")
+
+	delete(config.Profiles, "prod")
+	config.Build.Optimization = "O3"
+	err = config.Validate()
+	s.Error(err)
+	s.Contains(err.Error(), "invalid optimization: O3")
+	_, err = config.Defconfig()
+	s.Error(err)
+
+	config.Build.Optimization = ""
+	config.Profiles["../escape"] = ProfileConfig{}
+	err = config.Validate()
+	s.Error(err)
+	s.Contains(err.Error(), "invalid profile name")
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sst/forge/internal/features"
+)
+
+// SchemaDraft is the JSON Schema dialect of the generated schema
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema needed to describe forge.yml
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	PropertyNames        *jsonSchema            `json:"propertyNames,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+}
+
+// schemaEnums restricts the values at a config path. Map values are
+// addressed with *, and for lists the enum applies to the items.
+var schemaEnums = map[string][]string{
+	"architecture":                  ValidArchitectures,
+	"template":                      ValidTemplates,
+	"apps.[].build":                 ValidAppBuilds,
+	"build.optimization":            ValidOptimizations,
+	"profiles.*.build.optimization": ValidOptimizations,
+	"features":                      features.NewFeatureManager().FeatureNames(),
+	"profiles.*.features":           features.NewFeatureManager().FeatureNames(),
+}
+
+// schemaDescriptions documents config paths for editors
+var schemaDescriptions = map[string]string{
+	"schema_version":         "Version of the forge.yml schema",
+	"name":                   "Project name",
+	"version":                "Project version",
+	"architecture":           "Target CPU architecture",
+	"template":               "Project template the configuration is based on",
+	"buildroot":              "Buildroot settings",
+	"buildroot.download_dir": "Directory Buildroot keeps downloaded sources in (BR2_DL_DIR), relative to forge.yml; can be shared between projects",
+	"buildroot.sources":      "Source archive written by forge fetch to build from without network access",
+	"buildroot.offline":      "Never download sources; the build fails up front if any are missing",
+	"kernel":                 "Linux kernel settings",
+	"kernel.version":         "Kernel to build: latest, or a release such as 6.1 or 6.6.30; the toolchain uses its headers",
+	"kernel.config":          "Kernel config symbols without the CONFIG_ prefix, such as USB_SERIAL: y",
+	"packages":               "Packages installed on the target",
+	"features":               "Pre-configured features enabled on the target",
+	"apps":                   "In-house applications built from sources in the project, added with forge add app",
+	"apps.[].name":           "Buildroot package name of the application",
+	"apps.[].path":           "Source directory, relative to forge.yml",
+	"apps.[].build":          "Build system of the sources",
+	"apps.[].service":        "Start the application at boot with an init script or systemd unit",
+	"overlays":               "Files copied into the root filesystem",
+	"build":                  "Build options",
+	"build.optimization":     "Compiler optimization: size (-Os), performance and realtime (-O2), speed (-O3), security (-O2 with stack protection, full RELRO and FORTIFY_SOURCE) or debug (-Og)",
+	"build.debug":            "Build with debug symbols and install unstripped binaries",
+	"testing":                "Settings for forge test",
+	"network":                "Network interfaces and firewall",
+	"dnsmasq":                "DHCP and DNS server",
+	"monitoring":             "Monitoring service",
+	"watchdog":               "Hardware watchdog",
+	"mqtt":                   "MQTT broker",
+	"profiles":               "Named build variants selected with forge build --profile",
+	"profiles.*.build":       "Build options that replace the base ones",
+}
+
+// JSONSchema returns the forge.yml schema as a JSON Schema document,
+// generated from the Config type
+func JSONSchema() ([]byte, error) {
+	schema := schemaFor(reflect.TypeOf(Config{}), "")
+	schema.Schema = SchemaDraft
+	schema.Title = "forge.yml"
+	schema.Description = "Forge OS project configuration"
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaFor builds the schema of a Go type found at a config path
+func schemaFor(t reflect.Type, path string) *jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	schema := &jsonSchema{Description: schemaDescriptions[path]}
+
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		schema.Type = "string"
+		schema.Pattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+	case t.Kind() == reflect.String:
+		schema.Type = "string"
+
+	case t.Kind() == reflect.Bool:
+		schema.Type = "boolean"
+
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema.Type = "integer"
+
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema.Type = "number"
+
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		schema.Type = "array"
+		schema.Items = schemaFor(t.Elem(), path+".[]")
+
+	case t.Kind() == reflect.Map:
+		schema.Type = "object"
+		if t.Elem().Kind() != reflect.Interface {
+			schema.AdditionalProperties = schemaFor(t.Elem(), joinPath(path, "*"))
+		}
+		if path == "profiles" {
+			schema.PropertyNames = &jsonSchema{Pattern: profileNamePattern.String()}
+		}
+
+	case t.Kind() == reflect.Struct:
+		schema.Type = "object"
+		schema.Properties = make(map[string]*jsonSchema)
+		schema.AdditionalProperties = false
+		for name, field := range yamlFields(t) {
+			schema.Properties[name] = schemaFor(field.Type, joinPath(path, name))
+			if strings.Contains(field.Tag.Get("validate"), "required") {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		sort.Strings(schema.Required)
+	}
+
+	if enum, ok := schemaEnums[path]; ok {
+		if schema.Items != nil {
+			schema.Items.Enum = enum
+		} else {
+			schema.Enum = enum
+		}
+	}
+
+	return schema
+}
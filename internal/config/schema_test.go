@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SchemaTestSuite struct {
+	suite.Suite
+}
+
+func TestSchemaTestSuite(t *testing.T) {
+	suite.Run(t, new(SchemaTestSuite))
+}
+
+func (s *SchemaTestSuite) schema() map[string]interface{} {
+	data, err := JSONSchema()
+	s.Require().NoError(err)
+
+	var schema map[string]interface{}
+	s.Require().NoError(json.Unmarshal(data, &schema))
+	return schema
+}
+
+func (s *SchemaTestSuite) property(schema map[string]interface{}, names ...string) map[string]interface{} {
+	for _, name := range names {
+		properties, ok := schema["properties"].(map[string]interface{})
+		s.Require().True(ok, "no properties at %s", name)
+		schema, ok = properties[name].(map[string]interface{})
+		s.Require().True(ok, "no property %s", name)
+	}
+	return schema
+}
+
+func (s *SchemaTestSuite) TestTopLevel() {
+	schema := s.schema()
+	s.Equal(SchemaDraft, schema["$schema"])
+	s.Equal("object", schema["type"])
+	s.Equal(false, schema["additionalProperties"])
+	s.ElementsMatch([]interface{}{"schema_version", "name", "version", "architecture", "template"}, schema["required"])
+
+	// Fields excluded from YAML are not part of the schema
+	s.NotContains(schema["properties"], "Profile")
+	s.NotContains(schema["properties"], "profile")
+}
+
+func (s *SchemaTestSuite) TestTypes() {
+	schema := s.schema()
+	s.Equal("integer", s.property(schema, "build", "jobs")["type"])
+	s.Equal("boolean", s.property(schema, "build", "debug")["type"])
+	s.Equal("string", s.property(schema, "monitoring", "collect_interval")["type"])
+	s.NotEmpty(s.property(schema, "monitoring", "collect_interval")["pattern"])
+
+	interfaces := s.property(schema, "network", "interfaces")
+	s.Equal("array", interfaces["type"])
+	items := interfaces["items"].(map[string]interface{})
+	s.Equal("object", items["type"])
+
+	kernelConfig := s.property(schema, "kernel", "config")
+	s.Equal("object", kernelConfig["type"])
+	s.Equal("string", kernelConfig["additionalProperties"].(map[string]interface{})["type"])
+}
+
+func (s *SchemaTestSuite) TestEnums() {
+	schema := s.schema()
+	s.Len(s.property(schema, "architecture")["enum"], len(ValidArchitectures))
+	s.Len(s.property(schema, "template")["enum"], len(ValidTemplates))
+
+	features := s.property(schema, "features")["items"].(map[string]interface{})
+	s.Contains(features["enum"], "ssh-hardening")
+
+	profiles := s.property(schema, "profiles")
+	s.Equal(profileNamePattern.String(), profiles["propertyNames"].(map[string]interface{})["pattern"])
+	profile := profiles["additionalProperties"].(map[string]interface{})
+	profileFeatures := s.property(profile, "features")["items"].(map[string]interface{})
+	s.Contains(profileFeatures["enum"], "firewall")
+}
+
+func (s *SchemaTestSuite) TestPublishedSchemaIsCurrent() {
+	published, err := os.ReadFile("../../forge.schema.json")
+	s.Require().NoError(err)
+
+	data, err := JSONSchema()
+	s.Require().NoError(err)
+	s.Equal(string(data), string(published), "forge.schema.json is out of date, run make schema")
+}
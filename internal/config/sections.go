@@ -0,0 +1,147 @@
+package config
+
+import "time"
+
+// BuildConfig represents the build section of forge.yml
+type BuildConfig struct {
+	Optimization string `yaml:"optimization,omitempty"`
+	Debug        bool   `yaml:"debug,omitempty"`
+	Jobs         int    `yaml:"jobs,omitempty"`
+}
+
+// AppConfig represents an in-house application built from sources in the
+// project and packaged through the project's BR2_EXTERNAL tree
+type AppConfig struct {
+	Name    string `yaml:"name" validate:"required"`
+	Path    string `yaml:"path" validate:"required"`
+	Build   string `yaml:"build" validate:"required"`
+	Service bool   `yaml:"service,omitempty"`
+}
+
+// TestingConfig represents the testing section of forge.yml
+type TestingConfig struct {
+	QEMU QEMUConfig `yaml:"qemu,omitempty"`
+}
+
+// QEMUConfig represents QEMU settings used by forge test
+type QEMUConfig struct {
+	Memory   int      `yaml:"memory,omitempty"`
+	Network  string   `yaml:"network,omitempty"`
+	Graphics bool     `yaml:"graphics,omitempty"`
+	Ports    []string `yaml:"ports,omitempty"`
+}
+
+// NetworkConfig represents the network section of forge.yml
+type NetworkConfig struct {
+	Interfaces []NetworkInterface `yaml:"interfaces,omitempty"`
+	Firewall   FirewallConfig     `yaml:"firewall,omitempty"`
+}
+
+// NetworkInterface represents a single network interface definition
+type NetworkInterface struct {
+	Name        string   `yaml:"name"`
+	Type        string   `yaml:"type,omitempty"`
+	Description string   `yaml:"description,omitempty"`
+	DHCP        bool     `yaml:"dhcp,omitempty"`
+	Mode        string   `yaml:"mode,omitempty"`
+	SSID        string   `yaml:"ssid,omitempty"`
+	Security    string   `yaml:"security,omitempty"`
+	Password    string   `yaml:"password,omitempty"`
+	Channel     string   `yaml:"channel,omitempty"`
+	Members     []string `yaml:"members,omitempty"`
+}
+
+// FirewallConfig represents firewall rules for the target
+type FirewallConfig struct {
+	Rules []FirewallRule `yaml:"rules,omitempty"`
+}
+
+// FirewallRule represents a single firewall rule
+type FirewallRule struct {
+	Action   string `yaml:"action"`
+	Protocol string `yaml:"protocol,omitempty"`
+	Port     string `yaml:"port,omitempty"`
+	Source   string `yaml:"source,omitempty"`
+}
+
+// DnsmasqConfig represents the dnsmasq service settings
+type DnsmasqConfig struct {
+	Enabled     bool     `yaml:"enabled,omitempty"`
+	Domain      string   `yaml:"domain,omitempty"`
+	Interface   string   `yaml:"interface,omitempty"`
+	DHCPRange   string   `yaml:"dhcp_range,omitempty"`
+	DHCPOptions []string `yaml:"dhcp_options,omitempty"`
+}
+
+// MonitoringConfig represents the monitoring service settings
+type MonitoringConfig struct {
+	Enabled         bool           `yaml:"enabled,omitempty"`
+	MetricsPort     int            `yaml:"metrics_port,omitempty"`
+	CollectInterval time.Duration  `yaml:"collect_interval,omitempty"`
+	Exporters       []string       `yaml:"exporters,omitempty"`
+	MeshMetrics     bool           `yaml:"mesh_metrics,omitempty"`
+	Alerts          []MonitorAlert `yaml:"alerts,omitempty"`
+	CustomMetrics   []CustomMetric `yaml:"custom_metrics,omitempty"`
+}
+
+// MonitorAlert represents an alert raised by the monitoring service
+type MonitorAlert struct {
+	Type      string `yaml:"type"`
+	Threshold int    `yaml:"threshold,omitempty"`
+	Action    string `yaml:"action,omitempty"`
+}
+
+// CustomMetric represents an application-defined metric
+type CustomMetric struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// WatchdogConfig represents the hardware watchdog settings
+type WatchdogConfig struct {
+	Enabled      bool   `yaml:"enabled,omitempty"`
+	Device       string `yaml:"device,omitempty"`
+	Timeout      int    `yaml:"timeout,omitempty"`
+	PingInterval int    `yaml:"ping_interval,omitempty"`
+}
+
+// MQTTConfig represents the MQTT broker settings
+type MQTTConfig struct {
+	Enabled        bool       `yaml:"enabled,omitempty"`
+	Broker         string     `yaml:"broker,omitempty"`
+	Port           int        `yaml:"port,omitempty"`
+	WebsocketPort  int        `yaml:"websocket_port,omitempty"`
+	Persistence    bool       `yaml:"persistence,omitempty"`
+	AllowAnonymous bool       `yaml:"allow_anonymous,omitempty"`
+	TopicPrefix    string     `yaml:"topic_prefix,omitempty"`
+	QoS            int        `yaml:"qos,omitempty"`
+	Retain         bool       `yaml:"retain,omitempty"`
+	Users          []MQTTUser `yaml:"users,omitempty"`
+}
+
+// MQTTUser represents broker credentials
+type MQTTUser struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// ProfileConfig represents a named build variant, such as dev or prod, that
+// adjusts the base configuration when selected with forge build --profile
+type ProfileConfig struct {
+	Description    string             `yaml:"description,omitempty"`
+	Packages       []string           `yaml:"packages,omitempty"`
+	RemovePackages []string           `yaml:"remove_packages,omitempty"`
+	Features       []string           `yaml:"features,omitempty"`
+	RemoveFeatures []string           `yaml:"remove_features,omitempty"`
+	Kernel         KernelConfig       `yaml:"kernel,omitempty"`
+	Build          ProfileBuildConfig `yaml:"build,omitempty"`
+}
+
+// ProfileBuildConfig overrides build options for a profile. Debug is a
+// pointer so a profile can turn debugging off as well as on.
+type ProfileBuildConfig struct {
+	Optimization string `yaml:"optimization,omitempty"`
+	Debug        *bool  `yaml:"debug,omitempty"`
+	Jobs         int    `yaml:"jobs,omitempty"`
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sst/forge/internal/features"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidArchitectures lists the target architectures forge can build for
+var ValidArchitectures = []string{"x86_64", "arm", "aarch64", "riscv64", "i386", "armv7", "armv5", "mips"}
+
+// ValidTemplates lists the project templates
+var ValidTemplates = []string{"minimal", "networking", "iot", "security", "industrial", "kiosk"}
+
+// ValidOptimizations lists the values of build.optimization
+var ValidOptimizations = []string{"size", "performance", "speed", "realtime", "security", "debug"}
+
+// ValidAppBuilds lists the build systems of in-house applications
+var ValidAppBuilds = []string{"go", "cmake", "meson", "make"}
+
+// appNamePattern restricts app names to valid Buildroot package names
+var appNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// yamlErrorPattern extracts the line from yaml.v3 syntax and type errors
+var yamlErrorPattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// typeErrorPattern matches the message of a yaml.v3 type mismatch
+var typeErrorPattern = regexp.MustCompile("^cannot unmarshal !!\\w+ `(.*)` into (.*)$")
+
+// Validator checks forge.yml content and reports every violation it finds,
+// each with its position in the file
+type Validator struct {
+	// IsKnownPackage reports whether a package is in the package catalog.
+	// Packages are not checked when it is nil.
+	IsKnownPackage func(name string) bool
+	// IsKnownFeature reports whether a feature exists. Features are not
+	// checked when it is nil.
+	IsKnownFeature func(name string) bool
+	// Interpolator resolves ${...} references. When nil, references are
+	// resolved from the environment and the secrets file next to forge.yml.
+	Interpolator *Interpolator
+}
+
+// NewValidator creates a validator that checks features against the feature
+// registry
+func NewValidator() *Validator {
+	return &Validator{IsKnownFeature: features.NewFeatureManager().IsValidFeature}
+}
+
+// ValidateFile reads and validates a forge.yml file
+func (v *Validator) ValidateFile(path string) ([]ConfigIssue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	return v.Validate(data, path), nil
+}
+
+// Validate checks forge.yml content and returns all issues sorted by line.
+// Unlike LoadConfig it does not stop at the first problem.
+func (v *Validator) Validate(data []byte, filename string) []ConfigIssue {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []ConfigIssue{yamlIssue(err.Error(), filename)}
+	}
+
+	issues := NormalizeLegacyKeys(&root, filename)
+
+	interpolator := v.Interpolator
+	if interpolator == nil {
+		interpolator = NewInterpolator(filepath.Dir(filename))
+	}
+	issues = append(issues, interpolator.Interpolate(&root, filename)...)
+
+	// A type mismatch leaves the field empty but decoding carries on, so the
+	// remaining checks still run on everything else
+	var config Config
+	if err := root.Decode(&config); err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, message := range typeErr.Errors {
+				issues = append(issues, yamlIssue(message, filename))
+			}
+		} else {
+			issues = append(issues, yamlIssue(err.Error(), filename))
+		}
+	}
+
+	issues = append(issues, findUnknownKeys(&root, reflect.TypeOf(config), "", filename)...)
+
+	for _, violation := range config.violations() {
+		issues = append(issues, positioned(violation, &root, filename))
+	}
+
+	if v.IsKnownPackage != nil {
+		issues = append(issues, checkNames(&root, filename, IssueUnknownPackage, "package", "packages", config.Packages, v.IsKnownPackage)...)
+	}
+	if v.IsKnownFeature != nil {
+		issues = append(issues, checkNames(&root, filename, IssueUnknownFeature, "feature", "features", config.Features, v.IsKnownFeature)...)
+	}
+	for _, name := range config.ProfileNames() {
+		profile := config.Profiles[name]
+		path := "profiles." + name
+		if v.IsKnownPackage != nil {
+			issues = append(issues, checkNames(&root, filename, IssueUnknownPackage, "package", path+".packages", profile.Packages, v.IsKnownPackage)...)
+		}
+		if v.IsKnownFeature != nil {
+			issues = append(issues, checkNames(&root, filename, IssueUnknownFeature, "feature", path+".features", profile.Features, v.IsKnownFeature)...)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+	return issues
+}
+
+// violations checks required fields and allowed values. The issues carry a
+// path but no position.
+func (c *Config) violations() []ConfigIssue {
+	var issues []ConfigIssue
+	invalid := func(path, format string, args ...interface{}) {
+		issues = append(issues, ConfigIssue{
+			Kind:    IssueInvalid,
+			Path:    path,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	required := []struct {
+		path  string
+		value string
+	}{
+		{"schema_version", c.SchemaVersion},
+		{"name", c.Name},
+		{"version", c.Version},
+		{"architecture", c.Architecture},
+		{"template", c.Template},
+	}
+	for _, field := range required {
+		if field.value == "" {
+			invalid(field.path, "%s is required", field.path)
+		}
+	}
+
+	if c.Architecture != "" && !contains(ValidArchitectures, c.Architecture) {
+		invalid("architecture", "invalid architecture: %s (valid: %s)", c.Architecture, strings.Join(ValidArchitectures, ", "))
+	}
+	if c.Template != "" && !contains(ValidTemplates, c.Template) {
+		invalid("template", "invalid template: %s (valid: %s)", c.Template, strings.Join(ValidTemplates, ", "))
+	}
+
+	for _, conflict := range features.NewFeatureManager().ResolveFeatures(c.Features).Conflicts {
+		invalid("features", "conflicting features: %s", conflict)
+	}
+
+	if c.Build.Optimization != "" && !contains(ValidOptimizations, c.Build.Optimization) {
+		invalid("build.optimization", "invalid optimization: %s (valid: %s)", c.Build.Optimization, strings.Join(ValidOptimizations, ", "))
+	}
+
+	seen := make(map[string]bool)
+	for i, app := range c.Apps {
+		path := fmt.Sprintf("apps[%d]", i)
+		switch {
+		case app.Name == "":
+			invalid(path+".name", "%s.name is required", path)
+		case !appNamePattern.MatchString(app.Name):
+			invalid(path+".name", "invalid app name: %q (use lowercase letters, digits and '-')", app.Name)
+		case seen[app.Name]:
+			invalid(path+".name", "duplicate app name: %s", app.Name)
+		}
+		seen[app.Name] = true
+		if app.Path == "" {
+			invalid(path+".path", "%s.path is required", path)
+		}
+		if !contains(ValidAppBuilds, app.Build) {
+			invalid(path+".build", "invalid build system for app %s: %q (valid: %s)", app.Name, app.Build, strings.Join(ValidAppBuilds, ", "))
+		}
+	}
+
+	// Profile names become build directory names
+	for _, name := range c.ProfileNames() {
+		if !profileNamePattern.MatchString(name) {
+			invalid("profiles."+name, "invalid profile name: %q (use letters, digits, '-' and '_')", name)
+		}
+		if optimization := c.Profiles[name].Build.Optimization; optimization != "" && !contains(ValidOptimizations, optimization) {
+			invalid("profiles."+name+".build.optimization", "invalid optimization: %s (valid: %s)", optimization, strings.Join(ValidOptimizations, ", "))
+		}
+	}
+
+	return issues
+}
+
+// checkNames reports the items of the list at path that lookup does not know
+func checkNames(root *yaml.Node, file string, kind IssueKind, noun, path string, names []string, known func(string) bool) []ConfigIssue {
+	var issues []ConfigIssue
+	for i, name := range names {
+		if known(name) {
+			continue
+		}
+		issue := ConfigIssue{
+			Kind:    kind,
+			Path:    fmt.Sprintf("%s[%d]", path, i),
+			Message: fmt.Sprintf("unknown %s %q", noun, name),
+		}
+		issues = append(issues, positioned(issue, root, file))
+	}
+	return issues
+}
+
+// positioned fills in the file and the position of the node at issue.Path.
+// Issues about a missing key keep line 0.
+func positioned(issue ConfigIssue, root *yaml.Node, file string) ConfigIssue {
+	issue.File = file
+	if node := nodeAtPath(root, issue.Path); node != nil {
+		issue.Line = node.Line
+		issue.Column = node.Column
+	}
+	return issue
+}
+
+// nodeAtPath finds the node at a config path such as profiles.dev.packages[1].
+// Scalars resolve to the value node, mappings and sequences to their key so
+// the position points at the start of the section. Keys may contain dots.
+func nodeAtPath(root *yaml.Node, path string) *yaml.Node {
+	node := documentRoot(root)
+	var key *yaml.Node
+
+	for path != "" && node != nil {
+		switch node.Kind {
+		case yaml.MappingNode:
+			matched := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				name := node.Content[i].Value
+				rest, ok := strings.CutPrefix(path, name)
+				if !ok || (rest != "" && rest[0] != '.' && rest[0] != '[') {
+					continue
+				}
+				key, node = node.Content[i], node.Content[i+1]
+				path = strings.TrimPrefix(rest, ".")
+				matched = true
+				break
+			}
+			if !matched {
+				return nil
+			}
+
+		case yaml.SequenceNode:
+			end := strings.Index(path, "]")
+			if path[0] != '[' || end < 0 {
+				return nil
+			}
+			index, err := strconv.Atoi(path[1:end])
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil
+			}
+			key, node = nil, node.Content[index]
+			path = strings.TrimPrefix(path[end+1:], ".")
+
+		default:
+			return nil
+		}
+	}
+
+	if node == nil || path != "" {
+		return nil
+	}
+	if node.Kind != yaml.ScalarNode && key != nil {
+		return key
+	}
+	return node
+}
+
+// yamlIssue converts a yaml.v3 error message into an issue
+func yamlIssue(message, file string) ConfigIssue {
+	issue := ConfigIssue{Kind: IssueInvalid, File: file, Message: strings.TrimPrefix(message, "yaml: ")}
+
+	match := yamlErrorPattern.FindStringSubmatch(message)
+	if match == nil {
+		return issue
+	}
+	issue.Line, _ = strconv.Atoi(match[1])
+	issue.Message = match[2]
+
+	if typeMatch := typeErrorPattern.FindStringSubmatch(match[2]); typeMatch != nil {
+		issue.Message = fmt.Sprintf("invalid value %q: expected %s", typeMatch[1], describeGoType(typeMatch[2]))
+	}
+	return issue
+}
+
+// describeGoType turns a Go type name from a yaml.v3 type error into words
+func describeGoType(name string) string {
+	switch {
+	case strings.HasPrefix(name, "[]"):
+		return "a list"
+	case strings.HasPrefix(name, "map["), strings.HasPrefix(name, "config."):
+		return "a mapping"
+	case strings.HasPrefix(name, "int"), strings.HasPrefix(name, "uint"):
+		return "an integer"
+	case name == "bool":
+		return "true or false"
+	case name == "time.Duration":
+		return "a duration such as 30s"
+	case strings.HasPrefix(name, "float"):
+		return "a number"
+	}
+	return name
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidatorTestSuite struct {
+	suite.Suite
+	validator *Validator
+}
+
+func TestValidatorTestSuite(t *testing.T) {
+	suite.Run(t, new(ValidatorTestSuite))
+}
+
+func (s *ValidatorTestSuite) SetupTest() {
+	s.validator = NewValidator()
+	s.validator.IsKnownPackage = func(name string) bool {
+		return contains([]string{"busybox", "dropbear", "nginx"}, name)
+	}
+	s.validator.Interpolator = &Interpolator{
+		LookupEnv:    func(string) (string, bool) { return "", false },
+		LookupSecret: func(name string) (string, error) { return "", os.ErrNotExist },
+	}
+}
+
+func (s *ValidatorTestSuite) messages(issues []ConfigIssue) []string {
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = issue.String()
+	}
+	return messages
+}
+
+func (s *ValidatorTestSuite) TestValidConfig() {
+	content := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+packages:
+  - busybox
+features:
+  - ssh-hardening
+`
+	s.Empty(s.validator.Validate([]byte(content), "forge.yml"))
+}
+
+func (s *ValidatorTestSuite) TestReportsEveryViolation() {
+	content := `schema_version: "1.0"
+name: ""
+version: "1.0.0"
+architecture: "sparc"
+packages:
+  - busybox
+  - nginx-extras
+features:
+  - teleport
+build:
+  jobs: many
+  colour: blue
+profiles:
+  dev.local:
+    packages:
+      - gdbserver
+`
+	issues := s.validator.Validate([]byte(content), "forge.yml")
+	s.Equal([]string{
+		"forge.yml: template is required",
+		"forge.yml:2:7: name is required",
+		"forge.yml:4:15: invalid architecture: sparc (valid: x86_64, arm, aarch64, riscv64, i386, armv7, armv5, mips)",
+		"forge.yml:7:5: unknown package \"nginx-extras\"",
+		"forge.yml:9:5: unknown feature \"teleport\"",
+		"forge.yml:11: invalid value \"many\": expected an integer",
+		"forge.yml:12:3: unknown key \"build.colour\" is ignored",
+		"forge.yml:14:3: invalid profile name: \"dev.local\" (use letters, digits, '-' and '_')",
+		"forge.yml:16:9: unknown package \"gdbserver\"",
+	}, s.messages(issues))
+
+	s.Equal(IssueUnknownPackage, issues[3].Kind)
+	s.Equal("packages[1]", issues[3].Path)
+	s.Equal(IssueUnknownFeature, issues[4].Kind)
+	s.Equal(IssueInvalid, issues[5].Kind)
+}
+
+func (s *ValidatorTestSuite) TestConflictingFeatures() {
+	content := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+features:
+  - systemd
+  - sysvinit
+`
+	s.Equal([]string{"forge.yml:6:1: conflicting features: sysvinit conflicts with systemd"},
+		s.messages(s.validator.Validate([]byte(content), "forge.yml")))
+}
+
+func (s *ValidatorTestSuite) TestApps() {
+	content := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+apps:
+  - name: sensord
+    path: src/sensord
+    build: go
+  - name: Gateway
+    path: src/gateway
+    build: cargo
+  - name: sensord
+    build: make
+`
+	s.Equal([]string{
+		"forge.yml: apps[2].path is required",
+		"forge.yml:10:11: invalid app name: \"Gateway\" (use lowercase letters, digits and '-')",
+		"forge.yml:12:12: invalid build system for app Gateway: \"cargo\" (valid: go, cmake, meson, make)",
+		"forge.yml:13:11: duplicate app name: sensord",
+	}, s.messages(s.validator.Validate([]byte(content), "forge.yml")))
+}
+
+func (s *ValidatorTestSuite) TestSyntaxError() {
+	content := `schema_version: "1.0"
+name: test
+  version: 1.0.0
+`
+	issues := s.validator.Validate([]byte(content), "forge.yml")
+	s.Require().Len(issues, 1)
+	s.Equal(IssueInvalid, issues[0].Kind)
+	s.Equal("forge.yml:3: mapping values are not allowed in this context", issues[0].String())
+}
+
+func (s *ValidatorTestSuite) TestWarningsAndUnresolvedReferences() {
+	content := `schema_version: "1.0"
+name: "${PRODUCT}"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+buildroot_version: "2024.02"
+`
+	issues := s.validator.Validate([]byte(content), "forge.yml")
+	s.Require().Len(issues, 2)
+	s.Equal(IssueUnresolved, issues[0].Kind)
+	s.False(issues[0].IsWarning())
+	s.Equal(IssueDeprecated, issues[1].Kind)
+	s.True(issues[1].IsWarning())
+
+	// Unknown keys are ignored, so they leave the configuration usable
+	issues = s.validator.Validate([]byte(`schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+bootloader: grub
+`), "forge.yml")
+	s.Require().Len(issues, 1)
+	s.Equal(IssueUnknownKey, issues[0].Kind)
+	s.True(issues[0].IsWarning())
+}
+
+func (s *ValidatorTestSuite) TestChecksAreOptional() {
+	content := `schema_version: "1.0"
+name: "test"
+version: "1.0.0"
+architecture: "x86_64"
+template: "minimal"
+packages: [anything]
+features: [anything]
+`
+	validator := &Validator{Interpolator: s.validator.Interpolator}
+	s.Empty(validator.Validate([]byte(content), "forge.yml"))
+}
+
+func (s *ValidatorTestSuite) TestValidateFile() {
+	dir := s.T().TempDir()
+	configPath := filepath.Join(dir, "forge.yml")
+	s.Require().NoError(os.WriteFile(configPath, []byte("schema_version: \"1.0\"\n"), 0644))
+
+	issues, err := s.validator.ValidateFile(configPath)
+	s.NoError(err)
+	s.Len(issues, 4)
+	s.Equal(configPath, issues[0].File)
+
+	_, err = s.validator.ValidateFile(filepath.Join(dir, "missing.yml"))
+	s.Error(err)
+}
+
+func (s *ValidatorTestSuite) TestValidateMatchesValidate() {
+	config := &Config{SchemaVersion: "1.0", Name: "test", Version: "1.0.0", Architecture: "x86_64", Template: "nope"}
+	err := config.Validate()
+	s.Require().Error(err)
+	s.Equal(config.violations()[0].Message, err.Error())
+}
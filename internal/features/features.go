@@ -0,0 +1,462 @@
+package features
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FeatureInfo describes a pre-configured feature and everything it brings
+// into the image
+type FeatureInfo struct {
+	Name        string
+	Description string
+	Category    string
+	Packages    []string          // Forge packages the feature installs
+	Buildroot   []string          // Extra Buildroot symbols, e.g. BR2_INIT_SYSTEMD=y
+	Kernel      []string          // Kernel symbols, e.g. CONFIG_NETFILTER=y
+	Overlays    map[string]string // Root filesystem path -> file content
+	Conflicts   []string          // Features that cannot be enabled together with this one
+}
+
+// FeatureManager holds the catalog of features that can be enabled in forge.yml
+type FeatureManager struct {
+	features   map[string]*FeatureInfo
+	categories map[string][]string // Category -> feature names
+}
+
+// NewFeatureManager creates a feature manager with the built-in features
+func NewFeatureManager() *FeatureManager {
+	fm := &FeatureManager{
+		features:   make(map[string]*FeatureInfo),
+		categories: make(map[string][]string),
+	}
+
+	fm.initializeFeatureDatabase()
+	return fm
+}
+
+// initializeFeatureDatabase registers the built-in features
+func (fm *FeatureManager) initializeFeatureDatabase() {
+	// System features
+	fm.addFeature(&FeatureInfo{
+		Name:        "systemd",
+		Description: "Use systemd as the init system",
+		Category:    "system",
+		Buildroot:   []string{"BR2_INIT_SYSTEMD=y"},
+		Kernel: []string{
+			"CONFIG_CGROUPS=y",
+			"CONFIG_FHANDLE=y",
+			"CONFIG_INOTIFY_USER=y",
+			"CONFIG_SIGNALFD=y",
+			"CONFIG_TIMERFD=y",
+			"CONFIG_EPOLL=y",
+		},
+		Conflicts: []string{"sysvinit"},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "sysvinit",
+		Description: "Use SysV init scripts as the init system",
+		Category:    "system",
+		Buildroot:   []string{"BR2_INIT_SYSV=y"},
+		Conflicts:   []string{"systemd"},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "network",
+		Description: "Networking support for the base system, with eth0 configured by DHCP at boot",
+		Category:    "system",
+		Buildroot: []string{
+			"BR2_PACKAGE_IFUPDOWN_SCRIPTS=y",
+			`BR2_SYSTEM_DHCP="eth0"`,
+		},
+		Kernel: []string{
+			"CONFIG_NET=y",
+			"CONFIG_INET=y",
+			"CONFIG_PACKET=y",
+			"CONFIG_UNIX=y",
+		},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "debug",
+		Description: "Build with debugging symbols",
+		Category:    "system",
+		Buildroot:   []string{"BR2_ENABLE_DEBUG=y"},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "watchdog",
+		Description: "Reboot automatically when the system stops responding",
+		Category:    "system",
+		Packages:    []string{"watchdog"},
+		Kernel: []string{
+			"CONFIG_WATCHDOG=y",
+			"CONFIG_SOFT_WATCHDOG=y",
+		},
+		Overlays: map[string]string{
+			"/etc/watchdog.conf": `# Managed by the forge watchdog feature
+watchdog-device = /dev/watchdog
+interval = 10
+max-load-1 = 24
+`,
+		},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "real-time",
+		Description: "Fully preemptible kernel (PREEMPT_RT, Linux 6.12 or later) with high-resolution timers",
+		Category:    "system",
+		Kernel: []string{
+			"CONFIG_PREEMPT_RT=y",
+			"CONFIG_HIGH_RES_TIMERS=y",
+		},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "auto-updates",
+		Description: "Over-the-air image updates with SWUpdate",
+		Category:    "system",
+		Packages:    []string{"swupdate"},
+		Kernel:      []string{"CONFIG_MTD=y"},
+		Overlays: map[string]string{
+			"/etc/swupdate.cfg": `# Managed by the forge auto-updates feature
+globals :
+{
+	verbose = true;
+	loglevel = 5;
+};
+`,
+		},
+	})
+
+	// Security features
+	fm.addFeature(&FeatureInfo{
+		Name:        "ssh-hardening",
+		Description: "OpenSSH with key-only logins and a restricted configuration",
+		Category:    "security",
+		Packages:    []string{"openssh"},
+		Overlays: map[string]string{
+			"/etc/ssh/sshd_config": `# Managed by the forge ssh-hardening feature
+PermitRootLogin prohibit-password
+PasswordAuthentication no
+KbdInteractiveAuthentication no
+PermitEmptyPasswords no
+X11Forwarding no
+MaxAuthTries 3
+LoginGraceTime 30
+ClientAliveInterval 300
+ClientAliveCountMax 2
+Subsystem sftp /usr/libexec/sftp-server
+`,
+		},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "firewall",
+		Description: "iptables firewall that drops unsolicited incoming traffic except SSH",
+		Category:    "security",
+		Packages:    []string{"iptables"},
+		Kernel: []string{
+			"CONFIG_NETFILTER=y",
+			"CONFIG_NF_CONNTRACK=y",
+			"CONFIG_NETFILTER_XT_MATCH_CONNTRACK=y",
+			"CONFIG_IP_NF_IPTABLES=y",
+			"CONFIG_IP_NF_FILTER=y",
+		},
+		Overlays: map[string]string{
+			"/etc/iptables.conf": `# Managed by the forge firewall feature
+*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+-A INPUT -p icmp -j ACCEPT
+-A INPUT -p tcp --dport 22 -j ACCEPT
+COMMIT
+`,
+		},
+	})
+
+	// Network features
+	fm.addFeature(&FeatureInfo{
+		Name:        "vpn-gateway",
+		Description: "OpenVPN with NAT and IP forwarding for routing clients",
+		Category:    "network",
+		Packages:    []string{"openvpn", "iptables"},
+		Kernel: []string{
+			"CONFIG_TUN=y",
+			"CONFIG_NETFILTER=y",
+			"CONFIG_NF_CONNTRACK=y",
+			"CONFIG_NF_NAT=y",
+			"CONFIG_IP_NF_IPTABLES=y",
+			"CONFIG_IP_NF_NAT=y",
+			"CONFIG_IP_NF_TARGET_MASQUERADE=y",
+		},
+		Overlays: map[string]string{
+			"/etc/sysctl.d/90-forge-vpn-gateway.conf": `# Managed by the forge vpn-gateway feature
+net.ipv4.ip_forward = 1
+`,
+		},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "remote-management",
+		Description: "SSH access and file synchronisation for managing devices in the field",
+		Category:    "network",
+		Packages:    []string{"openssh", "rsync"},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "mesh-routing",
+		Description: "B.A.T.M.A.N. advanced layer 2 mesh routing, managed with batctl",
+		Category:    "network",
+		Packages:    []string{"batctl"},
+		Kernel:      []string{"CONFIG_BATMAN_ADV=m"},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "service-discovery",
+		Description: "mDNS/DNS-SD service discovery with the Avahi daemon",
+		Category:    "network",
+		Packages:    []string{"avahi"},
+		Buildroot:   []string{"BR2_PACKAGE_AVAHI_DAEMON=y"},
+	})
+
+	// Service features
+	fm.addFeature(&FeatureInfo{
+		Name:        "web-server",
+		Description: "nginx web server serving /var/www",
+		Category:    "services",
+		Packages:    []string{"nginx"},
+		Buildroot:   []string{"BR2_PACKAGE_NGINX_HTTP=y"},
+		Overlays: map[string]string{
+			"/var/www/index.html": `<!DOCTYPE html>
+<html><body><h1>Forge OS</h1></body></html>
+`,
+		},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "web-dashboard",
+		Description: "Device status dashboard served by lighttpd on port 8080",
+		Category:    "services",
+		Packages:    []string{"lighttpd"},
+		Overlays: map[string]string{
+			"/etc/lighttpd/conf.d/forge-dashboard.conf": `# Managed by the forge web-dashboard feature
+$SERVER["socket"] == ":8080" {
+	server.document-root = "/var/www/dashboard"
+}
+`,
+			"/var/www/dashboard/index.html": `<!DOCTYPE html>
+<html><body><h1>Forge OS dashboard</h1></body></html>
+`,
+		},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "file-sharing",
+		Description: "SMB file shares with Samba",
+		Category:    "services",
+		Packages:    []string{"samba4"},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "voip-services",
+		Description: "Asterisk PBX for VoIP telephony",
+		Category:    "services",
+		Packages:    []string{"asterisk"},
+	})
+
+	fm.addFeature(&FeatureInfo{
+		Name:        "monitoring",
+		Description: "System metrics collection with collectd",
+		Category:    "services",
+		Packages:    []string{"collectd"},
+		Buildroot: []string{
+			"BR2_PACKAGE_COLLECTD_CPU=y",
+			"BR2_PACKAGE_COLLECTD_MEMORY=y",
+			"BR2_PACKAGE_COLLECTD_DF=y",
+			"BR2_PACKAGE_COLLECTD_INTERFACE=y",
+		},
+	})
+}
+
+// addFeature adds a feature to the catalog
+func (fm *FeatureManager) addFeature(feature *FeatureInfo) {
+	fm.features[feature.Name] = feature
+	fm.categories[feature.Category] = append(fm.categories[feature.Category], feature.Name)
+}
+
+// GetFeatureInfo returns information about a feature
+func (fm *FeatureManager) GetFeatureInfo(name string) (*FeatureInfo, error) {
+	feature, exists := fm.features[name]
+	if !exists {
+		return nil, fmt.Errorf("feature %s not found", name)
+	}
+	return feature, nil
+}
+
+// ListFeatures returns all features sorted by name
+func (fm *FeatureManager) ListFeatures() []*FeatureInfo {
+	var features []*FeatureInfo
+	for _, feature := range fm.features {
+		features = append(features, feature)
+	}
+
+	sort.Slice(features, func(i, j int) bool {
+		return features[i].Name < features[j].Name
+	})
+
+	return features
+}
+
+// ListFeaturesByCategory returns the features in a category sorted by name
+func (fm *FeatureManager) ListFeaturesByCategory(category string) []*FeatureInfo {
+	var features []*FeatureInfo
+	for _, name := range fm.categories[category] {
+		features = append(features, fm.features[name])
+	}
+
+	sort.Slice(features, func(i, j int) bool {
+		return features[i].Name < features[j].Name
+	})
+
+	return features
+}
+
+// GetCategories returns all feature categories
+func (fm *FeatureManager) GetCategories() []string {
+	var categories []string
+	for category := range fm.categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// FeatureNames returns the names of all features, sorted
+func (fm *FeatureManager) FeatureNames() []string {
+	names := make([]string, 0, len(fm.features))
+	for name := range fm.features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsValidFeature checks if a feature exists
+func (fm *FeatureManager) IsValidFeature(name string) bool {
+	_, exists := fm.features[name]
+	return exists
+}
+
+// FeatureResolution is the combined effect of a set of features
+type FeatureResolution struct {
+	Features  []string          // Features in the order they were requested
+	Packages  []string          // Packages the features install, without duplicates
+	Buildroot []string          // Buildroot symbols
+	Kernel    []string          // Kernel symbols
+	Overlays  map[string]string // Root filesystem path -> file content
+	Missing   []string          // Features that don't exist
+	Conflicts []FeatureConflict // Conflicts between the requested features
+}
+
+// FeatureConflict is a pair of requested features that can't be enabled
+// together
+type FeatureConflict struct {
+	Feature string // The feature requested later
+	Other   string // The earlier feature it conflicts with
+	Path    string // Overlay file both provide differently, empty for a declared conflict
+}
+
+func (c FeatureConflict) String() string {
+	if c.Path != "" {
+		return fmt.Sprintf("%s and %s both provide %s", c.Other, c.Feature, c.Path)
+	}
+	return fmt.Sprintf("%s conflicts with %s", c.Feature, c.Other)
+}
+
+// ResolveFeatures combines the packages, symbols and overlay files of a set
+// of features and reports unknown and conflicting features
+func (fm *FeatureManager) ResolveFeatures(featureNames []string) *FeatureResolution {
+	result := &FeatureResolution{
+		Features:  make([]string, 0),
+		Packages:  make([]string, 0),
+		Buildroot: make([]string, 0),
+		Kernel:    make([]string, 0),
+		Overlays:  make(map[string]string),
+		Missing:   make([]string, 0),
+		Conflicts: make([]FeatureConflict, 0),
+	}
+
+	overlayOwners := make(map[string]string)
+	for _, name := range featureNames {
+		if contains(result.Features, name) {
+			continue
+		}
+
+		feature, exists := fm.features[name]
+		if !exists {
+			result.Missing = append(result.Missing, name)
+			continue
+		}
+
+		// A conflict declared by either feature counts
+		for _, other := range result.Features {
+			if contains(feature.Conflicts, other) || contains(fm.features[other].Conflicts, name) {
+				result.Conflicts = append(result.Conflicts, FeatureConflict{Feature: name, Other: other})
+			}
+		}
+
+		result.Features = append(result.Features, name)
+		result.Packages = appendMissing(result.Packages, feature.Packages...)
+		result.Buildroot = appendMissing(result.Buildroot, feature.Buildroot...)
+		result.Kernel = appendMissing(result.Kernel, feature.Kernel...)
+
+		for path, content := range feature.Overlays {
+			if owner, taken := overlayOwners[path]; taken && result.Overlays[path] != content {
+				result.Conflicts = append(result.Conflicts, FeatureConflict{Feature: name, Other: owner, Path: path})
+				continue
+			}
+			overlayOwners[path] = name
+			result.Overlays[path] = content
+		}
+	}
+
+	return result
+}
+
+// CheckConflicts returns the conflicts adding a feature would introduce to
+// the features already enabled
+func (fm *FeatureManager) CheckConflicts(enabled []string, name string) []FeatureConflict {
+	resolution := fm.ResolveFeatures(append(append([]string{}, enabled...), name))
+	var conflicts []FeatureConflict
+	for _, conflict := range resolution.Conflicts {
+		if conflict.Feature == name || conflict.Other == name {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// appendMissing appends the items that are not already in list
+func appendMissing(list []string, items ...string) []string {
+	for _, item := range items {
+		if !contains(list, item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// contains checks if a slice contains a string
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
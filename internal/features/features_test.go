@@ -0,0 +1,131 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FeaturesTestSuite struct {
+	suite.Suite
+	manager *FeatureManager
+}
+
+func TestFeaturesTestSuite(t *testing.T) {
+	suite.Run(t, new(FeaturesTestSuite))
+}
+
+func (s *FeaturesTestSuite) SetupTest() {
+	s.manager = NewFeatureManager()
+}
+
+func (s *FeaturesTestSuite) TestNewFeatureManager() {
+	s.NotNil(s.manager.features)
+	s.NotNil(s.manager.categories)
+
+	for _, name := range []string{
+		"ssh-hardening", "web-server", "vpn-gateway", "monitoring", "auto-updates",
+		"firewall", "web-dashboard", "remote-management", "watchdog",
+		"real-time", "mesh-routing", "service-discovery", "file-sharing", "voip-services",
+	} {
+		s.True(s.manager.IsValidFeature(name), name)
+	}
+	s.False(s.manager.IsValidFeature("kiosk-mode"))
+}
+
+func (s *FeaturesTestSuite) TestGetFeatureInfo() {
+	feature, err := s.manager.GetFeatureInfo("firewall")
+	s.NoError(err)
+	s.Equal("security", feature.Category)
+	s.Contains(feature.Packages, "iptables")
+	s.Contains(feature.Kernel, "CONFIG_NETFILTER=y")
+	s.Contains(feature.Overlays, "/etc/iptables.conf")
+
+	feature, err = s.manager.GetFeatureInfo("nonexistent")
+	s.Error(err)
+	s.Nil(feature)
+	s.Contains(err.Error(), "feature nonexistent not found")
+}
+
+func (s *FeaturesTestSuite) TestListFeatures() {
+	features := s.manager.ListFeatures()
+	s.Len(features, len(s.manager.FeatureNames()))
+	for i := 1; i < len(features); i++ {
+		s.Less(features[i-1].Name, features[i].Name)
+	}
+}
+
+func (s *FeaturesTestSuite) TestListFeaturesByCategory() {
+	var names []string
+	for _, feature := range s.manager.ListFeaturesByCategory("security") {
+		names = append(names, feature.Name)
+	}
+	s.Equal([]string{"firewall", "ssh-hardening"}, names)
+
+	s.Empty(s.manager.ListFeaturesByCategory("nonexistent"))
+	s.Equal([]string{"network", "security", "services", "system"}, s.manager.GetCategories())
+}
+
+func (s *FeaturesTestSuite) TestRegistryIsConsistent() {
+	for _, feature := range s.manager.ListFeatures() {
+		s.NotEmpty(feature.Description, feature.Name)
+		for _, conflict := range feature.Conflicts {
+			s.True(s.manager.IsValidFeature(conflict), "%s conflicts with unknown feature %s", feature.Name, conflict)
+		}
+		for _, line := range feature.Buildroot {
+			s.Regexp(`^BR2_\w+=`, line)
+		}
+		for _, line := range feature.Kernel {
+			s.Regexp(`^CONFIG_\w+=`, line)
+		}
+		for path := range feature.Overlays {
+			s.Regexp(`^/`, path)
+		}
+	}
+}
+
+func (s *FeaturesTestSuite) TestResolveFeatures() {
+	result := s.manager.ResolveFeatures([]string{"firewall", "vpn-gateway", "firewall", "unknown"})
+
+	s.Equal([]string{"firewall", "vpn-gateway"}, result.Features)
+	s.Equal([]string{"iptables", "openvpn"}, result.Packages)
+	s.Contains(result.Kernel, "CONFIG_TUN=y")
+	s.Contains(result.Overlays, "/etc/iptables.conf")
+	s.Contains(result.Overlays, "/etc/sysctl.d/90-forge-vpn-gateway.conf")
+	s.Equal([]string{"unknown"}, result.Missing)
+	s.Empty(result.Conflicts)
+
+	// Options shared by both features are listed once
+	count := 0
+	for _, line := range result.Kernel {
+		if line == "CONFIG_NETFILTER=y" {
+			count++
+		}
+	}
+	s.Equal(1, count)
+}
+
+func (s *FeaturesTestSuite) TestResolveFeaturesConflicts() {
+	result := s.manager.ResolveFeatures([]string{"systemd", "sysvinit"})
+	s.Equal([]FeatureConflict{{Feature: "sysvinit", Other: "systemd"}}, result.Conflicts)
+	s.Equal("sysvinit conflicts with systemd", result.Conflicts[0].String())
+}
+
+func (s *FeaturesTestSuite) TestCheckConflicts() {
+	s.Equal([]FeatureConflict{{Feature: "sysvinit", Other: "systemd"}}, s.manager.CheckConflicts([]string{"network", "systemd"}, "sysvinit"))
+	s.Empty(s.manager.CheckConflicts([]string{"network"}, "firewall"))
+}
+
+func (s *FeaturesTestSuite) TestCheckConflictsMatchesNamesExactly() {
+	s.manager.addFeature(&FeatureInfo{Name: "openssh-server", Category: "security", Conflicts: []string{"dropbear-server"}})
+	s.manager.addFeature(&FeatureInfo{Name: "dropbear-server", Category: "security", Conflicts: []string{"openssh-server"}})
+	s.manager.addFeature(&FeatureInfo{Name: "ssh", Category: "security"})
+	s.manager.addFeature(&FeatureInfo{Name: "telnetd", Category: "security", Conflicts: []string{"ssh"}})
+
+	// The existing conflict is not the doing of ssh, whose name is part of openssh-server
+	s.Empty(s.manager.CheckConflicts([]string{"openssh-server", "dropbear-server"}, "ssh"))
+
+	// A conflict only one side declares is found in either order
+	s.Equal([]FeatureConflict{{Feature: "telnetd", Other: "ssh"}}, s.manager.CheckConflicts([]string{"ssh"}, "telnetd"))
+	s.Equal([]FeatureConflict{{Feature: "ssh", Other: "telnetd"}}, s.manager.CheckConflicts([]string{"telnetd"}, "ssh"))
+}
@@ -0,0 +1,332 @@
+package kconfig
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Assignment is a symbol value requested in a defconfig or config fragment
+type Assignment struct {
+	Name   string // Name as written in .config, with the tree's prefix
+	Value  string // y, m, n, a number or a quoted string
+	Source string // The forge.yml setting that asked for it
+}
+
+// Issue is a problem with an assignment
+type Issue struct {
+	Assignment
+	Message    string
+	Suggestion string // Closest existing symbol, for unknown symbols
+	Warning    bool   // The build can go ahead, but may not get what was asked for
+}
+
+func (i Issue) String() string {
+	text := fmt.Sprintf("%s=%s (%s): %s", i.Name, i.Value, i.Source, i.Message)
+	if i.Suggestion != "" {
+		text += fmt.Sprintf(", did you mean %s?", i.Suggestion)
+	}
+	return text
+}
+
+var (
+	intPattern = regexp.MustCompile(`^-?[0-9]+$`)
+	hexPattern = regexp.MustCompile(`^0[xX][0-9a-fA-F]+$`)
+)
+
+// Check validates assignments against the tree: that each symbol exists,
+// that its value suits its type, that users can set it and that its
+// dependencies are met by the other assignments
+func (t *Tree) Check(assignments []Assignment) []Issue {
+	values := make(map[string]string, len(assignments))
+	for _, a := range assignments {
+		if name, ok := strings.CutPrefix(a.Name, t.Prefix); ok {
+			values[name] = a.Value
+		}
+	}
+	env := t.resolve(values)
+
+	var issues []Issue
+	for _, a := range assignments {
+		symbol, ok := t.Lookup(a.Name)
+		if !ok {
+			issues = append(issues, Issue{Assignment: a, Message: "unknown symbol", Suggestion: t.Suggest(a.Name)})
+			continue
+		}
+
+		if message := checkType(symbol.Type, a.Value); message != "" {
+			issues = append(issues, Issue{Assignment: a, Message: message})
+			continue
+		}
+
+		if !symbol.Prompt && symbol.Choice == nil {
+			issues = append(issues, Issue{Assignment: a, Message: "has no prompt and is only set by Kconfig itself", Warning: true})
+			continue
+		}
+
+		if ParseTristate(a.Value) == No && (symbol.Type == TypeBool || symbol.Type == TypeTristate) {
+			continue
+		}
+		if symbol.DependsOn != nil && symbol.DependsOn.eval(env) == No {
+			issues = append(issues, Issue{
+				Assignment: a,
+				Message:    fmt.Sprintf("depends on %s, which is not met%s", symbol.DependsOn, env.unmet(symbol.DependsOn)),
+				Warning:    true,
+			})
+		}
+	}
+	return issues
+}
+
+// checkType describes why value does not suit a symbol type
+func checkType(typ SymbolType, value string) string {
+	switch typ {
+	case TypeBool:
+		if value != "y" && value != "n" {
+			return "expects y or n"
+		}
+	case TypeTristate:
+		if value != "y" && value != "m" && value != "n" {
+			return "expects y, m or n"
+		}
+	case TypeInt:
+		if !intPattern.MatchString(value) {
+			return "expects an integer"
+		}
+	case TypeHex:
+		if !hexPattern.MatchString(value) {
+			return "expects a hexadecimal value such as 0x1000"
+		}
+	case TypeString:
+		if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+			return "expects a quoted string"
+		}
+	}
+	return ""
+}
+
+// Suggest returns the existing symbol closest to name, or "" if none is
+// close enough to be a likely typo
+func (t *Tree) Suggest(name string) string {
+	best, bestDistance := "", len(name)/4+2
+	for _, candidate := range t.Names() {
+		if d := distance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	// Fall back to a symbol that contains the last word of the name, such
+	// as BR2_PACKAGE_LIBMODBUS for BR2_PACKAGE_MODBUS
+	parts := strings.Split(name, "_")
+	word := parts[len(parts)-1]
+	prefix := strings.TrimSuffix(name, word)
+	if len(word) < 3 {
+		return ""
+	}
+	for _, candidate := range t.Names() {
+		if strings.HasPrefix(candidate, prefix) && strings.HasSuffix(candidate, word) && !strings.Contains(candidate[len(prefix):], "_") {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// distance is the Levenshtein distance between two strings
+func distance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// evaluator looks up symbol values while evaluating expressions
+type evaluator struct {
+	tree   *Tree
+	values map[string]string
+}
+
+// value returns the value of a symbol, with quotes removed from strings.
+// Words that are not symbols are constants and evaluate to themselves.
+func (e *evaluator) value(name string) string {
+	if value, ok := e.values[name]; ok {
+		return strings.Trim(value, `"`)
+	}
+	symbol, ok := e.tree.Symbols[name]
+	if !ok {
+		return name
+	}
+	if symbol.Type == TypeBool || symbol.Type == TypeTristate {
+		return "n"
+	}
+	return ""
+}
+
+// unmet lists the symbols of an expression that are not enabled
+func (e *evaluator) unmet(expr Expr) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range expr.symbols(nil) {
+		symbol, ok := e.tree.Symbols[name]
+		if !ok || seen[name] || (symbol.Type != TypeBool && symbol.Type != TypeTristate) {
+			continue
+		}
+		seen[name] = true
+		if ParseTristate(e.value(name)) == No {
+			names = append(names, e.tree.Prefix+name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return " (not enabled: " + strings.Join(names, ", ") + ")"
+}
+
+// maxPasses bounds the fixed point iteration in resolve
+const maxPasses = 20
+
+// resolve approximates the values Kconfig computes from the assigned ones:
+// selects are applied, unassigned symbols take their defaults and choices
+// their default member. It does not replace olddefconfig but is close
+// enough to check dependencies before Buildroot runs.
+func (t *Tree) resolve(assigned map[string]string) *evaluator {
+	env := &evaluator{tree: t, values: make(map[string]string, len(assigned))}
+	for name, value := range assigned {
+		env.values[name] = value
+	}
+
+	names := make([]string, 0, len(t.Symbols))
+	for name := range t.Symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	selected := make(map[string]bool)
+	for pass := 0; pass < maxPasses; pass++ {
+		changed := false
+		set := func(name, value string) {
+			if env.values[name] != value {
+				env.values[name] = value
+				changed = true
+			}
+		}
+
+		for _, name := range names {
+			symbol := t.Symbols[name]
+			if ParseTristate(env.values[name]) == No {
+				continue
+			}
+			for _, s := range symbol.Selects {
+				if s.Condition == nil || s.Condition.eval(env) != No {
+					selected[s.Symbol] = true
+					set(s.Symbol, "y")
+				}
+			}
+		}
+
+		for _, name := range names {
+			symbol := t.Symbols[name]
+			if _, ok := assigned[name]; ok || selected[name] || symbol.Choice != nil {
+				continue
+			}
+			if value, ok := t.defaultValue(symbol, env); ok {
+				set(name, value)
+			}
+		}
+
+		for _, choice := range t.Choices {
+			if member := t.choiceMember(choice, env); member != "" {
+				for _, m := range choice.Members {
+					if _, ok := assigned[m]; !ok && m != member {
+						set(m, "n")
+					}
+				}
+				set(member, "y")
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+	return env
+}
+
+// defaultValue returns the value a symbol takes from its first default
+// whose condition holds
+func (t *Tree) defaultValue(symbol *Symbol, env *evaluator) (string, bool) {
+	if symbol.DependsOn != nil && symbol.DependsOn.eval(env) == No {
+		if symbol.Type == TypeBool || symbol.Type == TypeTristate {
+			return "n", true
+		}
+		return "", false
+	}
+	for _, d := range symbol.Defaults {
+		if d.Condition != nil && d.Condition.eval(env) == No {
+			continue
+		}
+		switch symbol.Type {
+		case TypeBool, TypeTristate:
+			value := d.Value.eval(env)
+			if symbol.Type == TypeBool && value == Module {
+				value = Yes
+			}
+			return value.String(), true
+		default:
+			if constant, ok := d.Value.(*symbolExpr); ok {
+				if constant.quoted {
+					return `"` + constant.name + `"`, true
+				}
+				if _, isSymbol := t.Symbols[constant.name]; isSymbol {
+					return env.values[constant.name], true
+				}
+				return constant.name, true
+			}
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// choiceMember returns the member a choice selects: the one that is set,
+// else its default, else its first visible member
+func (t *Tree) choiceMember(choice *Choice, env *evaluator) string {
+	visible := func(name string) bool {
+		symbol, ok := t.Symbols[name]
+		return ok && (symbol.DependsOn == nil || symbol.DependsOn.eval(env) != No)
+	}
+
+	for _, m := range choice.Members {
+		if ParseTristate(env.values[m]) == Yes {
+			return m
+		}
+	}
+	for _, d := range choice.Defaults {
+		if (d.Condition == nil || d.Condition.eval(env) != No) && visible(d.Symbol) {
+			return d.Symbol
+		}
+	}
+	for _, m := range choice.Members {
+		if visible(m) {
+			return m
+		}
+	}
+	return ""
+}
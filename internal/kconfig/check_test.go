@@ -0,0 +1,152 @@
+package kconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CheckTestSuite struct {
+	suite.Suite
+	buildroot *Tree
+	kernel    *Tree
+}
+
+func TestCheckTestSuite(t *testing.T) {
+	suite.Run(t, new(CheckTestSuite))
+}
+
+func (s *CheckTestSuite) SetupTest() {
+	tempDir := s.T().TempDir()
+	write := func(dir string, files map[string]string) string {
+		for path, content := range files {
+			full := filepath.Join(tempDir, dir, path)
+			s.Require().NoError(os.MkdirAll(filepath.Dir(full), 0755))
+			s.Require().NoError(os.WriteFile(full, []byte(content), 0644))
+		}
+		return filepath.Join(tempDir, dir)
+	}
+
+	var err error
+	dir := write("buildroot", buildrootTree)
+	s.buildroot, err = ParseBuildroot(dir, filepath.Join(dir, "output"))
+	s.Require().NoError(err)
+	s.kernel, err = ParseKernel(write("linux", kernelTree), "x86")
+	s.Require().NoError(err)
+}
+
+func (s *CheckTestSuite) messages(issues []Issue) []string {
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	return messages
+}
+
+func (s *CheckTestSuite) TestValidConfig() {
+	issues := s.buildroot.Check([]Assignment{
+		{Name: "BR2_x86_64", Value: "y", Source: "architecture x86_64"},
+		{Name: "BR2_TOOLCHAIN_BUILDROOT_GLIBC", Value: "y", Source: "toolchain"},
+		{Name: "BR2_PACKAGE_BUSYBOX", Value: "y", Source: "template minimal"},
+		// Needs threads, which glibc selects
+		{Name: "BR2_PACKAGE_LIBMODBUS", Value: "y", Source: "package modbus"},
+		// Inside if BR2_USE_MMU, which defaults to y
+		{Name: "BR2_PACKAGE_NGINX", Value: "y", Source: "package nginx"},
+		{Name: "BR2_PACKAGE_NGINX_HTTP", Value: "y", Source: "feature web-server"},
+		{Name: "BR2_ROOTFS_OVERLAY", Value: `"/overlay"`, Source: "overlays"},
+		{Name: "BR2_JLEVEL", Value: "4", Source: "build"},
+	})
+	s.Empty(issues)
+}
+
+func (s *CheckTestSuite) TestUnknownSymbols() {
+	issues := s.buildroot.Check([]Assignment{
+		{Name: "BR2_PACKAGE_MODBUS", Value: "y", Source: "template industrial"},
+		{Name: "BR2_PACKAGE_BUSYBXO", Value: "y", Source: "package busybxo"},
+		{Name: "BR2_PACKAGE_CHROMIUM", Value: "y", Source: "template kiosk"},
+	})
+	s.Equal([]string{
+		"BR2_PACKAGE_MODBUS=y (template industrial): unknown symbol, did you mean BR2_PACKAGE_LIBMODBUS?",
+		"BR2_PACKAGE_BUSYBXO=y (package busybxo): unknown symbol, did you mean BR2_PACKAGE_BUSYBOX?",
+		"BR2_PACKAGE_CHROMIUM=y (template kiosk): unknown symbol",
+	}, s.messages(issues))
+	for _, issue := range issues {
+		s.False(issue.Warning)
+	}
+}
+
+func (s *CheckTestSuite) TestTypes() {
+	issues := s.buildroot.Check([]Assignment{
+		{Name: "BR2_JLEVEL", Value: "four", Source: "build"},
+		{Name: "BR2_ROOTFS_OVERLAY", Value: "/overlay", Source: "overlays"},
+		{Name: "BR2_PACKAGE_BUSYBOX", Value: "m", Source: "package busybox"},
+		{Name: "BR2_PACKAGE_NGINX_EXTRA", Value: "m", Source: "test"},
+	})
+	s.Equal([]string{
+		"BR2_JLEVEL=four (build): expects an integer",
+		"BR2_ROOTFS_OVERLAY=/overlay (overlays): expects a quoted string",
+		"BR2_PACKAGE_BUSYBOX=m (package busybox): expects y or n",
+		"BR2_PACKAGE_NGINX_EXTRA=m (test): depends on BR2_PACKAGE_NGINX, which is not met (not enabled: BR2_PACKAGE_NGINX)",
+	}, s.messages(issues))
+}
+
+func (s *CheckTestSuite) TestDependencies() {
+	issues := s.buildroot.Check([]Assignment{
+		{Name: "BR2_arm", Value: "y", Source: "architecture arm"},
+		{Name: "BR2_USE_MMU", Value: "n", Source: "test"},
+		{Name: "BR2_TOOLCHAIN_EXTERNAL", Value: "y", Source: "toolchain"},
+		{Name: "BR2_PACKAGE_LIBMODBUS", Value: "y", Source: "package modbus"},
+		{Name: "BR2_PACKAGE_NGINX", Value: "y", Source: "package nginx"},
+	})
+	s.Equal([]string{
+		"BR2_PACKAGE_LIBMODBUS=y (package modbus): depends on BR2_TOOLCHAIN_HAS_THREADS, which is not met (not enabled: BR2_TOOLCHAIN_HAS_THREADS)",
+		"BR2_PACKAGE_NGINX=y (package nginx): depends on BR2_USE_MMU && BR2_TOOLCHAIN_HAS_THREADS, which is not met (not enabled: BR2_TOOLCHAIN_HAS_THREADS, BR2_USE_MMU)",
+	}, s.messages(issues))
+	for _, issue := range issues {
+		s.True(issue.Warning)
+	}
+}
+
+func (s *CheckTestSuite) TestChoiceDefaults() {
+	// BR2_i386 is the default architecture and the Buildroot toolchain the
+	// default toolchain, so glibc is visible without setting either
+	issues := s.buildroot.Check([]Assignment{
+		{Name: "BR2_TOOLCHAIN_BUILDROOT_GLIBC", Value: "y", Source: "toolchain"},
+	})
+	s.Empty(issues)
+
+	env := s.buildroot.resolve(map[string]string{})
+	s.Equal("y", env.values["BR2_i386"])
+	s.Equal("n", env.values["BR2_x86_64"])
+	s.Equal(`"i686"`, env.values["BR2_ARCH"])
+}
+
+func (s *CheckTestSuite) TestHiddenSymbols() {
+	issues := s.buildroot.Check([]Assignment{
+		{Name: "BR2_ARCH", Value: `"x86_64"`, Source: "architecture x86_64"},
+	})
+	s.Equal([]string{`BR2_ARCH="x86_64" (architecture x86_64): has no prompt and is only set by Kconfig itself`}, s.messages(issues))
+	s.True(issues[0].Warning)
+}
+
+func (s *CheckTestSuite) TestKernel() {
+	issues := s.kernel.Check([]Assignment{
+		{Name: "CONFIG_NET", Value: "y", Source: "feature network"},
+		{Name: "CONFIG_NR_CPUS", Value: "8", Source: "kernel.config"},
+		{Name: "CONFIG_NETWORK", Value: "y", Source: "kernel.config"},
+		{Name: "CONFIG_X86_64", Value: "y", Source: "architecture x86_64"},
+	})
+	s.Equal([]string{
+		"CONFIG_NETWORK=y (kernel.config): unknown symbol, did you mean CONFIG_NET?",
+		"CONFIG_X86_64=y (architecture x86_64): has no prompt and is only set by Kconfig itself",
+	}, s.messages(issues))
+}
+
+func (s *CheckTestSuite) TestDistance() {
+	s.Equal(0, distance("abc", "abc"))
+	s.Equal(3, distance("MODBUS", "LIBMODBUS"))
+	s.Equal(2, distance("BUSYBXO", "BUSYBOX"))
+	s.Equal(3, distance("", "abc"))
+}
@@ -0,0 +1,409 @@
+package kconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tristate is the value of a Kconfig expression: n, m or y
+type Tristate int
+
+const (
+	No Tristate = iota
+	Module
+	Yes
+)
+
+// ParseTristate converts a symbol value to a tristate. Anything other than
+// y and m is n.
+func ParseTristate(value string) Tristate {
+	switch value {
+	case "y":
+		return Yes
+	case "m":
+		return Module
+	}
+	return No
+}
+
+func (t Tristate) String() string {
+	switch t {
+	case Yes:
+		return "y"
+	case Module:
+		return "m"
+	}
+	return "n"
+}
+
+// Expr is a parsed Kconfig expression as used by depends on, if, default
+// and select
+type Expr interface {
+	eval(env *evaluator) Tristate
+	symbols(names []string) []string
+	String() string
+}
+
+// symbolExpr is a symbol or a constant such as y, "x86" or 0x1000
+type symbolExpr struct {
+	name   string
+	quoted bool
+}
+
+func (e *symbolExpr) eval(env *evaluator) Tristate {
+	if e.quoted {
+		return ParseTristate(e.name)
+	}
+	return ParseTristate(env.value(e.name))
+}
+
+func (e *symbolExpr) symbols(names []string) []string {
+	if e.quoted {
+		return names
+	}
+	return append(names, e.name)
+}
+
+func (e *symbolExpr) String() string {
+	if e.quoted {
+		return strconv.Quote(e.name)
+	}
+	return e.name
+}
+
+// text returns the string value of the operand of a comparison
+func (e *symbolExpr) text(env *evaluator) string {
+	if e.quoted {
+		return e.name
+	}
+	return env.value(e.name)
+}
+
+// macroExpr is a $(...) preprocessor call, which forge cannot run. It is
+// assumed to hold.
+type macroExpr struct {
+	text string
+}
+
+func (e *macroExpr) eval(env *evaluator) Tristate    { return Yes }
+func (e *macroExpr) symbols(names []string) []string { return names }
+func (e *macroExpr) String() string                  { return e.text }
+
+type notExpr struct {
+	expr Expr
+}
+
+func (e *notExpr) eval(env *evaluator) Tristate    { return Yes - e.expr.eval(env) }
+func (e *notExpr) symbols(names []string) []string { return e.expr.symbols(names) }
+func (e *notExpr) String() string                  { return "!" + e.expr.String() }
+
+type andExpr struct {
+	left, right Expr
+}
+
+func (e *andExpr) eval(env *evaluator) Tristate {
+	return min(e.left.eval(env), e.right.eval(env))
+}
+
+func (e *andExpr) symbols(names []string) []string {
+	return e.right.symbols(e.left.symbols(names))
+}
+
+func (e *andExpr) String() string {
+	return wrapOr(e.left) + " && " + wrapOr(e.right)
+}
+
+type orExpr struct {
+	left, right Expr
+}
+
+func (e *orExpr) eval(env *evaluator) Tristate {
+	return max(e.left.eval(env), e.right.eval(env))
+}
+
+func (e *orExpr) symbols(names []string) []string {
+	return e.right.symbols(e.left.symbols(names))
+}
+
+func (e *orExpr) String() string {
+	return e.left.String() + " || " + e.right.String()
+}
+
+// wrapOr parenthesizes an || operand of &&
+func wrapOr(e Expr) string {
+	if _, ok := e.(*orExpr); ok {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+type compareExpr struct {
+	op          string
+	left, right *symbolExpr
+}
+
+func (e *compareExpr) eval(env *evaluator) Tristate {
+	left, right := e.left.text(env), e.right.text(env)
+
+	var result bool
+	switch e.op {
+	case "=":
+		result = left == right
+	case "!=":
+		result = left != right
+	default:
+		// Ordering compares numbers when both sides are numbers
+		l, lerr := strconv.ParseInt(left, 0, 64)
+		r, rerr := strconv.ParseInt(right, 0, 64)
+		cmp := strings.Compare(left, right)
+		if lerr == nil && rerr == nil {
+			cmp = int(l - r)
+		}
+		switch e.op {
+		case "<":
+			result = cmp < 0
+		case "<=":
+			result = cmp <= 0
+		case ">":
+			result = cmp > 0
+		case ">=":
+			result = cmp >= 0
+		}
+	}
+
+	if result {
+		return Yes
+	}
+	return No
+}
+
+func (e *compareExpr) symbols(names []string) []string {
+	return e.right.symbols(e.left.symbols(names))
+}
+
+func (e *compareExpr) String() string {
+	return e.left.String() + " " + e.op + " " + e.right.String()
+}
+
+// And combines two expressions, either of which may be nil
+func And(left, right Expr) Expr {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	}
+	return &andExpr{left, right}
+}
+
+// Or combines two expressions, either of which may be nil. A nil operand
+// always holds, so the result does too.
+func Or(left, right Expr) Expr {
+	if left == nil || right == nil {
+		return nil
+	}
+	return &orExpr{left, right}
+}
+
+// ParseExpr parses a Kconfig expression
+func ParseExpr(text string) (Expr, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	p := &exprParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", text, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q", text, p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits an expression into operators, symbols, quoted strings and
+// $(...) macro calls
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			var value strings.Builder
+			for end < len(text) && text[end] != c {
+				if text[end] == '\\' && end+1 < len(text) {
+					end++
+				}
+				value.WriteByte(text[end])
+				end++
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("unterminated string in %q", text)
+			}
+			tokens = append(tokens, token{text: value.String(), quoted: true})
+			i = end + 1
+		case strings.HasPrefix(text[i:], "$("):
+			depth, end := 0, i+1
+			for ; end < len(text); end++ {
+				if text[end] == '(' {
+					depth++
+				} else if text[end] == ')' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("unterminated macro in %q", text)
+			}
+			tokens = append(tokens, token{text: text[i : end+1]})
+			i = end + 1
+		case strings.ContainsRune("()", rune(c)):
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case strings.HasPrefix(text[i:], "&&"), strings.HasPrefix(text[i:], "||"),
+			strings.HasPrefix(text[i:], "!="), strings.HasPrefix(text[i:], "<="), strings.HasPrefix(text[i:], ">="):
+			tokens = append(tokens, token{text: text[i : i+2]})
+			i += 2
+		case strings.ContainsRune("!=<>", rune(c)):
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		default:
+			end := i
+			for end < len(text) && !strings.ContainsRune(" \t\"'()!=<>&|", rune(text[end])) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected %q in %q", string(c), text)
+			}
+			tokens = append(tokens, token{text: text[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *exprParser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (Expr, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr}, nil
+	case "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (Expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if macro, ok := left.(*macroExpr); ok {
+		return macro, nil
+	}
+
+	switch op := p.peek(); op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		p.pos++
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		l, lok := left.(*symbolExpr)
+		r, rok := right.(*symbolExpr)
+		if !lok || !rok {
+			// Comparisons against macros can't be evaluated
+			return &macroExpr{text: left.String() + " " + op + " " + right.String()}, nil
+		}
+		return &compareExpr{op: op, left: l, right: r}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOperand() (Expr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	tok := p.tokens[p.pos]
+	if !tok.quoted && strings.ContainsAny(tok.text[:1], "()!=<>&|") {
+		return nil, fmt.Errorf("unexpected %q", tok.text)
+	}
+	p.pos++
+	if !tok.quoted && strings.HasPrefix(tok.text, "$(") {
+		return &macroExpr{text: tok.text}, nil
+	}
+	return &symbolExpr{name: tok.text, quoted: tok.quoted}, nil
+}
@@ -0,0 +1,92 @@
+package kconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExprTestSuite struct {
+	suite.Suite
+	env *evaluator
+}
+
+func TestExprTestSuite(t *testing.T) {
+	suite.Run(t, new(ExprTestSuite))
+}
+
+func (s *ExprTestSuite) SetupTest() {
+	tree := &Tree{Symbols: map[string]*Symbol{
+		"A":    {Name: "A", Type: TypeBool},
+		"B":    {Name: "B", Type: TypeBool},
+		"M":    {Name: "M", Type: TypeTristate},
+		"ARCH": {Name: "ARCH", Type: TypeString},
+		"JOBS": {Name: "JOBS", Type: TypeInt},
+	}}
+	s.env = &evaluator{tree: tree, values: map[string]string{
+		"A":    "y",
+		"M":    "m",
+		"ARCH": `"x86_64"`,
+		"JOBS": "8",
+	}}
+}
+
+func (s *ExprTestSuite) eval(text string) Tristate {
+	expr, err := ParseExpr(text)
+	s.Require().NoError(err, text)
+	return expr.eval(s.env)
+}
+
+func (s *ExprTestSuite) TestLogic() {
+	s.Equal(Yes, s.eval("A"))
+	s.Equal(No, s.eval("B"))
+	s.Equal(Module, s.eval("M"))
+	s.Equal(Yes, s.eval("!B"))
+	s.Equal(No, s.eval("A && B"))
+	s.Equal(Yes, s.eval("A || B"))
+	s.Equal(Module, s.eval("A && M"))
+	s.Equal(Yes, s.eval("!(A && B)"))
+	s.Equal(Yes, s.eval("B || A && !B"))
+	s.Equal(Yes, s.eval("y"))
+	s.Equal(No, s.eval("UNDEFINED"))
+}
+
+func (s *ExprTestSuite) TestComparisons() {
+	s.Equal(Yes, s.eval(`ARCH = "x86_64"`))
+	s.Equal(No, s.eval(`ARCH != "x86_64"`))
+	s.Equal(Yes, s.eval("B = n"))
+	s.Equal(Yes, s.eval("A=y"))
+	s.Equal(Yes, s.eval("JOBS >= 4"))
+	s.Equal(No, s.eval("JOBS < 0x4"))
+}
+
+func (s *ExprTestSuite) TestMacrosHold() {
+	s.Equal(Yes, s.eval("$(cc-option,-mno-red-zone)"))
+	s.Equal(Yes, s.eval(`$(success,test "$(ARCH)" = x86) && A`))
+	s.Equal(No, s.eval("$(as-instr,nop) && B"))
+}
+
+func (s *ExprTestSuite) TestString() {
+	expr, err := ParseExpr(`(A || B) && !M && ARCH = "arm"`)
+	s.NoError(err)
+	s.Equal(`(A || B) && !M && ARCH = "arm"`, expr.String())
+	s.Equal([]string{"A", "B", "M", "ARCH"}, expr.symbols(nil))
+}
+
+func (s *ExprTestSuite) TestCombine() {
+	a, _ := ParseExpr("A")
+	b, _ := ParseExpr("B")
+
+	s.Nil(And(nil, nil))
+	s.Equal(a, And(a, nil))
+	s.Equal("A && B", And(a, b).String())
+	s.Nil(Or(a, nil))
+	s.Equal("A || B", Or(a, b).String())
+}
+
+func (s *ExprTestSuite) TestInvalid() {
+	for _, text := range []string{"", "A &&", "(A", `"open`, "A B", "&& A"} {
+		_, err := ParseExpr(text)
+		s.Error(err, text)
+	}
+}
@@ -0,0 +1,562 @@
+// Package kconfig reads Kconfig trees, such as Buildroot's Config.in files
+// and the Linux kernel's Kconfig files, so configuration symbols can be
+// checked before a build starts.
+package kconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SymbolType is the type of a Kconfig symbol
+type SymbolType string
+
+const (
+	TypeUnknown  SymbolType = ""
+	TypeBool     SymbolType = "bool"
+	TypeTristate SymbolType = "tristate"
+	TypeString   SymbolType = "string"
+	TypeInt      SymbolType = "int"
+	TypeHex      SymbolType = "hex"
+)
+
+// Default is a default value and the condition under which it applies
+type Default struct {
+	Value     Expr
+	Condition Expr
+}
+
+// Select is a symbol forced on by another, under a condition
+type Select struct {
+	Symbol    string
+	Condition Expr
+}
+
+// Symbol is a config symbol merged from all of its definitions
+type Symbol struct {
+	Name      string
+	Type      SymbolType
+	Prompt    bool // Whether users can set the symbol
+	DependsOn Expr // nil when the symbol has no dependencies
+	Defaults  []Default
+	Selects   []Select
+	Choice    *Choice // Choice group the symbol belongs to, if any
+	File      string
+	Line      int
+
+	definitions int
+}
+
+// Choice is a group of bool symbols of which exactly one is enabled
+type Choice struct {
+	Members  []string
+	Defaults []Select // Default member and its condition
+}
+
+// Tree is a parsed Kconfig tree
+type Tree struct {
+	// Prefix is written before symbol names in .config files: CONFIG_ for
+	// the kernel, nothing for Buildroot whose symbols start with BR2_
+	Prefix  string
+	Symbols map[string]*Symbol
+	Choices []*Choice
+	// Missing lists sourced files that were not found
+	Missing []string
+}
+
+// Lookup finds a symbol by the name used in .config files
+func (t *Tree) Lookup(name string) (*Symbol, bool) {
+	name, ok := strings.CutPrefix(name, t.Prefix)
+	if !ok {
+		return nil, false
+	}
+	symbol, ok := t.Symbols[name]
+	return symbol, ok
+}
+
+// Names returns the .config names of all symbols, sorted
+func (t *Tree) Names() []string {
+	names := make([]string, 0, len(t.Symbols))
+	for name := range t.Symbols {
+		names = append(names, t.Prefix+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// External is a BR2_EXTERNAL tree whose packages extend Buildroot's
+type External struct {
+	Name string // Name from external.desc, such as FORGE
+	Path string
+}
+
+// ParseBuildroot parses the Config.in tree of a Buildroot source directory
+// building into outputDir, then the Config.in of each external tree. Buildroot
+// only generates the files sourcing external trees when make runs, so they
+// are read directly.
+func ParseBuildroot(dir, outputDir string, externals ...External) (*Tree, error) {
+	env := map[string]string{"BR2_BASE_DIR": outputDir}
+	files := []string{"Config.in"}
+	for _, external := range externals {
+		env["BR2_EXTERNAL_"+external.Name+"_PATH"] = external.Path
+		files = append(files, filepath.Join(external.Path, "Config.in"))
+	}
+	return parse(dir, files, "", env)
+}
+
+// ParseKernel parses the Kconfig tree of a Linux source directory for an
+// architecture directory under arch/, such as x86 or arm64
+func ParseKernel(dir, srcarch string) (*Tree, error) {
+	return Parse(dir, "Kconfig", "CONFIG_", map[string]string{
+		"SRCARCH": srcarch,
+		"ARCH":    srcarch,
+	})
+}
+
+// Parse reads a Kconfig tree starting at file, relative to dir. Variables in
+// source statements are expanded from env, then from the environment.
+func Parse(dir, file, prefix string, env map[string]string) (*Tree, error) {
+	return parse(dir, []string{file}, prefix, env)
+}
+
+// parse reads the Kconfig files one after the other into a single tree
+func parse(dir string, files []string, prefix string, env map[string]string) (*Tree, error) {
+	p := &parser{
+		tree: &Tree{Prefix: prefix, Symbols: make(map[string]*Symbol)},
+		dir:  dir,
+		env:  env,
+		seen: make(map[string]bool),
+	}
+
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if _, err := os.Stat(file); err != nil {
+			return nil, fmt.Errorf("failed to read Kconfig tree: %v", err)
+		}
+		if err := p.parseFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return p.tree, nil
+}
+
+// block is an enclosing if, menu or choice whose dependencies apply to
+// everything inside it
+type block struct {
+	keyword   string
+	dependsOn Expr
+	choice    *Choice
+}
+
+// entry is the config, menu or choice whose attributes are being read
+type entry struct {
+	keyword   string
+	symbol    *Symbol
+	dependsOn Expr
+	prompt    bool
+	typ       SymbolType
+	defaults  []Default
+	selects   []Select
+	block     *block
+	line      int
+}
+
+type parser struct {
+	tree   *Tree
+	dir    string
+	env    map[string]string
+	seen   map[string]bool
+	blocks []*block
+	entry  *entry
+}
+
+// assignmentPattern matches preprocessor variable assignments such as
+// cc-option := ...
+var assignmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+\s*(:=|\+=|=)`)
+
+// variablePattern matches $VAR, ${VAR} and $(VAR) in source paths
+var variablePattern = regexp.MustCompile(`\$(\w+|\{\w+\}|\(\w+\))`)
+
+func (p *parser) parseFile(path string) error {
+	if p.seen[path] {
+		return nil
+	}
+	p.seen[path] = true
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	helpIndent := -1 // indentation of the help text being skipped, 0 right after help
+	inHelp := false
+	var pending string
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+
+		if inHelp {
+			if strings.TrimSpace(raw) == "" {
+				continue
+			}
+			indent := indentation(raw)
+			if helpIndent < 0 {
+				helpIndent = indent
+			}
+			if indent >= helpIndent && indent > 0 {
+				continue
+			}
+			inHelp = false
+		}
+
+		if strings.HasSuffix(raw, "\\") {
+			pending += strings.TrimSuffix(raw, "\\") + " "
+			continue
+		}
+		line := strings.TrimSpace(stripComment(pending + raw))
+		pending = ""
+		if line == "" {
+			continue
+		}
+
+		if line == "help" || line == "---help---" {
+			inHelp = true
+			helpIndent = -1
+			continue
+		}
+
+		if err := p.parseLine(path, lineNo, line); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	p.finishEntry()
+	return nil
+}
+
+func (p *parser) parseLine(path string, lineNo int, line string) error {
+	keyword, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch keyword {
+	case "config", "menuconfig":
+		p.finishEntry()
+		symbol := p.tree.Symbols[rest]
+		if symbol == nil {
+			symbol = &Symbol{Name: rest, File: p.relative(path), Line: lineNo}
+			p.tree.Symbols[rest] = symbol
+		}
+		p.entry = &entry{keyword: keyword, symbol: symbol, line: lineNo}
+		return nil
+
+	case "choice":
+		p.finishEntry()
+		choice := &Choice{}
+		p.tree.Choices = append(p.tree.Choices, choice)
+		b := &block{keyword: "choice", dependsOn: p.enclosingDeps(), choice: choice}
+		p.blocks = append(p.blocks, b)
+		p.entry = &entry{keyword: "choice", block: b, line: lineNo}
+		return nil
+
+	case "menu":
+		p.finishEntry()
+		b := &block{keyword: "menu", dependsOn: p.enclosingDeps()}
+		p.blocks = append(p.blocks, b)
+		p.entry = &entry{keyword: "menu", block: b, line: lineNo}
+		return nil
+
+	case "if":
+		p.finishEntry()
+		expr, err := ParseExpr(rest)
+		if err != nil {
+			return err
+		}
+		p.blocks = append(p.blocks, &block{keyword: "if", dependsOn: And(p.enclosingDeps(), expr)})
+		return nil
+
+	case "endchoice", "endmenu", "endif":
+		p.finishEntry()
+		want := strings.TrimPrefix(keyword, "end")
+		if len(p.blocks) == 0 || p.blocks[len(p.blocks)-1].keyword != want {
+			return fmt.Errorf("unexpected %s", keyword)
+		}
+		p.blocks = p.blocks[:len(p.blocks)-1]
+		return nil
+
+	case "source", "rsource", "osource", "orsource":
+		p.finishEntry()
+		return p.source(path, keyword, unquote(rest))
+
+	case "comment", "mainmenu":
+		p.finishEntry()
+		// Comments can carry depends on lines, which are read into a
+		// throwaway entry
+		p.entry = &entry{keyword: keyword, line: lineNo}
+		return nil
+	}
+
+	if assignmentPattern.MatchString(line) {
+		return nil
+	}
+	if p.entry == nil {
+		return nil
+	}
+	return p.parseAttribute(keyword, rest)
+}
+
+func (p *parser) parseAttribute(keyword, rest string) error {
+	e := p.entry
+	switch keyword {
+	case "bool", "tristate", "string", "int", "hex":
+		e.typ = SymbolType(keyword)
+		if prompt, _ := splitCondition(rest); prompt != "" {
+			e.prompt = true
+		}
+
+	case "def_bool", "def_tristate":
+		e.typ = SymbolType(strings.TrimPrefix(keyword, "def_"))
+		return p.addDefault(rest)
+
+	case "prompt":
+		e.prompt = true
+
+	case "default":
+		return p.addDefault(rest)
+
+	case "depends":
+		expr, err := ParseExpr(strings.TrimPrefix(rest, "on "))
+		if err != nil {
+			return err
+		}
+		e.dependsOn = And(e.dependsOn, expr)
+
+	case "visible":
+		// visible if hides the prompts of a menu without changing values
+
+	case "select":
+		name, condition := splitCondition(rest)
+		var cond Expr
+		if condition != "" {
+			var err error
+			if cond, err = ParseExpr(condition); err != nil {
+				return err
+			}
+		}
+		e.selects = append(e.selects, Select{Symbol: name, Condition: cond})
+	}
+	return nil
+}
+
+func (p *parser) addDefault(rest string) error {
+	value, condition := splitCondition(rest)
+	expr, err := ParseExpr(value)
+	if err != nil {
+		return err
+	}
+	var cond Expr
+	if condition != "" {
+		if cond, err = ParseExpr(condition); err != nil {
+			return err
+		}
+	}
+	p.entry.defaults = append(p.entry.defaults, Default{Value: expr, Condition: cond})
+	return nil
+}
+
+// finishEntry merges the attributes of the entry being read into its symbol
+// or block
+func (p *parser) finishEntry() {
+	e := p.entry
+	p.entry = nil
+	if e == nil {
+		return
+	}
+
+	if e.block != nil {
+		e.block.dependsOn = And(e.block.dependsOn, e.dependsOn)
+		if e.block.choice != nil {
+			for _, d := range e.defaults {
+				if name, ok := d.Value.(*symbolExpr); ok {
+					e.block.choice.Defaults = append(e.block.choice.Defaults, Select{Symbol: name.name, Condition: d.Condition})
+				}
+			}
+		}
+		return
+	}
+	if e.symbol == nil {
+		return
+	}
+
+	symbol := e.symbol
+	deps := And(p.enclosingDeps(), e.dependsOn)
+
+	if symbol.Type == TypeUnknown {
+		symbol.Type = e.typ
+	}
+	symbol.Prompt = symbol.Prompt || e.prompt
+
+	// A symbol defined in several places is visible if any definition is
+	switch {
+	case symbol.definitions == 0:
+		symbol.DependsOn = deps
+	case symbol.DependsOn != nil:
+		symbol.DependsOn = Or(symbol.DependsOn, deps)
+	}
+	symbol.definitions++
+
+	for _, d := range e.defaults {
+		symbol.Defaults = append(symbol.Defaults, Default{Value: d.Value, Condition: And(deps, d.Condition)})
+	}
+	for _, s := range e.selects {
+		symbol.Selects = append(symbol.Selects, Select{Symbol: s.Symbol, Condition: s.Condition})
+	}
+
+	if choice := p.enclosingChoice(); choice != nil {
+		symbol.Choice = choice
+		choice.Members = append(choice.Members, symbol.Name)
+	}
+}
+
+// enclosingDeps returns the combined dependencies of the enclosing blocks
+func (p *parser) enclosingDeps() Expr {
+	if len(p.blocks) == 0 {
+		return nil
+	}
+	return p.blocks[len(p.blocks)-1].dependsOn
+}
+
+func (p *parser) enclosingChoice() *Choice {
+	if len(p.blocks) == 0 {
+		return nil
+	}
+	return p.blocks[len(p.blocks)-1].choice
+}
+
+// source parses the files a source statement names. Paths are relative to
+// the top of the tree, or to the current file for rsource; the optional
+// forms may match nothing.
+func (p *parser) source(current, keyword, pattern string) error {
+	pattern = variablePattern.ReplaceAllStringFunc(pattern, func(ref string) string {
+		name := strings.Trim(ref[1:], "{}()")
+		if value, ok := p.env[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	})
+
+	base := p.dir
+	if strings.HasPrefix(keyword, "r") || strings.HasPrefix(keyword, "or") {
+		base = filepath.Dir(current)
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(base, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid source path %q: %v", pattern, err)
+	}
+	if len(matches) == 0 && !strings.HasPrefix(keyword, "o") {
+		p.tree.Missing = append(p.tree.Missing, p.relative(pattern))
+	}
+
+	// Blocks don't continue across files, so each file starts from the
+	// dependencies at the source statement
+	for _, match := range matches {
+		saved := p.blocks
+		p.blocks = append([]*block(nil), p.blocks...)
+		err := p.parseFile(match)
+		p.blocks = saved
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) relative(path string) string {
+	if rel, err := filepath.Rel(p.dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// splitCondition splits "value if condition" into its two parts
+func splitCondition(text string) (string, string) {
+	inQuote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case inQuote != 0:
+			if c == '\\' {
+				i++
+			} else if c == inQuote {
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			inQuote = c
+		case strings.HasPrefix(text[i:], " if "):
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+4:])
+		}
+	}
+	return strings.TrimSpace(text), ""
+}
+
+// stripComment removes a # comment outside quotes
+func stripComment(line string) string {
+	inQuote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuote != 0:
+			if c == '\\' {
+				i++
+			} else if c == inQuote {
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			inQuote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(text string) string {
+	if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') && text[len(text)-1] == text[0] {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
+
+// indentation measures leading whitespace, counting tabs as 8 columns
+func indentation(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 8 - width%8
+		default:
+			return width
+		}
+	}
+	return width
+}
@@ -0,0 +1,258 @@
+package kconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// buildrootTree is a miniature Buildroot Config.in tree
+var buildrootTree = map[string]string{
+	"Config.in": `mainmenu "Buildroot configuration"
+
+config BR2_HAVE_DOT_CONFIG
+	bool
+	default y
+
+source "arch/Config.in"
+
+menu "Toolchain"
+
+choice
+	prompt "Toolchain type"
+	default BR2_TOOLCHAIN_BUILDROOT
+
+config BR2_TOOLCHAIN_BUILDROOT
+	bool "Buildroot toolchain"
+
+config BR2_TOOLCHAIN_EXTERNAL
+	bool "External toolchain"
+
+endchoice
+
+config BR2_TOOLCHAIN_HAS_THREADS
+	bool
+
+config BR2_TOOLCHAIN_BUILDROOT_GLIBC
+	bool "glibc"
+	depends on BR2_TOOLCHAIN_BUILDROOT
+	select BR2_TOOLCHAIN_HAS_THREADS
+	help
+	  The GNU C library.
+
+	  config BR2_NOT_A_SYMBOL
+	  is part of the help text.
+
+endmenu
+
+config BR2_JLEVEL
+	int "Number of jobs to run simultaneously"
+	default 0
+
+config BR2_ROOTFS_OVERLAY
+	string "Root filesystem overlay directories" # space separated
+
+menu "Target packages"
+source "package/Config.in"
+endmenu
+
+source "$BR2_BASE_DIR/.br2-external.in"
+`,
+	"arch/Config.in": `choice
+	prompt "Target Architecture"
+	default BR2_i386
+
+config BR2_x86_64
+	bool "x86_64"
+
+config BR2_i386
+	bool "i386"
+
+config BR2_arm
+	bool "ARM (little endian)"
+
+endchoice
+
+config BR2_ARCH
+	string
+	default "x86_64" if BR2_x86_64
+	default "i686"   if BR2_i386
+	default "arm"    if BR2_arm
+
+config BR2_USE_MMU
+	bool "Enable MMU support" if BR2_arm
+	default y
+`,
+	"package/Config.in": `source "package/busybox/Config.in"
+rsource "libmodbus/Config.in"
+if BR2_USE_MMU
+source "package/nginx/Config.in"
+endif
+osource "package/*/Config.ext"
+`,
+	"package/busybox/Config.in": `config BR2_PACKAGE_BUSYBOX
+	bool "BusyBox"
+	default y
+`,
+	"package/libmodbus/Config.in": `config BR2_PACKAGE_LIBMODBUS
+	bool "libmodbus"
+	depends on BR2_TOOLCHAIN_HAS_THREADS # pthread
+`,
+	"package/nginx/Config.in": `config BR2_PACKAGE_NGINX
+	bool "nginx"
+	depends on BR2_TOOLCHAIN_HAS_THREADS
+
+if BR2_PACKAGE_NGINX
+
+config BR2_PACKAGE_NGINX_HTTP
+	bool "http server"
+	default y
+
+endif
+`,
+	"package/nginx/Config.ext": `config BR2_PACKAGE_NGINX_EXTRA
+	tristate "extra modules"
+	depends on \
+		BR2_PACKAGE_NGINX
+`,
+}
+
+// kernelTree is a miniature kernel Kconfig tree
+var kernelTree = map[string]string{
+	"Kconfig": `mainmenu "Linux Kernel Configuration"
+
+source "scripts/Kconfig.include"
+
+config NET
+	bool "Networking support"
+
+source "arch/$(SRCARCH)/Kconfig"
+`,
+	"scripts/Kconfig.include": `cc-option = $(success,true)
+`,
+	"arch/x86/Kconfig": `config X86_64
+	def_bool y
+
+config CC_HAS_RED_ZONE
+	def_bool $(cc-option,-mno-red-zone)
+
+config NR_CPUS
+	int "Maximum number of CPUs" if X86_64
+	default 64
+`,
+}
+
+type KconfigTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestKconfigTestSuite(t *testing.T) {
+	suite.Run(t, new(KconfigTestSuite))
+}
+
+func (s *KconfigTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *KconfigTestSuite) writeTree(name string, files map[string]string) string {
+	dir := filepath.Join(s.tempDir, name)
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		s.Require().NoError(os.MkdirAll(filepath.Dir(full), 0755))
+		s.Require().NoError(os.WriteFile(full, []byte(content), 0644))
+	}
+	return dir
+}
+
+func (s *KconfigTestSuite) TestParseBuildroot() {
+	dir := s.writeTree("buildroot", buildrootTree)
+	tree, err := ParseBuildroot(dir, filepath.Join(dir, "output"))
+	s.Require().NoError(err)
+
+	s.Equal("", tree.Prefix)
+	s.Len(tree.Symbols, 17)
+	s.NotContains(tree.Symbols, "BR2_NOT_A_SYMBOL")
+
+	symbol, ok := tree.Lookup("BR2_PACKAGE_LIBMODBUS")
+	s.True(ok)
+	s.Equal(TypeBool, symbol.Type)
+	s.True(symbol.Prompt)
+	s.Equal("BR2_TOOLCHAIN_HAS_THREADS", symbol.DependsOn.String())
+	s.Equal("package/libmodbus/Config.in", symbol.File)
+	s.Equal(1, symbol.Line)
+
+	// Dependencies of enclosing if blocks are included
+	symbol, _ = tree.Lookup("BR2_PACKAGE_NGINX_HTTP")
+	s.Equal("BR2_USE_MMU && BR2_PACKAGE_NGINX", symbol.DependsOn.String())
+
+	// Continuation lines and optional sources with globs
+	symbol, ok = tree.Lookup("BR2_PACKAGE_NGINX_EXTRA")
+	s.True(ok)
+	s.Equal(TypeTristate, symbol.Type)
+	s.Equal("BR2_PACKAGE_NGINX", symbol.DependsOn.String())
+
+	symbol, _ = tree.Lookup("BR2_ARCH")
+	s.Equal(TypeString, symbol.Type)
+	s.False(symbol.Prompt)
+	s.Len(symbol.Defaults, 3)
+
+	symbol, _ = tree.Lookup("BR2_ROOTFS_OVERLAY")
+	s.True(symbol.Prompt)
+
+	s.Len(tree.Choices, 2)
+	s.Equal([]string{"BR2_x86_64", "BR2_i386", "BR2_arm"}, tree.Choices[0].Members)
+	s.Equal("BR2_i386", tree.Choices[0].Defaults[0].Symbol)
+
+	s.Equal([]string{"output/.br2-external.in"}, tree.Missing)
+}
+
+func (s *KconfigTestSuite) TestParseBuildrootExternal() {
+	dir := s.writeTree("buildroot", buildrootTree)
+	external := s.writeTree("external", map[string]string{
+		"Config.in":                "source \"$BR2_EXTERNAL_FORGE_PATH/package/blinkd/Config.in\"\n",
+		"package/blinkd/Config.in": "config BR2_PACKAGE_BLINKD\n\tbool \"blinkd\"\n",
+	})
+
+	tree, err := ParseBuildroot(dir, filepath.Join(dir, "output"), External{Name: "FORGE", Path: external})
+	s.Require().NoError(err)
+	symbol, ok := tree.Lookup("BR2_PACKAGE_BLINKD")
+	s.True(ok)
+	s.Equal(TypeBool, symbol.Type)
+	s.Len(tree.Symbols, 18)
+
+	_, err = ParseBuildroot(dir, filepath.Join(dir, "output"), External{Name: "FORGE", Path: filepath.Join(s.tempDir, "missing")})
+	s.Error(err)
+}
+
+func (s *KconfigTestSuite) TestParseKernel() {
+	tree, err := ParseKernel(s.writeTree("linux", kernelTree), "x86")
+	s.Require().NoError(err)
+
+	s.Equal("CONFIG_", tree.Prefix)
+	s.Equal([]string{"CONFIG_CC_HAS_RED_ZONE", "CONFIG_NET", "CONFIG_NR_CPUS", "CONFIG_X86_64"}, tree.Names())
+
+	symbol, ok := tree.Lookup("CONFIG_NR_CPUS")
+	s.True(ok)
+	s.Equal(TypeInt, symbol.Type)
+	s.True(symbol.Prompt)
+	s.Equal("arch/x86/Kconfig", symbol.File)
+
+	_, ok = tree.Lookup("NET")
+	s.False(ok)
+}
+
+func (s *KconfigTestSuite) TestParseErrors() {
+	_, err := ParseBuildroot(filepath.Join(s.tempDir, "missing"), filepath.Join(s.tempDir, "missing", "output"))
+	s.Error(err)
+	s.Contains(err.Error(), "failed to read Kconfig tree")
+
+	dir := s.writeTree("broken", map[string]string{
+		"Config.in": "menu \"Broken\"\nendif\n",
+	})
+	_, err = ParseBuildroot(dir, filepath.Join(dir, "output"))
+	s.Error(err)
+	s.Contains(err.Error(), "Config.in:2: unexpected endif")
+}
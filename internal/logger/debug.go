@@ -8,6 +8,8 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // DebugCollector collects debugging information
@@ -151,36 +153,19 @@ func (d *DebugCollector) ViewLogs(logPath, levelFilter, componentFilter string)
 	return strings.Join(filteredLines, "\n"), nil
 }
 
-// ValidateConfig validates configuration content
+// ValidateConfig checks that configuration content is a well-formed YAML
+// mapping. Schema checks are done by config.Validator.
 func (d *DebugCollector) ValidateConfig(configContent string) error {
-	// Basic validation - check for basic structure
 	if strings.TrimSpace(configContent) == "" {
 		return fmt.Errorf("empty configuration")
 	}
 
-	// Check for basic YAML structure
-	if !strings.Contains(configContent, ":") {
-		return fmt.Errorf("invalid configuration format: missing key-value pairs")
+	var content interface{}
+	if err := yaml.Unmarshal([]byte(configContent), &content); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
 	}
-
-	// Try to parse as basic key-value format
-	lines := strings.Split(configContent, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Check for unclosed brackets/braces/quotes
-		if strings.Contains(line, "[") && !strings.Contains(line, "]") {
-			return fmt.Errorf("invalid configuration: unclosed bracket")
-		}
-		if strings.Contains(line, "{") && !strings.Contains(line, "}") {
-			return fmt.Errorf("invalid configuration: unclosed brace")
-		}
-		if strings.Count(line, "\"")%2 != 0 {
-			return fmt.Errorf("invalid configuration: unclosed quote")
-		}
+	if _, ok := content.(map[string]interface{}); !ok {
+		return fmt.Errorf("invalid configuration format: missing key-value pairs")
 	}
 
 	return nil
@@ -257,7 +257,7 @@ func (l *Logger) log(level LogLevel, message string, args ...interface{}) {
 		writer = l.out
 	}
 
-	fmt.Fprint(writer, output)
+	fmt.Fprint(writer, Redact(output))
 }
 
 // formatText formats a log entry as text
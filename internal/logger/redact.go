@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces sensitive values in log output
+const Redacted = "[REDACTED]"
+
+var (
+	sensitiveMu     sync.RWMutex
+	sensitiveValues []string
+)
+
+// AddSensitiveValue registers a value, such as a resolved secret, that must
+// never appear in log output
+func AddSensitiveValue(value string) {
+	if value == "" {
+		return
+	}
+
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+
+	for _, existing := range sensitiveValues {
+		if existing == value {
+			return
+		}
+	}
+	sensitiveValues = append(sensitiveValues, value)
+
+	// Replace longer values first so one secret containing another is fully hidden
+	sort.Slice(sensitiveValues, func(i, j int) bool {
+		return len(sensitiveValues[i]) > len(sensitiveValues[j])
+	})
+}
+
+// Redact replaces every registered sensitive value in s
+func Redact(s string) string {
+	sensitiveMu.RLock()
+	defer sensitiveMu.RUnlock()
+
+	for _, value := range sensitiveValues {
+		s = strings.ReplaceAll(s, value, Redacted)
+	}
+	return s
+}
+
+// resetSensitiveValues forgets all registered values
+func resetSensitiveValues() {
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	sensitiveValues = nil
+}
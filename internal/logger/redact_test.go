@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RedactTestSuite struct {
+	suite.Suite
+}
+
+func TestRedactTestSuite(t *testing.T) {
+	suite.Run(t, new(RedactTestSuite))
+}
+
+func (s *RedactTestSuite) TearDownTest() {
+	resetSensitiveValues()
+}
+
+func (s *RedactTestSuite) TestRedact() {
+	AddSensitiveValue("hunter2")
+	AddSensitiveValue("hunter2-extended")
+	AddSensitiveValue("")
+
+	s.Equal("password=[REDACTED]", Redact("password=hunter2"))
+	s.Equal("token [REDACTED] end", Redact("token hunter2-extended end"))
+	s.Equal("nothing to hide", Redact("nothing to hide"))
+}
+
+func (s *RedactTestSuite) TestLogOutputIsRedacted() {
+	var buf bytes.Buffer
+	logger := NewLogger(DEBUG, &buf, &buf)
+
+	AddSensitiveValue("s3cret")
+	logger.WithField("password", "s3cret").Info("connecting with %s", "s3cret")
+
+	s.NotContains(buf.String(), "s3cret")
+	s.Contains(buf.String(), "password=[REDACTED]")
+	s.Contains(buf.String(), "connecting with [REDACTED]")
+
+	buf.Reset()
+	logger.SetFormat(JSON)
+	logger.Error("failed: s3cret")
+	s.NotContains(buf.String(), "s3cret")
+}
@@ -0,0 +1,68 @@
+package packages
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Addition is the effect of adding packages to forge.yml
+type Addition struct {
+	Packages    []string // Packages new to the image, dependencies first
+	Size        int64    // Estimated size of the new packages in bytes
+	UnknownSize []string // New packages whose size is not known
+	Problems    []string // Conflicts and unmet dependencies the packages introduce
+}
+
+// PlanPackageAddition works out which packages adding some to forge.yml
+// brings into the image, how much space they take and which conflicts or
+// unmet dependencies they introduce
+func (pm *PackageManager) PlanPackageAddition(names []string) (*Addition, error) {
+	for _, name := range names {
+		if !pm.IsValidPackage(name) {
+			return nil, fmt.Errorf("package %s not found", name)
+		}
+	}
+
+	var current []string
+	if pm.config != nil {
+		roots, err := pm.ImageRoots()
+		if err != nil {
+			return nil, err
+		}
+		for _, root := range roots {
+			current = append(current, root.Package)
+		}
+	}
+
+	before := pm.resolve(current)
+	after := pm.resolve(append(append([]string(nil), current...), names...))
+
+	addition := &Addition{}
+	order, cycle := after.installOrder()
+	if len(cycle) > 0 {
+		addition.Problems = append(addition.Problems, "circular dependency: "+strings.Join(cycle, " -> "))
+		order = after.order
+	}
+
+	for _, name := range order {
+		if contains(before.order, name) {
+			continue
+		}
+		addition.Packages = append(addition.Packages, name)
+		if size := pm.packages[name].Size; size > 0 {
+			addition.Size += size
+		} else {
+			addition.UnknownSize = append(addition.UnknownSize, name)
+		}
+	}
+
+	// Problems the image already has are not the new packages' doing
+	existing := append(before.unsatisfiable, before.conflicts()...)
+	for _, problem := range append(after.unsatisfiable, after.conflicts()...) {
+		if !contains(existing, problem) {
+			addition.Problems = append(addition.Problems, problem)
+		}
+	}
+
+	return addition, nil
+}
@@ -0,0 +1,72 @@
+package packages
+
+import (
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type AddTestSuite struct {
+	suite.Suite
+	manager *PackageManager
+}
+
+func TestAddTestSuite(t *testing.T) {
+	suite.Run(t, new(AddTestSuite))
+}
+
+func (s *AddTestSuite) SetupTest() {
+	s.manager = NewPackageManager(&config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"openssh"},
+	})
+}
+
+func (s *AddTestSuite) TestNewPackages() {
+	// openssh already brings openssl and zlib
+	addition, err := s.manager.PlanPackageAddition([]string{"nginx"})
+	s.Require().NoError(err)
+	s.Equal([]string{"pcre", "nginx"}, addition.Packages)
+	s.Empty(addition.Problems)
+
+	addition, err = s.manager.PlanPackageAddition([]string{"openssl"})
+	s.Require().NoError(err)
+	s.Empty(addition.Packages)
+}
+
+func (s *AddTestSuite) TestSize() {
+	s.manager.AddDefinitions(SourceProject, []*PackageInfo{
+		{Name: "acme-agent", Size: 2097152, Dependencies: []string{"acme-lib", "zlib"}},
+		{Name: "acme-lib", Size: 524288},
+		{Name: "acme-tools"},
+	})
+
+	addition, err := s.manager.PlanPackageAddition([]string{"acme-agent", "acme-tools"})
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"acme-agent", "acme-lib", "acme-tools"}, addition.Packages)
+	s.Equal(int64(2621440), addition.Size)
+	s.Equal([]string{"acme-tools"}, addition.UnknownSize)
+}
+
+func (s *AddTestSuite) TestProblems() {
+	s.manager.AddDefinitions(SourceProject, []*PackageInfo{
+		{Name: "tiny-ssh", Conflicts: []string{"ssh-server"}},
+		{Name: "legacy-agent", Dependencies: []string{"openssl<3.0"}},
+	})
+
+	addition, err := s.manager.PlanPackageAddition([]string{"tiny-ssh", "legacy-agent"})
+	s.Require().NoError(err)
+	s.Equal([]string{
+		"legacy-agent requires openssl<3.0, but openssl is version 3.1.4 (legacy-agent was requested)",
+		"tiny-ssh conflicts with openssh (tiny-ssh was requested; openssh was requested)",
+	}, addition.Problems)
+
+	_, err = s.manager.PlanPackageAddition([]string{"nonexistent"})
+	s.Error(err)
+	s.Contains(err.Error(), "package nonexistent not found")
+}
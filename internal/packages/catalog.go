@@ -0,0 +1,407 @@
+package packages
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// catalogVersion is bumped whenever the cached catalog format changes
+const catalogVersion = 1
+
+// Catalog is the index of the packages in a Buildroot source tree
+type Catalog struct {
+	Version     int            `json:"version"`
+	Fingerprint string         `json:"fingerprint"`
+	Packages    []*PackageInfo `json:"packages"`
+}
+
+var (
+	configPattern  = regexp.MustCompile(`^(?:menu)?config\s+(BR2_PACKAGE_\w+)\s*$`)
+	symbolPattern  = regexp.MustCompile(`^BR2_PACKAGE_\w+$`)
+	variablePrefix = strings.NewReplacer("-", "_", ".", "_")
+	buildrootMake  = regexp.MustCompile(`(?m)^export BR2_VERSION\s*:=\s*(\S+)`)
+)
+
+// LoadCatalog returns the package catalog of a Buildroot source tree. The
+// index is cached at cachePath and rebuilt when the package tree changes.
+func LoadCatalog(buildrootDir, cachePath string) (*Catalog, error) {
+	fingerprint, err := catalogFingerprint(buildrootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cached Catalog
+		if json.Unmarshal(data, &cached) == nil && cached.Version == catalogVersion && cached.Fingerprint == fingerprint {
+			return &cached, nil
+		}
+	}
+
+	catalog, err := IndexBuildroot(buildrootDir)
+	if err != nil {
+		return nil, err
+	}
+	catalog.Fingerprint = fingerprint
+
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode package catalog: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write package catalog: %v", err)
+	}
+
+	return catalog, nil
+}
+
+// catalogFingerprint identifies the state of a Buildroot package tree: its
+// version and when a package was last added or removed
+func catalogFingerprint(buildrootDir string) (string, error) {
+	info, err := os.Stat(filepath.Join(buildrootDir, "package"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Buildroot package tree: %v", err)
+	}
+
+	version := "unknown"
+	if data, err := os.ReadFile(filepath.Join(buildrootDir, "Makefile")); err == nil {
+		if match := buildrootMake.FindSubmatch(data); match != nil {
+			version = string(match[1])
+		}
+	}
+
+	return fmt.Sprintf("%s %s %d", filepath.Clean(buildrootDir), version, info.ModTime().UnixNano()), nil
+}
+
+// IndexBuildroot reads every package/*/Config.in and .mk file of a
+// Buildroot source tree. Categories come from the menus of
+// package/Config.in that source each package.
+func IndexBuildroot(buildrootDir string) (*Catalog, error) {
+	packageDir := filepath.Join(buildrootDir, "package")
+	menus, err := parsePackageMenus(filepath.Join(packageDir, "Config.in"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Buildroot package tree: %v", err)
+	}
+
+	entries, err := os.ReadDir(packageDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Buildroot package tree: %v", err)
+	}
+
+	// Map every package symbol to its directory first, so dependencies on
+	// sub-options and toolchain symbols can be told apart from packages
+	symbols := make(map[string]string)
+	parsed := make(map[string]*configEntry)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		config, err := parsePackageConfig(filepath.Join(packageDir, name, "Config.in"), name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Host-only packages and infrastructure directories
+				continue
+			}
+			return nil, fmt.Errorf("failed to read package %s: %v", name, err)
+		}
+		symbols[config.symbol] = name
+		parsed[name] = config
+	}
+
+	catalog := &Catalog{Version: catalogVersion}
+	for name, config := range parsed {
+		pkg := &PackageInfo{
+			Name:         name,
+			Description:  config.description,
+			Category:     "other",
+			BuildrootPkg: config.symbol,
+		}
+		if menu, ok := menus[name]; ok {
+			pkg.Category = menu.category
+			config.dependsOn = append(config.dependsOn, menu.dependsOn...)
+		}
+
+		seen := make(map[string]bool)
+		for _, symbol := range append(config.dependsOn, config.selects...) {
+			dep, ok := symbols[symbol]
+			if ok && dep != name && !seen[dep] {
+				seen[dep] = true
+				pkg.Dependencies = append(pkg.Dependencies, dep)
+			}
+		}
+
+		pkg.Version, pkg.License = parsePackageMakefile(filepath.Join(packageDir, name, name+".mk"), name)
+		catalog.Packages = append(catalog.Packages, pkg)
+	}
+
+	sort.Slice(catalog.Packages, func(i, j int) bool {
+		return catalog.Packages[i].Name < catalog.Packages[j].Name
+	})
+	return catalog, nil
+}
+
+// configEntry is the main config entry of a package's Config.in
+type configEntry struct {
+	symbol      string
+	description string
+	dependsOn   []string // Package symbols that must be enabled
+	selects     []string // Package symbols enabled unconditionally
+}
+
+// parsePackageConfig reads the entry of a package's Config.in that enables
+// the package. Sub-options are skipped, as are conditional selects and
+// dependencies that are alternatives.
+func parsePackageConfig(path, name string) (*configEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	want := "BR2_PACKAGE_" + strings.ToUpper(variablePrefix.Replace(name))
+	var entries []*configEntry
+	var current *configEntry
+	var prompt string
+	var help []string
+	helpIndent := -1
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if helpIndent >= 0 {
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			if trimmed == "" || indent > helpIndent {
+				if current != nil {
+					help = append(help, trimmed)
+				}
+				continue
+			}
+			helpIndent = -1
+			if current != nil && current.description == "" {
+				current.description = firstParagraph(help)
+			}
+		}
+
+		if match := configPattern.FindStringSubmatch(trimmed); match != nil {
+			if current != nil && current.description == "" {
+				current.description = prompt
+			}
+			current = &configEntry{symbol: match[1]}
+			entries = append(entries, current)
+			prompt, help = "", nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		keyword, rest, _ := strings.Cut(trimmed, " ")
+		rest = strings.TrimSpace(rest)
+		switch keyword {
+		case "bool", "tristate", "prompt":
+			prompt = strings.Trim(rest, `"`)
+		case "depends":
+			if condition, ok := strings.CutPrefix(rest, "on "); ok {
+				current.dependsOn = append(current.dependsOn, requiredSymbols(condition)...)
+			}
+		case "select":
+			rest, _, _ = strings.Cut(rest, "#")
+			if symbol := strings.TrimSpace(rest); symbolPattern.MatchString(symbol) {
+				current.selects = append(current.selects, symbol)
+			}
+		case "help", "---help---":
+			helpIndent = len(line) - len(strings.TrimLeft(line, " \t"))
+		case "config", "menuconfig", "choice", "endchoice", "if", "endif", "menu", "endmenu", "comment", "source":
+			// Anything after the entry belongs to another one
+			if current.description == "" {
+				current.description = prompt
+			}
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil && current.description == "" {
+		if helpIndent >= 0 {
+			current.description = firstParagraph(help)
+		}
+		if current.description == "" {
+			current.description = prompt
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no config entry in %s", path)
+	}
+	for _, entry := range entries {
+		if entry.symbol == want {
+			return entry, nil
+		}
+	}
+	return entries[0], nil
+}
+
+// requiredSymbols returns the package symbols a depends on condition
+// requires. Negated symbols and alternatives joined by || are not
+// requirements.
+func requiredSymbols(condition string) []string {
+	condition, _, _ = strings.Cut(condition, "#")
+	if strings.Contains(condition, "||") {
+		return nil
+	}
+
+	var symbols []string
+	for _, part := range strings.Split(condition, "&&") {
+		part = strings.Trim(strings.TrimSpace(part), "()")
+		if symbolPattern.MatchString(part) {
+			symbols = append(symbols, part)
+		}
+	}
+	return symbols
+}
+
+// firstParagraph joins the first paragraph of help text into one line,
+// without its final period
+func firstParagraph(lines []string) string {
+	var words []string
+	for _, line := range lines {
+		if line == "" {
+			if len(words) > 0 {
+				break
+			}
+			continue
+		}
+		words = append(words, line)
+	}
+	return strings.TrimSuffix(strings.Join(words, " "), ".")
+}
+
+// packageMenu is where package/Config.in sources a package from
+type packageMenu struct {
+	category  string
+	dependsOn []string // Package symbols of enclosing if blocks
+}
+
+// parsePackageMenus maps each package sourced by package/Config.in to the
+// menu it appears in
+func parsePackageMenus(path string) (map[string]packageMenu, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	menus := make(map[string]packageMenu)
+	var titles []string
+	var conditions [][]string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		keyword, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		switch keyword {
+		case "menu":
+			titles = append(titles, strings.Trim(rest, `"`))
+		case "endmenu":
+			if len(titles) > 0 {
+				titles = titles[:len(titles)-1]
+			}
+		case "if":
+			conditions = append(conditions, requiredSymbols(rest))
+		case "endif":
+			if len(conditions) > 0 {
+				conditions = conditions[:len(conditions)-1]
+			}
+		case "source":
+			source := strings.Trim(rest, `"`)
+			parts := strings.Split(source, "/")
+			if len(parts) != 3 || parts[0] != "package" || parts[2] != "Config.in" {
+				continue
+			}
+			menu := packageMenu{category: "other"}
+			if len(titles) > 0 {
+				menu.category = categorySlug(titles[len(titles)-1])
+			}
+			for _, symbols := range conditions {
+				menu.dependsOn = append(menu.dependsOn, symbols...)
+			}
+			menus[parts[1]] = menu
+		}
+	}
+	return menus, scanner.Err()
+}
+
+// categorySlug turns a menu title such as "Networking applications" into a
+// category name such as networking-applications
+func categorySlug(title string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// parsePackageMakefile reads the version and license of a package from its
+// .mk file. Values that reference other variables are left out.
+func parsePackageMakefile(path, name string) (version, license string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", ""
+	}
+
+	prefix := strings.ToUpper(variablePrefix.Replace(name)) + "_"
+	for _, line := range strings.Split(string(data), "\n") {
+		variable, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		variable = strings.TrimSpace(strings.TrimRight(variable, ":?+"))
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "$(") {
+			continue
+		}
+		switch variable {
+		case prefix + "VERSION":
+			if version == "" {
+				version = value
+			}
+		case prefix + "LICENSE":
+			if license == "" {
+				license = value
+			}
+		}
+	}
+	return version, license
+}
+
+// AddCatalog adds the packages of a catalog to the package database.
+// Catalog entries replace built-in entries of the same name.
+func (pm *PackageManager) AddCatalog(catalog *Catalog) {
+	for _, pkg := range catalog.Packages {
+		pkg.Source = SourceBuildroot
+		pm.addPackage(pkg)
+	}
+}
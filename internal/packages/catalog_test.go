@@ -0,0 +1,250 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// buildrootPackages is a small Buildroot package tree
+var buildrootPackages = map[string]string{
+	"Makefile": "export BR2_VERSION := 2024.02.1\n",
+	"package/Config.in": `menu "Target packages"
+
+menu "Libraries"
+
+menu "Networking"
+	source "package/libnl/Config.in"
+endmenu
+
+menu "Crypto"
+	source "package/openssl/Config.in"
+endmenu
+
+endmenu
+
+menu "Networking applications"
+	source "package/dnsmasq/Config.in"
+	source "package/hostapd/Config.in"
+endmenu
+
+menu "Interpreter languages and scripting"
+	source "package/python3/Config.in"
+if BR2_PACKAGE_PYTHON3
+menu "External python modules"
+	source "package/python-serial/Config.in"
+endmenu
+endif
+endmenu
+
+endmenu
+`,
+	"package/libnl/Config.in": `config BR2_PACKAGE_LIBNL
+	bool "libnl"
+	depends on BR2_TOOLCHAIN_HAS_THREADS
+	help
+	  A library for applications dealing with netlink socket.
+
+	  http://www.infradead.org/~tgr/libnl/
+
+comment "libnl needs a toolchain w/ threads"
+	depends on !BR2_TOOLCHAIN_HAS_THREADS
+`,
+	"package/libnl/libnl.mk": `LIBNL_VERSION = 3.8.0
+LIBNL_SITE = https://github.com/thom311/libnl/releases/download/libnl$(subst .,_,$(LIBNL_VERSION))
+LIBNL_LICENSE = LGPL-2.1+
+`,
+	"package/openssl/Config.in": `config BR2_PACKAGE_OPENSSL
+	bool "openssl support"
+	select BR2_PACKAGE_HAS_OPENSSL
+	help
+	  Select the desired ssl library provider.
+`,
+	"package/openssl/openssl.mk": "OPENSSL_VERSION = 3.2.1\n",
+	"package/dnsmasq/Config.in": `config BR2_PACKAGE_DNSMASQ
+	bool "dnsmasq"
+	depends on BR2_USE_MMU # fork()
+	help
+	  A lightweight DNS and DHCP server. It is intended to provide
+	  coupled DNS and DHCP service to a LAN.
+
+	  http://www.thekelleys.org.uk/dnsmasq/
+
+if BR2_PACKAGE_DNSMASQ
+
+config BR2_PACKAGE_DNSMASQ_TFTP
+	bool "tftp support"
+	default y
+	help
+	  Enable TFTP support in dnsmasq.
+
+endif
+`,
+	"package/dnsmasq/dnsmasq.mk": `DNSMASQ_VERSION = 2.90
+DNSMASQ_LICENSE = GPL-2.0 or GPL-3.0
+`,
+	"package/hostapd/Config.in": `config BR2_PACKAGE_HOSTAPD
+	bool "hostapd"
+	depends on BR2_USE_MMU # fork()
+	select BR2_PACKAGE_LIBNL
+	select BR2_PACKAGE_OPENSSL if BR2_PACKAGE_HOSTAPD_EAP
+	help
+	  User space daemon for wireless access points.
+
+if BR2_PACKAGE_HOSTAPD
+
+config BR2_PACKAGE_HOSTAPD_EAP
+	bool "Enable EAP"
+	select BR2_PACKAGE_OPENSSL
+	depends on !BR2_STATIC_LIBS
+
+endif
+`,
+	"package/hostapd/hostapd.mk": `HOSTAPD_VERSION = 2.10
+HOSTAPD_LICENSE = BSD-3-Clause
+`,
+	"package/python3/Config.in": `config BR2_PACKAGE_PYTHON3
+	bool "python3"
+	depends on BR2_USE_WCHAR
+	depends on !BR2_PACKAGE_PYTHON || BR2_PACKAGE_PYTHON_FORCE
+	help
+	  The python language interpreter.
+`,
+	"package/python-serial/Config.in": `config BR2_PACKAGE_PYTHON_SERIAL
+	bool "python-serial"
+	help
+	  pySerial encapsulates the access for the serial port.
+`,
+	"package/python-serial/python-serial.mk": `PYTHON_SERIAL_VERSION = 3.5
+PYTHON_SERIAL_LICENSE = BSD-3-Clause
+`,
+	"package/lzip/Config.in.host": `config BR2_PACKAGE_HOST_LZIP
+	bool "host lzip"
+`,
+}
+
+type CatalogTestSuite struct {
+	suite.Suite
+	buildrootDir string
+}
+
+func TestCatalogTestSuite(t *testing.T) {
+	suite.Run(t, new(CatalogTestSuite))
+}
+
+func (s *CatalogTestSuite) SetupTest() {
+	s.buildrootDir = filepath.Join(s.T().TempDir(), "buildroot")
+	for path, content := range buildrootPackages {
+		full := filepath.Join(s.buildrootDir, path)
+		s.Require().NoError(os.MkdirAll(filepath.Dir(full), 0755))
+		s.Require().NoError(os.WriteFile(full, []byte(content), 0644))
+	}
+}
+
+func (s *CatalogTestSuite) packages(catalog *Catalog) map[string]*PackageInfo {
+	packages := make(map[string]*PackageInfo)
+	for _, pkg := range catalog.Packages {
+		packages[pkg.Name] = pkg
+	}
+	return packages
+}
+
+func (s *CatalogTestSuite) TestIndexBuildroot() {
+	catalog, err := IndexBuildroot(s.buildrootDir)
+	s.Require().NoError(err)
+
+	var names []string
+	for _, pkg := range catalog.Packages {
+		names = append(names, pkg.Name)
+	}
+	s.Equal([]string{"dnsmasq", "hostapd", "libnl", "openssl", "python-serial", "python3"}, names)
+
+	packages := s.packages(catalog)
+	s.Equal(&PackageInfo{
+		Name:         "hostapd",
+		Version:      "2.10",
+		Description:  "User space daemon for wireless access points",
+		Dependencies: []string{"libnl"},
+		Category:     "networking-applications",
+		License:      "BSD-3-Clause",
+		BuildrootPkg: "BR2_PACKAGE_HOSTAPD",
+	}, packages["hostapd"])
+
+	dnsmasq := packages["dnsmasq"]
+	s.Equal("A lightweight DNS and DHCP server. It is intended to provide coupled DNS and DHCP service to a LAN", dnsmasq.Description)
+	s.Equal("GPL-2.0 or GPL-3.0", dnsmasq.License)
+	s.Empty(dnsmasq.Dependencies)
+
+	s.Equal("3.8.0", packages["libnl"].Version)
+	s.Equal("networking", packages["libnl"].Category)
+	s.Equal("crypto", packages["openssl"].Category)
+
+	// Enclosing if blocks in package/Config.in are dependencies too
+	s.Equal([]string{"python3"}, packages["python-serial"].Dependencies)
+	s.Equal("external-python-modules", packages["python-serial"].Category)
+
+	// Alternatives are not dependencies
+	s.Empty(packages["python3"].Dependencies)
+	s.Empty(packages["python3"].License)
+}
+
+func (s *CatalogTestSuite) TestIndexBuildrootMissingTree() {
+	_, err := IndexBuildroot(filepath.Join(s.T().TempDir(), "missing"))
+	s.Error(err)
+	s.Contains(err.Error(), "failed to read Buildroot package tree")
+}
+
+func (s *CatalogTestSuite) TestLoadCatalogUsesCache() {
+	cachePath := filepath.Join(s.T().TempDir(), "cache", "packages.json")
+
+	catalog, err := LoadCatalog(s.buildrootDir, cachePath)
+	s.Require().NoError(err)
+	s.Len(catalog.Packages, 6)
+	s.FileExists(cachePath)
+
+	// Changing a package without touching the package directory keeps the
+	// cached index
+	hostapd := filepath.Join(s.buildrootDir, "package", "hostapd", "hostapd.mk")
+	s.Require().NoError(os.WriteFile(hostapd, []byte("HOSTAPD_VERSION = 2.11\n"), 0644))
+	catalog, err = LoadCatalog(s.buildrootDir, cachePath)
+	s.Require().NoError(err)
+	s.Equal("2.10", s.packages(catalog)["hostapd"].Version)
+
+	// Adding a package rebuilds it
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.buildrootDir, "package", "iw"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.buildrootDir, "package", "iw", "Config.in"),
+		[]byte("config BR2_PACKAGE_IW\n\tbool \"iw\"\n"), 0644))
+	later := time.Now().Add(time.Second)
+	s.Require().NoError(os.Chtimes(filepath.Join(s.buildrootDir, "package"), later, later))
+
+	catalog, err = LoadCatalog(s.buildrootDir, cachePath)
+	s.Require().NoError(err)
+	s.Len(catalog.Packages, 7)
+	s.Equal("2.11", s.packages(catalog)["hostapd"].Version)
+	s.Equal("iw", s.packages(catalog)["iw"].Description)
+}
+
+func (s *CatalogTestSuite) TestAddCatalog() {
+	catalog, err := IndexBuildroot(s.buildrootDir)
+	s.Require().NoError(err)
+
+	pm := NewPackageManager(nil)
+	s.False(pm.IsValidPackage("hostapd"))
+	pm.AddCatalog(catalog)
+	s.True(pm.IsValidPackage("hostapd"))
+
+	// Catalog entries replace built-in ones, including their category
+	openssl, err := pm.GetPackageInfo("openssl")
+	s.Require().NoError(err)
+	s.Equal("3.2.1", openssl.Version)
+	s.Contains(pm.GetCategories(), "crypto")
+	for _, pkg := range pm.ListPackagesByCategory("security") {
+		s.NotEqual("openssl", pkg.Name)
+	}
+
+	// Built-in packages that are not in the tree are kept
+	s.True(pm.IsValidPackage("fail2ban"))
+}
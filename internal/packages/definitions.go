@@ -0,0 +1,150 @@
+package packages
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sst/forge/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// PackagesDirEnv overrides the directory of user-level package definitions
+const PackagesDirEnv = "FORGE_PACKAGES_DIR"
+
+// Package sources, from lowest to highest precedence: the built-in
+// catalog, the Buildroot package tree, the user-level definitions and the
+// project's definitions. A definition replaces any package of the same name
+// from a lower source.
+const (
+	SourceBuiltin   = "built-in"
+	SourceBuildroot = "buildroot"
+	SourceUser      = "user"
+	SourceProject   = "project"
+)
+
+// definitionsFile is the format of a package definitions file
+type definitionsFile struct {
+	Packages []*PackageInfo `yaml:"packages"`
+}
+
+// UserPackagesDir returns the directory of user-level package definitions:
+// $FORGE_PACKAGES_DIR, else forge/packages in the user config directory
+func UserPackagesDir() string {
+	if dir := os.Getenv(PackagesDirEnv); dir != "" {
+		return dir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "forge", "packages")
+}
+
+// LoadDefinitions reads the package definitions in the *.yml and *.yaml
+// files of a directory. A missing directory has no definitions. Each
+// package may only be defined once per directory. Dependencies are written
+// as ParseRequirement expects.
+func LoadDefinitions(dir string) ([]*PackageInfo, error) {
+	var files []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var definitions []*PackageInfo
+	definedIn := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read package definitions: %v", err)
+		}
+
+		var parsed definitionsFile
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&parsed); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("invalid package definitions in %s: %v", file, err)
+		}
+
+		for i, pkg := range parsed.Packages {
+			if pkg == nil || pkg.Name == "" {
+				return nil, fmt.Errorf("invalid package definitions in %s: package %d has no name", file, i+1)
+			}
+			if previous, ok := definedIn[pkg.Name]; ok {
+				if previous == file {
+					return nil, fmt.Errorf("package %s is defined twice in %s", pkg.Name, file)
+				}
+				return nil, fmt.Errorf("package %s is defined in both %s and %s", pkg.Name, previous, file)
+			}
+			definedIn[pkg.Name] = file
+
+			if pkg.Category == "" {
+				pkg.Category = "custom"
+			}
+			if pkg.BuildrootPkg == "" {
+				pkg.BuildrootPkg = config.PackageSymbol(pkg.Name)
+			}
+			pkg.Origin = file
+			definitions = append(definitions, pkg)
+		}
+	}
+
+	return definitions, nil
+}
+
+// AddDefinitions adds package definitions from a source to the package
+// database, replacing packages of the same name. Sources must be added from
+// the lowest precedence to the highest.
+func (pm *PackageManager) AddDefinitions(source string, definitions []*PackageInfo) {
+	for _, pkg := range definitions {
+		pkg.Source = source
+		if existing, ok := pm.packages[pkg.Name]; ok {
+			pm.overrides = append(pm.overrides, fmt.Sprintf("%s from %s overrides %s", pkg.Name, describeSource(pkg), describeSource(existing)))
+		}
+		pm.addPackage(pkg)
+	}
+}
+
+// LoadDefinitions adds the user-level package definitions, then the ones
+// in the packages directory of the project
+func (pm *PackageManager) LoadDefinitions(projectDir string) error {
+	if dir := UserPackagesDir(); dir != "" {
+		definitions, err := LoadDefinitions(dir)
+		if err != nil {
+			return err
+		}
+		pm.AddDefinitions(SourceUser, definitions)
+	}
+
+	definitions, err := LoadDefinitions(filepath.Join(projectDir, "packages"))
+	if err != nil {
+		return err
+	}
+	pm.AddDefinitions(SourceProject, definitions)
+
+	return nil
+}
+
+// Overrides describes the packages that a definition replaced
+func (pm *PackageManager) Overrides() []string {
+	return append([]string(nil), pm.overrides...)
+}
+
+// describeSource names where a package definition comes from
+func describeSource(pkg *PackageInfo) string {
+	if pkg.Origin != "" {
+		return pkg.Origin
+	}
+	if pkg.Source == SourceBuildroot {
+		return "the Buildroot package tree"
+	}
+	return "the built-in packages"
+}
@@ -0,0 +1,179 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type DefinitionsTestSuite struct {
+	suite.Suite
+	projectDir string
+	userDir    string
+}
+
+func TestDefinitionsTestSuite(t *testing.T) {
+	suite.Run(t, new(DefinitionsTestSuite))
+}
+
+func (s *DefinitionsTestSuite) SetupTest() {
+	s.projectDir = s.T().TempDir()
+	s.userDir = s.T().TempDir()
+	s.T().Setenv(PackagesDirEnv, s.userDir)
+}
+
+func (s *DefinitionsTestSuite) writeFile(dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func (s *DefinitionsTestSuite) TestLoadDefinitions() {
+	path := s.writeFile(s.userDir, "acme.yml", `packages:
+  - name: acme-agent
+    version: "1.4.0"
+    description: Fleet agent
+    category: acme
+    dependencies: [openssl, zlib]
+    conflicts: [dropbear]
+    size: 2097152
+    buildroot_symbol: BR2_PACKAGE_ACME_FLEET_AGENT
+  - name: acme-tools
+    description: Diagnostics
+`)
+	s.writeFile(s.userDir, "README.md", "not a definitions file")
+
+	definitions, err := LoadDefinitions(s.userDir)
+	s.Require().NoError(err)
+	s.Require().Len(definitions, 2)
+
+	s.Equal(&PackageInfo{
+		Name:         "acme-agent",
+		Version:      "1.4.0",
+		Description:  "Fleet agent",
+		Dependencies: []string{"openssl", "zlib"},
+		Conflicts:    []string{"dropbear"},
+		Category:     "acme",
+		Size:         2097152,
+		BuildrootPkg: "BR2_PACKAGE_ACME_FLEET_AGENT",
+		Origin:       path,
+	}, definitions[0])
+
+	// Category and Buildroot symbol have defaults
+	s.Equal("custom", definitions[1].Category)
+	s.Equal("BR2_PACKAGE_ACME_TOOLS", definitions[1].BuildrootPkg)
+}
+
+func (s *DefinitionsTestSuite) TestLoadDefinitionsMissingDirectory() {
+	definitions, err := LoadDefinitions(filepath.Join(s.projectDir, "packages"))
+	s.NoError(err)
+	s.Empty(definitions)
+}
+
+func (s *DefinitionsTestSuite) TestLoadDefinitionsErrors() {
+	dir := filepath.Join(s.projectDir, "packages")
+
+	s.writeFile(dir, "a.yml", "packages:\n  - name: acme-agent\n    colour: blue\n")
+	_, err := LoadDefinitions(dir)
+	s.Error(err)
+	s.Contains(err.Error(), "invalid package definitions in "+filepath.Join(dir, "a.yml"))
+
+	s.writeFile(dir, "a.yml", "packages:\n  - description: no name\n")
+	_, err = LoadDefinitions(dir)
+	s.Error(err)
+	s.Contains(err.Error(), "package 1 has no name")
+
+	s.writeFile(dir, "a.yml", "packages:\n  - name: acme-agent\n  - name: acme-agent\n")
+	_, err = LoadDefinitions(dir)
+	s.Error(err)
+	s.Contains(err.Error(), "package acme-agent is defined twice in "+filepath.Join(dir, "a.yml"))
+
+	s.writeFile(dir, "a.yml", "packages:\n  - name: acme-agent\n")
+	s.writeFile(dir, "b.yaml", "packages:\n  - name: acme-agent\n")
+	_, err = LoadDefinitions(dir)
+	s.Error(err)
+	s.Contains(err.Error(), "package acme-agent is defined in both "+filepath.Join(dir, "a.yml")+" and "+filepath.Join(dir, "b.yaml"))
+}
+
+func (s *DefinitionsTestSuite) TestPrecedence() {
+	userFile := s.writeFile(s.userDir, "acme.yml", `packages:
+  - name: acme-agent
+    version: "1.0.0"
+    description: Fleet agent
+  - name: zlib
+    version: "1.3.1"
+    description: Patched compression library
+`)
+	projectFile := s.writeFile(filepath.Join(s.projectDir, "packages"), "agent.yml", `packages:
+  - name: acme-agent
+    version: "2.0.0"
+    description: Fleet agent, pinned for this product
+    dependencies: [zlib]
+`)
+
+	pm := NewPackageManager(nil)
+	s.Require().NoError(pm.LoadDefinitions(s.projectDir))
+
+	agent, err := pm.GetPackageInfo("acme-agent")
+	s.Require().NoError(err)
+	s.Equal("2.0.0", agent.Version)
+	s.Equal(SourceProject, agent.Source)
+
+	zlib, err := pm.GetPackageInfo("zlib")
+	s.Require().NoError(err)
+	s.Equal("1.3.1", zlib.Version)
+	s.Equal(SourceUser, zlib.Source)
+
+	busybox, err := pm.GetPackageInfo("busybox")
+	s.Require().NoError(err)
+	s.Equal(SourceBuiltin, busybox.Source)
+
+	s.Equal([]string{
+		"zlib from " + userFile + " overrides the built-in packages",
+		"acme-agent from " + projectFile + " overrides " + userFile,
+	}, pm.Overrides())
+
+	// Definitions resolve like any other package
+	resolution := pm.ResolveDependencies([]string{"acme-agent"})
+	s.Equal([]string{"zlib", "acme-agent"}, resolution.Packages)
+}
+
+func (s *DefinitionsTestSuite) TestDefinedSymbol() {
+	s.writeFile(filepath.Join(s.projectDir, "packages"), "agent.yml", `packages:
+  - name: agent
+    description: Fleet agent
+    buildroot_symbol: BR2_PACKAGE_ACME_AGENT
+`)
+	cfg := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"agent"},
+	}
+
+	pm := NewPackageManager(cfg)
+	s.Require().NoError(pm.LoadDefinitions(s.projectDir))
+
+	defconfig, err := cfg.GetBuildrootDefconfig()
+	s.Require().NoError(err)
+	s.Contains(defconfig, "BR2_PACKAGE_ACME_AGENT=y\n")
+	s.NotContains(defconfig, "BR2_PACKAGE_AGENT=y")
+
+	roots, err := pm.ImageRoots()
+	s.Require().NoError(err)
+	s.Contains(roots, ImageRoot{Package: "agent", Source: "package agent"})
+}
+
+func (s *DefinitionsTestSuite) TestInvalidUserDefinitions() {
+	s.writeFile(s.userDir, "broken.yml", "packages: [")
+
+	err := NewPackageManager(nil).LoadDefinitions(s.projectDir)
+	s.Error(err)
+	s.Contains(err.Error(), "invalid package definitions")
+}
@@ -0,0 +1,240 @@
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph formats
+const (
+	GraphDOT     = "dot"
+	GraphMermaid = "mermaid"
+	GraphJSON    = "json"
+)
+
+// categoryColors are the fill colours of package categories, assigned in
+// alphabetical order of the categories in a graph
+var categoryColors = []string{
+	"#a6cee3", "#b2df8a", "#fb9a99", "#fdbf6f", "#cab2d6",
+	"#ffff99", "#8dd3c7", "#bebada", "#fccde5", "#d9d9d9",
+}
+
+// GraphNode is a package of a dependency graph
+type GraphNode struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version,omitempty"`
+	Category string   `json:"category"`
+	Size     int64    `json:"size,omitempty"`
+	Sources  []string `json:"sources,omitempty"` // forge.yml settings that enable it directly
+}
+
+// GraphEdge is a dependency of one package on another
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the resolved dependency graph of a set of packages
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphOptions controls how a graph is rendered
+type GraphOptions struct {
+	Colors  bool // Colour packages by category
+	Weights bool // Size packages by their installed size
+}
+
+// ImageGraph returns the dependency graph of the packages in the image
+func (pm *PackageManager) ImageGraph() (*Graph, error) {
+	roots, err := pm.ImageRoots()
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string][]string)
+	var names []string
+	for _, root := range roots {
+		sources[root.Package] = append(sources[root.Package], root.Source)
+		names = append(names, root.Package)
+	}
+
+	graph := pm.DependencyGraph(names)
+	for i := range graph.Nodes {
+		graph.Nodes[i].Sources = sources[graph.Nodes[i].Name]
+	}
+	return graph, nil
+}
+
+// DependencyGraph returns the dependency graph of a set of packages, as
+// ResolveDependencies selects them
+func (pm *PackageManager) DependencyGraph(packageNames []string) *Graph {
+	r := pm.resolve(packageNames)
+
+	names := append([]string(nil), r.order...)
+	sort.Strings(names)
+
+	graph := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, name := range names {
+		pkg := pm.packages[name]
+		graph.Nodes = append(graph.Nodes, GraphNode{
+			Name:     pkg.Name,
+			Version:  pkg.Version,
+			Category: pkg.Category,
+			Size:     pkg.Size,
+		})
+		for _, dep := range r.edges[name] {
+			graph.Edges = append(graph.Edges, GraphEdge{From: name, To: dep})
+		}
+	}
+	return graph
+}
+
+// Render renders the graph as Graphviz DOT, Mermaid or JSON
+func (g *Graph) Render(format string, opts GraphOptions) (string, error) {
+	switch format {
+	case GraphDOT, "":
+		return g.dot(opts), nil
+	case GraphMermaid:
+		return g.mermaid(opts), nil
+	case GraphJSON:
+		data, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to render graph: %v", err)
+		}
+		return string(data) + "\n", nil
+	default:
+		return "", fmt.Errorf("unknown graph format %q (valid: dot, mermaid, json)", format)
+	}
+}
+
+func (g *Graph) dot(opts GraphOptions) string {
+	colors := g.categoryColors()
+	largest := g.largestSize()
+
+	var b strings.Builder
+	b.WriteString("digraph packages {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\", fontname=\"Helvetica\"];\n")
+
+	for _, node := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%q", g.label(node, opts, "\n"))}
+		if opts.Colors {
+			attrs = append(attrs, fmt.Sprintf("fillcolor=%q", colors[node.Category]))
+		}
+		if opts.Weights && largest > 0 {
+			attrs = append(attrs, fmt.Sprintf("width=%.2f", 1+2*float64(node.Size)/float64(largest)))
+		}
+		if len(node.Sources) > 0 {
+			attrs = append(attrs, "penwidth=2")
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", node.Name, strings.Join(attrs, ", "))
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q;\n", edge.From, edge.To)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (g *Graph) mermaid(opts GraphOptions) string {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+
+	for _, node := range g.Nodes {
+		label := strings.ReplaceAll(g.label(node, opts, "<br/>"), `"`, "#quot;")
+		if len(node.Sources) > 0 {
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", mermaidID(node.Name), label)
+		} else {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", mermaidID(node.Name), label)
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", mermaidID(edge.From), mermaidID(edge.To))
+	}
+
+	if opts.Colors {
+		colors := g.categoryColors()
+		members := make(map[string][]string)
+		for _, node := range g.Nodes {
+			members[node.Category] = append(members[node.Category], mermaidID(node.Name))
+		}
+		for _, category := range g.categories() {
+			class := "category_" + mermaidID(category)
+			fmt.Fprintf(&b, "  classDef %s fill:%s,stroke:#333\n", class, colors[category])
+			fmt.Fprintf(&b, "  class %s %s\n", strings.Join(members[category], ","), class)
+		}
+	}
+
+	return b.String()
+}
+
+// label returns the text of a node: its name and, when packages are
+// weighted, its size
+func (g *Graph) label(node GraphNode, opts GraphOptions, separator string) string {
+	if opts.Weights && node.Size > 0 {
+		return node.Name + separator + formatSize(node.Size)
+	}
+	return node.Name
+}
+
+// categories returns the categories of the graph's packages, sorted
+func (g *Graph) categories() []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, node := range g.Nodes {
+		if !seen[node.Category] {
+			seen[node.Category] = true
+			categories = append(categories, node.Category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// categoryColors assigns a fill colour to each category of the graph
+func (g *Graph) categoryColors() map[string]string {
+	colors := make(map[string]string)
+	for i, category := range g.categories() {
+		colors[category] = categoryColors[i%len(categoryColors)]
+	}
+	return colors
+}
+
+func (g *Graph) largestSize() int64 {
+	var largest int64
+	for _, node := range g.Nodes {
+		if node.Size > largest {
+			largest = node.Size
+		}
+	}
+	return largest
+}
+
+// mermaidID turns a package name into a Mermaid node ID, which may only
+// contain letters, digits and underscores
+func mermaidID(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,126 @@
+package packages
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type GraphTestSuite struct {
+	suite.Suite
+	manager *PackageManager
+}
+
+func TestGraphTestSuite(t *testing.T) {
+	suite.Run(t, new(GraphTestSuite))
+}
+
+func (s *GraphTestSuite) SetupTest() {
+	s.manager = NewPackageManager(&config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"fail2ban"},
+		Features:      []string{"firewall"},
+	})
+}
+
+func (s *GraphTestSuite) TestImageGraph() {
+	graph, err := s.manager.ImageGraph()
+	s.Require().NoError(err)
+
+	var names []string
+	for _, node := range graph.Nodes {
+		names = append(names, node.Name)
+	}
+	s.Equal([]string{"busybox", "expat", "fail2ban", "iptables", "libffi", "python3"}, names)
+
+	s.Equal([]string{"template minimal"}, graph.Nodes[0].Sources)
+	s.Equal([]string{"feature firewall"}, graph.Nodes[3].Sources)
+	s.Empty(graph.Nodes[5].Sources)
+
+	s.Contains(graph.Edges, GraphEdge{From: "fail2ban", To: "python3"})
+	s.Contains(graph.Edges, GraphEdge{From: "fail2ban", To: "iptables"})
+	s.Contains(graph.Edges, GraphEdge{From: "python3", To: "libffi"})
+}
+
+func (s *GraphTestSuite) TestDependencyGraph() {
+	graph := s.manager.DependencyGraph([]string{"nginx"})
+	s.Equal([]GraphEdge{
+		{From: "nginx", To: "openssl"},
+		{From: "nginx", To: "zlib"},
+		{From: "nginx", To: "pcre"},
+	}, graph.Edges)
+	s.Len(graph.Nodes, 4)
+}
+
+func (s *GraphTestSuite) TestRenderDOT() {
+	graph := s.manager.DependencyGraph([]string{"nginx"})
+
+	output, err := graph.Render(GraphDOT, GraphOptions{})
+	s.Require().NoError(err)
+	s.Contains(output, "digraph packages {")
+	s.Contains(output, `"nginx" [label="nginx"];`)
+	s.Contains(output, `"nginx" -> "openssl";`)
+	s.NotContains(output, "width=")
+
+	output, err = graph.Render(GraphDOT, GraphOptions{Colors: true})
+	s.Require().NoError(err)
+	s.Contains(output, `"pcre" [label="pcre", fillcolor="#a6cee3"];`)
+	s.Contains(output, `"nginx" [label="nginx", fillcolor="#b2df8a"];`)
+}
+
+func (s *GraphTestSuite) TestRenderWeights() {
+	s.manager.AddDefinitions(SourceProject, []*PackageInfo{
+		{Name: "acme-agent", Category: "acme", Size: 2097152, Dependencies: []string{"acme-lib"}},
+		{Name: "acme-lib", Category: "acme", Size: 524288},
+	})
+	graph := s.manager.DependencyGraph([]string{"acme-agent"})
+
+	output, err := graph.Render(GraphDOT, GraphOptions{Weights: true})
+	s.Require().NoError(err)
+	s.Contains(output, `"acme-agent" [label="acme-agent\n2.0 MB", width=3.00];`)
+	s.Contains(output, `"acme-lib" [label="acme-lib\n512.0 KB", width=1.50];`)
+
+	output, err = graph.Render(GraphMermaid, GraphOptions{Weights: true})
+	s.Require().NoError(err)
+	s.Contains(output, `  acme_agent["acme-agent<br/>2.0 MB"]`)
+}
+
+func (s *GraphTestSuite) TestRenderMermaid() {
+	graph, err := s.manager.ImageGraph()
+	s.Require().NoError(err)
+
+	output, err := graph.Render(GraphMermaid, GraphOptions{Colors: true})
+	s.Require().NoError(err)
+	s.Contains(output, "graph LR\n")
+	s.Contains(output, `  fail2ban(["fail2ban"])`)
+	s.Contains(output, `  libffi["libffi"]`)
+	s.Contains(output, "  python3 --> libffi\n")
+	s.Contains(output, "  classDef category_libs fill:")
+	s.Contains(output, "  class expat,libffi category_libs\n")
+
+	s.Equal("wpa_supplicant", mermaidID("wpa_supplicant"))
+	s.Equal("acme_agent_v2", mermaidID("acme-agent.v2"))
+}
+
+func (s *GraphTestSuite) TestRenderJSON() {
+	graph := s.manager.DependencyGraph([]string{"nginx"})
+
+	output, err := graph.Render(GraphJSON, GraphOptions{})
+	s.Require().NoError(err)
+
+	var decoded Graph
+	s.Require().NoError(json.Unmarshal([]byte(output), &decoded))
+	s.Equal(*graph, decoded)
+}
+
+func (s *GraphTestSuite) TestRenderUnknownFormat() {
+	_, err := s.manager.DependencyGraph(nil).Render("svg", GraphOptions{})
+	s.Error(err)
+	s.Contains(err.Error(), `unknown graph format "svg"`)
+}
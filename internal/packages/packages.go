@@ -2,8 +2,6 @@ package packages
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -13,14 +11,18 @@ import (
 
 // PackageInfo represents information about a package
 type PackageInfo struct {
-	Name         string
-	Version      string
-	Description  string
-	Dependencies []string
-	Conflicts    []string
-	Category     string
-	Size         int64  // Size in bytes
-	BuildrootPkg string // Corresponding Buildroot package name
+	Name         string   `json:"name" yaml:"name"`
+	Version      string   `json:"version,omitempty" yaml:"version"`
+	Description  string   `json:"description" yaml:"description"`
+	Dependencies []string `json:"dependencies,omitempty" yaml:"dependencies"`
+	Conflicts    []string `json:"conflicts,omitempty" yaml:"conflicts"`
+	Provides     []string `json:"provides,omitempty" yaml:"provides"` // Virtual capabilities such as ssh-server
+	Category     string   `json:"category" yaml:"category"`
+	License      string   `json:"license,omitempty" yaml:"license"`
+	Size         int64    `json:"size,omitempty" yaml:"size"`               // Size in bytes
+	BuildrootPkg string   `json:"buildroot_symbol" yaml:"buildroot_symbol"` // Corresponding Buildroot package symbol
+	Source       string   `json:"source,omitempty" yaml:"-"`                // Where the definition comes from, see SourceBuiltin
+	Origin       string   `json:"origin,omitempty" yaml:"-"`                // File the definition was read from, if any
 }
 
 // PackageManager manages OS packages for Forge projects
@@ -29,6 +31,7 @@ type PackageManager struct {
 	logger     *logger.Logger
 	packages   map[string]*PackageInfo
 	categories map[string][]string // Category -> package names
+	overrides  []string            // Definitions that replaced another package
 }
 
 // NewPackageManager creates a new package manager
@@ -41,9 +44,25 @@ func NewPackageManager(cfg *config.Config) *PackageManager {
 	}
 
 	pm.initializePackageDatabase()
+
+	// The defconfig enables packages under the symbol of their definition,
+	// which can name one other than the symbol derived from the name
+	if cfg != nil {
+		cfg.LookupPackageSymbol = pm.Symbol
+		cfg.ResolvePackageDependencies = pm.PackageDependencies
+	}
 	return pm
 }
 
+// Symbol returns the Buildroot symbol of a known package
+func (pm *PackageManager) Symbol(name string) (string, bool) {
+	pkg, ok := pm.packages[name]
+	if !ok || pkg.BuildrootPkg == "" {
+		return "", false
+	}
+	return pkg.BuildrootPkg, true
+}
+
 // initializePackageDatabase sets up the package database with known packages
 func (pm *PackageManager) initializePackageDatabase() {
 	// Core system packages
@@ -61,10 +80,21 @@ func (pm *PackageManager) initializePackageDatabase() {
 		Version:      "9.3",
 		Description:  "OpenSSH connectivity tools",
 		Dependencies: []string{"openssl", "zlib"},
+		Provides:     []string{"ssh-server"},
 		Category:     "network",
 		BuildrootPkg: "BR2_PACKAGE_OPENSSH",
 	})
 
+	pm.addPackage(&PackageInfo{
+		Name:         "dropbear",
+		Version:      "2022.83",
+		Description:  "Small SSH server and client",
+		Dependencies: []string{"zlib"},
+		Provides:     []string{"ssh-server"},
+		Category:     "network",
+		BuildrootPkg: "BR2_PACKAGE_DROPBEAR",
+	})
+
 	pm.addPackage(&PackageInfo{
 		Name:         "openssl",
 		Version:      "3.1.4",
@@ -210,8 +240,24 @@ func (pm *PackageManager) initializePackageDatabase() {
 	})
 }
 
-// addPackage adds a package to the database
+// addPackage adds a package to the database, replacing any package of the
+// same name
 func (pm *PackageManager) addPackage(pkg *PackageInfo) {
+	if old, exists := pm.packages[pkg.Name]; exists {
+		names := pm.categories[old.Category]
+		for i, name := range names {
+			if name == pkg.Name {
+				pm.categories[old.Category] = append(names[:i:i], names[i+1:]...)
+				break
+			}
+		}
+		if len(pm.categories[old.Category]) == 0 {
+			delete(pm.categories, old.Category)
+		}
+	}
+	if pkg.Source == "" {
+		pkg.Source = SourceBuiltin
+	}
 	pm.packages[pkg.Name] = pkg
 	pm.categories[pkg.Category] = append(pm.categories[pkg.Category], pkg.Name)
 }
@@ -272,21 +318,46 @@ func (pm *PackageManager) IsValidPackage(name string) bool {
 	return exists
 }
 
+// SearchPackages returns the packages matching a query, best match first.
+// See Search.
+func (pm *PackageManager) SearchPackages(query string) []*PackageInfo {
+	var pkgs []*PackageInfo
+	for _, result := range pm.Search(query, "") {
+		pkgs = append(pkgs, result.Package)
+	}
+	return pkgs
+}
+
 // DependencyResolution represents the result of dependency resolution
 type DependencyResolution struct {
-	Packages  []string // Packages in installation order
-	Missing   []string // Packages that couldn't be found
-	Circular  []string // Packages involved in circular dependencies
-	Conflicts []string // Packages with conflicts
+	Packages      []string          // Packages in installation order
+	Missing       []string          // Packages that couldn't be found
+	Circular      []string          // Packages involved in circular dependencies
+	Conflicts     []string          // Packages with conflicts
+	Unsatisfiable []string          // Dependencies no available package meets, with the reason
+	RequiredBy    map[string]string // Package -> package whose dependency pulled it in, unset for requested packages
+}
+
+// ResolveDependencies resolves dependencies for a list of packages.
+// Dependencies may carry a version constraint (openssl>=3.0), name a
+// virtual capability that other packages provide (ssh-server) or list
+// alternatives in order of preference (dropbear | openssh). Packages that
+// are already selected are preferred over other alternatives.
+func (pm *PackageManager) ResolveDependencies(packageNames []string) *DependencyResolution {
+	result, _ := pm.resolveDependencies(packageNames)
+	return result
 }
 
-// ResolveDependencies resolves dependencies for a list of packages
-func (pm *PackageManager) ResolveDependencies(packageNames []string) *DependencyResolution {
+// resolveDependencies resolves dependencies and also returns the resolver,
+// which is nil when requested packages are missing
+func (pm *PackageManager) resolveDependencies(packageNames []string) (*DependencyResolution, *resolver) {
 	result := &DependencyResolution{
-		Packages:  make([]string, 0),
-		Missing:   make([]string, 0),
-		Circular:  make([]string, 0),
-		Conflicts: make([]string, 0),
+		Packages:      make([]string, 0),
+		Missing:       make([]string, 0),
+		Circular:      make([]string, 0),
+		Conflicts:     make([]string, 0),
+		Unsatisfiable: make([]string, 0),
+		RequiredBy:    make(map[string]string),
 	}
 
 	// Check for missing packages first
@@ -297,224 +368,90 @@ func (pm *PackageManager) ResolveDependencies(packageNames []string) *Dependency
 	}
 
 	if len(result.Missing) > 0 {
-		return result
+		return result, nil
 	}
 
-	// Collect all packages that need to be installed (including dependencies)
-	allPackages := make(map[string]bool)
-	for _, name := range packageNames {
-		pm.collectAllDependencies(name, allPackages)
+	r := pm.resolve(packageNames)
+	result.Unsatisfiable = append(result.Unsatisfiable, r.unsatisfiable...)
+	for name, parent := range r.requiredBy {
+		result.RequiredBy[name] = parent
 	}
 
-	// Perform topological sort
-	visited := make(map[string]bool)
-	tempVisited := make(map[string]bool)
-	installOrder := make([]string, 0)
-
-	for pkg := range allPackages {
-		if !visited[pkg] {
-			if pm.topologicalSort(pkg, visited, tempVisited, &installOrder) {
-				result.Circular = append(result.Circular, pkg)
-				return result
-			}
-		}
+	installOrder, cycle := r.installOrder()
+	if len(cycle) > 0 {
+		result.Circular = cycle
+		return result, r
 	}
 
 	result.Packages = installOrder
-	result.Packages = installOrder
-	return result
+	return result, r
 }
 
-// hasCircularDependency performs DFS to detect circular dependencies and build topological order
-func (pm *PackageManager) hasCircularDependency(pkgName string, visited, recursionStack map[string]bool, installOrder *[]string) bool {
-	visited[pkgName] = true
-	recursionStack[pkgName] = true
-
-	pkg, _ := pm.packages[pkgName]
-	for _, dep := range pkg.Dependencies {
-		if !visited[dep] {
-			if pm.hasCircularDependency(dep, visited, recursionStack, installOrder) {
-				return true
-			}
-		} else if recursionStack[dep] {
-			// Found circular dependency
-			return true
-		}
-	}
-
-	recursionStack[pkgName] = false
-	// Add to install order after processing dependencies (dependencies first)
-	*installOrder = append(*installOrder, pkgName)
-	return false
-}
-
-// GetDependencyTree returns a tree representation of package dependencies
+// GetDependencyTree returns, for each package, the packages it needs in
+// installation order, ending with the package itself
 func (pm *PackageManager) GetDependencyTree(packageNames []string) map[string][]string {
 	tree := make(map[string][]string)
 
 	for _, name := range packageNames {
-		if pkg, exists := pm.packages[name]; exists {
-			tree[name] = pm.getAllDependencies(pkg)
+		if pm.IsValidPackage(name) {
+			order, _ := pm.resolve([]string{name}).installOrder()
+			tree[name] = order
 		}
 	}
 
 	return tree
 }
 
-// getAllDependencies recursively gets all dependencies for a package
-func (pm *PackageManager) getAllDependencies(pkg *PackageInfo) []string {
-	visited := make(map[string]bool)
-	var deps []string
-
-	pm.collectDependencies(pkg.Name, visited, &deps)
-	return deps
-}
-
-// collectDependencies recursively collects all dependencies
-func (pm *PackageManager) collectDependencies(pkgName string, visited map[string]bool, deps *[]string) {
-	if visited[pkgName] {
-		return
-	}
-
-	visited[pkgName] = true
-
-	pkg, exists := pm.packages[pkgName]
-	if !exists {
-		return
-	}
-
-	for _, dep := range pkg.Dependencies {
-		pm.collectDependencies(dep, visited, deps)
-	}
-
-	*deps = append(*deps, pkgName)
-}
-
-// collectAllDependencies collects all packages that need to be installed for a given package
-func (pm *PackageManager) collectAllDependencies(pkgName string, allPackages map[string]bool) {
-	if allPackages[pkgName] {
-		return
-	}
-
-	allPackages[pkgName] = true
-
-	pkg, exists := pm.packages[pkgName]
-	if !exists {
-		return
-	}
-
-	for _, dep := range pkg.Dependencies {
-		pm.collectAllDependencies(dep, allPackages)
-	}
-}
-
-// topologicalSort performs topological sort using DFS
-func (pm *PackageManager) topologicalSort(pkgName string, visited, tempVisited map[string]bool, result *[]string) bool {
-	visited[pkgName] = true
-	tempVisited[pkgName] = true
-
-	pkg, exists := pm.packages[pkgName]
-	if !exists {
-		tempVisited[pkgName] = false
-		return false
-	}
-
-	for _, dep := range pkg.Dependencies {
-		if tempVisited[dep] {
-			// Circular dependency detected
-			tempVisited[pkgName] = false
-			return true
-		}
-		if !visited[dep] {
-			if pm.topologicalSort(dep, visited, tempVisited, result) {
-				tempVisited[pkgName] = false
-				return true
-			}
-		}
-	}
-
-	tempVisited[pkgName] = false
-	// Add to result after all dependencies are processed
-	*result = append(*result, pkgName)
-	return false
-}
-
-// ValidatePackageSet checks if a set of packages can be installed together
+// ValidatePackageSet checks if a set of packages can be installed together:
+// every dependency must be satisfiable and no two selected packages may
+// conflict. Problems explain which chain of dependencies pulled each
+// package in.
 func (pm *PackageManager) ValidatePackageSet(packageNames []string) *DependencyResolution {
-	result := pm.ResolveDependencies(packageNames)
+	result, r := pm.resolveDependencies(packageNames)
 
 	if len(result.Missing) > 0 || len(result.Circular) > 0 {
 		return result
 	}
 
-	// Check for conflicts
-	installed := make(map[string]bool)
-	for _, pkgName := range result.Packages {
-		pkg := pm.packages[pkgName]
-
-		// Check conflicts
-		for _, conflict := range pkg.Conflicts {
-			if installed[conflict] {
-				result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s conflicts with %s", pkgName, conflict))
-			}
-		}
-
-		installed[pkgName] = true
-	}
+	result.Conflicts = append(result.Conflicts, r.conflicts()...)
 
 	return result
 }
 
 // GetRecommendedPackages returns recommended packages for a given set of packages
 func (pm *PackageManager) GetRecommendedPackages(packageNames []string) []string {
-	recommended := make(map[string]bool)
-	added := make(map[string]bool)
-
-	// Add explicitly requested packages
+	requested := make(map[string]bool)
 	for _, name := range packageNames {
-		added[name] = true
-	}
-
-	// Add all dependencies (including transitive)
-	for _, name := range packageNames {
-		pm.addAllDependencies(name, recommended, added)
+		requested[name] = true
 	}
 
+	// Recommend all dependencies (including transitive)
 	var result []string
-	for pkg := range recommended {
-		result = append(result, pkg)
+	for _, name := range pm.resolve(packageNames).order {
+		if !requested[name] {
+			result = append(result, name)
+		}
 	}
 
 	sort.Strings(result)
 	return result
 }
 
-// addAllDependencies recursively adds all dependencies
-func (pm *PackageManager) addAllDependencies(pkgName string, recommended, added map[string]bool) {
-	pkg, exists := pm.packages[pkgName]
-	if !exists {
-		return
-	}
-
-	for _, dep := range pkg.Dependencies {
-		if !added[dep] {
-			recommended[dep] = true
-			pm.addAllDependencies(dep, recommended, added)
-		}
-	}
-}
-
 // InstallationResult represents the result of a package installation
 type InstallationResult struct {
 	Package     string
 	Success     bool
 	Error       string
+	RequiredBy  string   // Package whose dependency pulled it in, empty if requested
 	ConfigFiles []string // Configuration files created/modified
 	Services    []string // Services that need to be started
 }
 
-// InstallPackages installs a set of packages
-func (pm *PackageManager) InstallPackages(packageNames []string, buildrootDir string) []*InstallationResult {
+// InstallPackages checks that a set of packages can be installed and
+// describes each package the installation brings in, in dependency order.
+// Packages are installed by adding them to forge.yml; the Buildroot
+// configuration is always generated from it.
+func (pm *PackageManager) InstallPackages(packageNames []string) []*InstallationResult {
 	results := []*InstallationResult{}
 
 	// Resolve dependencies
@@ -541,152 +478,68 @@ func (pm *PackageManager) InstallPackages(packageNames []string, buildrootDir st
 		return results
 	}
 
-	// Install packages in dependency order
-	for _, pkgName := range depResult.Packages {
-		result := pm.installPackage(pkgName, buildrootDir)
-		results = append(results, result)
-	}
-
-	return results
-}
-
-// installPackage installs a single package
-func (pm *PackageManager) installPackage(pkgName, buildrootDir string) *InstallationResult {
-	result := &InstallationResult{
-		Package: pkgName,
-	}
-
-	pkg, exists := pm.packages[pkgName]
-	if !exists {
-		result.Success = false
-		result.Error = "package not found"
-		return result
-	}
-
-	// Simulate package installation by enabling it in Buildroot config
-	configPath := pm.getBuildrootConfigPath(buildrootDir)
-	if configPath == "" {
-		result.Success = false
-		result.Error = "Buildroot config not found"
-		return result
-	}
-
-	// Enable the package in Buildroot config
-	err := pm.enablePackageInBuildroot(configPath, pkg.BuildrootPkg)
-	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("failed to enable package in Buildroot: %v", err)
-		return result
-	}
-
-	// Generate configuration files if needed
-	configFiles, err := pm.generatePackageConfig(pkgName, buildrootDir)
-	if err != nil {
-		pm.logger.Warn("Failed to generate config for %s: %v", pkgName, err)
-	}
-
-	// Get services that need to be started
-	services := pm.getPackageServices(pkgName)
-
-	result.Success = true
-	result.ConfigFiles = configFiles
-	result.Services = services
-
-	return result
-}
-
-// getBuildrootConfigPath finds the Buildroot config file
-func (pm *PackageManager) getBuildrootConfigPath(buildrootDir string) string {
-	// Look for .config file in buildroot directory
-	configPath := filepath.Join(buildrootDir, ".config")
-	if _, err := os.Stat(configPath); err == nil {
-		return configPath
-	}
-
-	// Look for config files in output directory
-	outputDir := filepath.Join(buildrootDir, "output")
-	configPath = filepath.Join(outputDir, ".config")
-	if _, err := os.Stat(configPath); err == nil {
-		return configPath
-	}
-
-	return ""
-}
-
-// enablePackageInBuildroot enables a package in the Buildroot config
-func (pm *PackageManager) enablePackageInBuildroot(configPath, buildrootPkg string) error {
-	// Read the current config
-	content, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read config: %v", err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	modified := false
-
-	// Look for the package config line and enable it
-	for i, line := range lines {
-		if strings.HasPrefix(line, "# "+buildrootPkg+" is not set") {
-			lines[i] = buildrootPkg + "=y"
-			modified = true
-			break
-		} else if strings.HasPrefix(line, buildrootPkg+"=") {
-			// Already configured, make sure it's enabled
-			if !strings.Contains(line, "=y") {
-				lines[i] = buildrootPkg + "=y"
-				modified = true
-			}
-			break
+	if len(depResult.Unsatisfiable) > 0 {
+		for _, problem := range depResult.Unsatisfiable {
+			results = append(results, &InstallationResult{
+				Package: strings.Join(packageNames, ", "),
+				Success: false,
+				Error:   problem,
+			})
 		}
+		return results
 	}
 
-	if !modified {
-		// Add the config line if it doesn't exist
-		lines = append(lines, buildrootPkg+"=y")
+	// Describe packages in dependency order
+	for _, pkgName := range depResult.Packages {
+		results = append(results, &InstallationResult{
+			Package:     pkgName,
+			Success:     true,
+			RequiredBy:  depResult.RequiredBy[pkgName],
+			ConfigFiles: pm.generatePackageConfig(pkgName),
+			Services:    pm.getPackageServices(pkgName),
+		})
 	}
 
-	// Write back the modified config
-	newContent := strings.Join(lines, "\n")
-	return os.WriteFile(configPath, []byte(newContent), 0644)
+	return results
 }
 
-// generatePackageConfig generates configuration files for a package
-func (pm *PackageManager) generatePackageConfig(pkgName, buildrootDir string) ([]string, error) {
+// generatePackageConfig returns the configuration files a package brings
+func (pm *PackageManager) generatePackageConfig(pkgName string) []string {
 	var configFiles []string
 
 	switch pkgName {
 	case "openssh":
-		configFiles = append(configFiles, pm.generateSSHConfig(buildrootDir)...)
+		configFiles = append(configFiles, pm.generateSSHConfig()...)
 	case "mosquitto":
-		configFiles = append(configFiles, pm.generateMosquittoConfig(buildrootDir)...)
+		configFiles = append(configFiles, pm.generateMosquittoConfig()...)
 	case "nginx":
-		configFiles = append(configFiles, pm.generateNginxConfig(buildrootDir)...)
+		configFiles = append(configFiles, pm.generateNginxConfig()...)
 	case "wpa_supplicant":
-		configFiles = append(configFiles, pm.generateWPAConfig(buildrootDir)...)
+		configFiles = append(configFiles, pm.generateWPAConfig()...)
 	}
 
-	return configFiles, nil
+	return configFiles
 }
 
 // generateSSHConfig generates SSH configuration
-func (pm *PackageManager) generateSSHConfig(buildrootDir string) []string {
+func (pm *PackageManager) generateSSHConfig() []string {
 	// This would generate SSH config files
 	// For now, just return the expected config files
 	return []string{"/etc/ssh/sshd_config", "/etc/ssh/ssh_config"}
 }
 
 // generateMosquittoConfig generates Mosquitto MQTT broker configuration
-func (pm *PackageManager) generateMosquittoConfig(buildrootDir string) []string {
+func (pm *PackageManager) generateMosquittoConfig() []string {
 	return []string{"/etc/mosquitto/mosquitto.conf"}
 }
 
 // generateNginxConfig generates Nginx web server configuration
-func (pm *PackageManager) generateNginxConfig(buildrootDir string) []string {
+func (pm *PackageManager) generateNginxConfig() []string {
 	return []string{"/etc/nginx/nginx.conf", "/etc/nginx/sites-enabled/default"}
 }
 
 // generateWPAConfig generates WPA supplicant configuration
-func (pm *PackageManager) generateWPAConfig(buildrootDir string) []string {
+func (pm *PackageManager) generateWPAConfig() []string {
 	return []string{"/etc/wpa_supplicant.conf"}
 }
 
@@ -708,75 +561,51 @@ func (pm *PackageManager) getPackageServices(pkgName string) []string {
 	}
 }
 
-// UninstallPackages uninstalls a set of packages
-func (pm *PackageManager) UninstallPackages(packageNames []string, buildrootDir string) []*InstallationResult {
+// UninstallPackages checks that a set of packages can be removed from
+// forge.yml: each must be listed there and no other entry may need it,
+// unless that entry is being uninstalled too
+func (pm *PackageManager) UninstallPackages(packageNames []string) []*InstallationResult {
 	results := []*InstallationResult{}
 
 	for _, pkgName := range packageNames {
-		result := pm.uninstallPackage(pkgName, buildrootDir)
-		results = append(results, result)
+		results = append(results, pm.uninstallPackage(pkgName, packageNames))
 	}
 
 	return results
 }
 
-// uninstallPackage uninstalls a single package
-func (pm *PackageManager) uninstallPackage(pkgName, buildrootDir string) *InstallationResult {
+// uninstallPackage checks that a single package can be removed
+func (pm *PackageManager) uninstallPackage(pkgName string, uninstalling []string) *InstallationResult {
 	result := &InstallationResult{
 		Package: pkgName,
 	}
 
-	pkg, exists := pm.packages[pkgName]
-	if !exists {
+	if !pm.IsValidPackage(pkgName) {
 		result.Success = false
 		result.Error = "package not found"
 		return result
 	}
 
-	// Disable the package in Buildroot config
-	configPath := pm.getBuildrootConfigPath(buildrootDir)
-	if configPath == "" {
+	removal, err := pm.PlanPackageRemoval(pkgName)
+	if err != nil {
 		result.Success = false
-		result.Error = "Buildroot config not found"
+		result.Error = err.Error()
 		return result
 	}
 
-	err := pm.disablePackageInBuildroot(configPath, pkg.BuildrootPkg)
-	if err != nil {
+	var dependents []string
+	for _, path := range removal.Dependents {
+		if name, ok := strings.CutPrefix(path.Source, "package "); ok && contains(uninstalling, name) {
+			continue
+		}
+		dependents = append(dependents, path.String())
+	}
+	if len(dependents) > 0 {
 		result.Success = false
-		result.Error = fmt.Sprintf("failed to disable package in Buildroot: %v", err)
+		result.Error = "needed by " + strings.Join(dependents, "; ")
 		return result
 	}
 
 	result.Success = true
 	return result
 }
-
-// disablePackageInBuildroot disables a package in the Buildroot config
-func (pm *PackageManager) disablePackageInBuildroot(configPath, buildrootPkg string) error {
-	// Read the current config
-	content, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read config: %v", err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	modified := false
-
-	// Look for the package config line and disable it
-	for i, line := range lines {
-		if strings.HasPrefix(line, buildrootPkg+"=y") {
-			lines[i] = "# " + buildrootPkg + " is not set"
-			modified = true
-			break
-		}
-	}
-
-	if modified {
-		// Write back the modified config
-		newContent := strings.Join(lines, "\n")
-		return os.WriteFile(configPath, []byte(newContent), 0644)
-	}
-
-	return nil
-}
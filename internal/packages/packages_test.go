@@ -1,8 +1,6 @@
 package packages
 
 import (
-	"os"
-	"path/filepath"
 	"testing"
 
 	"github.com/sst/forge/internal/config"
@@ -135,6 +133,17 @@ func (s *PackagesTestSuite) TestIsValidPackage() {
 	s.False(s.manager.IsValidPackage(""))
 }
 
+func (s *PackagesTestSuite) TestSearchPackages() {
+	var names []string
+	for _, pkg := range s.manager.SearchPackages("LIB") {
+		names = append(names, pkg.Name)
+	}
+	// Name matches first, then description matches
+	s.Equal([]string{"libffi", "libwebsockets", "zlib", "expat", "lzo"}, names)
+
+	s.Empty(s.manager.SearchPackages("nonexistent"))
+}
+
 func (s *PackagesTestSuite) TestPackageDependencies() {
 	// Test package with dependencies
 	pkg, err := s.manager.GetPackageInfo("mosquitto")
@@ -251,24 +260,11 @@ func (s *PackagesTestSuite) TestComplexDependencyResolution() {
 }
 
 func (s *PackagesTestSuite) TestInstallPackages() {
-	// Create a temporary directory for testing
-	tempDir := "/tmp/forge-test-buildroot"
-	os.MkdirAll(tempDir, 0755)
-	defer os.RemoveAll(tempDir)
-
-	// Create a mock Buildroot config
-	configPath := filepath.Join(tempDir, ".config")
-	configContent := `# Test Buildroot config
-BR2_PACKAGE_BUSYBOX=y
-# BR2_PACKAGE_OPENSSH is not set
-`
-	os.WriteFile(configPath, []byte(configContent), 0644)
-
 	// Test installing openssh
-	results := s.manager.InstallPackages([]string{"openssh"}, tempDir)
+	results := s.manager.InstallPackages([]string{"openssh"})
 	s.Len(results, 3) // zlib + openssl + openssh dependencies
 
-	// Check that all packages were installed successfully
+	// Check that all packages can be installed
 	for _, result := range results {
 		s.True(result.Success, "Package %s should install successfully", result.Package)
 	}
@@ -284,52 +280,47 @@ BR2_PACKAGE_BUSYBOX=y
 	s.Contains(packages, "openssh")
 
 	// Check that dependencies come before dependents
-	s.True(packages["zlib"] < packages["openssl"], "zlib should come before openssl")
+	s.True(packages["zlib"] < packages["openssh"], "zlib should come before openssh")
 	s.True(packages["openssl"] < packages["openssh"], "openssl should come before openssh")
 
-	// Check that the config was updated
-	updatedConfig, err := os.ReadFile(configPath)
-	s.NoError(err)
-	s.Contains(string(updatedConfig), "BR2_PACKAGE_OPENSSL=y")
-	s.Contains(string(updatedConfig), "BR2_PACKAGE_OPENSSH=y")
+	// Dependencies say what pulled them in
+	openssh := results[packages["openssh"]]
+	s.Empty(openssh.RequiredBy)
+	s.Equal([]string{"sshd"}, openssh.Services)
+	s.Contains(openssh.ConfigFiles, "/etc/ssh/sshd_config")
+	s.Equal("openssh", results[packages["zlib"]].RequiredBy)
 }
 
-func (s *PackagesTestSuite) TestInstallPackagesMissingBuildroot() {
-	results := s.manager.InstallPackages([]string{"openssh"}, "/nonexistent")
-	s.Len(results, 3) // zlib + openssl + openssh
-
-	for _, result := range results {
-		s.False(result.Success)
-		s.Contains(result.Error, "Buildroot config not found")
-	}
+func (s *PackagesTestSuite) TestInstallPackagesMissing() {
+	results := s.manager.InstallPackages([]string{"openssh", "nonexistent"})
+	s.Len(results, 1)
+	s.Equal("nonexistent", results[0].Package)
+	s.False(results[0].Success)
+	s.Contains(results[0].Error, "package not found")
 }
 
 func (s *PackagesTestSuite) TestUninstallPackages() {
-	// Create a temporary directory for testing
-	tempDir := "/tmp/forge-test-buildroot-uninstall"
-	os.MkdirAll(tempDir, 0755)
-	defer os.RemoveAll(tempDir)
-
-	// Create a mock Buildroot config with packages enabled
-	configPath := filepath.Join(tempDir, ".config")
-	configContent := `# Test Buildroot config
-BR2_PACKAGE_BUSYBOX=y
-BR2_PACKAGE_OPENSSL=y
-BR2_PACKAGE_OPENSSH=y
-`
-	os.WriteFile(configPath, []byte(configContent), 0644)
+	s.config.Packages = []string{"fail2ban", "python3", "openssh"}
 
 	// Test uninstalling openssh
-	results := s.manager.UninstallPackages([]string{"openssh"}, tempDir)
+	results := s.manager.UninstallPackages([]string{"openssh"})
 	s.Len(results, 1)
-
 	s.Equal("openssh", results[0].Package)
 	s.True(results[0].Success)
 
-	// Check that the config was updated
-	updatedConfig, err := os.ReadFile(configPath)
-	s.NoError(err)
-	s.Contains(string(updatedConfig), "# BR2_PACKAGE_OPENSSH is not set")
+	// fail2ban needs python3, unless it is uninstalled too
+	results = s.manager.UninstallPackages([]string{"python3"})
+	s.False(results[0].Success)
+	s.Contains(results[0].Error, "needed by package fail2ban (fail2ban -> python3)")
+
+	results = s.manager.UninstallPackages([]string{"python3", "fail2ban"})
+	s.True(results[0].Success)
+	s.True(results[1].Success)
+
+	// Only packages in forge.yml can be uninstalled
+	results = s.manager.UninstallPackages([]string{"nginx", "nonexistent"})
+	s.Contains(results[0].Error, "package nginx is not in forge.yml")
+	s.Contains(results[1].Error, "package not found")
 }
 
 func (s *PackagesTestSuite) TestGetPackageServices() {
@@ -341,17 +332,13 @@ func (s *PackagesTestSuite) TestGetPackageServices() {
 }
 
 func (s *PackagesTestSuite) TestGeneratePackageConfig() {
-	tempDir := "/tmp/forge-test-config"
-	os.MkdirAll(tempDir, 0755)
-	defer os.RemoveAll(tempDir)
-
 	// Test config generation for different packages
-	sshConfigs, _ := s.manager.generatePackageConfig("openssh", tempDir)
+	sshConfigs := s.manager.generatePackageConfig("openssh")
 	s.Contains(sshConfigs, "/etc/ssh/sshd_config")
 
-	mqttConfigs, _ := s.manager.generatePackageConfig("mosquitto", tempDir)
+	mqttConfigs := s.manager.generatePackageConfig("mosquitto")
 	s.Contains(mqttConfigs, "/etc/mosquitto/mosquitto.conf")
 
-	nginxConfigs, _ := s.manager.generatePackageConfig("nginx", tempDir)
+	nginxConfigs := s.manager.generatePackageConfig("nginx")
 	s.Contains(nginxConfigs, "/etc/nginx/nginx.conf")
 }
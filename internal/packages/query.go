@@ -0,0 +1,165 @@
+package packages
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxPaths bounds the paths Why reports, as large dependency graphs have
+// very many
+const maxPaths = 50
+
+// ImageRoot is a package the project configuration enables directly
+type ImageRoot struct {
+	Package string
+	Source  string // The forge.yml setting, such as "package nginx" or "feature firewall"
+}
+
+// DependencyPath is a chain of dependencies from an image root to a package
+type DependencyPath struct {
+	Source   string
+	Packages []string // From the root package to the target
+}
+
+func (p DependencyPath) String() string {
+	return fmt.Sprintf("%s (%s)", p.Source, strings.Join(p.Packages, " -> "))
+}
+
+// ImageRoots returns the packages the configuration enables directly:
+// packages of its template, its packages and the packages of its features
+func (pm *PackageManager) ImageRoots() ([]ImageRoot, error) {
+	if pm.config == nil {
+		return nil, fmt.Errorf("no project configuration loaded")
+	}
+
+	// Only the packages forge.yml names are roots, not their dependencies
+	cfg := *pm.config
+	cfg.ResolvePackageDependencies = nil
+	defconfig, err := cfg.Defconfig()
+	if err != nil {
+		return nil, err
+	}
+
+	bySymbol := make(map[string]string)
+	for _, pkg := range pm.ListPackages() {
+		if _, ok := bySymbol[pkg.BuildrootPkg]; !ok {
+			bySymbol[pkg.BuildrootPkg] = pkg.Name
+		}
+	}
+
+	var roots []ImageRoot
+	for _, symbol := range defconfig.Symbols() {
+		if name, ok := bySymbol[symbol.Name]; ok && symbol.Value == "y" {
+			roots = append(roots, ImageRoot{Package: name, Source: symbol.Source})
+		}
+	}
+	return roots, nil
+}
+
+// ImagePackages returns the packages that end up in the image: the image
+// roots and everything they depend on, sorted
+func (pm *PackageManager) ImagePackages() ([]string, error) {
+	roots, err := pm.ImageRoots()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, root := range roots {
+		names = append(names, root.Package)
+	}
+	packages := pm.resolve(names).order
+	sort.Strings(packages)
+	return packages, nil
+}
+
+// Why returns the dependency paths from the packages the configuration
+// enables directly to a package. A package that is enabled directly has a
+// path of its own.
+func (pm *PackageManager) Why(name string) ([]DependencyPath, error) {
+	if !pm.IsValidPackage(name) {
+		return nil, fmt.Errorf("package %s not found", name)
+	}
+
+	roots, err := pm.ImageRoots()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, root := range roots {
+		names = append(names, root.Package)
+	}
+	r := pm.resolve(names)
+
+	var paths []DependencyPath
+	for _, root := range roots {
+		var walk func(path []string)
+		walk = func(path []string) {
+			current := path[len(path)-1]
+			if current == name {
+				paths = append(paths, DependencyPath{Source: root.Source, Packages: append([]string(nil), path...)})
+				return
+			}
+			for _, dep := range r.edges[current] {
+				if len(paths) >= maxPaths || contains(path, dep) {
+					continue
+				}
+				walk(append(path, dep))
+			}
+		}
+		walk([]string{root.Package})
+	}
+
+	return paths, nil
+}
+
+// ReverseDependencies returns the packages that depend on a package,
+// directly or through a virtual capability it provides. With recursive,
+// packages that depend on those are included too.
+func (pm *PackageManager) ReverseDependencies(name string, recursive bool) []string {
+	found := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 {
+		target := pm.packages[queue[0]]
+		queue = queue[1:]
+		if target == nil {
+			continue
+		}
+
+		for _, pkg := range pm.packages {
+			if pkg.Name == name || found[pkg.Name] || !pm.dependsOn(pkg, target) {
+				continue
+			}
+			found[pkg.Name] = true
+			if recursive {
+				queue = append(queue, pkg.Name)
+			}
+		}
+	}
+
+	var result []string
+	for pkg := range found {
+		result = append(result, pkg)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// dependsOn reports whether any alternative of a package's dependencies
+// can be satisfied by target
+func (pm *PackageManager) dependsOn(pkg, target *PackageInfo) bool {
+	for _, spec := range pkg.Dependencies {
+		requirement, err := ParseRequirement(spec)
+		if err != nil {
+			continue
+		}
+		for _, alt := range requirement.Alternatives {
+			if (alt.Name == target.Name || contains(target.Provides, alt.Name)) && alt.Allows(target.Version) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package packages
+
+import (
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueryTestSuite struct {
+	suite.Suite
+	manager *PackageManager
+}
+
+func TestQueryTestSuite(t *testing.T) {
+	suite.Run(t, new(QueryTestSuite))
+}
+
+func (s *QueryTestSuite) SetupTest() {
+	s.manager = NewPackageManager(&config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"fail2ban"},
+		Features:      []string{"firewall"},
+	})
+}
+
+func (s *QueryTestSuite) TestImageRoots() {
+	roots, err := s.manager.ImageRoots()
+	s.Require().NoError(err)
+	s.Equal([]ImageRoot{
+		{Package: "busybox", Source: "template minimal"},
+		{Package: "fail2ban", Source: "package fail2ban"},
+		{Package: "iptables", Source: "feature firewall"},
+	}, roots)
+}
+
+func (s *QueryTestSuite) TestImagePackages() {
+	packages, err := s.manager.ImagePackages()
+	s.Require().NoError(err)
+	s.Equal([]string{"busybox", "expat", "fail2ban", "iptables", "libffi", "python3"}, packages)
+}
+
+func (s *QueryTestSuite) TestImageRootsWithoutConfig() {
+	_, err := NewPackageManager(nil).ImageRoots()
+	s.Error(err)
+}
+
+func (s *QueryTestSuite) TestWhy() {
+	paths, err := s.manager.Why("libffi")
+	s.Require().NoError(err)
+	s.Equal([]DependencyPath{
+		{Source: "package fail2ban", Packages: []string{"fail2ban", "python3", "libffi"}},
+	}, paths)
+
+	// Every path is reported, including the package being enabled directly
+	paths, err = s.manager.Why("iptables")
+	s.Require().NoError(err)
+	s.Equal([]DependencyPath{
+		{Source: "package fail2ban", Packages: []string{"fail2ban", "iptables"}},
+		{Source: "feature firewall", Packages: []string{"iptables"}},
+	}, paths)
+
+	// Packages that are not in the image have no paths
+	paths, err = s.manager.Why("nginx")
+	s.Require().NoError(err)
+	s.Empty(paths)
+
+	_, err = s.manager.Why("nonexistent")
+	s.Error(err)
+	s.Contains(err.Error(), "package nonexistent not found")
+}
+
+func (s *QueryTestSuite) TestReverseDependencies() {
+	s.Equal([]string{"fail2ban"}, s.manager.ReverseDependencies("python3", false))
+	s.Equal([]string{"dropbear", "libwebsockets", "nginx", "openssh"}, s.manager.ReverseDependencies("zlib", false))
+	s.Empty(s.manager.ReverseDependencies("fail2ban", false))
+
+	s.Equal([]string{"dbus", "fail2ban", "python3", "wpa_supplicant"}, s.manager.ReverseDependencies("expat", true))
+}
+
+func (s *QueryTestSuite) TestReverseDependenciesThroughProvides() {
+	s.manager.AddDefinitions(SourceProject, []*PackageInfo{
+		{Name: "remote-shell", Dependencies: []string{"ssh-server"}},
+		{Name: "legacy-shell", Dependencies: []string{"openssh<8.0"}},
+	})
+
+	s.Equal([]string{"remote-shell"}, s.manager.ReverseDependencies("openssh", false))
+	s.Equal([]string{"remote-shell"}, s.manager.ReverseDependencies("dropbear", false))
+}
@@ -0,0 +1,107 @@
+package packages
+
+import (
+	"fmt"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/sst/forge/internal/features"
+)
+
+// Removal is the effect of removing a package or feature from forge.yml
+type Removal struct {
+	Dependents []DependencyPath // Paths by which other forge.yml entries need what is removed
+	Orphans    []string         // forge.yml packages that nothing needs once it is removed
+}
+
+// PlanPackageRemoval works out what removing a package from forge.yml
+// affects
+func (pm *PackageManager) PlanPackageRemoval(name string) (*Removal, error) {
+	if pm.config == nil {
+		return nil, fmt.Errorf("no project configuration loaded")
+	}
+	if !contains(pm.config.Packages, name) {
+		return nil, fmt.Errorf("package %s is not in forge.yml", name)
+	}
+
+	without := *pm.config
+	without.Packages = remove(pm.config.Packages, name)
+	return pm.planRemoval("package "+name, []string{name}, &without)
+}
+
+// PlanFeatureRemoval works out what removing a feature from forge.yml
+// affects. Other entries depend on a feature when they need a package it
+// enables.
+func (pm *PackageManager) PlanFeatureRemoval(name string) (*Removal, error) {
+	if pm.config == nil {
+		return nil, fmt.Errorf("no project configuration loaded")
+	}
+	if !contains(pm.config.Features, name) {
+		return nil, fmt.Errorf("feature %s is not in forge.yml", name)
+	}
+
+	var targets []string
+	if feature, err := features.NewFeatureManager().GetFeatureInfo(name); err == nil {
+		targets = feature.Packages
+	}
+
+	without := *pm.config
+	without.Features = remove(pm.config.Features, name)
+	return pm.planRemoval("feature "+name, targets, &without)
+}
+
+// planRemoval finds the paths from other image roots through the packages
+// an entry enables, and the forge.yml packages that were only needed by
+// them
+func (pm *PackageManager) planRemoval(source string, targets []string, without *config.Config) (*Removal, error) {
+	removal := &Removal{}
+	for _, target := range targets {
+		if !pm.IsValidPackage(target) {
+			continue
+		}
+		paths, err := pm.Why(target)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			// Entries that enable the package themselves don't depend on it
+			if path.Source != source && len(path.Packages) > 1 {
+				removal.Dependents = append(removal.Dependents, path)
+			}
+		}
+	}
+
+	after := *pm
+	after.config = without
+	needed := pm.resolve(targets).order
+	for _, name := range without.Packages {
+		if contains(targets, name) || !contains(needed, name) {
+			continue
+		}
+		paths, err := after.Why(name)
+		if err != nil {
+			return nil, err
+		}
+		orphan := true
+		for _, path := range paths {
+			if path.Source != "package "+name {
+				orphan = false
+			}
+		}
+		if orphan {
+			removal.Orphans = append(removal.Orphans, name)
+		}
+	}
+
+	return removal, nil
+}
+
+// remove returns a copy of slice without item
+func remove(slice []string, item string) []string {
+	var result []string
+	for _, s := range slice {
+		if s != item {
+			result = append(result, s)
+		}
+	}
+	return result
+}
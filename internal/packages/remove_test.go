@@ -0,0 +1,73 @@
+package packages
+
+import (
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type RemoveTestSuite struct {
+	suite.Suite
+	manager *PackageManager
+}
+
+func TestRemoveTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoveTestSuite))
+}
+
+func (s *RemoveTestSuite) SetupTest() {
+	s.manager = NewPackageManager(&config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"fail2ban", "python3", "nginx", "openssl"},
+		Features:      []string{"firewall"},
+	})
+}
+
+func (s *RemoveTestSuite) TestPackageWithDependents() {
+	removal, err := s.manager.PlanPackageRemoval("python3")
+	s.Require().NoError(err)
+	s.Equal([]DependencyPath{
+		{Source: "package fail2ban", Packages: []string{"fail2ban", "python3"}},
+	}, removal.Dependents)
+	s.Empty(removal.Orphans)
+}
+
+func (s *RemoveTestSuite) TestPackageOrphans() {
+	removal, err := s.manager.PlanPackageRemoval("nginx")
+	s.Require().NoError(err)
+	s.Empty(removal.Dependents)
+	s.Equal([]string{"openssl"}, removal.Orphans)
+
+	removal, err = s.manager.PlanPackageRemoval("fail2ban")
+	s.Require().NoError(err)
+	s.Empty(removal.Dependents)
+	s.Equal([]string{"python3"}, removal.Orphans)
+}
+
+func (s *RemoveTestSuite) TestFeatureRemoval() {
+	// fail2ban needs iptables, which the firewall feature enables
+	removal, err := s.manager.PlanFeatureRemoval("firewall")
+	s.Require().NoError(err)
+	s.Equal([]DependencyPath{
+		{Source: "package fail2ban", Packages: []string{"fail2ban", "iptables"}},
+	}, removal.Dependents)
+	s.Empty(removal.Orphans)
+}
+
+func (s *RemoveTestSuite) TestNotInConfig() {
+	_, err := s.manager.PlanPackageRemoval("dropbear")
+	s.Error(err)
+	s.Contains(err.Error(), "package dropbear is not in forge.yml")
+
+	_, err = s.manager.PlanFeatureRemoval("monitoring")
+	s.Error(err)
+	s.Contains(err.Error(), "feature monitoring is not in forge.yml")
+
+	_, err = NewPackageManager(nil).PlanPackageRemoval("nginx")
+	s.Error(err)
+}
@@ -0,0 +1,392 @@
+package packages
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sst/forge/internal/config"
+)
+
+// Constraint is one acceptable choice for a dependency: a package or a
+// virtual capability, optionally limited to some versions
+type Constraint struct {
+	Name    string
+	Op      string // =, !=, <, <=, > or >=, empty for any version
+	Version string
+}
+
+func (c Constraint) String() string {
+	return c.Name + c.Op + c.Version
+}
+
+// Allows reports whether a package version meets the constraint. Packages
+// of unknown version are assumed to.
+func (c Constraint) Allows(version string) bool {
+	if c.Op == "" || version == "" {
+		return true
+	}
+
+	cmp := CompareVersions(version, c.Version)
+	switch c.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// Requirement is a parsed dependency such as "openssl>=3.0" or
+// "dropbear | openssh". Alternatives are in order of preference.
+type Requirement struct {
+	Alternatives []Constraint
+}
+
+func (r Requirement) String() string {
+	var parts []string
+	for _, alt := range r.Alternatives {
+		parts = append(parts, alt.String())
+	}
+	return strings.Join(parts, " | ")
+}
+
+// ParseRequirement parses a dependency
+func ParseRequirement(spec string) (Requirement, error) {
+	var requirement Requirement
+	for _, part := range strings.Split(spec, "|") {
+		part = strings.TrimSpace(part)
+		end := strings.IndexAny(part, "=!<>")
+		if end < 0 {
+			end = len(part)
+		}
+
+		constraint := Constraint{Name: strings.TrimSpace(part[:end])}
+		rest := part[end:]
+		for _, op := range []string{">=", "<=", "!=", "=", "<", ">"} {
+			if strings.HasPrefix(rest, op) {
+				constraint.Op = op
+				constraint.Version = strings.TrimSpace(rest[len(op):])
+				break
+			}
+		}
+
+		switch {
+		case constraint.Name == "":
+			return Requirement{}, fmt.Errorf("invalid dependency %q: missing package name", spec)
+		case rest != "" && (constraint.Op == "" || constraint.Version == ""):
+			return Requirement{}, fmt.Errorf("invalid dependency %q: expected a version after %s", spec, constraint.Name)
+		}
+		requirement.Alternatives = append(requirement.Alternatives, constraint)
+	}
+	return requirement, nil
+}
+
+// CompareVersions compares two versions such as 3.1.4 and 3.10, returning
+// -1, 0 or 1. Numeric parts compare as numbers, other parts as text, and a
+// version that is a prefix of another is older.
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		var cmp int
+		if errA == nil && errB == nil {
+			cmp = na - nb
+		} else {
+			cmp = strings.Compare(pa[i], pb[i])
+		}
+		if cmp < 0 {
+			return -1
+		}
+		if cmp > 0 {
+			return 1
+		}
+	}
+	switch {
+	case len(pa) < len(pb):
+		return -1
+	case len(pa) > len(pb):
+		return 1
+	}
+	return 0
+}
+
+// versionParts splits a version into runs of digits and of letters
+func versionParts(version string) []string {
+	var parts []string
+	var current strings.Builder
+	digits := false
+	for _, r := range version {
+		if !unicode.IsDigit(r) && !unicode.IsLetter(r) {
+			if current.Len() > 0 {
+				parts = append(parts, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		if current.Len() > 0 && unicode.IsDigit(r) != digits {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+		digits = unicode.IsDigit(r)
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// preferredProviders orders the packages that provide a virtual capability
+// when nothing else decides between them
+var preferredProviders = map[string][]string{
+	"ssh-server": {"dropbear", "openssh"},
+}
+
+// providers returns the packages that can satisfy a name: the package of
+// that name, then the packages that provide it in order of preference
+func (pm *PackageManager) providers(name string) []*PackageInfo {
+	var result []*PackageInfo
+	if pkg, ok := pm.packages[name]; ok {
+		result = append(result, pkg)
+	}
+
+	var providing []*PackageInfo
+	for _, pkg := range pm.packages {
+		if pkg.Name != name && contains(pkg.Provides, name) {
+			providing = append(providing, pkg)
+		}
+	}
+	rank := func(pkg *PackageInfo) int {
+		for i, preferred := range preferredProviders[name] {
+			if preferred == pkg.Name {
+				return i
+			}
+		}
+		return len(preferredProviders[name])
+	}
+	sort.Slice(providing, func(i, j int) bool {
+		ri, rj := rank(providing[i]), rank(providing[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return providing[i].Name < providing[j].Name
+	})
+
+	return append(result, providing...)
+}
+
+// resolver selects the packages a set of requested packages needs,
+// remembering which dependency pulled in each one
+type resolver struct {
+	pm            *PackageManager
+	order         []string            // Selected packages, requested ones first
+	requiredBy    map[string]string   // Package -> package whose dependency selected it
+	edges         map[string][]string // Package -> selected dependencies
+	unsatisfiable []string
+}
+
+// resolve selects the requested packages and, breadth first, everything
+// they depend on. Unknown requested packages are skipped.
+func (pm *PackageManager) resolve(packageNames []string) *resolver {
+	r := &resolver{
+		pm:         pm,
+		requiredBy: make(map[string]string),
+		edges:      make(map[string][]string),
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range packageNames {
+		if pm.IsValidPackage(name) && !selected[name] {
+			selected[name] = true
+			r.order = append(r.order, name)
+		}
+	}
+
+	for i := 0; i < len(r.order); i++ {
+		name := r.order[i]
+		for _, spec := range pm.packages[name].Dependencies {
+			requirement, err := ParseRequirement(spec)
+			if err != nil {
+				r.unsatisfiable = append(r.unsatisfiable, fmt.Sprintf("%s has an %v (%s)", name, err, r.explain(name)))
+				continue
+			}
+
+			chosen, problem := r.choose(requirement, selected)
+			if chosen == "" {
+				r.unsatisfiable = append(r.unsatisfiable, fmt.Sprintf("%s requires %s, but %s (%s)", name, requirement, problem, r.explain(name)))
+				continue
+			}
+
+			r.edges[name] = append(r.edges[name], chosen)
+			if !selected[chosen] {
+				selected[chosen] = true
+				r.requiredBy[chosen] = name
+				r.order = append(r.order, chosen)
+			}
+		}
+	}
+
+	return r
+}
+
+// PackageDependencies returns the packages that the named packages pull in
+// through their dependencies, in the order they were resolved, each with
+// the package that needed it. Unknown packages are skipped. It fails when a
+// dependency can't be met, so the defconfig doesn't ask Buildroot for a
+// package it would drop.
+func (pm *PackageManager) PackageDependencies(packageNames []string) ([]config.PackageDependency, error) {
+	r := pm.resolve(packageNames)
+	if len(r.unsatisfiable) > 0 {
+		return nil, fmt.Errorf("unmet package dependencies: %s", strings.Join(r.unsatisfiable, "; "))
+	}
+
+	var dependencies []config.PackageDependency
+	for _, name := range r.order {
+		if parent, ok := r.requiredBy[name]; ok {
+			dependencies = append(dependencies, config.PackageDependency{Package: name, RequiredBy: parent})
+		}
+	}
+	return dependencies, nil
+}
+
+// choose picks the package that satisfies a requirement: an already
+// selected one if possible, else the first by preference. It explains why
+// when nothing does.
+func (r *resolver) choose(requirement Requirement, selected map[string]bool) (string, string) {
+	var candidates []string
+	var problems []string
+	for _, alt := range requirement.Alternatives {
+		providers := r.pm.providers(alt.Name)
+		if len(providers) == 0 {
+			problems = append(problems, fmt.Sprintf("no package provides %s", alt.Name))
+			continue
+		}
+		for _, pkg := range providers {
+			if alt.Allows(pkg.Version) {
+				candidates = append(candidates, pkg.Name)
+			} else {
+				problems = append(problems, fmt.Sprintf("%s is version %s", pkg.Name, pkg.Version))
+			}
+		}
+	}
+
+	for _, name := range candidates {
+		if selected[name] {
+			return name, ""
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0], ""
+	}
+	return "", strings.Join(problems, " and ")
+}
+
+// chain returns the packages from a requested package down to name
+func (r *resolver) chain(name string) []string {
+	chain := []string{name}
+	for parent, ok := r.requiredBy[name]; ok; parent, ok = r.requiredBy[parent] {
+		chain = append([]string{parent}, chain...)
+	}
+	return chain
+}
+
+// explain describes why a package was selected
+func (r *resolver) explain(name string) string {
+	chain := r.chain(name)
+	if len(chain) == 1 {
+		return name + " was requested"
+	}
+	return "pulled in by " + strings.Join(chain, " -> ")
+}
+
+// conflicts reports selected packages that conflict with each other, with
+// the chains that pulled both in
+func (r *resolver) conflicts() []string {
+	var conflicts []string
+	reported := make(map[string]bool)
+	for _, name := range r.order {
+		for _, conflict := range r.pm.packages[name].Conflicts {
+			for _, other := range r.order {
+				otherPkg := r.pm.packages[other]
+				if other == name || (other != conflict && !contains(otherPkg.Provides, conflict)) {
+					continue
+				}
+				pair := [2]string{name, other}
+				sort.Strings(pair[:])
+				if reported[pair[0]+" "+pair[1]] {
+					continue
+				}
+				reported[pair[0]+" "+pair[1]] = true
+				conflicts = append(conflicts, fmt.Sprintf("%s conflicts with %s (%s; %s)", name, other, r.explain(name), r.explain(other)))
+			}
+		}
+	}
+	return conflicts
+}
+
+// installOrder sorts the selected packages so dependencies come first. It
+// returns the packages of a dependency cycle instead if there is one.
+func (r *resolver) installOrder() ([]string, []string) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var order, stack, cycle []string
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			for i, entry := range stack {
+				if entry == name {
+					cycle = append(append([]string(nil), stack[i:]...), name)
+				}
+			}
+			return false
+		case done:
+			return true
+		}
+
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range r.edges[name] {
+			if !visit(dep) {
+				return false
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		order = append(order, name)
+		return true
+	}
+
+	for _, name := range r.order {
+		if !visit(name) {
+			return nil, cycle
+		}
+	}
+	return order, nil
+}
+
+// contains reports whether a slice contains an item
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package packages
+
+import (
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolveTestSuite struct {
+	suite.Suite
+	manager *PackageManager
+}
+
+func TestResolveTestSuite(t *testing.T) {
+	suite.Run(t, new(ResolveTestSuite))
+}
+
+func (s *ResolveTestSuite) SetupTest() {
+	s.manager = NewPackageManager(nil)
+}
+
+func (s *ResolveTestSuite) define(packages ...*PackageInfo) {
+	s.manager.AddDefinitions(SourceProject, packages)
+}
+
+func (s *ResolveTestSuite) TestParseRequirement() {
+	requirement, err := ParseRequirement("openssl>=3.0")
+	s.Require().NoError(err)
+	s.Equal([]Constraint{{Name: "openssl", Op: ">=", Version: "3.0"}}, requirement.Alternatives)
+
+	requirement, err = ParseRequirement("dropbear | openssh > 9")
+	s.Require().NoError(err)
+	s.Equal([]Constraint{{Name: "dropbear"}, {Name: "openssh", Op: ">", Version: "9"}}, requirement.Alternatives)
+	s.Equal("dropbear | openssh>9", requirement.String())
+
+	_, err = ParseRequirement(">=1.0")
+	s.Error(err)
+	s.Contains(err.Error(), "missing package name")
+
+	_, err = ParseRequirement("openssl>=")
+	s.Error(err)
+	s.Contains(err.Error(), "expected a version after openssl")
+}
+
+func (s *ResolveTestSuite) TestCompareVersions() {
+	s.Equal(0, CompareVersions("3.1.4", "3.1.4"))
+	s.Equal(-1, CompareVersions("3.9", "3.10"))
+	s.Equal(1, CompareVersions("2.0.18", "2.0.9"))
+	s.Equal(-1, CompareVersions("1.0", "1.0.1"))
+	s.Equal(1, CompareVersions("9.3p2", "9.3p1"))
+	s.Equal(1, CompareVersions("2022.83", "2020.81"))
+}
+
+func (s *ResolveTestSuite) TestConstraintAllows() {
+	s.True(Constraint{Name: "openssl", Op: ">=", Version: "3.0"}.Allows("3.1.4"))
+	s.False(Constraint{Name: "openssl", Op: "<", Version: "3.0"}.Allows("3.1.4"))
+	s.True(Constraint{Name: "openssl", Op: "!=", Version: "3.0"}.Allows("3.1.4"))
+	s.True(Constraint{Name: "openssl"}.Allows("3.1.4"))
+	s.True(Constraint{Name: "openssl", Op: "=", Version: "3.0"}.Allows(""))
+}
+
+func (s *ResolveTestSuite) TestVersionConstraint() {
+	s.define(&PackageInfo{Name: "app", Dependencies: []string{"openssl>=3.0", "zlib"}})
+
+	result := s.manager.ValidatePackageSet([]string{"app"})
+	s.Empty(result.Unsatisfiable)
+	s.Equal([]string{"openssl", "zlib", "app"}, result.Packages)
+	s.Equal("app", result.RequiredBy["openssl"])
+}
+
+func (s *ResolveTestSuite) TestUnsatisfiableConstraint() {
+	s.define(
+		&PackageInfo{Name: "gateway", Dependencies: []string{"legacy-agent"}},
+		&PackageInfo{Name: "legacy-agent", Dependencies: []string{"openssl<3.0"}},
+	)
+
+	result := s.manager.ValidatePackageSet([]string{"gateway"})
+	s.Equal([]string{
+		"legacy-agent requires openssl<3.0, but openssl is version 3.1.4 (pulled in by gateway -> legacy-agent)",
+	}, result.Unsatisfiable)
+
+	results := s.manager.InstallPackages([]string{"gateway"})
+	s.Require().Len(results, 1)
+	s.False(results[0].Success)
+	s.Contains(results[0].Error, "legacy-agent requires openssl<3.0")
+}
+
+func (s *ResolveTestSuite) TestUnknownDependency() {
+	s.define(&PackageInfo{Name: "app", Dependencies: []string{"mqtt-broker | ssh-client"}})
+
+	result := s.manager.ValidatePackageSet([]string{"app"})
+	s.Equal([]string{
+		"app requires mqtt-broker | ssh-client, but no package provides mqtt-broker and no package provides ssh-client (app was requested)",
+	}, result.Unsatisfiable)
+}
+
+func (s *ResolveTestSuite) TestVirtualPackages() {
+	s.define(&PackageInfo{Name: "remote-shell", Dependencies: []string{"ssh-server"}})
+
+	// dropbear is the preferred ssh-server
+	result := s.manager.ValidatePackageSet([]string{"remote-shell"})
+	s.Equal([]string{"zlib", "dropbear", "remote-shell"}, result.Packages)
+
+	// A provider that is already selected wins
+	result = s.manager.ValidatePackageSet([]string{"openssh", "remote-shell"})
+	s.NotContains(result.Packages, "dropbear")
+	s.Contains(result.Packages, "openssh")
+}
+
+func (s *ResolveTestSuite) TestAlternatives() {
+	s.define(&PackageInfo{Name: "broker-client", Dependencies: []string{"mosquitto>=3.0 | libwebsockets"}})
+
+	// mosquitto is preferred but too old
+	result := s.manager.ValidatePackageSet([]string{"broker-client"})
+	s.Empty(result.Unsatisfiable)
+	s.Contains(result.Packages, "libwebsockets")
+	s.NotContains(result.Packages, "mosquitto")
+}
+
+func (s *ResolveTestSuite) TestConflictChains() {
+	s.define(
+		&PackageInfo{Name: "fleet-agent", Dependencies: []string{"openssh"}},
+		&PackageInfo{Name: "tiny-ssh", Conflicts: []string{"ssh-server"}},
+	)
+
+	result := s.manager.ValidatePackageSet([]string{"tiny-ssh", "fleet-agent"})
+	s.Equal([]string{
+		"tiny-ssh conflicts with openssh (tiny-ssh was requested; pulled in by fleet-agent -> openssh)",
+	}, result.Conflicts)
+}
+
+func (s *ResolveTestSuite) TestCircularDependencies() {
+	s.define(
+		&PackageInfo{Name: "a", Dependencies: []string{"b"}},
+		&PackageInfo{Name: "b", Dependencies: []string{"c"}},
+		&PackageInfo{Name: "c", Dependencies: []string{"a"}},
+	)
+
+	result := s.manager.ResolveDependencies([]string{"a"})
+	s.Equal([]string{"a", "b", "c", "a"}, result.Circular)
+	s.Empty(result.Packages)
+}
+
+func (s *ResolveTestSuite) TestDefconfigEnablesDependencies() {
+	cfg := &config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test",
+		Version:       "1.0.0",
+		Architecture:  "x86_64",
+		Packages:      []string{"gateway"},
+	}
+	s.manager = NewPackageManager(cfg)
+	s.define(
+		&PackageInfo{Name: "gateway", Dependencies: []string{"mqtt-client"}, BuildrootPkg: "BR2_PACKAGE_GATEWAY"},
+		&PackageInfo{Name: "mqtt-client", Dependencies: []string{"openssl"}, BuildrootPkg: "BR2_PACKAGE_PAHO_MQTT_C"},
+	)
+
+	defconfig, err := cfg.Defconfig()
+	s.Require().NoError(err)
+	symbol, ok := defconfig.Get("BR2_PACKAGE_PAHO_MQTT_C")
+	s.Require().True(ok)
+	s.Equal(config.DefconfigSymbol{Name: "BR2_PACKAGE_PAHO_MQTT_C", Value: "y", Source: "dependency of gateway"}, symbol)
+	symbol, ok = defconfig.Get("BR2_PACKAGE_OPENSSL")
+	s.Require().True(ok)
+	s.Equal("dependency of mqtt-client", symbol.Source)
+
+	// Dependencies are not roots of the image
+	roots, err := s.manager.ImageRoots()
+	s.Require().NoError(err)
+	s.Equal([]ImageRoot{{Package: "gateway", Source: "package gateway"}}, roots)
+
+	// A dependency Buildroot can't meet fails the defconfig up front
+	s.define(&PackageInfo{Name: "mqtt-client", Dependencies: []string{"openssl<3.0"}})
+	_, err = cfg.Defconfig()
+	s.Error(err)
+	s.Contains(err.Error(), "unmet package dependencies: mqtt-client requires openssl<3.0")
+}
@@ -0,0 +1,100 @@
+package packages
+
+import (
+	"sort"
+	"strings"
+)
+
+// Search scores, from best to worst way for a query word to match
+const (
+	scoreExactName   = 100
+	scoreNamePrefix  = 80
+	scoreName        = 60
+	scoreSymbol      = 50
+	scoreCategory    = 40
+	scoreDescription = 30
+	scoreTypo        = 20
+	scoreAbbrev      = 10
+)
+
+// SearchResult is a package that matches a search
+type SearchResult struct {
+	Package *PackageInfo
+	Score   int // Higher is a better match
+}
+
+// Search ranks the packages matching a query by name, Buildroot symbol,
+// category and description, ignoring case. Every word of the query has to
+// match; names also match with a typo or as an abbreviation such as
+// "wpasup". A category restricts the results to it, and with an empty
+// query all its packages are returned.
+func (pm *PackageManager) Search(query, category string) []SearchResult {
+	words := strings.Fields(strings.ToLower(query))
+
+	var results []SearchResult
+	for _, pkg := range pm.ListPackages() {
+		if category != "" && pkg.Category != category {
+			continue
+		}
+
+		total := 0
+		for _, word := range words {
+			score := matchScore(pkg, word)
+			if score == 0 {
+				total = 0
+				break
+			}
+			total += score
+		}
+		if total > 0 || len(words) == 0 {
+			results = append(results, SearchResult{Package: pkg, Score: total})
+		}
+	}
+
+	// ListPackages is sorted by name, which breaks ties
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// matchScore returns how well one query word matches a package, 0 if it
+// does not
+func matchScore(pkg *PackageInfo, word string) int {
+	name := strings.ToLower(pkg.Name)
+	switch {
+	case name == word:
+		return scoreExactName
+	case strings.HasPrefix(name, word):
+		return scoreNamePrefix
+	case strings.Contains(name, word):
+		return scoreName
+	case strings.Contains(strings.ToLower(pkg.BuildrootPkg), word):
+		return scoreSymbol
+	case pkg.Category == word || contains(strings.Split(pkg.Category, "-"), word):
+		return scoreCategory
+	case strings.Contains(strings.ToLower(pkg.Description), word):
+		return scoreDescription
+	}
+
+	if len(word) >= 4 {
+		if distance := editDistance(word, name); distance <= len(word)/4+1 {
+			return scoreTypo - distance
+		}
+	}
+	if len(word) >= 3 && isSubsequence(word, name) {
+		return scoreAbbrev
+	}
+	return 0
+}
+
+// isSubsequence reports whether the characters of a appear in b in order
+func isSubsequence(a, b string) bool {
+	i := 0
+	for j := 0; i < len(a) && j < len(b); j++ {
+		if a[i] == b[j] {
+			i++
+		}
+	}
+	return i == len(a)
+}
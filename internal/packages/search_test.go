@@ -0,0 +1,65 @@
+package packages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SearchTestSuite struct {
+	suite.Suite
+	manager *PackageManager
+}
+
+func TestSearchTestSuite(t *testing.T) {
+	suite.Run(t, new(SearchTestSuite))
+}
+
+func (s *SearchTestSuite) SetupTest() {
+	s.manager = NewPackageManager(nil)
+}
+
+func (s *SearchTestSuite) names(results []SearchResult) []string {
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Package.Name)
+	}
+	return names
+}
+
+func (s *SearchTestSuite) TestRanking() {
+	// An exact name beats a close one
+	results := s.manager.Search("openssl", "")
+	s.Equal([]string{"openssl", "openssh"}, s.names(results))
+	s.Equal(scoreExactName, results[0].Score)
+
+	// Name matches beat description matches
+	s.Equal([]string{"libffi", "libwebsockets", "zlib", "expat", "lzo"}, s.names(s.manager.Search("lib", "")))
+}
+
+func (s *SearchTestSuite) TestFields() {
+	// Buildroot symbol
+	s.Equal([]string{"wpa_supplicant"}, s.names(s.manager.Search("BR2_PACKAGE_WPA", "")))
+
+	// Category
+	s.Contains(s.names(s.manager.Search("security", "")), "openssl")
+
+	// Every word has to match
+	s.Equal([]string{"mosquitto"}, s.names(s.manager.Search("mqtt broker", "")))
+}
+
+func (s *SearchTestSuite) TestFuzzy() {
+	s.Equal("wpa_supplicant", s.names(s.manager.Search("wpa_suplicant", ""))[0])
+	s.Equal("wpa_supplicant", s.names(s.manager.Search("wpasup", ""))[0])
+	s.Empty(s.manager.Search("kubernetes", ""))
+}
+
+func (s *SearchTestSuite) TestCategory() {
+	results := s.manager.Search("", "security")
+	s.NotEmpty(results)
+	for _, result := range results {
+		s.Equal("security", result.Package.Category)
+	}
+
+	s.Empty(s.manager.Search("nginx", "security"))
+}
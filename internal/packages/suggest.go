@@ -0,0 +1,75 @@
+package packages
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions bounds the close matches Suggest returns
+const maxSuggestions = 3
+
+// Suggest returns the package names closest to a name that does not exist,
+// best first, for "did you mean" hints. Names within a few edits of it and
+// names that contain it are considered.
+func (pm *PackageManager) Suggest(name string) []string {
+	name = strings.ToLower(name)
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for candidate := range pm.packages {
+		distance := editDistance(name, strings.ToLower(candidate))
+		if distance > maxDistance && !strings.Contains(candidate, name) {
+			continue
+		}
+		matches = append(matches, match{candidate, distance})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	var suggestions []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, matches[i].name)
+	}
+	return suggestions
+}
+
+// editDistance returns the Levenshtein distance between two strings,
+// counting a swap of adjacent characters as one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d := min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d = min(d, rows[i-2][j-2]+1)
+			}
+			rows[i][j] = d
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
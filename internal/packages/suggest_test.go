@@ -0,0 +1,37 @@
+package packages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SuggestTestSuite struct {
+	suite.Suite
+	manager *PackageManager
+}
+
+func TestSuggestTestSuite(t *testing.T) {
+	suite.Run(t, new(SuggestTestSuite))
+}
+
+func (s *SuggestTestSuite) SetupTest() {
+	s.manager = NewPackageManager(nil)
+}
+
+func (s *SuggestTestSuite) TestSuggest() {
+	s.Equal("wpa_supplicant", s.manager.Suggest("wpa_suplicant")[0])
+	s.Equal("nginx", s.manager.Suggest("ngnix")[0])
+	s.Equal("openssl", s.manager.Suggest("OpenSSL")[0])
+	s.Equal("wpa_supplicant", s.manager.Suggest("supplicant")[0])
+	s.Empty(s.manager.Suggest("kubernetes"))
+	s.LessOrEqual(len(s.manager.Suggest("lib")), maxSuggestions)
+}
+
+func (s *SuggestTestSuite) TestEditDistance() {
+	s.Equal(0, editDistance("nginx", "nginx"))
+	s.Equal(1, editDistance("nginx", "ngnix"))
+	s.Equal(1, editDistance("zlib", "zli"))
+	s.Equal(2, editDistance("dropbear", "drpobaer"))
+	s.Equal(3, editDistance("", "abc"))
+}
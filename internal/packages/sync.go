@@ -0,0 +1,62 @@
+package packages
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sst/forge/internal/config"
+)
+
+// Drift is the difference between the packages forge.yml declares and the
+// ones a Buildroot .config enables
+type Drift struct {
+	Missing []ImageRoot // Packages forge.yml enables that the .config does not
+	Extra   []string    // Known packages the .config enables that nothing in forge.yml needs
+}
+
+// Empty reports whether forge.yml and the .config agree
+func (d *Drift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0
+}
+
+// CheckDrift compares the packages of the image with a Buildroot .config,
+// such as one edited with menuconfig or by an older forge. Packages
+// Buildroot selects on its own are only known when the Buildroot catalog
+// is loaded.
+func (pm *PackageManager) CheckDrift(configPath string) (*Drift, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", configPath, err)
+	}
+	enabled := config.ParseConfigSymbols(data)
+
+	roots, err := pm.ImageRoots()
+	if err != nil {
+		return nil, err
+	}
+	image, err := pm.ImagePackages()
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &Drift{}
+	for _, root := range roots {
+		if enabled[pm.packages[root.Package].BuildrootPkg] != "y" {
+			drift.Missing = append(drift.Missing, root)
+		}
+	}
+
+	// Several packages can share a symbol, so compare by symbol
+	needed := make(map[string]bool)
+	for _, name := range image {
+		needed[pm.packages[name].BuildrootPkg] = true
+	}
+	for _, pkg := range pm.ListPackages() {
+		if enabled[pkg.BuildrootPkg] == "y" && !needed[pkg.BuildrootPkg] {
+			needed[pkg.BuildrootPkg] = true
+			drift.Extra = append(drift.Extra, pkg.Name)
+		}
+	}
+
+	return drift, nil
+}
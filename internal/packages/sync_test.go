@@ -0,0 +1,70 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/forge/internal/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type SyncTestSuite struct {
+	suite.Suite
+	manager    *PackageManager
+	configPath string
+}
+
+func TestSyncTestSuite(t *testing.T) {
+	suite.Run(t, new(SyncTestSuite))
+}
+
+func (s *SyncTestSuite) SetupTest() {
+	s.manager = NewPackageManager(&config.Config{
+		SchemaVersion: "1.0",
+		Name:          "test-project",
+		Version:       "0.1.0",
+		Architecture:  "x86_64",
+		Template:      "minimal",
+		Packages:      []string{"nginx"},
+	})
+	s.configPath = filepath.Join(s.T().TempDir(), ".config")
+}
+
+func (s *SyncTestSuite) writeConfig(content string) {
+	s.Require().NoError(os.WriteFile(s.configPath, []byte(content), 0644))
+}
+
+func (s *SyncTestSuite) TestInSync() {
+	// Dependencies Buildroot selected are not drift
+	s.writeConfig(`BR2_PACKAGE_BUSYBOX=y
+BR2_PACKAGE_NGINX=y
+BR2_PACKAGE_OPENSSL=y
+BR2_PACKAGE_ZLIB=y
+BR2_PACKAGE_HOST_CMAKE=y
+`)
+
+	drift, err := s.manager.CheckDrift(s.configPath)
+	s.Require().NoError(err)
+	s.True(drift.Empty())
+}
+
+func (s *SyncTestSuite) TestDrift() {
+	// nginx was disabled and mosquitto enabled by hand
+	s.writeConfig(`BR2_PACKAGE_BUSYBOX=y
+# BR2_PACKAGE_NGINX is not set
+BR2_PACKAGE_MOSQUITTO=y
+`)
+
+	drift, err := s.manager.CheckDrift(s.configPath)
+	s.Require().NoError(err)
+	s.False(drift.Empty())
+	s.Equal([]ImageRoot{{Package: "nginx", Source: "package nginx"}}, drift.Missing)
+	s.Equal([]string{"mosquitto"}, drift.Extra)
+}
+
+func (s *SyncTestSuite) TestMissingConfig() {
+	_, err := s.manager.CheckDrift(s.configPath)
+	s.Error(err)
+	s.Contains(err.Error(), "failed to read")
+}
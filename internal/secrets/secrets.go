@@ -0,0 +1,286 @@
+package secrets
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultFile is the project-local encrypted secrets file, next to forge.yml
+	DefaultFile = "forge.secrets"
+
+	// PassphraseEnv holds the passphrase used to unlock the secrets file
+	PassphraseEnv = "FORGE_SECRETS_PASSPHRASE"
+	// KeyFileEnv points at a key file used to unlock the secrets file
+	KeyFileEnv = "FORGE_SECRETS_KEY_FILE"
+
+	fileVersion = 1
+	saltSize    = 16
+
+	// scrypt parameters recommended for interactive use
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrNoKey is returned when neither a passphrase nor a key file is configured
+var ErrNoKey = fmt.Errorf("secrets are locked: set %s or %s", PassphraseEnv, KeyFileEnv)
+
+// ErrWrongKey is returned when the secrets file cannot be decrypted
+var ErrWrongKey = errors.New("failed to decrypt secrets: wrong passphrase or key file")
+
+// secretsFile is the on-disk layout of the encrypted secrets file
+type secretsFile struct {
+	Version int    `yaml:"version"`
+	Cipher  string `yaml:"cipher"`
+	KDF     string `yaml:"kdf"`
+	Salt    string `yaml:"salt"`
+	Data    string `yaml:"data"`
+}
+
+// Store holds decrypted secrets and writes them back encrypted
+type Store struct {
+	path   string
+	key    []byte
+	values map[string]string
+}
+
+// Exists reports whether a secrets file exists at path
+func Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Create creates a new, empty secrets file at path locked with key
+func Create(path string, key []byte) (*Store, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("passphrase or key file is required")
+	}
+	if Exists(path) {
+		return nil, fmt.Errorf("secrets file already exists: %s", path)
+	}
+
+	store := &Store{
+		path:   path,
+		key:    key,
+		values: make(map[string]string),
+	}
+	if err := store.Save(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Open decrypts the secrets file at path with key
+func Open(path string, key []byte) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %v", err)
+	}
+
+	var file secretsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %v", err)
+	}
+	if file.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported secrets file version: %d", file.Version)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: invalid salt")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(file.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: invalid data")
+	}
+
+	aead, err := newCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("failed to parse secrets file: data too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+
+	values := make(map[string]string)
+	if err := yaml.Unmarshal(plaintext, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted secrets: %v", err)
+	}
+
+	return &Store{path: path, key: key, values: values}, nil
+}
+
+// Get returns the value of a secret
+func (s *Store) Get(name string) (string, bool) {
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// Set adds or replaces a secret
+func (s *Store) Set(name, value string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid secret name: %q (use letters, digits, '_', '-' and '.')", name)
+	}
+	s.values[name] = value
+	return nil
+}
+
+// Delete removes a secret and reports whether it existed
+func (s *Store) Delete(name string) bool {
+	if _, ok := s.values[name]; !ok {
+		return false
+	}
+	delete(s.values, name)
+	return true
+}
+
+// Names returns the names of all secrets, sorted
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.values))
+	for name := range s.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Path returns the location of the secrets file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Save encrypts the secrets with a fresh salt and nonce and writes them to disk
+func (s *Store) Save() error {
+	plaintext, err := yaml.Marshal(s.values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %v", err)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	aead, err := newCipher(s.key, salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+
+	file := secretsFile{
+		Version: fileVersion,
+		Cipher:  "xchacha20poly1305",
+		KDF:     "scrypt",
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Data:    base64.StdEncoding.EncodeToString(sealed),
+	}
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets file: %v", err)
+	}
+	data = append([]byte("# Forge OS encrypted secrets. Edit with \"forge secrets\".\n"), data...)
+
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets file: %v", err)
+	}
+	return nil
+}
+
+// ValidName reports whether name can be used as a secret name
+func ValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ReadKeyFile reads key material from a key file
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key file is empty: %s", path)
+	}
+	return key, nil
+}
+
+// GenerateKeyFile writes a new random key file readable only by its owner
+func GenerateKeyFile(path string) ([]byte, error) {
+	if Exists(path) {
+		return nil, fmt.Errorf("key file already exists: %s", path)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	key := []byte(hex.EncodeToString(raw))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(key, '\n'), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key file: %v", err)
+	}
+	return key, nil
+}
+
+// KeyFromEnv returns the key configured through FORGE_SECRETS_KEY_FILE or
+// FORGE_SECRETS_PASSPHRASE, in that order
+func KeyFromEnv() ([]byte, error) {
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		return ReadKeyFile(path)
+	}
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	return nil, ErrNoKey
+}
+
+// newCipher derives the file key from key material and salt
+func newCipher(key, salt []byte) (cipher.AEAD, error) {
+	derived, err := scrypt.Key(key, salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %v", err)
+	}
+	aead, err := chacha20poly1305.NewX(derived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return aead, nil
+}
@@ -0,0 +1,127 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SecretsTestSuite struct {
+	suite.Suite
+	tempDir string
+	path    string
+}
+
+func TestSecretsTestSuite(t *testing.T) {
+	suite.Run(t, new(SecretsTestSuite))
+}
+
+func (s *SecretsTestSuite) SetupTest() {
+	var err error
+	s.tempDir, err = os.MkdirTemp("", "forge-secrets-test-*")
+	s.Require().NoError(err)
+	s.path = filepath.Join(s.tempDir, DefaultFile)
+}
+
+func (s *SecretsTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+func (s *SecretsTestSuite) TestCreateSetAndReopen() {
+	store, err := Create(s.path, []byte("correct horse"))
+	s.Require().NoError(err)
+	s.Empty(store.Names())
+
+	s.NoError(store.Set("wifi_password", "changeme123"))
+	s.NoError(store.Set("dashboard.password", "admin123"))
+	s.NoError(store.Save())
+
+	// The value never appears in the file
+	data, err := os.ReadFile(s.path)
+	s.NoError(err)
+	s.NotContains(string(data), "changeme123")
+	s.Contains(string(data), "cipher: xchacha20poly1305")
+
+	info, err := os.Stat(s.path)
+	s.NoError(err)
+	s.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	reopened, err := Open(s.path, []byte("correct horse"))
+	s.Require().NoError(err)
+	s.Equal([]string{"dashboard.password", "wifi_password"}, reopened.Names())
+	value, ok := reopened.Get("wifi_password")
+	s.True(ok)
+	s.Equal("changeme123", value)
+}
+
+func (s *SecretsTestSuite) TestOpenWrongKey() {
+	_, err := Create(s.path, []byte("correct horse"))
+	s.Require().NoError(err)
+
+	_, err = Open(s.path, []byte("battery staple"))
+	s.ErrorIs(err, ErrWrongKey)
+}
+
+func (s *SecretsTestSuite) TestCreateExisting() {
+	_, err := Create(s.path, []byte("key"))
+	s.Require().NoError(err)
+
+	_, err = Create(s.path, []byte("key"))
+	s.Error(err)
+	s.Contains(err.Error(), "already exists")
+
+	_, err = Create(filepath.Join(s.tempDir, "other"), nil)
+	s.Error(err)
+}
+
+func (s *SecretsTestSuite) TestSetAndDelete() {
+	store, err := Create(s.path, []byte("key"))
+	s.Require().NoError(err)
+
+	s.Error(store.Set("has space", "value"))
+	s.Error(store.Set("", "value"))
+
+	s.NoError(store.Set("token", "abc"))
+	s.True(store.Delete("token"))
+	s.False(store.Delete("token"))
+}
+
+func (s *SecretsTestSuite) TestKeyFiles() {
+	keyPath := filepath.Join(s.tempDir, "keys", "forge.key")
+	key, err := GenerateKeyFile(keyPath)
+	s.Require().NoError(err)
+	s.Len(key, 64)
+
+	read, err := ReadKeyFile(keyPath)
+	s.NoError(err)
+	s.Equal(key, read)
+
+	_, err = GenerateKeyFile(keyPath)
+	s.Error(err)
+
+	empty := filepath.Join(s.tempDir, "empty.key")
+	s.NoError(os.WriteFile(empty, []byte("\n"), 0600))
+	_, err = ReadKeyFile(empty)
+	s.Error(err)
+}
+
+func (s *SecretsTestSuite) TestKeyFromEnv() {
+	s.T().Setenv(KeyFileEnv, "")
+	s.T().Setenv(PassphraseEnv, "")
+	_, err := KeyFromEnv()
+	s.ErrorIs(err, ErrNoKey)
+
+	s.T().Setenv(PassphraseEnv, "from-env")
+	key, err := KeyFromEnv()
+	s.NoError(err)
+	s.Equal([]byte("from-env"), key)
+
+	keyPath := filepath.Join(s.tempDir, "forge.key")
+	s.NoError(os.WriteFile(keyPath, []byte("from-file\n"), 0600))
+	s.T().Setenv(KeyFileEnv, keyPath)
+	key, err = KeyFromEnv()
+	s.NoError(err)
+	s.Equal([]byte("from-file"), key)
+}
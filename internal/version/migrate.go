@@ -3,7 +3,9 @@ package version
 import (
 	"fmt"
 	"regexp"
-	"strings"
+
+	"github.com/sst/forge/internal/config"
+	"gopkg.in/yaml.v3"
 )
 
 // MigrationStep represents a single migration step
@@ -72,49 +74,68 @@ func (s *SchemaVersion) Compare(other *SchemaVersion) int {
 	return 0
 }
 
-// MigrateV1ToV2 migrates a forge.yml config from version 1.0 to 2.0
-func MigrateV1ToV2(config string) (string, error) {
-	lines := strings.Split(config, "\n")
-	var result []string
-	schemaUpdated := false
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Update schema version
-		if strings.Contains(trimmed, "schema_version") {
-			result = append(result, strings.Replace(line, "\"1.0\"", "\"2.0\"", 1))
-			schemaUpdated = true
-			continue
-		}
+// MigrateV1ToV2 migrates a forge.yml config from version 1.0 to 2.0.
+// The migration edits the text of the file, so comments, spacing and key
+// order survive while legacy top-level keys move into their buildroot and
+// kernel sections.
+func MigrateV1ToV2(content string) (string, error) {
+	doc, err := config.ParseDocument([]byte(content))
+	if err != nil {
+		return "", err
+	}
 
-		result = append(result, line)
+	if _, err := migrateV1ToV2(doc); err != nil {
+		return "", err
 	}
 
-	// Add missing fields if not present
-	if !schemaUpdated {
-		return "", fmt.Errorf("schema_version not found in config")
+	return string(doc.Bytes()), nil
+}
+
+// migrateV1ToV2 applies the 1.0 to 2.0 schema changes to a document and
+// describes each change it made
+func migrateV1ToV2(doc *config.Document) ([]string, error) {
+	schemaNode := doc.Lookup("schema_version")
+	if schemaNode == nil {
+		return nil, fmt.Errorf("schema_version not found in config")
 	}
 
-	// Add missing fields at the end
-	var newLines []string
+	var changes []string
 
-	// Add buildroot_version if missing
-	if !strings.Contains(config, "buildroot_version") {
-		newLines = append(newLines, "buildroot_version: stable")
+	changes = append(changes, fmt.Sprintf("update schema_version from %s to 2.0", schemaNode.Value))
+	version := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "2.0", Style: yaml.DoubleQuotedStyle}
+	if err := doc.Set("schema_version", version); err != nil {
+		return nil, err
 	}
 
-	// Add kernel_version if missing
-	if !strings.Contains(config, "kernel_version") {
-		newLines = append(newLines, "kernel_version: lts")
+	// Move legacy top-level keys into their sections
+	moved, err := doc.MoveLegacyKeys()
+	if err != nil {
+		return nil, err
 	}
+	changes = append(changes, moved...)
 
-	// Append new lines at the end
-	if len(newLines) > 0 {
-		result = append(result, newLines...)
+	// Fill in versions that 2.0 expects to be explicit. A kernel section
+	// turns on a kernel build, so the kernel version is only filled in for
+	// projects that already configure the kernel.
+	if doc.Lookup("buildroot.version") == nil {
+		if err := doc.Set("buildroot.version", scalar("stable")); err != nil {
+			return nil, err
+		}
+		changes = append(changes, "add buildroot.version: stable")
+	}
+	if doc.Lookup("kernel") != nil && doc.Lookup("kernel.version") == nil {
+		if err := doc.Set("kernel.version", scalar("lts")); err != nil {
+			return nil, err
+		}
+		changes = append(changes, "add kernel.version: lts")
 	}
 
-	return strings.Join(result, "\n"), nil
+	return changes, nil
+}
+
+// scalar builds a plain string node
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
 }
 
 // IsBackwardCompatible checks if a config is backward compatible
@@ -137,10 +158,10 @@ func CanRollback(steps []MigrationStep) bool {
 }
 
 // DryRunMigration performs a dry run of migration and returns the changes that would be made
-func DryRunMigration(config string) ([]string, error) {
+func DryRunMigration(content string) ([]string, error) {
 	var changes []string
 
-	fromVersion, err := DetectSchemaVersion(config)
+	fromVersion, err := DetectSchemaVersion(content)
 	if err != nil {
 		return nil, err
 	}
@@ -151,15 +172,18 @@ func DryRunMigration(config string) ([]string, error) {
 		return []string{"No migration needed"}, nil
 	}
 
-	changes = append(changes, fmt.Sprintf("Would update schema_version from %s to %s", fromVersion.String(), toVersion.String()))
+	doc, err := config.ParseDocument([]byte(content))
+	if err != nil {
+		return nil, err
+	}
 
-	// Check what fields would be added
-	if !strings.Contains(config, "buildroot_version") {
-		changes = append(changes, "Would add buildroot_version: stable")
+	migrated, err := migrateV1ToV2(doc)
+	if err != nil {
+		return nil, err
 	}
 
-	if !strings.Contains(config, "kernel_version") {
-		changes = append(changes, "Would add kernel_version: lts")
+	for _, change := range migrated {
+		changes = append(changes, "Would "+change)
 	}
 
 	return changes, nil
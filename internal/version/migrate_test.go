@@ -132,8 +132,10 @@ architecture: x86_64`,
 			expected: `schema_version: "2.0"
 name: test-project
 architecture: x86_64
-buildroot_version: stable
-kernel_version: lts`,
+
+buildroot:
+  version: stable
+`,
 			hasError: false,
 		},
 		{
@@ -145,8 +147,8 @@ buildroot_version: 2023.02`,
 			expected: `schema_version: "2.0"
 name: test-project
 architecture: arm
-buildroot_version: 2023.02
-kernel_version: lts`,
+buildroot:
+  version: 2023.02`,
 			hasError: false,
 		},
 		{
@@ -159,10 +161,69 @@ kernel_version: latest`,
 			expected: `schema_version: "2.0"
 name: test-project
 architecture: aarch64
+buildroot:
+  version: stable
+kernel:
+  version: latest`,
+			hasError: false,
+		},
+		{
+			name: "migrate v1 to v2 preserves comments and order",
+			input: `schema_version: "1.0"
+name: test-project
+
+# Buildroot configuration
 buildroot_version: stable
-kernel_version: latest`,
+kernel_version: "6.1" # long term support
+
+# Real-time kernel
+kernel_config:
+  preempt_rt: true
+  debug_info: false
+
+# Packages
+packages:
+  - busybox`,
+			expected: `schema_version: "2.0"
+name: test-project
+
+# Buildroot configuration
+buildroot:
+  version: stable
+kernel:
+  version: "6.1" # long term support
+  # Real-time kernel
+  config:
+    PREEMPT_RT: y
+    DEBUG_INFO: n
+
+# Packages
+packages:
+  - busybox`,
+			hasError: false,
+		},
+		{
+			name: "nested values win over legacy keys",
+			input: `schema_version: "1.0"
+name: test-project
+kernel:
+  version: "6.6"
+kernel_version: "6.1"`,
+			expected: `schema_version: "2.0"
+name: test-project
+kernel:
+  version: "6.6"
+
+buildroot:
+  version: stable
+`,
 			hasError: false,
 		},
+		{
+			name:     "missing schema version",
+			input:    `name: test-project`,
+			hasError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -254,11 +315,46 @@ name: test-project
 architecture: x86_64`,
 			expected: []string{
 				"Would update schema_version from 1.0 to 2.0",
-				"Would add buildroot_version: stable",
-				"Would add kernel_version: lts",
+				"Would add buildroot.version: stable",
+			},
+			hasError: false,
+		},
+		{
+			name: "dry run moves legacy keys",
+			config: `schema_version: "1.0"
+name: test-project
+buildroot_version: stable
+kernel_config:
+  realtime: true`,
+			expected: []string{
+				"Would update schema_version from 1.0 to 2.0",
+				"Would move buildroot_version to buildroot.version",
+				"Would move kernel_config to kernel.config",
+				"Would add kernel.version: lts",
 			},
 			hasError: false,
 		},
+		{
+			name: "dry run drops legacy keys the nested form overrides",
+			config: `schema_version: "1.0"
+name: test-project
+kernel:
+  version: "6.6"
+kernel_version: "6.1"`,
+			expected: []string{
+				"Would update schema_version from 1.0 to 2.0",
+				"Would drop kernel_version (kernel.version is already set)",
+				"Would add buildroot.version: stable",
+			},
+			hasError: false,
+		},
+		{
+			name: "dry run on current schema",
+			config: `schema_version: "2.0"
+name: test-project`,
+			expected: []string{"No migration needed"},
+			hasError: false,
+		},
 	}
 
 	for _, tt := range tests {